@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sync"
+
+	spotifyauth "github.com/zmb3/spotify/v2/auth"
+	"golang.org/x/oauth2"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/config"
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/eras"
+	"github.com/justestif/go-spotify-era-organizer/internal/lastfm"
+	spotifyclient "github.com/justestif/go-spotify-era-organizer/internal/spotify"
+	syncpkg "github.com/justestif/go-spotify-era-organizer/internal/sync"
+	"github.com/justestif/go-spotify-era-organizer/internal/tags"
+	"github.com/justestif/go-spotify-era-organizer/internal/web"
+)
+
+// errNotLoggedIn is returned when a command needs Spotify but no refresh
+// token is available.
+var errNotLoggedIn = errors.New(`not logged in to Spotify: run "spotify-era-organizer login" or set SPOTIFY_REFRESH_TOKEN`)
+
+// commonFlags are the flags shared by headless subcommands.
+type commonFlags struct {
+	json   bool
+	userID string
+}
+
+// register adds the common flags to a flag set.
+func (f *commonFlags) register(fset *flag.FlagSet) {
+	fset.BoolVar(&f.json, "json", false, "print JSON instead of a table")
+	f.registerUser(fset)
+}
+
+// registerUser adds only the -user flag, for commands without -json.
+func (f *commonFlags) registerUser(fset *flag.FlagSet) {
+	fset.StringVar(&f.userID, "user", "",
+		"Spotify user ID (default $SPOTIFY_USER_ID or cli.user_id, or the logged-in user)")
+}
+
+// loadConfig loads the configuration from CONFIG_FILE and the environment
+// once per process.
+var loadConfig = sync.OnceValues(config.FromEnv)
+
+// app holds the dependencies for headless subcommands.
+type app struct {
+	cfg    *config.Config
+	db     *db.DB
+	syncs  *syncpkg.Service
+	eras   *eras.Service
+	userID string
+
+	spotify spotifyclient.LibraryClient // nil until spotifyClient is called
+}
+
+// newApp connects to the database and resolves the user. The user comes from
+// -user, the credential file saved by login, or the Spotify account that owns
+// the refresh token.
+func newApp(ctx context.Context, flags commonFlags) (*app, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	database, err := openDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &app{
+		cfg:    cfg,
+		db:     database,
+		syncs:  syncpkg.New(database, syncpkg.WithSyncCooldown(cfg.Sync.Cooldown)),
+		eras:   eras.New(database, eras.WithDefaults(cfg.Clustering.TagClusterConfig())),
+		userID: flags.userID,
+	}
+
+	if a.userID == "" {
+		a.userID = cfg.CLI.UserID
+	}
+	if a.userID == "" {
+		a.userID, err = a.defaultUserID(ctx)
+		if err != nil {
+			a.Close()
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// openDB connects to the configured database and brings its schema up to
+// date, encrypting tokens with the configured keys.
+func openDB(ctx context.Context) (*db.DB, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	database, err := connectDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := prepareSchema(ctx, database, cfg); err != nil {
+		database.Close()
+		return nil, err
+	}
+	return database, nil
+}
+
+// connectDB connects to the configured database without checking its
+// schema.
+func connectDB(ctx context.Context) (*db.DB, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Database.URL == "" {
+		return nil, errors.New("please set DATABASE_URL environment variable or database.url in the config file")
+	}
+	keys, err := cfg.Database.Keyring()
+	if err != nil {
+		return nil, err
+	}
+	database, err := db.New(ctx, cfg.Database.URL)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	database.SetKeyring(keys)
+	return database, nil
+}
+
+// defaultUserID returns the logged-in user from the credential file, or asks
+// Spotify who owns SPOTIFY_REFRESH_TOKEN.
+func (a *app) defaultUserID(ctx context.Context) (string, error) {
+	if a.cfg.CLI.RefreshToken == "" {
+		creds, err := a.credentials()
+		if err != nil {
+			return "", err
+		}
+		if creds != nil {
+			return creds.UserID, nil
+		}
+	}
+
+	client, err := a.spotifyClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	return client.UserID(ctx)
+}
+
+// Close releases the database connection.
+func (a *app) Close() {
+	a.db.Close()
+}
+
+// credentials loads the credential file, or returns nil if there is none.
+func (a *app) credentials() (*credentials, error) {
+	path, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	return loadCredentials(path)
+}
+
+// token returns the stored Spotify token, checked in order:
+// SPOTIFY_REFRESH_TOKEN, the credential file, then the users table. The
+// returned SaveFunc writes a refreshed token back to where it came from; it
+// is nil for SPOTIFY_REFRESH_TOKEN, which can't be updated.
+func (a *app) token(ctx context.Context) (*oauth2.Token, spotifyclient.SaveFunc, error) {
+	if refreshToken := a.cfg.CLI.RefreshToken; refreshToken != "" {
+		// An empty access token forces a refresh on the first request
+		return &oauth2.Token{RefreshToken: refreshToken}, nil, nil
+	}
+
+	creds, err := a.credentials()
+	if err != nil {
+		return nil, nil, err
+	}
+	if creds != nil && (a.userID == "" || creds.UserID == a.userID) {
+		save := func(_ context.Context, token *oauth2.Token) error {
+			path, err := credentialsPath()
+			if err != nil {
+				return err
+			}
+			return saveCredentials(path, &credentials{UserID: creds.UserID, Token: token})
+		}
+		return creds.Token, save, nil
+	}
+
+	if a.userID == "" {
+		return nil, nil, errNotLoggedIn
+	}
+	stored, err := a.db.Users().GetToken(ctx, a.userID)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil, errNotLoggedIn
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	userID := a.userID
+	save := func(ctx context.Context, token *oauth2.Token) error {
+		return a.db.Users().SaveToken(ctx, userID, token.AccessToken, token.RefreshToken, token.Expiry)
+	}
+	return &oauth2.Token{
+		AccessToken:  stored.AccessToken,
+		RefreshToken: stored.RefreshToken,
+		Expiry:       stored.TokenExpiry,
+		TokenType:    "Bearer",
+	}, save, nil
+}
+
+// spotifyClient returns a Spotify client authenticated with the stored
+// token. Expired access tokens are refreshed and saved back to the
+// credential file or users table, so the next command reuses them.
+func (a *app) spotifyClient(ctx context.Context) (spotifyclient.LibraryClient, error) {
+	if a.spotify != nil {
+		return a.spotify, nil
+	}
+
+	cfg, err := oauthConfig(a.cfg.Server.RedirectURI())
+	if err != nil {
+		return nil, err
+	}
+	token, save, err := a.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	source := spotifyclient.NewTokenSource(ctx, token, spotifyclient.ConfigRefresher(cfg), save)
+	a.spotify = spotifyclient.NewClientFromTokenSource(ctx, source)
+	return a.spotify, nil
+}
+
+// oauthConfig returns the Spotify OAuth configuration for the CLI.
+// The client secret is optional because login uses PKCE.
+func oauthConfig(redirectURL string) (*oauth2.Config, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Spotify.ClientID == "" {
+		return nil, errors.New("please set SPOTIFY_ID environment variable or spotify.client_id in the config file")
+	}
+	return &oauth2.Config{
+		ClientID:     cfg.Spotify.ClientID,
+		ClientSecret: cfg.Spotify.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       web.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  spotifyauth.AuthURL,
+			TokenURL: spotifyauth.TokenURL,
+		},
+	}, nil
+}
+
+// newEnricher returns a Last.fm tag enricher, or nil if no Last.fm API key
+// is configured.
+func newEnricher(database *db.DB, cfg *config.Config) *tags.Enricher {
+	if cfg.LastFM.APIKey == "" {
+		return nil
+	}
+	client := lastfm.NewClient(&lastfm.Config{APIKey: cfg.LastFM.APIKey})
+	service := tags.NewService(client, tags.WithConcurrency(cfg.Tags.Concurrency))
+	return tags.NewEnricher(database, service, tags.WithCacheTTL(cfg.Tags.CacheTTL))
+}
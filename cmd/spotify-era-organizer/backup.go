@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/backup"
+)
+
+// runBackup writes a full-library backup archive.
+func runBackup(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fset := flag.NewFlagSet("backup", flag.ContinueOnError)
+	flags.registerUser(fset)
+	output := fset.String("o", "", "write to file instead of stdout")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	a, err := newApp(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	archive, err := backup.New(backup.NewDBStore(a.db)).Backup(ctx, a.userID)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	return backup.Write(w, archive)
+}
+
+// runRestore restores a backup archive into the database.
+func runRestore(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("restore", flag.ContinueOnError)
+	jsonOut := fset.Bool("json", false, "print JSON instead of text")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 1 {
+		return errors.New("usage: spotify-era-organizer restore [flags] <backup.json>")
+	}
+
+	f, err := os.Open(fset.Arg(0))
+	if err != nil {
+		return fmt.Errorf("opening backup: %w", err)
+	}
+	defer f.Close()
+
+	archive, err := backup.Read(f)
+	if err != nil {
+		return err
+	}
+
+	database, err := openDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	result, err := backup.New(backup.NewDBStore(database)).Restore(ctx, archive)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOut {
+		return writeJSON(os.Stdout, result)
+	}
+	fmt.Printf("Restored %s: %d tracks, %d likes, %d tags, %d user tags, %d plays, %d eras\n",
+		result.UserID, result.Tracks, result.Likes, result.Tags, result.UserTags, result.Plays, result.Eras)
+	return nil
+}
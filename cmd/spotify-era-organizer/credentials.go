@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/oauth2"
+)
+
+// credentials is the local credential file written by the login command.
+type credentials struct {
+	UserID string        `json:"user_id"`
+	Token  *oauth2.Token `json:"token"`
+}
+
+// credentialsPath returns the credential file location: cli.credentials_file
+// or $SPOTIFY_CREDENTIALS, else credentials.json in the user's config
+// directory.
+func credentialsPath() (string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	if cfg.CLI.CredentialsFile != "" {
+		return cfg.CLI.CredentialsFile, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("locating config directory: %w", err)
+	}
+	return filepath.Join(dir, "spotify-era-organizer", "credentials.json"), nil
+}
+
+// loadCredentials reads the credential file.
+// Returns nil without error if the file doesn't exist.
+func loadCredentials(path string) (*credentials, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading credentials: %w", err)
+	}
+
+	var creds credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("parsing credentials %s: %w", path, err)
+	}
+	if creds.Token == nil || creds.Token.RefreshToken == "" {
+		return nil, fmt.Errorf("credentials %s have no refresh token", path)
+	}
+	return &creds, nil
+}
+
+// saveCredentials writes the credential file, readable only by the owner.
+func saveCredentials(path string, creds *credentials) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("creating credentials directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding credentials: %w", err)
+	}
+
+	// Write to a temp file and rename so a crash never leaves a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("writing credentials: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing credentials: %w", err)
+	}
+	return nil
+}
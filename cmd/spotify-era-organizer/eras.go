@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/eras"
+	"github.com/justestif/go-spotify-era-organizer/internal/exporter"
+)
+
+// runEras dispatches the eras subcommands.
+func runEras(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: spotify-era-organizer eras <list|show|rename> [flags]")
+	}
+	switch args[0] {
+	case "list":
+		return runErasList(ctx, args[1:])
+	case "show":
+		return runErasShow(ctx, args[1:])
+	case "rename":
+		return runErasRename(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown eras command %q (want list, show or rename)", args[0])
+	}
+}
+
+// runErasList lists the user's eras.
+func runErasList(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fset := flag.NewFlagSet("eras list", flag.ContinueOnError)
+	flags.register(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	a, err := newApp(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	out, err := loadEras(ctx, a, nil, false)
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		return writeJSON(os.Stdout, out)
+	}
+	if len(out) == 0 {
+		fmt.Println(`No eras yet. Run "spotify-era-organizer analyze" to detect them.`)
+		return nil
+	}
+	return printEraTable(out)
+}
+
+// runErasShow shows a single era and its tracks.
+func runErasShow(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fset := flag.NewFlagSet("eras show", flag.ContinueOnError)
+	flags.register(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 1 {
+		return errors.New("usage: spotify-era-organizer eras show [flags] <era-id>")
+	}
+
+	a, err := newApp(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	out, err := loadEras(ctx, a, fset.Args(), true)
+	if err != nil {
+		return err
+	}
+	era := out[0]
+
+	if flags.json {
+		return writeJSON(os.Stdout, era)
+	}
+
+	fmt.Printf("%s\n", era.Name)
+	fmt.Printf("  ID:       %s\n", era.ID)
+	fmt.Printf("  Dates:    %s to %s\n", era.StartDate, era.EndDate)
+	fmt.Printf("  Tags:     %s\n", strings.Join(era.TopTags, ", "))
+	if era.PlaylistID != "" {
+		fmt.Printf("  Playlist: https://open.spotify.com/playlist/%s\n", era.PlaylistID)
+	}
+	fmt.Printf("  Tracks:   %d\n\n", era.TrackCount)
+
+	t := newTable(os.Stdout, "TRACK", "ARTIST", "ALBUM")
+	for _, track := range era.Tracks {
+		t.row(track.Name, track.Artist, track.Album)
+	}
+	return t.flush()
+}
+
+// runErasRename names an era. The name is kept when re-analysis matches the
+// era with a new one; an empty name restores the generated name.
+func runErasRename(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fset := flag.NewFlagSet("eras rename", flag.ContinueOnError)
+	flags.register(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 2 {
+		return errors.New(`usage: spotify-era-organizer eras rename [flags] <era-id> <name> (use "" to restore the generated name)`)
+	}
+
+	a, err := newApp(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	eraID := fset.Arg(0)
+	era, err := a.eras.RenameEra(ctx, a.userID, eraID, fset.Arg(1))
+	if errors.Is(err, db.ErrNotFound) {
+		return fmt.Errorf("era %s not found", eraID)
+	}
+	if err != nil {
+		return err
+	}
+
+	tracks, err := a.eras.GetEraTracks(ctx, a.userID, era.ID.String())
+	if err != nil {
+		return err
+	}
+	if flags.json {
+		return writeJSON(os.Stdout, toEraJSON(*era, tracks, false))
+	}
+	fmt.Printf("Renamed era %s to %q\n", era.ID, era.DisplayName())
+	return nil
+}
+
+// runExport writes eras and their tracks as M3U8, XSPF, CSV or JSON.
+func runExport(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fset := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.registerUser(fset)
+	output := fset.String("o", "", "write to file instead of stdout")
+	formatName := fset.String("format", "", "m3u8, xspf, csv or json (default: from -o extension, else json)")
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), "Usage: spotify-era-organizer export [flags] [era-id...]")
+		fmt.Fprintln(fset.Output(), "Exports all eras when no IDs are given.")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	name := *formatName
+	if name == "" {
+		name = strings.TrimPrefix(filepath.Ext(*output), ".")
+	}
+	format := exporter.FormatJSON
+	if name != "" {
+		var err error
+		format, err = exporter.ParseFormat(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	a, err := newApp(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	list, err := a.eras.Exporter().Load(ctx, a.userID, fset.Args()...)
+	if errors.Is(err, db.ErrNotFound) {
+		return errors.New("era not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	return exporter.Write(w, format, list)
+}
+
+// loadEras loads the user's eras, or only the given era IDs in order.
+func loadEras(ctx context.Context, a *app, ids []string, withTracks bool) ([]eraJSON, error) {
+	var list []db.Era
+	depths := make(map[uuid.UUID]int)
+	if len(ids) == 0 {
+		all, err := a.eras.GetUserEras(ctx, a.userID)
+		if err != nil {
+			return nil, err
+		}
+		// Sub-eras follow their parents
+		_ = walkEras(eras.BuildTree(all), 0, func(era db.Era, depth int) error {
+			list = append(list, era)
+			depths[era.ID] = depth
+			return nil
+		})
+	} else {
+		for _, id := range ids {
+			era, err := a.eras.GetEra(ctx, a.userID, id)
+			if errors.Is(err, db.ErrNotFound) {
+				return nil, fmt.Errorf("era %s not found", id)
+			}
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, *era)
+		}
+	}
+
+	out := make([]eraJSON, 0, len(list))
+	for _, era := range list {
+		tracks, err := a.eras.GetEraTracks(ctx, a.userID, era.ID.String())
+		if err != nil {
+			return nil, err
+		}
+		e := toEraJSON(era, tracks, withTracks)
+		e.depth = depths[era.ID]
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// runPlaylist dispatches the playlist subcommands.
+func runPlaylist(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "publish" {
+		return errors.New("usage: spotify-era-organizer playlist publish [flags] <era-id>")
+	}
+	return runPlaylistPublish(ctx, args[1:])
+}
+
+// publishOutput is the output of the playlist publish command.
+type publishOutput struct {
+	EraID      string `json:"era_id"`
+	PlaylistID string `json:"playlist_id"`
+	URL        string `json:"url"`
+	Created    bool   `json:"created"` // False if the era was already published
+}
+
+// runPlaylistPublish creates a Spotify playlist from an era.
+func runPlaylistPublish(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fset := flag.NewFlagSet("playlist publish", flag.ContinueOnError)
+	flags.register(fset)
+	public := fset.Bool("public", false, "make the playlist public")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 1 {
+		return errors.New("usage: spotify-era-organizer playlist publish [flags] <era-id>")
+	}
+
+	a, err := newApp(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	client, err := a.spotifyClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	eraID := fset.Arg(0)
+	era, err := a.eras.PublishPlaylist(ctx, client, a.userID, eraID, *public)
+	created := true
+	switch {
+	case errors.Is(err, eras.ErrAlreadyPublished):
+		created = false
+	case errors.Is(err, db.ErrNotFound):
+		return fmt.Errorf("era %s not found", eraID)
+	case err != nil:
+		return err
+	}
+
+	out := publishOutput{
+		EraID:      era.ID.String(),
+		PlaylistID: *era.PlaylistID,
+		URL:        "https://open.spotify.com/playlist/" + *era.PlaylistID,
+		Created:    created,
+	}
+	if flags.json {
+		return writeJSON(os.Stdout, out)
+	}
+	if !created {
+		fmt.Printf("%q is already published: %s\n", era.DisplayName(), out.URL)
+		return nil
+	}
+	fmt.Printf("Published %q: %s\n", era.DisplayName(), out.URL)
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/housekeeping"
+)
+
+// runHousekeeping dispatches the housekeeping subcommands.
+func runHousekeeping(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: spotify-era-organizer housekeeping <run|history> [flags]")
+	}
+	switch args[0] {
+	case "run":
+		return runHousekeepingRun(ctx, args[1:])
+	case "history":
+		return runHousekeepingHistory(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown housekeeping command %q (want run or history)", args[0])
+	}
+}
+
+// runHousekeepingRun runs the database cleanup tasks once and records them.
+// The server's in-memory state is cleaned up by the server itself.
+func runHousekeepingRun(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("housekeeping run", flag.ContinueOnError)
+	jsonOut := fset.Bool("json", false, "print JSON instead of text")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	database, err := openDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	results := housekeeping.New(database, housekeeping.DBTasks(database)...).RunOnce(ctx)
+	if *jsonOut {
+		return writeJSON(os.Stdout, results)
+	}
+
+	tbl := newTable(os.Stdout, "TASK", "REMOVED", "DURATION", "ERROR")
+	failed := 0
+	for _, r := range results {
+		tbl.row(r.Task, strconv.FormatInt(r.Removed, 10), r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond).String(), r.Error)
+		if r.Error != "" {
+			failed++
+		}
+	}
+	if err := tbl.flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d housekeeping tasks failed", failed)
+	}
+	return nil
+}
+
+// runHousekeepingHistory lists recorded housekeeping task runs.
+func runHousekeepingHistory(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("housekeeping history", flag.ContinueOnError)
+	jsonOut := fset.Bool("json", false, "print JSON instead of text")
+	limit := fset.Int("n", 20, "number of task runs to show")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if *limit < 1 {
+		return fmt.Errorf("invalid -n %d: must be at least 1", *limit)
+	}
+
+	database, err := openDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	runs, err := database.Housekeeping().ListRecent(ctx, *limit)
+	if err != nil {
+		return err
+	}
+
+	results := make([]housekeeping.Result, len(runs))
+	for i, run := range runs {
+		results[i] = housekeeping.Result{
+			Task:       run.Task,
+			Removed:    run.Removed,
+			StartedAt:  run.StartedAt,
+			FinishedAt: run.FinishedAt,
+		}
+		if run.Error != nil {
+			results[i].Error = *run.Error
+		}
+	}
+	if *jsonOut {
+		return writeJSON(os.Stdout, results)
+	}
+	if len(results) == 0 {
+		fmt.Println("No housekeeping runs recorded yet.")
+		return nil
+	}
+
+	tbl := newTable(os.Stdout, "STARTED", "TASK", "REMOVED", "ERROR")
+	for _, r := range results {
+		tbl.row(formatTime(&r.StartedAt), r.Task, strconv.FormatInt(r.Removed, 10), r.Error)
+	}
+	return tbl.flush()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/importer"
+	"github.com/justestif/go-spotify-era-organizer/internal/lastfm"
+)
+
+// runImport dispatches the import subcommands.
+func runImport(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: spotify-era-organizer import <spotify|lastfm> [flags]")
+	}
+	switch args[0] {
+	case "spotify":
+		return runImportSpotify(ctx, args[1:])
+	case "lastfm":
+		return runImportLastFM(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown import command %q (want spotify or lastfm)", args[0])
+	}
+}
+
+// runImportSpotify imports an unzipped Spotify data export directory.
+func runImportSpotify(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fset := flag.NewFlagSet("import spotify", flag.ContinueOnError)
+	flags.register(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 1 {
+		return errors.New("usage: spotify-era-organizer import spotify [flags] <export-dir>")
+	}
+
+	export, err := importer.LoadDir(os.DirFS(fset.Arg(0)))
+	if err != nil {
+		return err
+	}
+
+	a, err := newApp(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	result, err := importer.New(importer.NewDBStore(a.db)).Import(ctx, a.userID, export)
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		return writeJSON(os.Stdout, result)
+	}
+	fmt.Printf("Imported %d tracks (%d skipped, %d dated by history) and %d of %d plays\n",
+		result.TracksImported, result.TracksSkipped, result.TracksPlayed, result.PlaysImported, result.PlaysRead)
+	return nil
+}
+
+// runImportLastFM imports new scrobbles from a Last.fm account.
+func runImportLastFM(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fset := flag.NewFlagSet("import lastfm", flag.ContinueOnError)
+	flags.register(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 1 {
+		return errors.New("usage: spotify-era-organizer import lastfm [flags] <lastfm-username>")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.LastFM.APIKey == "" {
+		return lastfm.ErrMissingAPIKey
+	}
+
+	a, err := newApp(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	imp := importer.New(importer.NewDBStore(a.db))
+	client := lastfm.NewClient(&lastfm.Config{APIKey: cfg.LastFM.APIKey})
+	result, err := imp.ImportScrobbles(ctx, a.userID, fset.Arg(0), client)
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		return writeJSON(os.Stdout, result)
+	}
+	fmt.Printf("Imported %d new scrobbles since %s (%d pages)\n",
+		result.PlaysImported, formatTime(result.Since), result.Pages)
+	return nil
+}
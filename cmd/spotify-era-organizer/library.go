@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/clustering"
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/eras"
+	"github.com/justestif/go-spotify-era-organizer/internal/lastfm"
+	syncpkg "github.com/justestif/go-spotify-era-organizer/internal/sync"
+)
+
+// syncOutput is the output of the sync command.
+type syncOutput struct {
+	TracksSynced int       `json:"tracks_synced"`
+	SyncedAt     time.Time `json:"synced_at"`
+}
+
+// runSync syncs liked songs from Spotify.
+func runSync(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fset := flag.NewFlagSet("sync", flag.ContinueOnError)
+	flags.register(fset)
+	force := fset.Bool("force", false, "sync even if within the cooldown period")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	a, err := newApp(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	client, err := a.spotifyClient(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := a.syncs.SyncLikedSongs(ctx, client, a.userID, *force)
+	if err != nil {
+		return err
+	}
+
+	out := syncOutput{TracksSynced: result.TracksCount, SyncedAt: result.SyncedAt}
+	if flags.json {
+		return writeJSON(os.Stdout, out)
+	}
+	fmt.Printf("Synced %d tracks at %s\n", out.TracksSynced, formatTime(&out.SyncedAt))
+	return nil
+}
+
+// tagOutput is the output of the tag command.
+type tagOutput struct {
+	TracksMissing int `json:"tracks_missing"`
+	TracksStale   int `json:"tracks_stale"`
+	TracksTagged  int `json:"tracks_tagged"`
+	TracksFailed  int `json:"tracks_failed"`
+	TagsStored    int `json:"tags_stored"`
+}
+
+// runTag fetches Last.fm tags for tracks without any, and refreshes stale
+// tags.
+func runTag(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fset := flag.NewFlagSet("tag", flag.ContinueOnError)
+	flags.register(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	a, err := newApp(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	enricher := newEnricher(a.db, a.cfg)
+	if enricher == nil {
+		return lastfm.ErrMissingAPIKey
+	}
+	result, err := enricher.FetchMissing(ctx, a.userID)
+	if err != nil {
+		return err
+	}
+
+	out := tagOutput(*result)
+	if flags.json {
+		return writeJSON(os.Stdout, out)
+	}
+	fmt.Printf("Tagged %d of %d untagged or stale tracks (%d tags stored, %d lookups failed)\n",
+		out.TracksTagged, out.TracksMissing+out.TracksStale, out.TagsStored, out.TracksFailed)
+	return nil
+}
+
+// analyzeOutput is the output of the analyze command.
+type analyzeOutput struct {
+	Basis        clustering.Basis `json:"basis"`
+	TotalTracks  int              `json:"total_tracks"`
+	OutlierCount int              `json:"outlier_count"`
+	Eras         []eraJSON        `json:"eras"`
+	Changes      []changeJSON     `json:"changes,omitempty"` // Omitted on the first analysis
+}
+
+// runAnalyze runs the full pipeline: sync, tag, then detect eras.
+func runAnalyze(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fset := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	flags.register(fset)
+	noSync := fset.Bool("no-sync", false, "skip syncing from Spotify and use stored tracks")
+	basis := fset.String("basis", "", `date eras by "likes" or "plays" (default: plays if listening history exists)`)
+	clusters := fset.Int("clusters", 0, "number of eras to detect (default from settings)")
+	subEraDepth := fset.Int("sub-eras", -1, fmt.Sprintf("levels of sub-eras to detect, 0 to %d (default from settings, else 0)", eras.MaxSubEraDepth))
+	nameTemplate := fset.String("name-template", "", `era name template, e.g. "{season} {year} – {tag1}" (default "`+clustering.DefaultNameTemplate+`")`)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	a, err := newApp(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	// Step 1: Sync liked songs (a cooldown just means the data is fresh)
+	if !*noSync {
+		client, err := a.spotifyClient(ctx)
+		if err != nil {
+			return err
+		}
+		result, err := a.syncs.SyncLikedSongs(ctx, client, a.userID, false)
+		switch {
+		case errors.Is(err, syncpkg.ErrSyncTooRecent):
+			log.Printf("Sync skipped (recently synced)")
+		case err != nil:
+			return err
+		default:
+			log.Printf("Synced %d tracks", result.TracksCount)
+		}
+	}
+
+	// Step 2: Fetch missing tags
+	if enricher := newEnricher(a.db, a.cfg); enricher != nil {
+		result, err := enricher.FetchMissing(ctx, a.userID)
+		if err != nil {
+			log.Printf("Warning: tag fetching failed: %v", err)
+		} else if result.TracksMissing+result.TracksStale > 0 {
+			log.Printf("Tagged %d of %d untagged or stale tracks", result.TracksTagged, result.TracksMissing+result.TracksStale)
+		}
+	} else {
+		log.Printf("LASTFM_API_KEY not set, skipping tag fetch")
+	}
+
+	// Step 3: Detect and persist eras
+	cfg, err := a.eras.DefaultConfig(ctx, a.userID)
+	if err != nil {
+		return err
+	}
+	if *basis != "" {
+		b, ok := clustering.ParseBasis(*basis)
+		if !ok {
+			return fmt.Errorf("invalid -basis %q: must be likes or plays", *basis)
+		}
+		cfg.Basis = b
+	}
+	if *clusters > 0 {
+		cfg.NumClusters = *clusters
+	}
+	if *subEraDepth >= 0 {
+		if *subEraDepth > eras.MaxSubEraDepth {
+			return fmt.Errorf("invalid -sub-eras %d: must be at most %d", *subEraDepth, eras.MaxSubEraDepth)
+		}
+		cfg.SubEraDepth = *subEraDepth
+	}
+	if *nameTemplate != "" {
+		if err := clustering.ValidateNameTemplate(*nameTemplate); err != nil {
+			return fmt.Errorf("invalid -name-template: %w", err)
+		}
+		cfg.NameTemplate = *nameTemplate
+	}
+
+	result, err := a.eras.DetectAndPersist(ctx, a.userID, cfg)
+	if err != nil {
+		return err
+	}
+
+	out := analyzeOutput{
+		Basis:        cfg.Basis,
+		TotalTracks:  result.TotalTracks,
+		OutlierCount: result.OutlierCount,
+		Eras:         make([]eraJSON, 0, len(result.Eras)+len(result.SubEras)),
+		Changes:      toChangeJSON(result.Changes),
+	}
+	tree := eras.BuildTree(append(result.Eras, result.SubEras...))
+	err = walkEras(tree, 0, func(era db.Era, depth int) error {
+		tracks, err := a.db.Eras().GetTracksForOwner(ctx, a.userID, era.ID)
+		if err != nil {
+			return fmt.Errorf("getting era tracks: %w", err)
+		}
+		e := toEraJSON(era, tracks, false)
+		e.depth = depth
+		out.Eras = append(out.Eras, e)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		return writeJSON(os.Stdout, out)
+	}
+	fmt.Printf("Detected %d eras and %d sub-eras from %d tracks by %s (%d outliers)\n\n",
+		len(result.Eras), len(result.SubEras), out.TotalTracks, out.Basis, out.OutlierCount)
+	if err := printEraTable(out.Eras); err != nil {
+		return err
+	}
+	return printChangeTable(out.Changes)
+}
+
+// printChangeTable prints what changed since the previous analysis,
+// skipping unchanged eras.
+func printChangeTable(changes []changeJSON) error {
+	var rows []changeJSON
+	for _, c := range changes {
+		if c.Status != eras.StatusUnchanged {
+			rows = append(rows, c)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	if len(rows) == 0 {
+		fmt.Println("\nNo eras changed since the last analysis")
+		return nil
+	}
+
+	fmt.Println("\nChanges since the last analysis:")
+	t := newTable(os.Stdout, "STATUS", "NAME", "FROM")
+	for _, c := range rows {
+		names := make([]string, len(c.Previous))
+		for i, p := range c.Previous {
+			names[i] = p.Name
+		}
+		from := strings.Join(names, " + ")
+		if from == "" {
+			from = "-"
+		}
+		t.row(string(c.Status), strings.Repeat("  ", c.Depth)+c.Name, from)
+	}
+	return t.flush()
+}
+
+// printEraTable prints eras as a table.
+func printEraTable(eras []eraJSON) error {
+	t := newTable(os.Stdout, "ID", "NAME", "START", "END", "TRACKS", "PLAYLIST")
+	for _, e := range eras {
+		playlist := e.PlaylistID
+		if playlist == "" {
+			playlist = "-"
+		}
+		name := strings.Repeat("  ", e.depth) + e.Name
+		t.row(e.ID, name, e.StartDate, e.EndDate, strconv.Itoa(e.TrackCount), playlist)
+	}
+	return t.flush()
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	spotifyclient "github.com/justestif/go-spotify-era-organizer/internal/spotify"
+)
+
+// loginTimeout bounds how long login waits for the browser redirect.
+const loginTimeout = 5 * time.Minute
+
+// Token storage targets for the login command.
+const (
+	storeFile = "file"
+	storeDB   = "db"
+)
+
+// loginOutput is the output of the login command.
+type loginOutput struct {
+	UserID      string `json:"user_id"`
+	DisplayName string `json:"display_name"`
+	Store       string `json:"store"`
+	Location    string `json:"location,omitempty"` // Credential file path
+}
+
+// runLogin authorizes the CLI with Spotify using PKCE and a temporary
+// loopback listener, then stores the refresh token for later commands.
+func runLogin(ctx context.Context, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fset := flag.NewFlagSet("login", flag.ContinueOnError)
+	jsonOut := fset.Bool("json", false, "print JSON instead of text")
+	store := fset.String("store", storeFile, `where to save the token: "file" or "db" (users table)`)
+	redirect := fset.String("redirect", cfg.Server.RedirectURI(),
+		"redirect URI registered with the Spotify app; login listens on its host and port")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if *store != storeFile && *store != storeDB {
+		return fmt.Errorf("invalid -store %q: must be file or db", *store)
+	}
+
+	redirectURL, err := url.Parse(*redirect)
+	if err != nil || redirectURL.Host == "" {
+		return fmt.Errorf("invalid -redirect %q", *redirect)
+	}
+
+	oauth, err := oauthConfig(*redirect)
+	if err != nil {
+		return err
+	}
+
+	token, err := authorize(ctx, oauth, redirectURL)
+	if err != nil {
+		return err
+	}
+
+	// Identify the account the token belongs to. The token is fresh and is
+	// saved below, so there's nothing to save on refresh yet
+	source := spotifyclient.NewTokenSource(ctx, token, spotifyclient.ConfigRefresher(oauth), nil)
+	user, err := spotifyclient.NewClientFromTokenSource(ctx, source).Profile(ctx)
+	if err != nil {
+		return err
+	}
+
+	out := loginOutput{
+		UserID:      user.ID,
+		DisplayName: user.DisplayName,
+		Store:       *store,
+	}
+
+	switch *store {
+	case storeFile:
+		path, err := credentialsPath()
+		if err != nil {
+			return err
+		}
+		if err := saveCredentials(path, &credentials{UserID: user.ID, Token: token}); err != nil {
+			return err
+		}
+		out.Location = path
+	case storeDB:
+		if err := saveUserToken(ctx, &db.User{
+			ID:          user.ID,
+			DisplayName: user.DisplayName,
+			Email:       user.Email,
+		}, token); err != nil {
+			return err
+		}
+	}
+
+	if *jsonOut {
+		return writeJSON(os.Stdout, out)
+	}
+	fmt.Printf("Logged in as %s (%s)\n", out.DisplayName, out.UserID)
+	if out.Location != "" {
+		fmt.Printf("Credentials saved to %s\n", out.Location)
+	} else {
+		fmt.Println("Credentials saved to the users table")
+	}
+	return nil
+}
+
+// authorize runs the authorization code flow with PKCE. It listens on the
+// redirect URI's address, prints the authorization URL, and exchanges the
+// code once the browser is redirected back.
+func authorize(ctx context.Context, cfg *oauth2.Config, redirectURL *url.URL) (*oauth2.Token, error) {
+	state, err := randomState()
+	if err != nil {
+		return nil, err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	listener, err := net.Listen("tcp", redirectURL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s (is the web server running?): %w", redirectURL.Host, err)
+	}
+
+	results := make(chan callbackResult, 1)
+	mux := http.NewServeMux()
+	path := redirectURL.Path
+	if path == "" {
+		path = "/"
+	}
+	mux.Handle(path, callbackHandler(state, results))
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	authURL := cfg.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+	fmt.Fprintf(os.Stderr, "Open this URL in a browser to authorize Spotify Era Organizer:\n\n  %s\n\nWaiting for authorization...\n", authURL)
+
+	ctx, cancel := context.WithTimeout(ctx, loginTimeout)
+	defer cancel()
+
+	var result callbackResult
+	select {
+	case result = <-results:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for authorization: %w", ctx.Err())
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	token, err := cfg.Exchange(ctx, result.code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchanging authorization code: %w", err)
+	}
+	if token.RefreshToken == "" {
+		return nil, errors.New("spotify did not return a refresh token")
+	}
+	return token, nil
+}
+
+// callbackResult is the outcome of the OAuth redirect.
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// callbackHandler handles the OAuth redirect, reporting the first valid
+// result on results. Requests with the wrong state are rejected without
+// ending the login, so stray requests can't abort it.
+func callbackHandler(state string, results chan<- callbackResult) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(w, "Invalid state. Please try logging in again.", http.StatusBadRequest)
+			return
+		}
+
+		var result callbackResult
+		switch {
+		case query.Get("error") != "":
+			result.err = fmt.Errorf("spotify auth error: %s", query.Get("error"))
+			http.Error(w, "Authorization failed: "+query.Get("error"), http.StatusBadRequest)
+		case query.Get("code") == "":
+			result.err = errors.New("spotify redirect had no authorization code")
+			http.Error(w, "Missing authorization code", http.StatusBadRequest)
+		default:
+			result.code = query.Get("code")
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprintln(w, "Logged in. You can close this window and return to the terminal.")
+		}
+
+		select {
+		case results <- result:
+		default:
+			// A result was already reported
+		}
+	})
+}
+
+// randomState generates a random OAuth state parameter.
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating state: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// saveUserToken upserts the user and stores the token in the users table.
+func saveUserToken(ctx context.Context, user *db.User, token *oauth2.Token) error {
+	database, err := openDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if err := database.Users().Upsert(ctx, user); err != nil {
+		return err
+	}
+	return database.Users().SaveToken(ctx, user.ID, token.AccessToken, token.RefreshToken, token.Expiry)
+}
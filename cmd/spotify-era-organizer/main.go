@@ -1,80 +1,88 @@
-// Command spotify-era-organizer runs the Spotify Era Organizer web application.
+// Command spotify-era-organizer runs the Spotify Era Organizer web application
+// and provides headless subcommands for scripting syncs and analysis.
 package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io/fs"
-	"log"
+	"io"
 	"os"
-
-	"github.com/justestif/go-spotify-era-organizer/internal/db"
-	"github.com/justestif/go-spotify-era-organizer/internal/web"
-	webfs "github.com/justestif/go-spotify-era-organizer/web"
+	"os/signal"
+	"syscall"
 )
 
+// command is a CLI subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+// commands lists the subcommands in the order shown in usage.
+var commands = []command{
+	{"serve", "Run the web server (default)", runServe},
+	{"worker", "Run background syncs without the web server", runWorker},
+	{"login", "Authorize the CLI with Spotify", runLogin},
+	{"sync", "Sync liked songs from Spotify", runSync},
+	{"tag", "Fetch Last.fm tags for untagged tracks", runTag},
+	{"analyze", "Sync, tag and detect eras", runAnalyze},
+	{"import", "Import a Spotify data export or Last.fm scrobbles", runImport},
+	{"eras", "List, show or rename eras", runEras},
+	{"export", "Export eras and their tracks", runExport},
+	{"playlist", "Publish an era as a Spotify playlist", runPlaylist},
+	{"backup", "Write a full-library backup archive", runBackup},
+	{"restore", "Restore a backup archive", runRestore},
+	{"housekeeping", "Clean up expired sessions and orphaned tracks", runHousekeeping},
+	{"encrypt-tokens", "Encrypt stored OAuth tokens with the primary key", runEncryptTokens},
+	{"migrate", "Apply, revert or report database migrations", runMigrate},
+}
+
 func main() {
-	if err := run(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func run() error {
-	// Validate environment variables
-	clientID := os.Getenv("SPOTIFY_ID")
-	clientSecret := os.Getenv("SPOTIFY_SECRET")
-
-	if clientID == "" || clientSecret == "" {
-		return fmt.Errorf("please set SPOTIFY_ID and SPOTIFY_SECRET environment variables")
+// run dispatches to a subcommand. With no arguments it runs the web server.
+func run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return runServe(ctx, nil)
 	}
 
-	// Read optional Last.fm API key
-	lastfmAPIKey := os.Getenv("LASTFM_API_KEY")
-	if lastfmAPIKey == "" {
-		log.Println("Warning: LASTFM_API_KEY not set, tag fetching will be disabled")
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage(os.Stdout)
+		return nil
 	}
 
-	// Connect to database (optional - gracefully degrade if not available)
-	var database *db.DB
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL != "" {
-		var err error
-		database, err = db.New(context.Background(), databaseURL)
-		if err != nil {
-			return fmt.Errorf("connecting to database: %w", err)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd.run(ctx, args[1:])
 		}
-		defer database.Close()
-		log.Println("Connected to PostgreSQL database")
-	} else {
-		log.Println("Warning: DATABASE_URL not set, using in-memory session storage")
-		log.Println("Data will not persist across restarts")
 	}
 
-	// Create sub-filesystems for templates and static files
-	templates, err := fs.Sub(webfs.TemplatesFS, "templates")
-	if err != nil {
-		return fmt.Errorf("creating templates filesystem: %w", err)
-	}
-
-	static, err := fs.Sub(webfs.StaticFS, "static")
-	if err != nil {
-		return fmt.Errorf("creating static filesystem: %w", err)
-	}
+	printUsage(os.Stderr)
+	return fmt.Errorf("unknown command %q", name)
+}
 
-	// Create and start server
-	server, err := web.NewServer(web.ServerConfig{
-		Addr:         web.DefaultAddr,
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		TemplatesFS:  templates,
-		StaticFS:     static,
-		DB:           database,
-		LastFMAPIKey: lastfmAPIKey,
-	})
-	if err != nil {
-		return fmt.Errorf("creating server: %w", err)
+// printUsage writes the list of subcommands.
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: spotify-era-organizer <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-15s %s\n", cmd.name, cmd.summary)
 	}
-
-	return server.Run()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "spotify-era-organizer <command> -h" for command flags.`)
 }
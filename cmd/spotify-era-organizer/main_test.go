@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/db/dbtest"
+)
+
+func TestRun_UnknownCommand(t *testing.T) {
+	err := run(context.Background(), []string{"bogus"})
+	if err == nil || !strings.Contains(err.Error(), "bogus") {
+		t.Errorf("run() error = %v, want unknown command error", err)
+	}
+}
+
+func TestRun_SubcommandUsage(t *testing.T) {
+	tests := [][]string{
+		{"eras"},
+		{"eras", "delete"},
+		{"playlist"},
+		{"import", "itunes"},
+		{"housekeeping"},
+		{"migrate", "sideways"},
+		{"migrate", "force"},
+	}
+
+	for _, args := range tests {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			if err := run(context.Background(), args); err == nil {
+				t.Errorf("run(%v) succeeded, want usage error", args)
+			}
+		})
+	}
+}
+
+func TestLoadMigrations_Embedded(t *testing.T) {
+	for _, driver := range []string{db.DriverPostgres, db.DriverSQLite} {
+		all, err := loadMigrations(driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) == 0 {
+			t.Fatalf("no %s migrations embedded", driver)
+		}
+		for i, m := range all {
+			if m.Version != uint64(i+1) {
+				t.Errorf("%s migration %d has version %d, want consecutive versions", driver, i, m.Version)
+			}
+			if m.Down == "" {
+				t.Errorf("%s migration %d_%s has no down file", driver, m.Version, m.Name)
+			}
+		}
+	}
+}
+
+func TestPrintUsage_ListsCommands(t *testing.T) {
+	var buf bytes.Buffer
+	printUsage(&buf)
+
+	for _, cmd := range commands {
+		if !strings.Contains(buf.String(), cmd.name) {
+			t.Errorf("usage missing command %q", cmd.name)
+		}
+	}
+}
+
+func TestTable(t *testing.T) {
+	var buf bytes.Buffer
+	tbl := newTable(&buf, "ID", "NAME")
+	tbl.row("1", "Short")
+	tbl.row("22", "A longer name")
+	if err := tbl.flush(); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+
+	want := "ID  NAME\n1   Short\n22  A longer name\n"
+	if buf.String() != want {
+		t.Errorf("table =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestToEraJSON(t *testing.T) {
+	playlistID := "pl123"
+	album := "OK Computer"
+	era := db.Era{
+		ID:         uuid.MustParse("6f1c1f0e-8d5e-4d3b-9a51-4c1c1f0e8d5e"),
+		Name:       "Rock: Jan 1 - Feb 1, 2024",
+		TopTags:    []string{"rock"},
+		StartDate:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:    time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		PlaylistID: &playlistID,
+	}
+	tracks := []db.Track{{ID: "t1", Name: "Airbag", Artist: "Radiohead", Album: &album}}
+
+	got := toEraJSON(era, tracks, false)
+	if got.StartDate != "2024-01-01" || got.EndDate != "2024-02-01" {
+		t.Errorf("dates = %s - %s", got.StartDate, got.EndDate)
+	}
+	if got.TrackCount != 1 || got.Tracks != nil {
+		t.Errorf("TrackCount = %d, Tracks = %v; want 1 and no tracks", got.TrackCount, got.Tracks)
+	}
+	if got.PlaylistID != playlistID {
+		t.Errorf("PlaylistID = %q, want %q", got.PlaylistID, playlistID)
+	}
+
+	got = toEraJSON(era, tracks, true)
+	if len(got.Tracks) != 1 || got.Tracks[0].Album != album {
+		t.Errorf("Tracks = %+v, want one track with album", got.Tracks)
+	}
+}
+
+func TestCredentials_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "credentials.json")
+
+	creds, err := loadCredentials(path)
+	if err != nil || creds != nil {
+		t.Fatalf("loadCredentials(missing) = %v, %v; want nil, nil", creds, err)
+	}
+
+	want := &credentials{
+		UserID: "user1",
+		Token: &oauth2.Token{
+			AccessToken:  "access",
+			RefreshToken: "refresh",
+			Expiry:       time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}
+	if err := saveCredentials(path, want); err != nil {
+		t.Fatalf("saveCredentials() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("credentials permissions = %o, want 600", perm)
+	}
+
+	got, err := loadCredentials(path)
+	if err != nil {
+		t.Fatalf("loadCredentials() error = %v", err)
+	}
+	if got.UserID != want.UserID || got.Token.RefreshToken != want.Token.RefreshToken ||
+		!got.Token.Expiry.Equal(want.Token.Expiry) {
+		t.Errorf("loadCredentials() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadCredentials_NoRefreshToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte(`{"user_id":"user1","token":{"access_token":"a"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadCredentials(path); err == nil {
+		t.Error("loadCredentials() succeeded, want error for missing refresh token")
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantCode   string
+		wantErr    bool
+		wantResult bool
+	}{
+		{name: "success", query: "state=s1&code=abc", wantStatus: http.StatusOK, wantCode: "abc", wantResult: true},
+		{name: "denied", query: "state=s1&error=access_denied", wantStatus: http.StatusBadRequest, wantErr: true, wantResult: true},
+		{name: "missing code", query: "state=s1", wantStatus: http.StatusBadRequest, wantErr: true, wantResult: true},
+		{name: "wrong state", query: "state=other&code=abc", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make(chan callbackResult, 1)
+			rec := httptest.NewRecorder()
+			callbackHandler("s1", results).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/callback?"+tt.query, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			select {
+			case result := <-results:
+				if !tt.wantResult {
+					t.Fatalf("got result %+v, want none", result)
+				}
+				if result.code != tt.wantCode || (result.err != nil) != tt.wantErr {
+					t.Errorf("result = %+v, want code %q, error %v", result, tt.wantCode, tt.wantErr)
+				}
+			default:
+				if tt.wantResult {
+					t.Error("no result reported")
+				}
+			}
+		})
+	}
+}
+
+func TestEncryptStoredTokens(t *testing.T) {
+	ctx := context.Background()
+	database := dbtest.NewSQLite(t)
+	if err := database.Users().EnsureExists(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Users().SaveToken(ctx, "alice", "access", "refresh", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without keys the tokens stay plaintext
+	if err := encryptStoredTokens(ctx, database); err != nil {
+		t.Fatalf("encryptStoredTokens() without keys error = %v", err)
+	}
+
+	keys, err := db.ParseKeyring("k1:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.SetKeyring(keys)
+	if err := encryptStoredTokens(ctx, database); err != nil {
+		t.Fatalf("encryptStoredTokens() error = %v", err)
+	}
+	if rotated, err := database.RotateTokens(ctx); err != nil || rotated.Users != 0 {
+		t.Errorf("RotateTokens() afterwards = %+v, %v, want nothing left to encrypt", rotated, err)
+	}
+	if token, err := database.Users().GetToken(ctx, "alice"); err != nil || token.RefreshToken != "refresh" {
+		t.Errorf("GetToken() = %+v, %v, want the encrypted token readable", token, err)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/config"
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/migrations"
+)
+
+// loadMigrations returns the migrations embedded in the binary for a
+// storage backend, db.DriverPostgres or db.DriverSQLite.
+func loadMigrations(driver string) ([]db.Migration, error) {
+	if driver == db.DriverSQLite {
+		return db.LoadMigrations(migrations.SQLite)
+	}
+	return db.LoadMigrations(migrations.FS)
+}
+
+// prepareSchema applies pending migrations, or with auto-migration off
+// checks that there are none. Either way it refuses a dirty schema or one
+// newer than this binary.
+func prepareSchema(ctx context.Context, database *db.DB, cfg *config.Config) error {
+	all, err := loadMigrations(database.Driver())
+	if err != nil {
+		return err
+	}
+
+	if !cfg.Database.AutoMigrate {
+		if _, err := database.CheckSchema(ctx, all); err != nil {
+			return fmt.Errorf("%w (run \"spotify-era-organizer migrate\")", err)
+		}
+		return nil
+	}
+
+	before, err := database.SchemaStatus(ctx, all)
+	if err != nil {
+		return err
+	}
+	status, err := database.Migrate(ctx, all)
+	if err != nil {
+		return fmt.Errorf("migrating database: %w", err)
+	}
+	if status.Version != before.Version {
+		log.Printf("Migrated database schema from version %d to %d", before.Version, status.Version)
+	}
+	return nil
+}
+
+// runMigrate dispatches the migrate subcommands. With no subcommand it
+// applies pending migrations.
+func runMigrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return runMigrateUp(ctx, nil)
+	}
+	switch args[0] {
+	case "up":
+		return runMigrateUp(ctx, args[1:])
+	case "down":
+		return runMigrateDown(ctx, args[1:])
+	case "status":
+		return runMigrateStatus(ctx, args[1:])
+	case "force":
+		return runMigrateForce(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown migrate command %q (want up, down, status or force)", args[0])
+	}
+}
+
+// migrateDB connects to the database and loads the embedded migrations for
+// a migrate subcommand.
+func migrateDB(ctx context.Context) (*db.DB, []db.Migration, error) {
+	database, err := connectDB(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	all, err := loadMigrations(database.Driver())
+	if err != nil {
+		database.Close()
+		return nil, nil, err
+	}
+	return database, all, nil
+}
+
+// printSchemaStatus prints the schema status as JSON or text.
+func printSchemaStatus(status *db.SchemaStatus, jsonOut bool) error {
+	if jsonOut {
+		return writeJSON(os.Stdout, status)
+	}
+	state := "clean"
+	if status.Dirty {
+		state = "dirty"
+	}
+	fmt.Printf("Schema version %d (%s), latest %d, %d pending\n", status.Version, state, status.Latest, status.Pending)
+	return nil
+}
+
+// runMigrateUp applies every pending migration, then encrypts any tokens
+// still stored in plaintext or under an older key.
+func runMigrateUp(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("migrate up", flag.ContinueOnError)
+	jsonOut := fset.Bool("json", false, "print JSON instead of text")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	database, all, err := migrateDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	status, err := database.Migrate(ctx, all)
+	if err != nil {
+		return err
+	}
+	if err := encryptStoredTokens(ctx, database); err != nil {
+		return err
+	}
+	return printSchemaStatus(status, *jsonOut)
+}
+
+// runMigrateDown reverts the most recent migrations.
+func runMigrateDown(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+	jsonOut := fset.Bool("json", false, "print JSON instead of text")
+	steps := fset.Int("n", 1, "number of migrations to revert")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if *steps < 1 {
+		return fmt.Errorf("invalid -n %d: must be at least 1", *steps)
+	}
+
+	database, all, err := migrateDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	status, err := database.MigrateDown(ctx, all, *steps)
+	if err != nil {
+		return err
+	}
+	return printSchemaStatus(status, *jsonOut)
+}
+
+// runMigrateStatus reports the schema version. It fails on a dirty schema
+// or one newer than this binary, so it can gate deploys.
+func runMigrateStatus(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("migrate status", flag.ContinueOnError)
+	jsonOut := fset.Bool("json", false, "print JSON instead of text")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	database, all, err := migrateDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	status, err := database.CheckSchema(ctx, all)
+	if status == nil {
+		return err
+	}
+	if printErr := printSchemaStatus(status, *jsonOut); printErr != nil {
+		return printErr
+	}
+	if errors.Is(err, db.ErrSchemaOutdated) {
+		return nil // Pending migrations are reported above
+	}
+	return err
+}
+
+// runMigrateForce records a version as applied and clean, after a failed
+// migration has been repaired by hand.
+func runMigrateForce(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: spotify-era-organizer migrate force <version>")
+	}
+	version, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid version %q", args[0])
+	}
+
+	database, _, err := migrateDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if err := database.ForceVersion(ctx, version); err != nil {
+		return err
+	}
+	fmt.Printf("Schema version set to %d\n", version)
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/eras"
+)
+
+// dateFormat is the date layout used in tables and JSON output.
+const dateFormat = "2006-01-02"
+
+// writeJSON writes v as indented JSON.
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// table writes tab-aligned columns.
+type table struct {
+	tw *tabwriter.Writer
+}
+
+// newTable creates a table with the given column headers.
+func newTable(w io.Writer, headers ...string) *table {
+	t := &table{tw: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)}
+	t.row(headers...)
+	return t
+}
+
+// row writes a single row.
+func (t *table) row(cells ...string) {
+	fmt.Fprintln(t.tw, strings.Join(cells, "\t"))
+}
+
+// flush writes the aligned table.
+func (t *table) flush() error {
+	return t.tw.Flush()
+}
+
+// eraJSON is the JSON representation of an era.
+type eraJSON struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	TopTags    []string    `json:"top_tags"`
+	StartDate  string      `json:"start_date"`
+	EndDate    string      `json:"end_date"`
+	PlaylistID string      `json:"playlist_id,omitempty"`
+	ParentID   string      `json:"parent_id,omitempty"` // Set for sub-eras
+	TrackCount int         `json:"track_count"`
+	Cohesion   *float64    `json:"cohesion,omitempty"`
+	Silhouette *float64    `json:"silhouette,omitempty"`
+	Tracks     []trackJSON `json:"tracks,omitempty"`
+
+	depth int // Sub-era nesting level, for indenting tables
+}
+
+// changeJSON is the JSON representation of what happened to an era in a
+// re-analysis.
+type changeJSON struct {
+	Status     eras.ChangeStatus `json:"status"`
+	EraID      string            `json:"era_id"` // The deleted era's ID if gone
+	Name       string            `json:"name"`
+	Previous   []previousEraJSON `json:"previous,omitempty"`
+	Similarity float64           `json:"similarity,omitempty"`
+	Depth      int               `json:"depth,omitempty"`
+}
+
+// previousEraJSON identifies an era from before a re-analysis.
+type previousEraJSON struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// toChangeJSON converts a re-analysis report.
+func toChangeJSON(changes []eras.EraChange) []changeJSON {
+	var result []changeJSON
+	for _, c := range changes {
+		change := changeJSON{
+			Status:     c.Status,
+			EraID:      c.EraID.String(),
+			Name:       c.Name,
+			Similarity: c.Similarity,
+			Depth:      c.Depth,
+		}
+		for _, p := range c.Previous {
+			change.Previous = append(change.Previous, previousEraJSON{ID: p.ID.String(), Name: p.Name})
+		}
+		result = append(result, change)
+	}
+	return result
+}
+
+// trackJSON is the JSON representation of a track.
+type trackJSON struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Artist string `json:"artist"`
+	Album  string `json:"album,omitempty"`
+}
+
+// toEraJSON converts an era and its tracks. Tracks are included only if
+// withTracks is set.
+func toEraJSON(era db.Era, tracks []db.Track, withTracks bool) eraJSON {
+	e := eraJSON{
+		ID:         era.ID.String(),
+		Name:       era.DisplayName(),
+		TopTags:    era.TopTags,
+		StartDate:  era.StartDate.Format(dateFormat),
+		EndDate:    era.EndDate.Format(dateFormat),
+		TrackCount: len(tracks),
+		Cohesion:   era.Cohesion,
+		Silhouette: era.Silhouette,
+	}
+	if era.PlaylistID != nil {
+		e.PlaylistID = *era.PlaylistID
+	}
+	if era.ParentID != nil {
+		e.ParentID = era.ParentID.String()
+	}
+	if withTracks {
+		e.Tracks = make([]trackJSON, len(tracks))
+		for i, t := range tracks {
+			e.Tracks[i] = toTrackJSON(t)
+		}
+	}
+	return e
+}
+
+// walkEras calls fn for each era in the tree, parents before their
+// sub-eras, with depth 0 for top-level eras.
+func walkEras(nodes []eras.EraNode, depth int, fn func(era db.Era, depth int) error) error {
+	for _, node := range nodes {
+		if err := fn(node.Era, depth); err != nil {
+			return err
+		}
+		if err := walkEras(node.SubEras, depth+1, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toTrackJSON converts a track.
+func toTrackJSON(t db.Track) trackJSON {
+	album := ""
+	if t.Album != nil {
+		album = *t.Album
+	}
+	return trackJSON{
+		ID:     t.ID,
+		Name:   t.Name,
+		Artist: t.Artist,
+		Album:  album,
+	}
+}
+
+// formatTime formats an optional timestamp for tables.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+	return t.Local().Format(time.DateTime)
+}
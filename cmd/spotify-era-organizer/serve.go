@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/housekeeping"
+	"github.com/justestif/go-spotify-era-organizer/internal/web"
+	webfs "github.com/justestif/go-spotify-era-organizer/web"
+)
+
+// runServe runs the web server.
+func runServe(ctx context.Context, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fset := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fset.StringVar(&cfg.Server.Addr, "addr", cfg.Server.Addr, "listen address (overrides server.addr)")
+	runScheduler := fset.Bool("scheduler", true, "run background syncs in the server (needs DATABASE_URL)")
+	var schedFlags schedulerFlags
+	schedFlags.register(fset)
+	housekeepingInterval := fset.Duration("housekeeping-interval", housekeeping.DefaultInterval,
+		"time between cleanups of expired sessions and orphaned tracks (0 to turn off)")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	// -addr may leave the derived base URL without a reachable host
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.Spotify.ClientID == "" || cfg.Spotify.ClientSecret == "" {
+		return fmt.Errorf("please set SPOTIFY_ID and SPOTIFY_SECRET environment variables (or spotify.client_id and spotify.client_secret in the config file)")
+	}
+
+	// The Last.fm API key is optional
+	if cfg.LastFM.APIKey == "" {
+		log.Println("Warning: LASTFM_API_KEY not set, tag fetching will be disabled")
+	}
+
+	// Connect to database (optional - gracefully degrade if not available)
+	var database *db.DB
+	if cfg.Database.URL != "" {
+		keys, err := cfg.Database.Keyring()
+		if err != nil {
+			return err
+		}
+		database, err = db.New(ctx, cfg.Database.URL)
+		if err != nil {
+			return fmt.Errorf("connecting to database: %w", err)
+		}
+		defer database.Close()
+		log.Printf("Connected to %s database", database.Driver())
+
+		if err := prepareSchema(ctx, database, cfg); err != nil {
+			return err
+		}
+
+		database.SetKeyring(keys)
+		if err := encryptStoredTokens(ctx, database); err != nil {
+			return err
+		}
+	} else {
+		log.Println("Warning: DATABASE_URL not set, using in-memory session storage")
+		log.Println("Data will not persist across restarts")
+	}
+
+	// Sync every user's library in the background
+	if database != nil && *runScheduler {
+		s, err := newScheduler(database, schedFlags)
+		if err != nil {
+			return err
+		}
+		go s.Run(ctx)
+	}
+
+	// Create sub-filesystems for templates and static files
+	templates, err := fs.Sub(webfs.TemplatesFS, "templates")
+	if err != nil {
+		return fmt.Errorf("creating templates filesystem: %w", err)
+	}
+
+	static, err := fs.Sub(webfs.StaticFS, "static")
+	if err != nil {
+		return fmt.Errorf("creating static filesystem: %w", err)
+	}
+
+	// Create and start server
+	server, err := web.NewServer(web.ServerConfig{
+		Addr:            cfg.Server.Addr,
+		RedirectURI:     cfg.Server.RedirectURI(),
+		ClientID:        cfg.Spotify.ClientID,
+		ClientSecret:    cfg.Spotify.ClientSecret,
+		TemplatesFS:     templates,
+		StaticFS:        static,
+		DB:              database,
+		LastFMAPIKey:    cfg.LastFM.APIKey,
+		ReadTimeout:     cfg.Server.ReadTimeout,
+		WriteTimeout:    cfg.Server.WriteTimeout,
+		IdleTimeout:     cfg.Server.IdleTimeout,
+		ShutdownTimeout: cfg.Server.ShutdownTimeout,
+		SyncCooldown:    cfg.Sync.Cooldown,
+		TagConcurrency:  cfg.Tags.Concurrency,
+		TagCacheTTL:     cfg.Tags.CacheTTL,
+		EraDefaults:     cfg.Clustering.TagClusterConfig(),
+	})
+	if err != nil {
+		return fmt.Errorf("creating server: %w", err)
+	}
+
+	// Clean up expired sessions, OAuth states and orphaned tracks
+	if *housekeepingInterval > 0 {
+		tasks := server.HousekeepingTasks()
+		if database != nil {
+			tasks = append(housekeeping.DBTasks(database), tasks...)
+		}
+		go housekeeping.New(database, tasks...).Run(ctx, *housekeepingInterval)
+	}
+
+	return server.Run()
+}
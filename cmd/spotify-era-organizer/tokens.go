@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// runEncryptTokens re-encrypts stored OAuth tokens with the primary key.
+func runEncryptTokens(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("encrypt-tokens", flag.ContinueOnError)
+	jsonOut := fset.Bool("json", false, "print JSON instead of text")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	database, err := openDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	result, err := database.RotateTokens(ctx)
+	if errors.Is(err, db.ErrNoKeyring) {
+		return errors.New("please set TOKEN_ENCRYPTION_KEYS environment variable or database.token_encryption_keys in the config file")
+	}
+	if err != nil {
+		return err
+	}
+
+	if *jsonOut {
+		return writeJSON(os.Stdout, result)
+	}
+	fmt.Printf("Encrypted tokens of %d sessions and %d users\n", result.Sessions, result.Users)
+	return nil
+}
+
+// encryptStoredTokens encrypts tokens left from before encryption or a key
+// rotation with the primary key. serve, worker and migrate run it, so no
+// deployment keeps plaintext tokens once keys are configured. Without keys
+// it only warns.
+func encryptStoredTokens(ctx context.Context, database *db.DB) error {
+	rotated, err := database.RotateTokens(ctx)
+	if errors.Is(err, db.ErrNoKeyring) {
+		log.Println("Warning: TOKEN_ENCRYPTION_KEYS not set, OAuth tokens are stored unencrypted")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("encrypting stored tokens: %w", err)
+	}
+	if rotated.Sessions > 0 || rotated.Users > 0 {
+		log.Printf("Encrypted tokens of %d sessions and %d users", rotated.Sessions, rotated.Users)
+	}
+	return nil
+}
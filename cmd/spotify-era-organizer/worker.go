@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/scheduler"
+	spotifyclient "github.com/justestif/go-spotify-era-organizer/internal/spotify"
+)
+
+// schedulerFlags are the scheduler settings shared by serve and worker.
+type schedulerFlags struct {
+	interval time.Duration
+	jitter   time.Duration
+	tick     time.Duration
+}
+
+// register adds the scheduler flags to fset.
+func (f *schedulerFlags) register(fset *flag.FlagSet) {
+	fset.DurationVar(&f.interval, "sync-interval", scheduler.DefaultInterval,
+		"time between background syncs for users who haven't chosen one")
+	fset.DurationVar(&f.jitter, "sync-jitter", scheduler.DefaultJitter,
+		"most each user's background sync is delayed by, to spread syncs out")
+	fset.DurationVar(&f.tick, "sync-tick", scheduler.DefaultTick,
+		"how often to look for users who are due a sync")
+}
+
+// newScheduler creates a scheduler that refreshes tokens with the app's
+// Spotify credentials and tags tracks if a Last.fm API key is configured.
+func newScheduler(database *db.DB, f schedulerFlags) (*scheduler.Scheduler, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	oauth, err := oauthConfig(cfg.Server.RedirectURI())
+	if err != nil {
+		return nil, err
+	}
+
+	return scheduler.New(database, spotifyclient.ConfigRefresher(oauth), newEnricher(database, cfg), scheduler.Config{
+		Interval: f.interval,
+		Jitter:   f.jitter,
+		Tick:     f.tick,
+		Cooldown: cfg.Sync.Cooldown,
+		Defaults: cfg.Clustering.TagClusterConfig(),
+	}), nil
+}
+
+// runWorker runs the background sync scheduler without the web server, so
+// syncs can run in their own process. Run the web server with
+// -scheduler=false alongside it.
+func runWorker(ctx context.Context, args []string) error {
+	var flags schedulerFlags
+	fset := flag.NewFlagSet("worker", flag.ContinueOnError)
+	flags.register(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	database, err := openDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+	if err := encryptStoredTokens(ctx, database); err != nil {
+		return err
+	}
+
+	s, err := newScheduler(database, flags)
+	if err != nil {
+		return err
+	}
+	s.Run(ctx)
+	return nil
+}
@@ -0,0 +1,215 @@
+// Package backup creates and restores full-library backups: a versioned JSON
+// archive of everything the app stores for a user, for moving between
+// deployments or building reproducible test fixtures.
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Format identifies backup archives.
+const Format = "spotify-era-organizer-backup"
+
+// Version is the archive schema version written by Backup. Restore accepts
+// archives up to this version.
+const Version = 1
+
+// ErrUnsupportedVersion is returned when an archive is newer than this build.
+var ErrUnsupportedVersion = errors.New("backup archive version is not supported")
+
+// Archive is a full-library backup. OAuth tokens and sessions are never
+// included.
+type Archive struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	User      User      `json:"user"`
+	Settings  *Settings `json:"settings,omitempty"`
+	Tracks    []Track   `json:"tracks"`
+	Likes     []Like    `json:"likes"`
+	Tags      []Tag     `json:"tags"`
+	UserTags  []UserTag `json:"user_tags"`
+	Plays     []Play    `json:"plays"`
+	Eras      []Era     `json:"eras"`
+}
+
+// User is the user's profile.
+type User struct {
+	ID          string     `json:"id"`
+	DisplayName string     `json:"display_name"`
+	Email       string     `json:"email,omitempty"`
+	LastSyncAt  *time.Time `json:"last_sync_at,omitempty"`
+}
+
+// Settings are the user's analysis settings. Nil fields use the defaults.
+type Settings struct {
+	NumClusters     *int     `json:"num_clusters,omitempty"`
+	MinClusterSize  *int     `json:"min_cluster_size,omitempty"`
+	MaxTags         *int     `json:"max_tags,omitempty"`
+	ManualTagWeight *float64 `json:"manual_tag_weight,omitempty"`
+	Basis           *string  `json:"basis,omitempty"`
+	TimeWeight      *float64 `json:"time_weight,omitempty"`
+	NameTemplate    *string  `json:"name_template,omitempty"`
+}
+
+// Track is a catalog track referenced by the library, plays or eras.
+type Track struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Artist     string   `json:"artist"`
+	Album      *string  `json:"album,omitempty"`
+	AlbumID    *string  `json:"album_id,omitempty"`
+	DurationMs *int     `json:"duration_ms,omitempty"`
+	Artists    []Artist `json:"artists,omitempty"` // Credit order, primary first
+}
+
+// Artist is an artist credited on a track.
+type Artist struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Like is a track in the user's library.
+type Like struct {
+	TrackID string    `json:"track_id"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// Tag is a Last.fm tag on a track.
+type Tag struct {
+	TrackID   string    `json:"track_id"`
+	Name      string    `json:"name"`
+	Count     int       `json:"count"`
+	Source    string    `json:"source"` // "track" or "artist"
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// UserTag is a tag the user assigned to a track.
+type UserTag struct {
+	TrackID   string    `json:"track_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Play is a single listen from the user's history.
+type Play struct {
+	TrackID    *string   `json:"track_id,omitempty"`
+	ArtistName string    `json:"artist_name"`
+	TrackName  string    `json:"track_name"`
+	PlayedAt   time.Time `json:"played_at"`
+	MsPlayed   int       `json:"ms_played"`
+	Source     string    `json:"source"`
+}
+
+// Era is a detected era and its tracks.
+type Era struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	NameOverride *string   `json:"name_override,omitempty"` // Name the user gave the era
+	TopTags      []string  `json:"top_tags"`
+	StartDate    time.Time `json:"start_date"`
+	EndDate      time.Time `json:"end_date"`
+	PlaylistID   *string   `json:"playlist_id,omitempty"`
+	ParentID     *string   `json:"parent_id,omitempty"` // Parent era of a sub-era, listed earlier
+	TrackIDs     []string  `json:"track_ids"`
+
+	// Quality metrics, absent for eras detected before they were stored
+	Cohesion   *float64            `json:"cohesion,omitempty"`
+	Silhouette *float64            `json:"silhouette,omitempty"`
+	TagWeights []TagWeight         `json:"tag_weights,omitempty"`
+	Reasons    map[string][]string `json:"reasons,omitempty"` // Track ID to the tags tying it to the era
+}
+
+// TagWeight is a tag's weight in an era's centroid.
+type TagWeight struct {
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+}
+
+// Write encodes an archive as indented JSON.
+func Write(w io.Writer, archive *Archive) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(archive); err != nil {
+		return fmt.Errorf("encoding backup: %w", err)
+	}
+	return nil
+}
+
+// Read decodes and validates an archive.
+func Read(r io.Reader) (*Archive, error) {
+	var archive Archive
+	if err := json.NewDecoder(r).Decode(&archive); err != nil {
+		return nil, fmt.Errorf("decoding backup: %w", err)
+	}
+	if err := archive.Validate(); err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+// Validate checks that the archive is a supported backup and that its
+// records only reference tracks it contains.
+func (a *Archive) Validate() error {
+	if a.Format != Format {
+		return fmt.Errorf("not a backup archive (format %q)", a.Format)
+	}
+	if a.Version < 1 || a.Version > Version {
+		return fmt.Errorf("%w: got %d, want at most %d", ErrUnsupportedVersion, a.Version, Version)
+	}
+	if a.User.ID == "" {
+		return errors.New("backup archive has no user ID")
+	}
+
+	tracks := make(map[string]bool, len(a.Tracks))
+	for _, t := range a.Tracks {
+		if t.ID == "" {
+			return errors.New("backup archive has a track without an ID")
+		}
+		tracks[t.ID] = true
+	}
+
+	check := func(kind, trackID string) error {
+		if !tracks[trackID] {
+			return fmt.Errorf("backup archive %s references unknown track %q", kind, trackID)
+		}
+		return nil
+	}
+	for _, l := range a.Likes {
+		if err := check("like", l.TrackID); err != nil {
+			return err
+		}
+	}
+	for _, t := range a.Tags {
+		if err := check("tag", t.TrackID); err != nil {
+			return err
+		}
+	}
+	for _, t := range a.UserTags {
+		if err := check("user tag", t.TrackID); err != nil {
+			return err
+		}
+	}
+	eras := make(map[string]bool, len(a.Eras))
+	for _, e := range a.Eras {
+		if e.ParentID != nil && !eras[*e.ParentID] {
+			return fmt.Errorf("backup archive era %q has parent %q that isn't listed before it", e.ID, *e.ParentID)
+		}
+		eras[e.ID] = true
+		for _, id := range e.TrackIDs {
+			if err := check("era", id); err != nil {
+				return err
+			}
+		}
+		for id := range e.Reasons {
+			if err := check("era reason", id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,501 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// Store reads and writes the data included in backups.
+type Store interface {
+	GetUser(ctx context.Context, userID string) (*db.User, error)
+	// GetSettings returns db.ErrNotFound if the user has no settings.
+	GetSettings(ctx context.Context, userID string) (*db.UserSettings, error)
+	GetLikes(ctx context.Context, userID string) ([]db.UserTrack, []db.Track, error)
+	GetArtists(ctx context.Context, trackIDs []string) (map[string][]db.Artist, error)
+	GetTags(ctx context.Context, trackIDs []string) (map[string][]db.TrackTag, error)
+	GetUserTags(ctx context.Context, userID string, trackIDs []string) (map[string][]db.UserTrackTag, error)
+	GetPlays(ctx context.Context, userID string) ([]db.Play, error)
+	GetEras(ctx context.Context, userID string) ([]db.Era, error)
+	GetEraTracks(ctx context.Context, userID string, eraID uuid.UUID) ([]db.Track, error)
+	GetEraReasons(ctx context.Context, userID string, eraID uuid.UUID) (map[string][]string, error)
+
+	SaveUser(ctx context.Context, user *db.User) error
+	SaveSettings(ctx context.Context, settings *db.UserSettings) error
+	SaveTracks(ctx context.Context, tracks []db.Track, artists []db.Artist, credits []db.TrackArtist) error
+	SaveLikes(ctx context.Context, userID string, likes []db.UserTrack) error
+	SaveTags(ctx context.Context, tags []db.TrackTag) error
+	SaveUserTags(ctx context.Context, userID string, tags []db.UserTrackTag) error
+	// SavePlays stores plays, skipping ones already recorded.
+	SavePlays(ctx context.Context, userID string, plays []db.Play) error
+	// ReplaceEras deletes the user's eras and creates the given ones, all or
+	// nothing.
+	ReplaceEras(ctx context.Context, userID string, eras []db.Era, tracks [][]db.EraTrack) error
+}
+
+// dbStore implements Store using the database.
+type dbStore struct {
+	db *db.DB
+}
+
+// NewDBStore creates a Store backed by the database.
+func NewDBStore(database *db.DB) Store {
+	return &dbStore{db: database}
+}
+
+func (s *dbStore) GetUser(ctx context.Context, userID string) (*db.User, error) {
+	return s.db.Users().Get(ctx, userID)
+}
+
+func (s *dbStore) GetSettings(ctx context.Context, userID string) (*db.UserSettings, error) {
+	return s.db.Settings().Get(ctx, userID)
+}
+
+func (s *dbStore) GetLikes(ctx context.Context, userID string) ([]db.UserTrack, []db.Track, error) {
+	return s.db.Tracks().GetUserTracksWithAddedAt(ctx, userID)
+}
+
+func (s *dbStore) GetArtists(ctx context.Context, trackIDs []string) (map[string][]db.Artist, error) {
+	return s.db.Artists().GetForTracks(ctx, trackIDs)
+}
+
+func (s *dbStore) GetTags(ctx context.Context, trackIDs []string) (map[string][]db.TrackTag, error) {
+	return s.db.Tags().GetForTracks(ctx, trackIDs)
+}
+
+func (s *dbStore) GetUserTags(ctx context.Context, userID string, trackIDs []string) (map[string][]db.UserTrackTag, error) {
+	return s.db.Tags().GetUserTagsForTracks(ctx, userID, trackIDs)
+}
+
+func (s *dbStore) GetPlays(ctx context.Context, userID string) ([]db.Play, error) {
+	return s.db.Plays().GetForUser(ctx, userID)
+}
+
+func (s *dbStore) GetEras(ctx context.Context, userID string) ([]db.Era, error) {
+	return s.db.Eras().GetForUser(ctx, userID)
+}
+
+func (s *dbStore) GetEraTracks(ctx context.Context, userID string, eraID uuid.UUID) ([]db.Track, error) {
+	return s.db.Eras().GetTracksForOwner(ctx, userID, eraID)
+}
+
+func (s *dbStore) GetEraReasons(ctx context.Context, userID string, eraID uuid.UUID) (map[string][]string, error) {
+	return s.db.Eras().GetTrackReasonsForOwner(ctx, userID, eraID)
+}
+
+// SaveUser upserts the profile and restores the last sync time.
+func (s *dbStore) SaveUser(ctx context.Context, user *db.User) error {
+	if err := s.db.Users().Upsert(ctx, user); err != nil {
+		return err
+	}
+	if user.LastSyncAt != nil {
+		return s.db.Users().UpdateLastSync(ctx, user.ID, *user.LastSyncAt)
+	}
+	return nil
+}
+
+func (s *dbStore) SaveSettings(ctx context.Context, settings *db.UserSettings) error {
+	return s.db.Settings().Upsert(ctx, settings)
+}
+
+func (s *dbStore) SaveTracks(ctx context.Context, tracks []db.Track, artists []db.Artist, credits []db.TrackArtist) error {
+	if err := s.db.Tracks().UpsertBatch(ctx, tracks); err != nil {
+		return err
+	}
+	if err := s.db.Artists().UpsertBatch(ctx, artists); err != nil {
+		return err
+	}
+	return s.db.Artists().ReplaceTrackArtists(ctx, credits)
+}
+
+func (s *dbStore) SaveLikes(ctx context.Context, userID string, likes []db.UserTrack) error {
+	return s.db.Tracks().LinkBatchToUser(ctx, userID, likes)
+}
+
+func (s *dbStore) SaveTags(ctx context.Context, tags []db.TrackTag) error {
+	return s.db.Tags().UpsertBatch(ctx, tags)
+}
+
+func (s *dbStore) SaveUserTags(ctx context.Context, userID string, tags []db.UserTrackTag) error {
+	return s.db.Tags().InsertUserTagsBatch(ctx, userID, tags)
+}
+
+func (s *dbStore) SavePlays(ctx context.Context, userID string, plays []db.Play) error {
+	_, err := s.db.Plays().InsertBatch(ctx, userID, plays)
+	return err
+}
+
+func (s *dbStore) ReplaceEras(ctx context.Context, userID string, eras []db.Era, tracks [][]db.EraTrack) error {
+	return s.db.Eras().ReplaceForUser(ctx, userID, eras, tracks)
+}
+
+// Service creates and restores backups.
+type Service struct {
+	store Store
+	now   func() time.Time
+}
+
+// New creates a backup Service.
+func New(store Store) *Service {
+	return &Service{store: store, now: time.Now}
+}
+
+// Backup builds an archive of everything stored for the user. Records are
+// sorted so backups of unchanged data differ only in CreatedAt.
+func (s *Service) Backup(ctx context.Context, userID string) (*Archive, error) {
+	user, err := s.store.GetUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	archive := &Archive{
+		Format:    Format,
+		Version:   Version,
+		CreatedAt: s.now().UTC(),
+		User: User{
+			ID:          user.ID,
+			DisplayName: user.DisplayName,
+			Email:       user.Email,
+			LastSyncAt:  user.LastSyncAt,
+		},
+		Tracks:   []Track{},
+		Likes:    []Like{},
+		Tags:     []Tag{},
+		UserTags: []UserTag{},
+		Plays:    []Play{},
+		Eras:     []Era{},
+	}
+
+	settings, err := s.store.GetSettings(ctx, userID)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("getting settings: %w", err)
+	default:
+		archive.Settings = &Settings{
+			NumClusters:     settings.NumClusters,
+			MinClusterSize:  settings.MinClusterSize,
+			MaxTags:         settings.MaxTags,
+			ManualTagWeight: settings.ManualTagWeight,
+			Basis:           settings.Basis,
+			TimeWeight:      settings.TimeWeight,
+			NameTemplate:    settings.NameTemplate,
+		}
+	}
+
+	likes, libraryTracks, err := s.store.GetLikes(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("getting library: %w", err)
+	}
+	for _, l := range likes {
+		archive.Likes = append(archive.Likes, Like{TrackID: l.TrackID, AddedAt: l.AddedAt})
+	}
+
+	// Tracks come from the library and from eras, which may outlive a like
+	tracks := make(map[string]db.Track, len(libraryTracks))
+	for _, t := range libraryTracks {
+		tracks[t.ID] = t
+	}
+
+	eras, err := s.store.GetEras(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("getting eras: %w", err)
+	}
+	for _, era := range eras {
+		eraTracks, err := s.store.GetEraTracks(ctx, userID, era.ID)
+		if err != nil {
+			return nil, fmt.Errorf("getting era tracks: %w", err)
+		}
+		ids := make([]string, 0, len(eraTracks))
+		for _, t := range eraTracks {
+			tracks[t.ID] = t
+			ids = append(ids, t.ID)
+		}
+		sort.Strings(ids)
+
+		reasons, err := s.store.GetEraReasons(ctx, userID, era.ID)
+		if err != nil {
+			return nil, fmt.Errorf("getting era reasons: %w", err)
+		}
+		if len(reasons) == 0 {
+			reasons = nil
+		}
+
+		topTags := era.TopTags
+		if topTags == nil {
+			topTags = []string{}
+		}
+		archived := Era{
+			ID:           era.ID.String(),
+			Name:         era.Name,
+			NameOverride: era.NameOverride,
+			TopTags:      topTags,
+			StartDate:    era.StartDate,
+			EndDate:      era.EndDate,
+			PlaylistID:   era.PlaylistID,
+			TrackIDs:     ids,
+			Cohesion:     era.Cohesion,
+			Silhouette:   era.Silhouette,
+			Reasons:      reasons,
+		}
+		if era.ParentID != nil {
+			parentID := era.ParentID.String()
+			archived.ParentID = &parentID
+		}
+		for _, w := range era.TagWeights {
+			archived.TagWeights = append(archived.TagWeights, TagWeight{Name: w.Name, Weight: w.Weight})
+		}
+		archive.Eras = append(archive.Eras, archived)
+	}
+
+	trackIDs := make([]string, 0, len(tracks))
+	for id := range tracks {
+		trackIDs = append(trackIDs, id)
+	}
+	sort.Strings(trackIDs)
+
+	artists, err := s.store.GetArtists(ctx, trackIDs)
+	if err != nil {
+		return nil, fmt.Errorf("getting artists: %w", err)
+	}
+	tags, err := s.store.GetTags(ctx, trackIDs)
+	if err != nil {
+		return nil, fmt.Errorf("getting tags: %w", err)
+	}
+	userTags, err := s.store.GetUserTags(ctx, userID, trackIDs)
+	if err != nil {
+		return nil, fmt.Errorf("getting user tags: %w", err)
+	}
+
+	for _, id := range trackIDs {
+		t := tracks[id]
+		track := Track{
+			ID:         t.ID,
+			Name:       t.Name,
+			Artist:     t.Artist,
+			Album:      t.Album,
+			AlbumID:    t.AlbumID,
+			DurationMs: t.DurationMs,
+		}
+		for _, a := range artists[id] {
+			track.Artists = append(track.Artists, Artist{ID: a.ID, Name: a.Name})
+		}
+		archive.Tracks = append(archive.Tracks, track)
+
+		for _, tag := range tags[id] {
+			archive.Tags = append(archive.Tags, Tag{
+				TrackID:   id,
+				Name:      tag.TagName,
+				Count:     tag.TagCount,
+				Source:    tag.Source,
+				FetchedAt: tag.FetchedAt,
+			})
+		}
+		for _, tag := range userTags[id] {
+			archive.UserTags = append(archive.UserTags, UserTag{
+				TrackID:   id,
+				Name:      tag.TagName,
+				CreatedAt: tag.CreatedAt,
+			})
+		}
+	}
+
+	sort.Slice(archive.Likes, func(i, j int) bool {
+		return archive.Likes[i].TrackID < archive.Likes[j].TrackID
+	})
+
+	plays, err := s.store.GetPlays(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("getting plays: %w", err)
+	}
+	for _, p := range plays {
+		archive.Plays = append(archive.Plays, Play{
+			TrackID:    p.TrackID,
+			ArtistName: p.ArtistName,
+			TrackName:  p.TrackName,
+			PlayedAt:   p.PlayedAt,
+			MsPlayed:   p.MsPlayed,
+			Source:     p.Source,
+		})
+	}
+
+	return archive, nil
+}
+
+// RestoreResult contains counts of restored records.
+type RestoreResult struct {
+	UserID   string `json:"user_id"`
+	Tracks   int    `json:"tracks"`
+	Likes    int    `json:"likes"`
+	Tags     int    `json:"tags"`
+	UserTags int    `json:"user_tags"`
+	Plays    int    `json:"plays"`
+	Eras     int    `json:"eras"`
+}
+
+// Restore writes an archive into the store under the archive's user ID.
+//
+// Restoring is idempotent: tracks, likes and tags are upserted, plays
+// already recorded are skipped, and the user's eras are replaced by the
+// archived ones, keeping their IDs.
+func (s *Service) Restore(ctx context.Context, archive *Archive) (*RestoreResult, error) {
+	if err := archive.Validate(); err != nil {
+		return nil, err
+	}
+	userID := archive.User.ID
+
+	eras := make([]db.Era, len(archive.Eras))
+	eraTracks := make([][]db.EraTrack, len(archive.Eras))
+	for i, e := range archive.Eras {
+		id, err := uuid.Parse(e.ID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid era ID %q: %w", e.ID, err)
+		}
+		eras[i] = db.Era{
+			ID:           id,
+			UserID:       userID,
+			Name:         e.Name,
+			NameOverride: e.NameOverride,
+			TopTags:      e.TopTags,
+			StartDate:    e.StartDate,
+			EndDate:      e.EndDate,
+			PlaylistID:   e.PlaylistID,
+			Cohesion:     e.Cohesion,
+			Silhouette:   e.Silhouette,
+		}
+		if e.ParentID != nil {
+			parentID, err := uuid.Parse(*e.ParentID)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parent era ID %q: %w", *e.ParentID, err)
+			}
+			eras[i].ParentID = &parentID
+		}
+		for _, w := range e.TagWeights {
+			eras[i].TagWeights = append(eras[i].TagWeights, db.EraTagWeight{Name: w.Name, Weight: w.Weight})
+		}
+		for _, id := range e.TrackIDs {
+			eraTracks[i] = append(eraTracks[i], db.EraTrack{TrackID: id, ReasonTags: e.Reasons[id]})
+		}
+	}
+
+	if err := s.store.SaveUser(ctx, &db.User{
+		ID:          userID,
+		DisplayName: archive.User.DisplayName,
+		Email:       archive.User.Email,
+		LastSyncAt:  archive.User.LastSyncAt,
+	}); err != nil {
+		return nil, fmt.Errorf("restoring user: %w", err)
+	}
+
+	if st := archive.Settings; st != nil {
+		if err := s.store.SaveSettings(ctx, &db.UserSettings{
+			UserID:          userID,
+			NumClusters:     st.NumClusters,
+			MinClusterSize:  st.MinClusterSize,
+			MaxTags:         st.MaxTags,
+			ManualTagWeight: st.ManualTagWeight,
+			Basis:           st.Basis,
+			TimeWeight:      st.TimeWeight,
+			NameTemplate:    st.NameTemplate,
+		}); err != nil {
+			return nil, fmt.Errorf("restoring settings: %w", err)
+		}
+	}
+
+	tracks := make([]db.Track, len(archive.Tracks))
+	var artists []db.Artist
+	var credits []db.TrackArtist
+	seenArtists := make(map[string]bool)
+	for i, t := range archive.Tracks {
+		tracks[i] = db.Track{
+			ID:         t.ID,
+			Name:       t.Name,
+			Artist:     t.Artist,
+			Album:      t.Album,
+			AlbumID:    t.AlbumID,
+			DurationMs: t.DurationMs,
+		}
+		for pos, a := range t.Artists {
+			if !seenArtists[a.ID] {
+				seenArtists[a.ID] = true
+				artists = append(artists, db.Artist{ID: a.ID, Name: a.Name})
+			}
+			credits = append(credits, db.TrackArtist{TrackID: t.ID, ArtistID: a.ID, Position: pos})
+		}
+	}
+	if err := s.store.SaveTracks(ctx, tracks, artists, credits); err != nil {
+		return nil, fmt.Errorf("restoring tracks: %w", err)
+	}
+
+	likes := make([]db.UserTrack, len(archive.Likes))
+	for i, l := range archive.Likes {
+		likes[i] = db.UserTrack{UserID: userID, TrackID: l.TrackID, AddedAt: l.AddedAt}
+	}
+	if err := s.store.SaveLikes(ctx, userID, likes); err != nil {
+		return nil, fmt.Errorf("restoring likes: %w", err)
+	}
+
+	tags := make([]db.TrackTag, len(archive.Tags))
+	for i, t := range archive.Tags {
+		tags[i] = db.TrackTag{
+			TrackID:   t.TrackID,
+			TagName:   t.Name,
+			TagCount:  t.Count,
+			Source:    t.Source,
+			FetchedAt: t.FetchedAt,
+		}
+	}
+	if err := s.store.SaveTags(ctx, tags); err != nil {
+		return nil, fmt.Errorf("restoring tags: %w", err)
+	}
+
+	userTags := make([]db.UserTrackTag, len(archive.UserTags))
+	for i, t := range archive.UserTags {
+		userTags[i] = db.UserTrackTag{UserID: userID, TrackID: t.TrackID, TagName: t.Name, CreatedAt: t.CreatedAt}
+	}
+	if err := s.store.SaveUserTags(ctx, userID, userTags); err != nil {
+		return nil, fmt.Errorf("restoring user tags: %w", err)
+	}
+
+	plays := make([]db.Play, len(archive.Plays))
+	for i, p := range archive.Plays {
+		plays[i] = db.Play{
+			UserID:     userID,
+			TrackID:    p.TrackID,
+			ArtistName: p.ArtistName,
+			TrackName:  p.TrackName,
+			PlayedAt:   p.PlayedAt,
+			MsPlayed:   p.MsPlayed,
+			Source:     p.Source,
+		}
+	}
+	for start := 0; start < len(plays); start += playBatchSize {
+		end := min(start+playBatchSize, len(plays))
+		if err := s.store.SavePlays(ctx, userID, plays[start:end]); err != nil {
+			return nil, fmt.Errorf("restoring plays: %w", err)
+		}
+	}
+
+	if err := s.store.ReplaceEras(ctx, userID, eras, eraTracks); err != nil {
+		return nil, fmt.Errorf("restoring eras: %w", err)
+	}
+
+	return &RestoreResult{
+		UserID:   userID,
+		Tracks:   len(tracks),
+		Likes:    len(likes),
+		Tags:     len(tags),
+		UserTags: len(userTags),
+		Plays:    len(plays),
+		Eras:     len(eras),
+	}, nil
+}
+
+// playBatchSize limits the number of plays written per query.
+const playBatchSize = 5000
@@ -0,0 +1,372 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/db/dbtest"
+)
+
+// memStore is an in-memory Store for a single user.
+type memStore struct {
+	user     *db.User
+	settings *db.UserSettings
+	tracks   map[string]db.Track
+	artists  map[string][]db.Artist
+	likes    map[string]time.Time
+	tags     map[string][]db.TrackTag
+	userTags map[string][]db.UserTrackTag
+	plays    []db.Play
+	eras     []db.Era
+	eraTrack map[uuid.UUID][]string
+	reasons  map[uuid.UUID]map[string][]string
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		tracks:   make(map[string]db.Track),
+		artists:  make(map[string][]db.Artist),
+		likes:    make(map[string]time.Time),
+		tags:     make(map[string][]db.TrackTag),
+		userTags: make(map[string][]db.UserTrackTag),
+		eraTrack: make(map[uuid.UUID][]string),
+		reasons:  make(map[uuid.UUID]map[string][]string),
+	}
+}
+
+func (m *memStore) GetUser(_ context.Context, userID string) (*db.User, error) {
+	if m.user == nil || m.user.ID != userID {
+		return nil, db.ErrNotFound
+	}
+	u := *m.user
+	return &u, nil
+}
+
+func (m *memStore) GetSettings(_ context.Context, _ string) (*db.UserSettings, error) {
+	if m.settings == nil {
+		return nil, db.ErrNotFound
+	}
+	return m.settings, nil
+}
+
+func (m *memStore) GetLikes(_ context.Context, userID string) ([]db.UserTrack, []db.Track, error) {
+	var likes []db.UserTrack
+	var tracks []db.Track
+	for id, addedAt := range m.likes {
+		likes = append(likes, db.UserTrack{UserID: userID, TrackID: id, AddedAt: addedAt})
+		tracks = append(tracks, m.tracks[id])
+	}
+	return likes, tracks, nil
+}
+
+func (m *memStore) GetArtists(_ context.Context, trackIDs []string) (map[string][]db.Artist, error) {
+	result := make(map[string][]db.Artist)
+	for _, id := range trackIDs {
+		if a, ok := m.artists[id]; ok {
+			result[id] = a
+		}
+	}
+	return result, nil
+}
+
+func (m *memStore) GetTags(_ context.Context, trackIDs []string) (map[string][]db.TrackTag, error) {
+	result := make(map[string][]db.TrackTag)
+	for _, id := range trackIDs {
+		if t, ok := m.tags[id]; ok {
+			result[id] = t
+		}
+	}
+	return result, nil
+}
+
+func (m *memStore) GetUserTags(_ context.Context, _ string, trackIDs []string) (map[string][]db.UserTrackTag, error) {
+	result := make(map[string][]db.UserTrackTag)
+	for _, id := range trackIDs {
+		if t, ok := m.userTags[id]; ok {
+			result[id] = t
+		}
+	}
+	return result, nil
+}
+
+func (m *memStore) GetPlays(_ context.Context, _ string) ([]db.Play, error) {
+	return m.plays, nil
+}
+
+func (m *memStore) GetEras(_ context.Context, _ string) ([]db.Era, error) {
+	return m.eras, nil
+}
+
+func (m *memStore) GetEraTracks(_ context.Context, _ string, eraID uuid.UUID) ([]db.Track, error) {
+	var tracks []db.Track
+	for _, id := range m.eraTrack[eraID] {
+		tracks = append(tracks, m.tracks[id])
+	}
+	return tracks, nil
+}
+
+func (m *memStore) GetEraReasons(_ context.Context, _ string, eraID uuid.UUID) (map[string][]string, error) {
+	return m.reasons[eraID], nil
+}
+
+func (m *memStore) SaveUser(_ context.Context, user *db.User) error {
+	u := *user
+	m.user = &u
+	return nil
+}
+
+func (m *memStore) SaveSettings(_ context.Context, settings *db.UserSettings) error {
+	m.settings = settings
+	return nil
+}
+
+func (m *memStore) SaveTracks(_ context.Context, tracks []db.Track, artists []db.Artist, credits []db.TrackArtist) error {
+	for _, t := range tracks {
+		m.tracks[t.ID] = t
+	}
+	byID := make(map[string]db.Artist)
+	for _, a := range artists {
+		byID[a.ID] = a
+	}
+	sort.SliceStable(credits, func(i, j int) bool { return credits[i].Position < credits[j].Position })
+	m.artists = make(map[string][]db.Artist)
+	for _, c := range credits {
+		m.artists[c.TrackID] = append(m.artists[c.TrackID], byID[c.ArtistID])
+	}
+	return nil
+}
+
+func (m *memStore) SaveLikes(_ context.Context, _ string, likes []db.UserTrack) error {
+	for _, l := range likes {
+		m.likes[l.TrackID] = l.AddedAt
+	}
+	return nil
+}
+
+func (m *memStore) SaveTags(_ context.Context, tags []db.TrackTag) error {
+	m.tags = make(map[string][]db.TrackTag)
+	for _, t := range tags {
+		m.tags[t.TrackID] = append(m.tags[t.TrackID], t)
+	}
+	return nil
+}
+
+func (m *memStore) SaveUserTags(_ context.Context, _ string, tags []db.UserTrackTag) error {
+	m.userTags = make(map[string][]db.UserTrackTag)
+	for _, t := range tags {
+		m.userTags[t.TrackID] = append(m.userTags[t.TrackID], t)
+	}
+	return nil
+}
+
+func (m *memStore) SavePlays(_ context.Context, _ string, plays []db.Play) error {
+	m.plays = append(m.plays, plays...)
+	return nil
+}
+
+func (m *memStore) ReplaceEras(_ context.Context, _ string, eras []db.Era, tracks [][]db.EraTrack) error {
+	m.eras = eras
+	m.eraTrack = make(map[uuid.UUID][]string)
+	m.reasons = make(map[uuid.UUID]map[string][]string)
+	for i, era := range eras {
+		m.reasons[era.ID] = make(map[string][]string)
+		for _, t := range tracks[i] {
+			m.eraTrack[era.ID] = append(m.eraTrack[era.ID], t.TrackID)
+			if len(t.ReasonTags) > 0 {
+				m.reasons[era.ID][t.TrackID] = t.ReasonTags
+			}
+		}
+	}
+	return nil
+}
+
+func seededStore() *memStore {
+	album := "Kid A"
+	duration := 300000
+	playlist := "pl1"
+	lastSync := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	eraID := uuid.MustParse("33333333-3333-3333-3333-333333333333")
+	trackID := "t1"
+
+	m := newMemStore()
+	clusters := 5
+	basis := "plays"
+	m.settings = &db.UserSettings{UserID: "user1", NumClusters: &clusters, Basis: &basis}
+	m.user = &db.User{ID: "user1", DisplayName: "User One", Email: "one@example.com", LastSyncAt: &lastSync}
+	m.tracks["t1"] = db.Track{ID: "t1", Name: "Idioteque", Artist: "Radiohead", Album: &album, DurationMs: &duration}
+	m.tracks["t2"] = db.Track{ID: "t2", Name: "Unliked", Artist: "Someone"} // Only in an era
+	m.artists["t1"] = []db.Artist{{ID: "a1", Name: "Radiohead"}}
+	m.likes["t1"] = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	m.tags["t1"] = []db.TrackTag{{TrackID: "t1", TagName: "electronic", TagCount: 100, Source: "track", FetchedAt: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)}}
+	m.userTags["t1"] = []db.UserTrackTag{{UserID: "user1", TrackID: "t1", TagName: "late night", CreatedAt: time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC)}}
+	m.plays = []db.Play{{UserID: "user1", TrackID: &trackID, ArtistName: "Radiohead", TrackName: "Idioteque", PlayedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), MsPlayed: 120000, Source: db.PlaySourceSpotify}}
+	cohesion, silhouette := 0.25, 0.75
+	m.eras = []db.Era{{ID: eraID, UserID: "user1", Name: "Electronic", TopTags: []string{"electronic"}, StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), PlaylistID: &playlist,
+		Cohesion: &cohesion, Silhouette: &silhouette, TagWeights: []db.EraTagWeight{{Name: "electronic", Weight: 0.9}}}}
+	m.eraTrack[eraID] = []string{"t2", "t1"}
+	m.reasons[eraID] = map[string][]string{"t1": {"electronic"}}
+	subEraID := uuid.MustParse("44444444-4444-4444-4444-444444444444")
+	override := "Glitch phase"
+	m.eras = append(m.eras, db.Era{ID: subEraID, UserID: "user1", Name: "Glitch", NameOverride: &override, TopTags: []string{"glitch"}, StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), ParentID: &eraID})
+	m.eraTrack[subEraID] = []string{"t1"}
+	return m
+}
+
+func TestBackupRestore_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	clock := func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	source := New(seededStore())
+	source.now = clock
+	archive, err := source.Backup(ctx, "user1")
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+
+	if archive.Settings == nil || *archive.Settings.NumClusters != 5 || *archive.Settings.Basis != "plays" {
+		t.Errorf("Settings = %+v, want stored settings", archive.Settings)
+	}
+	if len(archive.Tracks) != 2 || archive.Tracks[0].ID != "t1" || archive.Tracks[1].ID != "t2" {
+		t.Errorf("Tracks = %+v, want library and era tracks sorted by ID", archive.Tracks)
+	}
+	if got := archive.Eras[0].TrackIDs; !reflect.DeepEqual(got, []string{"t1", "t2"}) {
+		t.Errorf("era TrackIDs = %v, want sorted [t1 t2]", got)
+	}
+	if era := archive.Eras[0]; era.Cohesion == nil || len(era.TagWeights) != 1 || len(era.Reasons["t1"]) != 1 {
+		t.Errorf("era metrics = %+v, want stored cohesion, tag weights and reasons", era)
+	}
+	if parent := archive.Eras[1].ParentID; parent == nil || *parent != archive.Eras[0].ID {
+		t.Errorf("sub-era ParentID = %v, want %s", parent, archive.Eras[0].ID)
+	}
+	if name := archive.Eras[1].NameOverride; name == nil || *name != "Glitch phase" {
+		t.Errorf("sub-era NameOverride = %v, want Glitch phase", name)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, archive); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(strings.ToLower(buf.String()), "token") {
+		t.Error("backup must not contain OAuth tokens")
+	}
+
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	target := newMemStore()
+	result, err := New(target).Restore(ctx, read)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if result.Tracks != 2 || result.Likes != 1 || result.Tags != 1 || result.UserTags != 1 || result.Plays != 1 || result.Eras != 2 {
+		t.Errorf("Restore() result = %+v", result)
+	}
+
+	restored := New(target)
+	restored.now = clock
+	again, err := restored.Backup(ctx, "user1")
+	if err != nil {
+		t.Fatalf("Backup() of restored store error = %v", err)
+	}
+	if !reflect.DeepEqual(normalize(t, archive), normalize(t, again)) {
+		t.Errorf("restored backup differs:\n got %+v\nwant %+v", again, archive)
+	}
+}
+
+// normalize round-trips an archive through JSON so times compare equal.
+func normalize(t *testing.T, a *Archive) *Archive {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, a); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestRead_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{"wrong format", `{"format":"other","version":1,"user":{"id":"u"}}`, "not a backup"},
+		{"newer version", `{"format":"spotify-era-organizer-backup","version":99,"user":{"id":"u"}}`, "version"},
+		{"missing user", `{"format":"spotify-era-organizer-backup","version":1,"user":{}}`, "user ID"},
+		{"dangling like", `{"format":"spotify-era-organizer-backup","version":1,"user":{"id":"u"},"likes":[{"track_id":"x","added_at":"2024-01-01T00:00:00Z"}]}`, "unknown track"},
+		{"dangling era track", `{"format":"spotify-era-organizer-backup","version":1,"user":{"id":"u"},"eras":[{"id":"33333333-3333-3333-3333-333333333333","track_ids":["x"]}]}`, "unknown track"},
+		{"sub-era before parent", `{"format":"spotify-era-organizer-backup","version":1,"user":{"id":"u"},"eras":[{"id":"44444444-4444-4444-4444-444444444444","parent_id":"33333333-3333-3333-3333-333333333333","track_ids":[]},{"id":"33333333-3333-3333-3333-333333333333","track_ids":[]}]}`, "isn't listed before it"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.json))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Read() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	_, err := Read(strings.NewReader(tests[1].json))
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Read(newer) error = %v, want ErrUnsupportedVersion", err)
+	}
+}
+
+func TestBackup_UnknownUser(t *testing.T) {
+	_, err := New(newMemStore()).Backup(context.Background(), "nobody")
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("Backup() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRestore_FailedErasKeepCurrentOnes(t *testing.T) {
+	ctx := context.Background()
+	database := dbtest.NewSQLite(t)
+	current := db.Era{UserID: "alice", Name: "Current"}
+	taken := db.Era{UserID: "bob", Name: "Bob's"}
+	for _, era := range []*db.Era{&current, &taken} {
+		if err := database.Users().EnsureExists(ctx, era.UserID); err != nil {
+			t.Fatal(err)
+		}
+		if err := database.Eras().Create(ctx, era, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The second era's ID is already bob's, so creating it fails
+	archive := &Archive{
+		Format:  Format,
+		Version: Version,
+		User:    User{ID: "alice"},
+		Eras: []Era{
+			{ID: uuid.NewString(), Name: "Restored", TrackIDs: []string{}},
+			{ID: taken.ID.String(), Name: "Clash", TrackIDs: []string{}},
+		},
+	}
+	if _, err := New(NewDBStore(database)).Restore(ctx, archive); err == nil {
+		t.Fatal("Restore() with a clashing era ID succeeded, want an error")
+	}
+
+	eras, err := database.Eras().GetForUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eras) != 1 || eras[0].ID != current.ID {
+		t.Errorf("eras after a failed restore = %+v, want only the current era", eras)
+	}
+}
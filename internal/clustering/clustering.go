@@ -11,11 +11,13 @@ type Track struct {
 	Name    string
 	Artist  string
 	AddedAt time.Time
-	Tags    []Tag // Tags from Last.fm (empty if not fetched or unavailable)
+	Tags    []Tag       // Tags from Last.fm and the user (empty if none are available)
+	Plays   []time.Time // When the track was played (empty if no listening history)
 }
 
 // Tag represents a music tag with popularity count.
 type Tag struct {
-	Name  string
-	Count int
+	Name   string
+	Count  int
+	Manual bool // Assigned by the user rather than fetched from Last.fm
 }
@@ -0,0 +1,252 @@
+package clustering
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultNameTemplate names eras like "Summer 2023: shoegaze & dream pop".
+const DefaultNameTemplate = "{period}: {tags}"
+
+// MaxNameTemplateLength is the longest name template accepted.
+const MaxNameTemplateLength = 100
+
+// NamePlaceholders describes the placeholders available in name templates.
+var NamePlaceholders = []struct{ Name, Description string }{
+	{"period", `When the era happened: "Summer 2023", "Spring–Summer 2023", "2023" or "2021–2023"`},
+	{"season", "Season in the middle of the era (northern hemisphere)"},
+	{"year", "Year in the middle of the era"},
+	{"dates", `Exact date range: "Jan 15, 2024 - Feb 3, 2024"`},
+	{"tags", "The era's two most distinctive tags, or its top artists when tags are weak"},
+	{"tag1", "Most distinctive tag (or top artist)"},
+	{"tag2", "Second most distinctive tag (or artist)"},
+	{"tag3", "Third most distinctive tag (or artist)"},
+	{"artist", "Artist with the most tracks in the era"},
+}
+
+// minNameTagWeight is the centroid weight below which a tag is too weak to
+// name an era. An era without a tag this strong is named after its artists.
+const minNameTagWeight = 0.25
+
+// nameTagCount is the number of tags or artists {tags} joins.
+const nameTagCount = 2
+
+var placeholderPattern = regexp.MustCompile(`\{([a-z0-9]+)\}`)
+
+// ValidateNameTemplate checks that a name template only uses known
+// placeholders and has at least one, so eras get distinct names.
+func ValidateNameTemplate(tmpl string) error {
+	if len(tmpl) > MaxNameTemplateLength {
+		return fmt.Errorf("must be at most %d characters", MaxNameTemplateLength)
+	}
+
+	known := make(map[string]bool, len(NamePlaceholders))
+	for _, p := range NamePlaceholders {
+		known[p.Name] = true
+	}
+	matches := placeholderPattern.FindAllStringSubmatch(tmpl, -1)
+	if len(matches) == 0 {
+		return errors.New("must use at least one placeholder, such as {period} or {tags}")
+	}
+	for _, m := range matches {
+		if !known[m[1]] {
+			return fmt.Errorf("unknown placeholder {%s}", m[1])
+		}
+	}
+	if rest := placeholderPattern.ReplaceAllString(tmpl, ""); strings.ContainsAny(rest, "{}") {
+		return errors.New("has an unmatched { or }")
+	}
+	return nil
+}
+
+// nameEras names each era from the template, or DefaultNameTemplate if it
+// is empty. Tags are chosen for how much they set an era apart from the
+// others, so all eras must be named together. Duplicate names are numbered,
+// oldest era first.
+func nameEras(eras []MoodEra, tmpl string) {
+	if tmpl == "" {
+		tmpl = DefaultNameTemplate
+	}
+
+	// Mean weight of each tag across all eras, for distinctiveness
+	totals := make(map[string]float64)
+	for _, era := range eras {
+		for _, w := range era.Metrics.TagWeights {
+			totals[w.Name] += w.Weight
+		}
+	}
+
+	for i := range eras {
+		eras[i].Name = renderName(tmpl, nameValues(eras[i], totals, len(eras)))
+	}
+
+	// Eras are sorted newest first; number repeats from the oldest
+	seen := make(map[string]int)
+	for i := len(eras) - 1; i >= 0; i-- {
+		name := eras[i].Name
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			eras[i].Name = fmt.Sprintf("%s (%d)", name, n)
+		}
+	}
+}
+
+// nameValues returns the placeholder values for an era. totals holds each
+// tag's summed weight over all numEras eras.
+func nameValues(era MoodEra, totals map[string]float64, numEras int) map[string]string {
+	artists := topArtists(era.Tracks, 3)
+	labels := distinctiveTags(era, totals, numEras, 3)
+	if len(labels) == 0 {
+		labels = artists
+	}
+
+	values := map[string]string{
+		"period": periodName(era.StartDate, era.EndDate),
+		"dates":  formatDateRange(era.StartDate, era.EndDate),
+		"tags":   "Mixed",
+	}
+	mid := era.StartDate.Add(era.EndDate.Sub(era.StartDate) / 2)
+	values["season"], _ = season(mid)
+	values["year"] = mid.Format("2006")
+	if len(labels) > 0 {
+		values["tags"] = strings.Join(labels[:min(nameTagCount, len(labels))], " & ")
+	}
+	for i, label := range labels {
+		values[fmt.Sprintf("tag%d", i+1)] = label
+	}
+	if len(artists) > 0 {
+		values["artist"] = artists[0]
+	}
+	return values
+}
+
+// distinctiveTags returns up to n of the era's tags, ranked by how much
+// heavier they are in this era than on average in the others. Tags below
+// minNameTagWeight are skipped, so a weakly tagged era may get none.
+func distinctiveTags(era MoodEra, totals map[string]float64, numEras, n int) []string {
+	weights := era.Metrics.TagWeights
+	if len(weights) == 0 {
+		// Eras built without metrics only have their top tags
+		for _, tag := range era.TopTags {
+			weights = append(weights, TagWeight{Name: tag, Weight: 1})
+		}
+	}
+
+	type scored struct {
+		name  string
+		score float64
+	}
+	var candidates []scored
+	for _, w := range weights {
+		if w.Weight < minNameTagWeight {
+			continue
+		}
+		score := w.Weight
+		if numEras > 1 {
+			score -= (totals[w.Name] - w.Weight) / float64(numEras-1)
+		}
+		if score > 0 {
+			candidates = append(candidates, scored{name: w.Name, score: score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	result := make([]string, 0, min(n, len(candidates)))
+	for i := 0; i < len(candidates) && i < n; i++ {
+		result = append(result, candidates[i].name)
+	}
+	return result
+}
+
+// topArtists returns up to n artists with the most tracks, ties broken by name.
+func topArtists(tracks []Track, n int) []string {
+	counts := make(map[string]int)
+	for _, t := range tracks {
+		if t.Artist != "" {
+			counts[t.Artist]++
+		}
+	}
+	artists := make([]string, 0, len(counts))
+	for name := range counts {
+		artists = append(artists, name)
+	}
+	sort.Slice(artists, func(i, j int) bool {
+		if counts[artists[i]] != counts[artists[j]] {
+			return counts[artists[i]] > counts[artists[j]]
+		}
+		return artists[i] < artists[j]
+	})
+	return artists[:min(n, len(artists))]
+}
+
+// renderName fills in a template's placeholders. Separators left dangling
+// by empty placeholders are trimmed.
+func renderName(tmpl string, values map[string]string) string {
+	name := placeholderPattern.ReplaceAllStringFunc(tmpl, func(m string) string {
+		return values[m[1:len(m)-1]]
+	})
+	name = strings.Join(strings.Fields(name), " ")
+	name = strings.Trim(name, " -–—:&·,|/")
+	if name == "" {
+		return values["dates"]
+	}
+	return name
+}
+
+// seasons are the meteorological seasons of the northern hemisphere,
+// indexed by month-1.
+var seasons = [12]string{
+	"Winter", "Winter", "Spring", "Spring", "Spring", "Summer",
+	"Summer", "Summer", "Autumn", "Autumn", "Autumn", "Winter",
+}
+
+// season returns the season of t and an index that increases by one from
+// each season to the next. December belongs to the following year's winter.
+func season(t time.Time) (string, int) {
+	month := int(t.Month())
+	year := t.Year()
+	if month == 12 {
+		year++
+	}
+	return seasons[month-1], year*4 + (month%12)/3
+}
+
+// periodName describes a date range as a season, a pair of adjacent
+// seasons, a year or a range of years.
+func periodName(start, end time.Time) string {
+	startSeason, startIdx := season(start)
+	endSeason, endIdx := season(end)
+	seasonYear := func(idx int) int { return idx / 4 }
+
+	switch {
+	case startIdx == endIdx:
+		return fmt.Sprintf("%s %d", startSeason, seasonYear(startIdx))
+	case endIdx == startIdx+1 && seasonYear(startIdx) == seasonYear(endIdx):
+		return fmt.Sprintf("%s–%s %d", startSeason, endSeason, seasonYear(endIdx))
+	case endIdx == startIdx+1:
+		return fmt.Sprintf("%s %d–%s %d", startSeason, start.Year(), endSeason, seasonYear(endIdx))
+	case start.Year() == end.Year():
+		return start.Format("2006")
+	default:
+		return fmt.Sprintf("%d–%d", start.Year(), end.Year())
+	}
+}
+
+// formatDateRange formats a date range as "Jan 15, 2024 - Feb 3, 2024", or
+// a single date if both fall on the same day.
+func formatDateRange(start, end time.Time) string {
+	const dateFormat = "Jan 2, 2006"
+
+	startStr := start.Format(dateFormat)
+	endStr := end.Format(dateFormat)
+	if startStr == endStr {
+		return startStr
+	}
+	return startStr + " - " + endStr
+}
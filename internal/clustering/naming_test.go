@@ -0,0 +1,183 @@
+package clustering
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(year, month, day int) time.Time {
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestFormatDateRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       string
+	}{
+		{"range", date(2024, 1, 15), date(2024, 2, 3), "Jan 15, 2024 - Feb 3, 2024"},
+		{"same day", date(2024, 3, 10), date(2024, 3, 10), "Mar 10, 2024"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatDateRange(tt.start, tt.end); got != tt.want {
+				t.Errorf("formatDateRange() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodName(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       string
+	}{
+		{"one season", date(2023, 6, 1), date(2023, 8, 31), "Summer 2023"},
+		{"winter spans new year", date(2023, 12, 5), date(2024, 2, 10), "Winter 2024"},
+		{"adjacent seasons", date(2023, 4, 1), date(2023, 7, 15), "Spring–Summer 2023"},
+		{"adjacent seasons across years", date(2023, 10, 1), date(2023, 12, 20), "Autumn 2023–Winter 2024"},
+		{"same year", date(2023, 2, 1), date(2023, 9, 1), "2023"},
+		{"several years", date(2021, 5, 1), date(2023, 1, 1), "2021–2023"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := periodName(tt.start, tt.end); got != tt.want {
+				t.Errorf("periodName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateNameTemplate(t *testing.T) {
+	tests := []struct {
+		tmpl    string
+		wantErr string
+	}{
+		{tmpl: DefaultNameTemplate},
+		{tmpl: "{season} {year} – {tag1}"},
+		{tmpl: "My era", wantErr: "at least one placeholder"},
+		{tmpl: "{period} {mood}", wantErr: "unknown placeholder {mood}"},
+		{tmpl: "{period} {tags", wantErr: "unmatched"},
+		{tmpl: "{period}" + strings.Repeat("x", MaxNameTemplateLength), wantErr: "at most"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			err := ValidateNameTemplate(tt.tmpl)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateNameTemplate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateNameTemplate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNameEras_DistinctiveTags(t *testing.T) {
+	// "indie" is heavy in both eras, so it doesn't tell them apart
+	eras := []MoodEra{
+		{
+			StartDate: date(2023, 6, 1),
+			EndDate:   date(2023, 8, 1),
+			Metrics: EraMetrics{TagWeights: []TagWeight{
+				{Name: "indie", Weight: 0.9}, {Name: "shoegaze", Weight: 0.7}, {Name: "dream pop", Weight: 0.5},
+			}},
+		},
+		{
+			StartDate: date(2022, 1, 1),
+			EndDate:   date(2022, 11, 1),
+			Metrics: EraMetrics{TagWeights: []TagWeight{
+				{Name: "indie", Weight: 0.9}, {Name: "folk", Weight: 0.6},
+			}},
+		},
+	}
+
+	nameEras(eras, "")
+
+	want := []string{"Summer 2023: shoegaze & dream pop", "2022: folk"}
+	for i, era := range eras {
+		if era.Name != want[i] {
+			t.Errorf("eras[%d].Name = %q, want %q", i, era.Name, want[i])
+		}
+	}
+}
+
+func TestNameEras_ArtistFallback(t *testing.T) {
+	eras := []MoodEra{{
+		StartDate: date(2024, 3, 1),
+		EndDate:   date(2024, 4, 1),
+		Tracks: []Track{
+			{ID: "1", Artist: "Bjork"},
+			{ID: "2", Artist: "Air"},
+			{ID: "3", Artist: "Bjork"},
+		},
+		Metrics: EraMetrics{TagWeights: []TagWeight{{Name: "electronic", Weight: 0.1}}},
+	}}
+
+	nameEras(eras, "{season} {year} – {tag1}")
+
+	if want := "Spring 2024 – Bjork"; eras[0].Name != want {
+		t.Errorf("Name = %q, want %q", eras[0].Name, want)
+	}
+}
+
+func TestNameEras_Template(t *testing.T) {
+	era := MoodEra{
+		StartDate: date(2024, 1, 15),
+		EndDate:   date(2024, 2, 3),
+		Tracks:    []Track{{ID: "1", Artist: "Air"}},
+		Metrics:   EraMetrics{TagWeights: []TagWeight{{Name: "rock", Weight: 0.8}}},
+	}
+
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"{tags}: {dates}", "rock: Jan 15, 2024 - Feb 3, 2024"},
+		{"{artist} ({year})", "Air (2024)"},
+		// Empty placeholders don't leave dangling separators
+		{"{tag1} & {tag2} – {tag3}", "rock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			eras := []MoodEra{era}
+			nameEras(eras, tt.tmpl)
+			if eras[0].Name != tt.want {
+				t.Errorf("Name = %q, want %q", eras[0].Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameEras_Duplicates(t *testing.T) {
+	// Sorted newest first; the oldest keeps the plain name
+	eras := []MoodEra{
+		{StartDate: date(2024, 7, 1), EndDate: date(2024, 7, 2)},
+		{StartDate: date(2024, 6, 1), EndDate: date(2024, 6, 2)},
+	}
+
+	nameEras(eras, "{period}")
+
+	got := []string{eras[0].Name, eras[1].Name}
+	want := []string{"Summer 2024 (2)", "Summer 2024"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("names = %v, want %v", got, want)
+	}
+}
+
+func TestTopArtists(t *testing.T) {
+	tracks := []Track{
+		{Artist: "B"}, {Artist: "A"}, {Artist: "C"}, {Artist: "C"}, {Artist: ""},
+	}
+	got := topArtists(tracks, 2)
+	want := []string{"C", "A"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("topArtists() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,205 @@
+package clustering
+
+import (
+	"slices"
+	"time"
+
+	"github.com/muesli/clusters"
+	"github.com/muesli/kmeans"
+)
+
+// Basis selects which timestamps eras are built from.
+type Basis string
+
+const (
+	// BasisLikes dates tracks by when they were liked (Track.AddedAt).
+	BasisLikes Basis = "likes"
+	// BasisPlays dates tracks by when they were played (Track.Plays).
+	BasisPlays Basis = "plays"
+)
+
+// ParseBasis parses a basis name, reporting whether it is valid.
+func ParseBasis(s string) (Basis, bool) {
+	switch b := Basis(s); b {
+	case BasisLikes, BasisPlays:
+		return b, true
+	}
+	return "", false
+}
+
+// playObservation is a single play of a track, implementing clusters.Observation.
+type playObservation struct {
+	track    *Track
+	playedAt time.Time
+	coords   clusters.Coordinates
+}
+
+func (o playObservation) Coordinates() clusters.Coordinates {
+	return o.coords
+}
+
+func (o playObservation) Distance(point clusters.Coordinates) float64 {
+	return o.coords.Distance(point)
+}
+
+// detectPlayEras clusters individual plays rather than tracks, so a track
+// weighs in proportion to how often it was played. Each play's vector is its
+// track's tag vector plus its position in the listening timeline, scaled by
+// cfg.TimeWeight, so eras describe what was played together in a period.
+// A track played in several periods can belong to several eras.
+// Tracks without tags or plays are treated as outliers.
+func detectPlayEras(tracks []Track, cfg TagClusterConfig) ([]MoodEra, []Track) {
+	var validTracks []*Track
+	var skipped []Track
+	numPlays := 0
+
+	for i := range tracks {
+		t := &tracks[i]
+		if len(t.Tags) > 0 && len(t.Plays) > 0 {
+			validTracks = append(validTracks, t)
+			numPlays += len(t.Plays)
+		} else {
+			skipped = append(skipped, *t)
+		}
+	}
+
+	allOutliers := func() []Track {
+		outliers := make([]Track, 0, len(tracks))
+		for _, t := range validTracks {
+			outliers = append(outliers, *t)
+		}
+		return append(outliers, skipped...)
+	}
+
+	if numPlays < cfg.NumClusters {
+		return nil, allOutliers()
+	}
+
+	vocabulary := buildTagVocabulary(validTracks, cfg.MaxTags)
+	if len(vocabulary) == 0 {
+		return nil, allOutliers()
+	}
+
+	// Find the listening timeline to normalize play times to 0-1
+	first, last := validTracks[0].Plays[0], validTracks[0].Plays[0]
+	for _, t := range validTracks {
+		for _, p := range t.Plays {
+			if p.Before(first) {
+				first = p
+			}
+			if p.After(last) {
+				last = p
+			}
+		}
+	}
+	span := last.Sub(first)
+
+	// One observation per play
+	obs := make(clusters.Observations, 0, numPlays)
+	for _, t := range validTracks {
+		tagVector := buildTagVector(t, vocabulary, cfg.ManualTagWeight)
+		for _, p := range t.Plays {
+			coords := make(clusters.Coordinates, len(tagVector)+1)
+			copy(coords, tagVector)
+			if span > 0 {
+				coords[len(tagVector)] = cfg.TimeWeight * float64(p.Sub(first)) / float64(span)
+			}
+			obs = append(obs, playObservation{track: t, playedAt: p, coords: coords})
+		}
+	}
+
+	km := kmeans.New()
+	result, err := km.Partition(obs, cfg.NumClusters)
+	if err != nil {
+		return nil, allOutliers()
+	}
+
+	members := make([][]clusters.Coordinates, len(result))
+	for c, cluster := range result {
+		for _, o := range cluster.Observations {
+			members[c] = append(members[c], o.Coordinates())
+		}
+	}
+	centers, cohesion, silhouette := partitionQuality(members)
+
+	var eras []MoodEra
+	inEra := make(map[string]bool)
+
+	for c, cluster := range result {
+		// Collect distinct tracks, ordered by their first play in this cluster
+		firstPlay := make(map[string]time.Time)
+		reasons := make(map[string][]string)
+		var clusterTracks []Track
+		var start, end time.Time
+		plays := 0
+
+		for _, o := range cluster.Observations {
+			po, ok := o.(playObservation)
+			if !ok {
+				continue
+			}
+			plays++
+			if start.IsZero() || po.playedAt.Before(start) {
+				start = po.playedAt
+			}
+			if po.playedAt.After(end) {
+				end = po.playedAt
+			}
+			prev, seen := firstPlay[po.track.ID]
+			if !seen {
+				clusterTracks = append(clusterTracks, *po.track)
+				reasons[po.track.ID] = closestTags(po.coords, centers[c], vocabulary, reasonTags)
+			}
+			if !seen || po.playedAt.Before(prev) {
+				firstPlay[po.track.ID] = po.playedAt
+			}
+		}
+
+		// Minimum size counts tracks, not plays
+		if len(clusterTracks) == 0 || len(clusterTracks) < cfg.MinClusterSize {
+			continue
+		}
+
+		slices.SortFunc(clusterTracks, func(a, b Track) int {
+			return firstPlay[a.ID].Compare(firstPlay[b.ID])
+		})
+		for _, t := range clusterTracks {
+			inEra[t.ID] = true
+		}
+
+		// Tags are averaged over plays; the time dimension past the
+		// vocabulary is ignored
+		topTags := extractTopTags(centers[c][:len(vocabulary)], vocabulary, 3)
+
+		eras = append(eras, MoodEra{
+			Tracks:    clusterTracks,
+			TopTags:   topTags,
+			StartDate: start,
+			EndDate:   end,
+			PlayCount: plays,
+			Metrics: EraMetrics{
+				Cohesion:   cohesion[c],
+				Silhouette: silhouette[c],
+				TagWeights: tagWeights(centers[c], vocabulary),
+			},
+			Reasons: reasons,
+		})
+	}
+
+	// Tracks whose plays all landed in undersized clusters are outliers
+	var outliers []Track
+	for _, t := range validTracks {
+		if !inEra[t.ID] {
+			outliers = append(outliers, *t)
+		}
+	}
+	outliers = append(outliers, skipped...)
+
+	// Sort eras by start date (most recent first)
+	slices.SortFunc(eras, func(a, b MoodEra) int {
+		return b.StartDate.Compare(a.StartDate)
+	})
+	nameEras(eras, cfg.NameTemplate)
+
+	return eras, outliers
+}
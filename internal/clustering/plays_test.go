@@ -0,0 +1,128 @@
+package clustering
+
+import (
+	"testing"
+	"time"
+)
+
+// playsAt returns n plays one day apart starting at start.
+func playsAt(start time.Time, n int) []time.Time {
+	plays := make([]time.Time, n)
+	for i := range plays {
+		plays[i] = start.AddDate(0, 0, i)
+	}
+	return plays
+}
+
+func TestParseBasis(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Basis
+		ok    bool
+	}{
+		{"likes", BasisLikes, true},
+		{"plays", BasisPlays, true},
+		{"", "", false},
+		{"Plays", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseBasis(tt.input)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseBasis(%q) = %q, %v; want %q, %v", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDetectMoodEras_PlaysWithoutHistoryAreOutliers(t *testing.T) {
+	tracks := []Track{
+		{ID: "1", Tags: []Tag{{Name: "rock", Count: 100}}},
+		{ID: "2", Tags: []Tag{{Name: "rock", Count: 90}}},
+		{ID: "3", Tags: []Tag{{Name: "rock", Count: 80}}},
+	}
+
+	cfg := DefaultTagClusterConfig()
+	cfg.Basis = BasisPlays
+	eras, outliers := DetectMoodEras(tracks, cfg)
+
+	if len(eras) != 0 {
+		t.Errorf("expected 0 eras, got %d", len(eras))
+	}
+	if len(outliers) != 3 {
+		t.Errorf("expected 3 outliers, got %d", len(outliers))
+	}
+}
+
+func TestDetectMoodEras_PlaysDateErasByListening(t *testing.T) {
+	liked := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	spring := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	autumn := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+	rock := []Tag{{Name: "rock", Count: 100}}
+
+	// Same tags, all liked on the same day, but played months apart.
+	tracks := []Track{
+		{ID: "s1", AddedAt: liked, Tags: rock, Plays: playsAt(spring, 5)},
+		{ID: "s2", AddedAt: liked, Tags: rock, Plays: playsAt(spring, 5)},
+		{ID: "s3", AddedAt: liked, Tags: rock, Plays: playsAt(spring, 5)},
+		{ID: "a1", AddedAt: liked, Tags: rock, Plays: playsAt(autumn, 5)},
+		{ID: "a2", AddedAt: liked, Tags: rock, Plays: playsAt(autumn, 5)},
+		{ID: "a3", AddedAt: liked, Tags: rock, Plays: playsAt(autumn, 5)},
+	}
+
+	cfg := TagClusterConfig{NumClusters: 2, MinClusterSize: 3, MaxTags: 50, Basis: BasisPlays}
+	eras, outliers := DetectMoodEras(tracks, cfg)
+
+	if len(eras) != 2 {
+		t.Fatalf("expected 2 eras, got %d", len(eras))
+	}
+	if len(outliers) != 0 {
+		t.Errorf("expected 0 outliers, got %d", len(outliers))
+	}
+
+	// Most recent first
+	if !eras[0].StartDate.Equal(autumn) || !eras[0].EndDate.Equal(autumn.AddDate(0, 0, 4)) {
+		t.Errorf("era 0 = %v - %v, want autumn plays", eras[0].StartDate, eras[0].EndDate)
+	}
+	if !eras[1].StartDate.Equal(spring) {
+		t.Errorf("era 1 starts %v, want %v", eras[1].StartDate, spring)
+	}
+	for _, era := range eras {
+		if era.PlayCount != 15 {
+			t.Errorf("PlayCount = %d, want 15", era.PlayCount)
+		}
+		if len(era.TopTags) != 1 || era.TopTags[0] != "rock" {
+			t.Errorf("TopTags = %v, want [rock]", era.TopTags)
+		}
+	}
+}
+
+func TestDetectMoodEras_PlaysWeightByPlayCount(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Three heavily played rock tracks and three jazz tracks played once:
+	// the centroid of a mixed period is dominated by what was played most.
+	tracks := []Track{
+		{ID: "r1", Tags: []Tag{{Name: "rock", Count: 100}}, Plays: playsAt(start, 20)},
+		{ID: "r2", Tags: []Tag{{Name: "rock", Count: 100}}, Plays: playsAt(start, 20)},
+		{ID: "r3", Tags: []Tag{{Name: "rock", Count: 100}}, Plays: playsAt(start, 20)},
+		{ID: "j1", Tags: []Tag{{Name: "jazz", Count: 100}}, Plays: playsAt(start, 1)},
+		{ID: "j2", Tags: []Tag{{Name: "jazz", Count: 100}}, Plays: playsAt(start, 1)},
+		{ID: "j3", Tags: []Tag{{Name: "jazz", Count: 100}}, Plays: playsAt(start, 1)},
+	}
+
+	cfg := TagClusterConfig{NumClusters: 1, MinClusterSize: 1, MaxTags: 50, Basis: BasisPlays}
+	eras, _ := DetectMoodEras(tracks, cfg)
+
+	if len(eras) != 1 {
+		t.Fatalf("expected 1 era, got %d", len(eras))
+	}
+	if eras[0].PlayCount != 63 {
+		t.Errorf("PlayCount = %d, want 63", eras[0].PlayCount)
+	}
+	if len(eras[0].Tracks) != 6 {
+		t.Errorf("expected 6 distinct tracks, got %d", len(eras[0].Tracks))
+	}
+	if eras[0].TopTags[0] != "rock" {
+		t.Errorf("TopTags[0] = %q, want rock", eras[0].TopTags[0])
+	}
+}
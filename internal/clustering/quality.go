@@ -0,0 +1,141 @@
+package clustering
+
+import (
+	"math"
+	"sort"
+
+	"github.com/muesli/clusters"
+)
+
+// reasonTags is the number of tags kept to explain a track's membership.
+const reasonTags = 3
+
+// TagWeight is a tag's weight in an era's centroid.
+type TagWeight struct {
+	Name   string
+	Weight float64
+}
+
+// EraMetrics describes how well an era's tracks fit together.
+type EraMetrics struct {
+	// Cohesion is the mean distance from members to the centroid. Lower
+	// is tighter; 0 means every member has the same vector.
+	Cohesion float64
+	// Silhouette is the mean simplified silhouette of the members, from -1
+	// to 1. Near 1, members are much closer to this era's centroid than to
+	// any other; near 0 or below, the era overlaps its neighbors.
+	Silhouette float64
+	// TagWeights are the centroid's tag weights, heaviest first. Tags with
+	// zero weight are left out.
+	TagWeights []TagWeight
+}
+
+// partitionQuality computes each cluster's centroid, cohesion and
+// silhouette from its members' coordinates. Centroids are recomputed from
+// the members because the partition's centers aren't guaranteed to match
+// the final assignment.
+//
+// The silhouette is the simplified (centroid-based) form: a member's own
+// centroid distance is compared with its distance to the nearest other
+// centroid. It needs O(n·k) distances instead of O(n²), which matters when
+// clustering individual plays.
+func partitionQuality(members [][]clusters.Coordinates) (centers []clusters.Coordinates, cohesion, silhouette []float64) {
+	centers = make([]clusters.Coordinates, len(members))
+	cohesion = make([]float64, len(members))
+	silhouette = make([]float64, len(members))
+
+	for c, coords := range members {
+		centers[c] = meanCoordinates(coords)
+	}
+
+	for c, coords := range members {
+		if len(coords) == 0 {
+			continue
+		}
+		var sumDist, sumSil float64
+		for _, p := range coords {
+			a := distance(p, centers[c])
+			sumDist += a
+
+			b := math.Inf(1)
+			for other, center := range centers {
+				if other != c && center != nil {
+					b = min(b, distance(p, center))
+				}
+			}
+			// A single cluster has no neighbor to be separated from
+			if !math.IsInf(b, 1) && max(a, b) > 0 {
+				sumSil += (b - a) / max(a, b)
+			}
+		}
+		cohesion[c] = sumDist / float64(len(coords))
+		silhouette[c] = sumSil / float64(len(coords))
+	}
+	return centers, cohesion, silhouette
+}
+
+// meanCoordinates returns the mean of the coordinates, or nil if there are none.
+func meanCoordinates(coords []clusters.Coordinates) clusters.Coordinates {
+	if len(coords) == 0 {
+		return nil
+	}
+	mean := make(clusters.Coordinates, len(coords[0]))
+	for _, p := range coords {
+		for i, v := range p {
+			mean[i] += v
+		}
+	}
+	for i := range mean {
+		mean[i] /= float64(len(coords))
+	}
+	return mean
+}
+
+// distance returns the Euclidean distance between two points.
+// clusters.Coordinates.Distance returns the squared distance.
+func distance(a, b clusters.Coordinates) float64 {
+	return math.Sqrt(a.Distance(b))
+}
+
+// tagWeights returns the centroid's nonzero tag weights, heaviest first.
+// The centroid may have extra trailing dimensions (such as play time),
+// which are ignored.
+func tagWeights(centroid clusters.Coordinates, vocabulary []string) []TagWeight {
+	var weights []TagWeight
+	for i, name := range vocabulary {
+		if i < len(centroid) && centroid[i] > 0 {
+			weights = append(weights, TagWeight{Name: name, Weight: centroid[i]})
+		}
+	}
+	sort.SliceStable(weights, func(i, j int) bool {
+		return weights[i].Weight > weights[j].Weight
+	})
+	return weights
+}
+
+// closestTags explains why a track belongs to an era: the tags the track
+// and the centroid share, ranked by the product of their weights.
+func closestTags(vector, centroid clusters.Coordinates, vocabulary []string, n int) []string {
+	type scored struct {
+		name  string
+		score float64
+	}
+	var shared []scored
+	for i, name := range vocabulary {
+		if i >= len(vector) || i >= len(centroid) {
+			break
+		}
+		if score := vector[i] * centroid[i]; score > 0 {
+			shared = append(shared, scored{name: name, score: score})
+		}
+	}
+	sort.SliceStable(shared, func(i, j int) bool {
+		return shared[i].score > shared[j].score
+	})
+
+	result := make([]string, 0, min(n, len(shared)))
+	for i := 0; i < len(shared) && i < n; i++ {
+		result = append(result, shared[i].name)
+	}
+	return result
+}
@@ -0,0 +1,111 @@
+package clustering
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/muesli/clusters"
+)
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPartitionQuality(t *testing.T) {
+	members := [][]clusters.Coordinates{
+		{{0, 0}, {0, 2}},   // Center (0, 1)
+		{{10, 0}, {10, 2}}, // Center (10, 1)
+		{},                 // Empty clusters are ignored
+	}
+
+	centers, cohesion, silhouette := partitionQuality(members)
+
+	if !reflect.DeepEqual(centers[0], clusters.Coordinates{0, 1}) || !reflect.DeepEqual(centers[1], clusters.Coordinates{10, 1}) {
+		t.Errorf("centers = %v, want [0 1] and [10 1]", centers)
+	}
+	if centers[2] != nil {
+		t.Errorf("empty cluster center = %v, want nil", centers[2])
+	}
+
+	// Each member is 1 from its own center and sqrt(101) from the other
+	wantSil := 1 - 1/math.Sqrt(101)
+	for c := range 2 {
+		if !approxEqual(cohesion[c], 1) {
+			t.Errorf("cohesion[%d] = %v, want 1", c, cohesion[c])
+		}
+		if !approxEqual(silhouette[c], wantSil) {
+			t.Errorf("silhouette[%d] = %v, want %v", c, silhouette[c], wantSil)
+		}
+	}
+}
+
+func TestPartitionQuality_SingleCluster(t *testing.T) {
+	_, cohesion, silhouette := partitionQuality([][]clusters.Coordinates{{{0, 0}, {2, 0}}})
+	if !approxEqual(cohesion[0], 1) {
+		t.Errorf("cohesion = %v, want 1", cohesion[0])
+	}
+	if silhouette[0] != 0 {
+		t.Errorf("silhouette = %v, want 0 with no neighboring cluster", silhouette[0])
+	}
+}
+
+func TestTagWeights(t *testing.T) {
+	// The trailing dimension is play time and has no tag
+	centroid := clusters.Coordinates{0.2, 0, 0.9, 0.5}
+	got := tagWeights(centroid, []string{"rock", "pop", "jazz"})
+	want := []TagWeight{{Name: "jazz", Weight: 0.9}, {Name: "rock", Weight: 0.2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tagWeights() = %v, want %v", got, want)
+	}
+}
+
+func TestClosestTags(t *testing.T) {
+	vocabulary := []string{"rock", "pop", "jazz", "folk"}
+	centroid := clusters.Coordinates{0.9, 0.5, 0.1, 0.8}
+	track := clusters.Coordinates{0.3, 1, 1, 0}
+
+	// Shared tags ranked by track weight times centroid weight; folk isn't
+	// on the track
+	got := closestTags(track, centroid, vocabulary, 2)
+	want := []string{"pop", "rock"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("closestTags() = %v, want %v", got, want)
+	}
+}
+
+func TestDetectMoodEras_Metrics(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	tracks := []Track{
+		{ID: "r1", AddedAt: day(1), Tags: []Tag{{Name: "rock", Count: 100}, {Name: "guitar", Count: 80}}},
+		{ID: "r2", AddedAt: day(2), Tags: []Tag{{Name: "rock", Count: 100}, {Name: "guitar", Count: 60}}},
+		{ID: "r3", AddedAt: day(3), Tags: []Tag{{Name: "rock", Count: 100}, {Name: "guitar", Count: 70}}},
+		{ID: "e1", AddedAt: day(4), Tags: []Tag{{Name: "electronic", Count: 100}, {Name: "dance", Count: 90}}},
+		{ID: "e2", AddedAt: day(5), Tags: []Tag{{Name: "electronic", Count: 100}, {Name: "dance", Count: 80}}},
+		{ID: "e3", AddedAt: day(6), Tags: []Tag{{Name: "electronic", Count: 100}, {Name: "dance", Count: 85}}},
+	}
+
+	eras, _ := DetectMoodEras(tracks, TagClusterConfig{NumClusters: 2, MinClusterSize: 3, MaxTags: 50})
+	if len(eras) != 2 {
+		t.Fatalf("expected 2 eras, got %d", len(eras))
+	}
+
+	for _, era := range eras {
+		m := era.Metrics
+		if m.Cohesion <= 0 || m.Cohesion > 0.2 {
+			t.Errorf("%s: cohesion = %v, want a small positive distance", era.Name, m.Cohesion)
+		}
+		if m.Silhouette < 0.8 || m.Silhouette > 1 {
+			t.Errorf("%s: silhouette = %v, want close to 1 for well-separated eras", era.Name, m.Silhouette)
+		}
+		if len(m.TagWeights) != 2 || m.TagWeights[0].Name != era.TopTags[0] {
+			t.Errorf("%s: tag weights = %v, want two tags led by %q", era.Name, m.TagWeights, era.TopTags[0])
+		}
+		for _, track := range era.Tracks {
+			if reasons := era.Reasons[track.ID]; len(reasons) != 2 || reasons[0] != era.TopTags[0] {
+				t.Errorf("%s: reasons for %s = %v, want both tags led by %q", era.Name, track.ID, reasons, era.TopTags[0])
+			}
+		}
+	}
+}
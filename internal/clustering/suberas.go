@@ -0,0 +1,49 @@
+package clustering
+
+import "time"
+
+// detectSubEras splits each era into sub-eras by clustering its tracks
+// again, recursing until cfg.SubEraDepth levels deep. Each split uses up to
+// cfg.NumClusters clusters, fewer when the era is too small for each to reach
+// cfg.MinClusterSize. An era that doesn't split into at least two sub-eras
+// keeps none, since a single sub-era would repeat its parent. Tracks left out
+// of every sub-era stay in the parent only.
+func detectSubEras(eras []MoodEra, cfg TagClusterConfig) {
+	sub := cfg
+	sub.SubEraDepth--
+
+	for i := range eras {
+		era := &eras[i]
+		k := min(cfg.NumClusters, len(era.Tracks)/max(cfg.MinClusterSize, 1))
+		if k < 2 {
+			continue
+		}
+		sub.NumClusters = k
+
+		tracks := era.Tracks
+		if cfg.Basis == BasisPlays {
+			tracks = playsWithin(tracks, era.StartDate, era.EndDate)
+		}
+		if children, _ := DetectMoodEras(tracks, sub); len(children) >= 2 {
+			era.SubEras = children
+		}
+	}
+}
+
+// playsWithin returns copies of tracks keeping only plays between start and
+// end inclusive, so a sub-era is built from its parent's period. Plays in
+// that period that went to other eras are included.
+func playsWithin(tracks []Track, start, end time.Time) []Track {
+	result := make([]Track, len(tracks))
+	for i, t := range tracks {
+		var plays []time.Time
+		for _, p := range t.Plays {
+			if !p.Before(start) && !p.After(end) {
+				plays = append(plays, p)
+			}
+		}
+		t.Plays = plays
+		result[i] = t
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+package clustering
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// subMoodTracks returns three tracks tagged with main plus a weaker sub tag.
+func subMoodTracks(prefix, main, sub string, added time.Time) []Track {
+	tracks := make([]Track, 3)
+	for i := range tracks {
+		tracks[i] = Track{
+			ID:      prefix + string(rune('1'+i)),
+			AddedAt: added.AddDate(0, 0, i),
+			Tags:    []Tag{{Name: main, Count: 100}, {Name: sub, Count: 30}},
+		}
+	}
+	return tracks
+}
+
+func TestDetectMoodEras_SubEras(t *testing.T) {
+	var tracks []Track
+	tracks = append(tracks, subMoodTracks("rp", "rock", "punk", date(2023, 1, 1))...)
+	tracks = append(tracks, subMoodTracks("rb", "rock", "blues", date(2023, 2, 1))...)
+	tracks = append(tracks, subMoodTracks("eh", "electronic", "house", date(2023, 6, 1))...)
+	tracks = append(tracks, subMoodTracks("et", "electronic", "techno", date(2023, 7, 1))...)
+
+	eras, _ := DetectMoodEras(tracks, TagClusterConfig{NumClusters: 2, MinClusterSize: 3, MaxTags: 50, SubEraDepth: 1})
+	if len(eras) != 2 {
+		t.Fatalf("expected 2 eras, got %d", len(eras))
+	}
+
+	for _, era := range eras {
+		if len(era.SubEras) != 2 {
+			t.Fatalf("%s: expected 2 sub-eras, got %d", era.Name, len(era.SubEras))
+		}
+		var subTags []string
+		for _, sub := range era.SubEras {
+			if len(sub.Tracks) != 3 {
+				t.Errorf("%s: sub-era %s has %d tracks, want 3", era.Name, sub.Name, len(sub.Tracks))
+			}
+			if len(sub.SubEras) != 0 {
+				t.Errorf("%s: sub-era %s has sub-eras past the depth limit", era.Name, sub.Name)
+			}
+			// Sub-eras are told apart by the tag their parent doesn't share
+			subTags = append(subTags, sub.Metrics.TagWeights[1].Name)
+		}
+		sort.Strings(subTags)
+		want := map[string][2]string{"rock": {"blues", "punk"}, "electronic": {"house", "techno"}}[era.TopTags[0]]
+		if subTags[0] != want[0] || subTags[1] != want[1] {
+			t.Errorf("%s: sub-era tags = %v, want %v", era.Name, subTags, want)
+		}
+	}
+}
+
+func TestDetectMoodEras_SubErasTooSmall(t *testing.T) {
+	var tracks []Track
+	tracks = append(tracks, subMoodTracks("r", "rock", "punk", date(2023, 1, 1))...)
+	tracks = append(tracks, subMoodTracks("e", "electronic", "house", date(2023, 6, 1))...)
+
+	// Three tracks can't make two sub-eras of three
+	eras, _ := DetectMoodEras(tracks, TagClusterConfig{NumClusters: 2, MinClusterSize: 3, MaxTags: 50, SubEraDepth: 2})
+	for _, era := range eras {
+		if len(era.SubEras) != 0 {
+			t.Errorf("%s: got %d sub-eras, want none", era.Name, len(era.SubEras))
+		}
+	}
+}
+
+func TestPlaysWithin(t *testing.T) {
+	tracks := []Track{{ID: "1", Plays: []time.Time{date(2023, 1, 1), date(2023, 2, 1), date(2023, 3, 1)}}}
+
+	got := playsWithin(tracks, date(2023, 2, 1), date(2023, 3, 1))
+	if len(got[0].Plays) != 2 {
+		t.Errorf("plays = %v, want the last two", got[0].Plays)
+	}
+	if len(tracks[0].Plays) != 3 {
+		t.Error("playsWithin modified its input")
+	}
+}
@@ -1,7 +1,6 @@
 package clustering
 
 import (
-	"fmt"
 	"slices"
 	"sort"
 	"strings"
@@ -11,29 +10,47 @@ import (
 	"github.com/muesli/kmeans"
 )
 
+// manualTagCount is the popularity count credited to a manual tag when
+// building the vocabulary. It matches the highest count Last.fm reports, so
+// user tags are never crowded out of the vocabulary by crowd-sourced ones.
+const manualTagCount = 100
+
 // TagClusterConfig holds tag-based clustering parameters.
 type TagClusterConfig struct {
-	NumClusters    int // Number of clusters to create (default: 3)
-	MinClusterSize int // Minimum tracks per era (smaller clusters become outliers)
-	MaxTags        int // Maximum tags to use in vectors (default: 50)
+	NumClusters     int     // Number of clusters to create (default: 3)
+	MinClusterSize  int     // Minimum tracks per era (smaller clusters become outliers)
+	MaxTags         int     // Maximum tags to use in vectors (default: 50)
+	ManualTagWeight float64 // Vector weight for user-assigned tags; Last.fm tags are at most 1.0 (default: 2.0)
+	Basis           Basis   // What dates eras are built from (default: BasisLikes)
+	TimeWeight      float64 // Vector weight of play time relative to tags, BasisPlays only (default: 1.0)
+	NameTemplate    string  // Era name template, see NamePlaceholders (default: DefaultNameTemplate)
+	SubEraDepth     int     // Levels of sub-eras to split each era into (default: 0, none)
 }
 
 // DefaultTagClusterConfig returns the recommended default configuration.
 func DefaultTagClusterConfig() TagClusterConfig {
 	return TagClusterConfig{
-		NumClusters:    3,
-		MinClusterSize: 3,
-		MaxTags:        50,
+		NumClusters:     3,
+		MinClusterSize:  3,
+		MaxTags:         50,
+		ManualTagWeight: 2.0,
+		Basis:           BasisLikes,
+		TimeWeight:      1.0,
+		NameTemplate:    DefaultNameTemplate,
 	}
 }
 
 // MoodEra represents a cluster of tracks grouped by tag similarity.
 type MoodEra struct {
-	Name      string    // Descriptive name: "Rock & Indie & Pop: Jan 15 - Feb 3, 2024"
+	Name      string    // Descriptive name: "Summer 2023: shoegaze & dream pop"
 	Tracks    []Track   // Tracks in this era
 	TopTags   []string  // Top 3 dominant tags for this cluster
-	StartDate time.Time // Earliest track add date
-	EndDate   time.Time // Latest track add date
+	StartDate time.Time // Earliest track add date (or play, for BasisPlays)
+	EndDate   time.Time // Latest track add date (or play, for BasisPlays)
+	PlayCount int       // Plays in this era (0 for BasisLikes)
+	Metrics   EraMetrics
+	Reasons   map[string][]string // Track ID to the tags that most tie it to the era
+	SubEras   []MoodEra           // Sub-moods within this era, when cfg.SubEraDepth > 0
 }
 
 // trackObservation wraps a Track to implement clusters.Observation interface.
@@ -52,7 +69,9 @@ func (o trackObservation) Distance(point clusters.Coordinates) float64 {
 
 // DetectMoodEras groups tracks by tag similarity using k-means clustering.
 // Returns mood-based eras and outlier tracks that don't fit into any era.
-// Tracks without tags are treated as outliers.
+// Tracks without tags are treated as outliers. With BasisPlays, eras are
+// built from listening history instead of like dates (see detectPlayEras).
+// With cfg.SubEraDepth set, each era is split into sub-eras (see detectSubEras).
 func DetectMoodEras(tracks []Track, cfg TagClusterConfig) ([]MoodEra, []Track) {
 	if len(tracks) == 0 {
 		return nil, nil
@@ -65,6 +84,20 @@ func DetectMoodEras(tracks []Track, cfg TagClusterConfig) ([]MoodEra, []Track) {
 	if cfg.MaxTags <= 0 {
 		cfg.MaxTags = DefaultTagClusterConfig().MaxTags
 	}
+	if cfg.ManualTagWeight <= 0 {
+		cfg.ManualTagWeight = DefaultTagClusterConfig().ManualTagWeight
+	}
+	if cfg.TimeWeight <= 0 {
+		cfg.TimeWeight = DefaultTagClusterConfig().TimeWeight
+	}
+
+	if cfg.Basis == BasisPlays {
+		eras, outliers := detectPlayEras(tracks, cfg)
+		if cfg.SubEraDepth > 0 {
+			detectSubEras(eras, cfg)
+		}
+		return eras, outliers
+	}
 
 	// Separate tracks with and without tags
 	var validTracks []*Track
@@ -106,7 +139,7 @@ func DetectMoodEras(tracks []Track, cfg TagClusterConfig) ([]MoodEra, []Track) {
 	for i, t := range validTracks {
 		observations[i] = trackObservation{
 			track:  t,
-			coords: buildTagVector(t, vocabulary),
+			coords: buildTagVector(t, vocabulary, cfg.ManualTagWeight),
 		}
 	}
 
@@ -129,16 +162,26 @@ func DetectMoodEras(tracks []Track, cfg TagClusterConfig) ([]MoodEra, []Track) {
 		return nil, outliers
 	}
 
+	members := make([][]clusters.Coordinates, len(result))
+	for c, cluster := range result {
+		for _, o := range cluster.Observations {
+			members[c] = append(members[c], o.Coordinates())
+		}
+	}
+	centers, cohesion, silhouette := partitionQuality(members)
+
 	// Build MoodEras from clusters
 	var eras []MoodEra
 	var outliers []Track
 
-	for _, cluster := range result {
+	for c, cluster := range result {
 		// Extract tracks from this cluster
 		var clusterTracks []Track
+		reasons := make(map[string][]string)
 		for _, obs := range cluster.Observations {
 			if to, ok := obs.(trackObservation); ok {
 				clusterTracks = append(clusterTracks, *to.track)
+				reasons[to.track.ID] = closestTags(to.coords, centers[c], vocabulary, reasonTags)
 			}
 		}
 
@@ -154,19 +197,22 @@ func DetectMoodEras(tracks []Track, cfg TagClusterConfig) ([]MoodEra, []Track) {
 		})
 
 		// Extract top tags from centroid
-		topTags := extractTopTags(cluster.Center, vocabulary, 3)
+		topTags := extractTopTags(centers[c], vocabulary, 3)
 
-		// Generate era name
 		startDate := clusterTracks[0].AddedAt
 		endDate := clusterTracks[len(clusterTracks)-1].AddedAt
-		fullName := generateEraName(topTags, startDate, endDate)
 
 		eras = append(eras, MoodEra{
-			Name:      fullName,
 			Tracks:    clusterTracks,
 			TopTags:   topTags,
 			StartDate: startDate,
 			EndDate:   endDate,
+			Metrics: EraMetrics{
+				Cohesion:   cohesion[c],
+				Silhouette: silhouette[c],
+				TagWeights: tagWeights(centers[c], vocabulary),
+			},
+			Reasons: reasons,
 		})
 	}
 
@@ -177,6 +223,10 @@ func DetectMoodEras(tracks []Track, cfg TagClusterConfig) ([]MoodEra, []Track) {
 	slices.SortFunc(eras, func(a, b MoodEra) int {
 		return b.StartDate.Compare(a.StartDate) // Descending
 	})
+	nameEras(eras, cfg.NameTemplate)
+	if cfg.SubEraDepth > 0 {
+		detectSubEras(eras, cfg)
+	}
 
 	return eras, outliers
 }
@@ -195,7 +245,11 @@ func buildTagVocabulary(tracks []*Track, maxTags int) []string {
 		for _, tag := range t.Tags {
 			// Normalize tag name to lowercase
 			name := strings.ToLower(tag.Name)
-			counts[name] += tag.Count
+			if tag.Manual {
+				counts[name] += manualTagCount
+			} else {
+				counts[name] += tag.Count
+			}
 		}
 	}
 
@@ -221,18 +275,19 @@ func buildTagVocabulary(tracks []*Track, maxTags int) []string {
 }
 
 // buildTagVector creates a feature vector for a track based on its tags.
-// Vector values are normalized tag counts (0-1 scale).
-func buildTagVector(track *Track, vocabulary []string) clusters.Coordinates {
+// Last.fm tag values are normalized counts (0-1 scale); manual tags are set to
+// manualWeight so they pull harder than any crowd-sourced tag.
+func buildTagVector(track *Track, vocabulary []string, manualWeight float64) clusters.Coordinates {
 	// Create vocabulary index for fast lookup
 	vocabIndex := make(map[string]int, len(vocabulary))
 	for i, tag := range vocabulary {
 		vocabIndex[tag] = i
 	}
 
-	// Find max count for normalization
+	// Find max count for normalization (manual tags carry no count)
 	var maxCount int
 	for _, tag := range track.Tags {
-		if tag.Count > maxCount {
+		if !tag.Manual && tag.Count > maxCount {
 			maxCount = tag.Count
 		}
 	}
@@ -244,10 +299,16 @@ func buildTagVector(track *Track, vocabulary []string) clusters.Coordinates {
 	vector := make(clusters.Coordinates, len(vocabulary))
 	for _, tag := range track.Tags {
 		name := strings.ToLower(tag.Name)
-		if idx, ok := vocabIndex[name]; ok {
-			// Normalize count to 0-1 scale
-			vector[idx] = float64(tag.Count) / float64(maxCount)
+		idx, ok := vocabIndex[name]
+		if !ok {
+			continue
+		}
+		if tag.Manual {
+			vector[idx] = max(vector[idx], manualWeight)
+			continue
 		}
+		// Normalize count to 0-1 scale
+		vector[idx] = max(vector[idx], float64(tag.Count)/float64(maxCount))
 	}
 
 	return vector
@@ -288,25 +349,3 @@ func extractTopTags(centroid clusters.Coordinates, vocabulary []string, n int) [
 
 	return result
 }
-
-// generateEraName creates a descriptive name from top tags and date range.
-func generateEraName(topTags []string, start, end time.Time) string {
-	const dateFormat = "Jan 2, 2006"
-
-	// Format tags
-	var tagPart string
-	if len(topTags) == 0 {
-		tagPart = "Mixed"
-	} else {
-		tagPart = strings.Join(topTags, " & ")
-	}
-
-	// Format date range
-	startStr := start.Format(dateFormat)
-	endStr := end.Format(dateFormat)
-
-	if startStr == endStr {
-		return fmt.Sprintf("%s: %s", tagPart, startStr)
-	}
-	return fmt.Sprintf("%s: %s - %s", tagPart, startStr, endStr)
-}
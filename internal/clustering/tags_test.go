@@ -193,7 +193,7 @@ func TestBuildTagVector(t *testing.T) {
 	}
 
 	vocabulary := []string{"rock", "indie", "pop", "jazz"}
-	vector := buildTagVector(track, vocabulary)
+	vector := buildTagVector(track, vocabulary, 2.0)
 
 	if len(vector) != 4 {
 		t.Fatalf("expected vector length 4, got %d", len(vector))
@@ -220,6 +220,87 @@ func TestBuildTagVector(t *testing.T) {
 	}
 }
 
+func TestBuildTagVector_ManualTags(t *testing.T) {
+	track := &Track{
+		Tags: []Tag{
+			{Name: "rock", Count: 100},
+			{Name: "indie", Count: 50},
+			{Name: "Gym", Manual: true},
+			{Name: "indie", Manual: true},
+		},
+	}
+
+	vocabulary := []string{"rock", "indie", "gym"}
+	vector := buildTagVector(track, vocabulary, 2.0)
+
+	// rock: manual tags don't affect normalization, so 100/100 = 1.0
+	if vector[0] != 1.0 {
+		t.Errorf("vector[rock] = %v, want 1.0", vector[0])
+	}
+
+	// indie: manual weight wins over the Last.fm count
+	if vector[1] != 2.0 {
+		t.Errorf("vector[indie] = %v, want 2.0", vector[1])
+	}
+
+	// gym: manual only, matched case-insensitively
+	if vector[2] != 2.0 {
+		t.Errorf("vector[gym] = %v, want 2.0", vector[2])
+	}
+}
+
+func TestBuildTagVocabulary_IncludesManualTags(t *testing.T) {
+	tracks := []*Track{
+		{Tags: []Tag{{Name: "rock", Count: 100}, {Name: "indie", Count: 90}}},
+		{Tags: []Tag{{Name: "rock", Count: 80}, {Name: "pop", Count: 70}, {Name: "rainy day", Manual: true}}},
+	}
+
+	vocab := buildTagVocabulary(tracks, 2)
+
+	// rock (180) beats the manual tag (100), which beats indie (90)
+	expected := []string{"rock", "rainy day"}
+	for i, want := range expected {
+		if vocab[i] != want {
+			t.Errorf("vocab[%d] = %q, want %q", i, vocab[i], want)
+		}
+	}
+}
+
+func TestDetectMoodEras_ManualTagsOutweighLastFM(t *testing.T) {
+	makeDate := func(year, month, day int) time.Time {
+		return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	}
+
+	// Last.fm says all six tracks are rock, but the user tagged three as "gym".
+	tracks := []Track{
+		{ID: "1", AddedAt: makeDate(2024, 1, 1), Tags: []Tag{{Name: "rock", Count: 100}, {Name: "gym", Manual: true}}},
+		{ID: "2", AddedAt: makeDate(2024, 1, 2), Tags: []Tag{{Name: "rock", Count: 100}, {Name: "gym", Manual: true}}},
+		{ID: "3", AddedAt: makeDate(2024, 1, 3), Tags: []Tag{{Name: "rock", Count: 100}, {Name: "gym", Manual: true}}},
+		{ID: "4", AddedAt: makeDate(2024, 1, 4), Tags: []Tag{{Name: "rock", Count: 100}}},
+		{ID: "5", AddedAt: makeDate(2024, 1, 5), Tags: []Tag{{Name: "rock", Count: 100}}},
+		{ID: "6", AddedAt: makeDate(2024, 1, 6), Tags: []Tag{{Name: "rock", Count: 100}}},
+	}
+
+	eras, _ := DetectMoodEras(tracks, TagClusterConfig{NumClusters: 2, MinClusterSize: 3, MaxTags: 50})
+
+	if len(eras) != 2 {
+		t.Fatalf("expected 2 eras, got %d", len(eras))
+	}
+
+	for _, era := range eras {
+		if len(era.TopTags) == 0 || era.TopTags[0] != "gym" {
+			continue
+		}
+		for _, track := range era.Tracks {
+			if track.ID > "3" {
+				t.Errorf("gym era contains untagged track %s", track.ID)
+			}
+		}
+		return
+	}
+	t.Error("expected an era led by the manual \"gym\" tag")
+}
+
 func TestExtractTopTags(t *testing.T) {
 	vocabulary := []string{"rock", "indie", "pop", "jazz", "electronic"}
 	centroid := []float64{0.8, 0.6, 0.0, 0.3, 0.0}
@@ -254,54 +335,6 @@ func TestExtractTopTags_SkipsZeroWeights(t *testing.T) {
 	}
 }
 
-func TestGenerateEraName(t *testing.T) {
-	tests := []struct {
-		name     string
-		topTags  []string
-		start    time.Time
-		end      time.Time
-		expected string
-	}{
-		{
-			name:     "multiple tags different dates",
-			topTags:  []string{"rock", "indie", "alternative"},
-			start:    time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
-			end:      time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC),
-			expected: "rock & indie & alternative: Jan 15, 2024 - Feb 3, 2024",
-		},
-		{
-			name:     "single tag same date",
-			topTags:  []string{"electronic"},
-			start:    time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
-			end:      time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
-			expected: "electronic: Mar 10, 2024",
-		},
-		{
-			name:     "no tags",
-			topTags:  nil,
-			start:    time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
-			end:      time.Date(2024, 4, 5, 0, 0, 0, 0, time.UTC),
-			expected: "Mixed: Apr 1, 2024 - Apr 5, 2024",
-		},
-		{
-			name:     "empty tags slice",
-			topTags:  []string{},
-			start:    time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
-			end:      time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
-			expected: "Mixed: May 1, 2024",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := generateEraName(tt.topTags, tt.start, tt.end)
-			if got != tt.expected {
-				t.Errorf("generateEraName() = %q, want %q", got, tt.expected)
-			}
-		})
-	}
-}
-
 func TestDefaultTagClusterConfig(t *testing.T) {
 	cfg := DefaultTagClusterConfig()
 
@@ -314,6 +347,18 @@ func TestDefaultTagClusterConfig(t *testing.T) {
 	if cfg.MaxTags != 50 {
 		t.Errorf("MaxTags = %d, want 50", cfg.MaxTags)
 	}
+	if cfg.ManualTagWeight != 2.0 {
+		t.Errorf("ManualTagWeight = %v, want 2.0", cfg.ManualTagWeight)
+	}
+	if cfg.Basis != BasisLikes {
+		t.Errorf("Basis = %q, want %q", cfg.Basis, BasisLikes)
+	}
+	if cfg.TimeWeight != 1.0 {
+		t.Errorf("TimeWeight = %v, want 1.0", cfg.TimeWeight)
+	}
+	if cfg.NameTemplate != DefaultNameTemplate {
+		t.Errorf("NameTemplate = %q, want %q", cfg.NameTemplate, DefaultNameTemplate)
+	}
 }
 
 func TestDetectMoodEras_UsesDefaults(t *testing.T) {
@@ -0,0 +1,415 @@
+// Package config loads the application configuration from an optional YAML
+// or TOML file and environment variable overrides, and validates it.
+//
+// Settings are applied in order: built-in defaults, then the file named by
+// CONFIG_FILE, then environment variables. Command-line flags such as
+// serve's -addr override the result.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/clustering"
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/eras"
+	syncpkg "github.com/justestif/go-spotify-era-organizer/internal/sync"
+	"github.com/justestif/go-spotify-era-organizer/internal/tags"
+)
+
+// FileEnv is the environment variable naming the config file.
+const FileEnv = "CONFIG_FILE"
+
+// Server defaults.
+const (
+	DefaultAddr            = "127.0.0.1:8080"
+	DefaultReadTimeout     = 15 * time.Second
+	DefaultWriteTimeout    = 15 * time.Second
+	DefaultIdleTimeout     = 60 * time.Second
+	DefaultShutdownTimeout = 10 * time.Second
+)
+
+// MaxTagConcurrency bounds concurrent Last.fm lookups, which share one API
+// key's rate limit.
+const MaxTagConcurrency = 50
+
+// Config is the application configuration.
+type Config struct {
+	Server     Server     `yaml:"server" toml:"server"`
+	Spotify    Spotify    `yaml:"spotify" toml:"spotify"`
+	LastFM     LastFM     `yaml:"lastfm" toml:"lastfm"`
+	Database   Database   `yaml:"database" toml:"database"`
+	Sync       Sync       `yaml:"sync" toml:"sync"`
+	Tags       Tags       `yaml:"tags" toml:"tags"`
+	Clustering Clustering `yaml:"clustering" toml:"clustering"`
+	CLI        CLI        `yaml:"cli" toml:"cli"`
+}
+
+// Server configures the web server.
+type Server struct {
+	Addr string `yaml:"addr" toml:"addr"` // Listen address
+	// BaseURL is the public URL the app is reached at, e.g.
+	// https://eras.example.com. The Spotify redirect URI is derived from it.
+	// Defaults to http://<addr>.
+	BaseURL         string        `yaml:"base_url" toml:"base_url"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+// PublicURL returns the base URL without a trailing slash, derived from
+// the listen address if none is set.
+func (s Server) PublicURL() string {
+	if s.BaseURL == "" {
+		return "http://" + s.Addr
+	}
+	return strings.TrimSuffix(s.BaseURL, "/")
+}
+
+// RedirectURI returns the OAuth callback URL, which must be registered with
+// the Spotify app.
+func (s Server) RedirectURI() string {
+	return s.PublicURL() + "/callback"
+}
+
+// Spotify holds the Spotify app credentials.
+type Spotify struct {
+	ClientID     string `yaml:"client_id" toml:"client_id"`
+	ClientSecret string `yaml:"client_secret" toml:"client_secret"`
+}
+
+// LastFM holds the Last.fm API key. Tag fetching is disabled without one.
+type LastFM struct {
+	APIKey string `yaml:"api_key" toml:"api_key"`
+}
+
+// Database configures the database connection.
+type Database struct {
+	URL string `yaml:"url" toml:"url"` // Empty runs the server without a database
+	// AutoMigrate applies pending migrations on connect. When false, the
+	// schema must already be current.
+	AutoMigrate bool `yaml:"auto_migrate" toml:"auto_migrate"`
+	// TokenEncryptionKeys encrypt stored OAuth tokens, as comma-separated
+	// "<id>:<base64 key>" entries with the primary key first. Empty stores
+	// tokens unencrypted.
+	TokenEncryptionKeys string `yaml:"token_encryption_keys" toml:"token_encryption_keys"`
+}
+
+// Keyring returns the token encryption keys, or nil if none are set.
+func (d Database) Keyring() (*db.Keyring, error) {
+	return db.ParseKeyring(d.TokenEncryptionKeys)
+}
+
+// Sync configures library syncs.
+type Sync struct {
+	Cooldown time.Duration `yaml:"cooldown" toml:"cooldown"` // Minimum time between a user's syncs
+}
+
+// Tags configures Last.fm tag fetching.
+type Tags struct {
+	Concurrency int           `yaml:"concurrency" toml:"concurrency"` // Concurrent Last.fm lookups
+	CacheTTL    time.Duration `yaml:"cache_ttl" toml:"cache_ttl"`     // Age after which fetched tags are refreshed
+}
+
+// Clustering holds the era detection defaults that users' settings
+// override.
+type Clustering struct {
+	NumClusters     int     `yaml:"num_clusters" toml:"num_clusters"`
+	MinClusterSize  int     `yaml:"min_cluster_size" toml:"min_cluster_size"`
+	MaxTags         int     `yaml:"max_tags" toml:"max_tags"`
+	ManualTagWeight float64 `yaml:"manual_tag_weight" toml:"manual_tag_weight"`
+	TimeWeight      float64 `yaml:"time_weight" toml:"time_weight"`
+	NameTemplate    string  `yaml:"name_template" toml:"name_template"`
+	SubEraDepth     int     `yaml:"sub_era_depth" toml:"sub_era_depth"`
+}
+
+// TagClusterConfig returns the clustering configuration with these defaults.
+func (c Clustering) TagClusterConfig() clustering.TagClusterConfig {
+	cfg := clustering.DefaultTagClusterConfig()
+	c.settings().Apply(&cfg)
+	return cfg
+}
+
+// settings returns the defaults as era settings, which share their limits.
+func (c Clustering) settings() eras.Settings {
+	return eras.Settings{
+		NumClusters:     &c.NumClusters,
+		MinClusterSize:  &c.MinClusterSize,
+		MaxTags:         &c.MaxTags,
+		ManualTagWeight: &c.ManualTagWeight,
+		TimeWeight:      &c.TimeWeight,
+		NameTemplate:    &c.NameTemplate,
+		SubEraDepth:     &c.SubEraDepth,
+	}
+}
+
+// CLI configures the headless subcommands.
+type CLI struct {
+	UserID string `yaml:"user_id" toml:"user_id"` // User whose data commands use; defaults to the logged-in user
+	// RefreshToken is a Spotify refresh token used instead of the one
+	// saved by login.
+	RefreshToken string `yaml:"refresh_token" toml:"refresh_token"`
+	// CredentialsFile is where login saves its token. Defaults to
+	// credentials.json in the user's config directory.
+	CredentialsFile string `yaml:"credentials_file" toml:"credentials_file"`
+}
+
+// Default returns the built-in configuration.
+func Default() *Config {
+	defaults := clustering.DefaultTagClusterConfig()
+	return &Config{
+		Server: Server{
+			Addr:            DefaultAddr,
+			ReadTimeout:     DefaultReadTimeout,
+			WriteTimeout:    DefaultWriteTimeout,
+			IdleTimeout:     DefaultIdleTimeout,
+			ShutdownTimeout: DefaultShutdownTimeout,
+		},
+		Database: Database{AutoMigrate: true},
+		Sync:     Sync{Cooldown: syncpkg.DefaultSyncCooldown},
+		Tags:     Tags{Concurrency: tags.DefaultConcurrency, CacheTTL: tags.CacheTTL},
+		Clustering: Clustering{
+			NumClusters:     defaults.NumClusters,
+			MinClusterSize:  defaults.MinClusterSize,
+			MaxTags:         defaults.MaxTags,
+			ManualTagWeight: defaults.ManualTagWeight,
+			TimeWeight:      defaults.TimeWeight,
+			NameTemplate:    defaults.NameTemplate,
+			SubEraDepth:     defaults.SubEraDepth,
+		},
+	}
+}
+
+// FromEnv loads the file named by CONFIG_FILE, if set, and applies
+// environment overrides.
+func FromEnv() (*Config, error) {
+	return load(os.Getenv(FileEnv), os.Getenv)
+}
+
+// Load loads the config file at path, or only the defaults if path is
+// empty, and applies environment overrides. The result is validated.
+func Load(path string) (*Config, error) {
+	return load(path, os.Getenv)
+}
+
+// load is Load with the environment lookup injected.
+func load(path string, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(getenv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readFile decodes a YAML or TOML file, chosen by its extension, over cfg.
+// Unknown keys are errors, so typos don't silently keep the default.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		// An empty file decodes to io.EOF and keeps the defaults
+		if err := dec.Decode(c); err != nil && len(bytes.TrimSpace(data)) > 0 {
+			return fmt.Errorf("parsing config %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("parsing config %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, len(undecoded))
+			for i, key := range undecoded {
+				keys[i] = key.String()
+			}
+			return fmt.Errorf("parsing config %s: unknown keys %s", path, strings.Join(keys, ", "))
+		}
+	default:
+		return fmt.Errorf("config %s: unsupported format %q (use .yaml, .yml or .toml)", path, ext)
+	}
+	return nil
+}
+
+// applyEnv overrides settings with the environment variables that are set.
+func (c *Config) applyEnv(getenv func(string) string) error {
+	var errs []error
+	str := func(name string, dst *string) {
+		if v := getenv(name); v != "" {
+			*dst = v
+		}
+	}
+	parse := func(name string, set func(string) error) {
+		if v := getenv(name); v != "" {
+			if err := set(v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+	duration := func(name string, dst *time.Duration) {
+		parse(name, func(v string) (err error) {
+			*dst, err = time.ParseDuration(v)
+			return err
+		})
+	}
+	integer := func(name string, dst *int) {
+		parse(name, func(v string) (err error) {
+			*dst, err = strconv.Atoi(v)
+			return err
+		})
+	}
+	boolean := func(name string, dst *bool) {
+		parse(name, func(v string) (err error) {
+			*dst, err = strconv.ParseBool(v)
+			return err
+		})
+	}
+	float := func(name string, dst *float64) {
+		parse(name, func(v string) (err error) {
+			*dst, err = strconv.ParseFloat(v, 64)
+			return err
+		})
+	}
+
+	str("LISTEN_ADDR", &c.Server.Addr)
+	str("BASE_URL", &c.Server.BaseURL)
+	duration("HTTP_READ_TIMEOUT", &c.Server.ReadTimeout)
+	duration("HTTP_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	duration("HTTP_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	duration("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+	str("SPOTIFY_ID", &c.Spotify.ClientID)
+	str("SPOTIFY_SECRET", &c.Spotify.ClientSecret)
+	str("LASTFM_API_KEY", &c.LastFM.APIKey)
+	str("DATABASE_URL", &c.Database.URL)
+	boolean("AUTO_MIGRATE", &c.Database.AutoMigrate)
+	str("TOKEN_ENCRYPTION_KEYS", &c.Database.TokenEncryptionKeys)
+	duration("SYNC_COOLDOWN", &c.Sync.Cooldown)
+	integer("TAG_CONCURRENCY", &c.Tags.Concurrency)
+	duration("TAG_CACHE_TTL", &c.Tags.CacheTTL)
+	integer("ERA_NUM_CLUSTERS", &c.Clustering.NumClusters)
+	integer("ERA_MIN_CLUSTER_SIZE", &c.Clustering.MinClusterSize)
+	integer("ERA_MAX_TAGS", &c.Clustering.MaxTags)
+	float("ERA_MANUAL_TAG_WEIGHT", &c.Clustering.ManualTagWeight)
+	float("ERA_TIME_WEIGHT", &c.Clustering.TimeWeight)
+	str("ERA_NAME_TEMPLATE", &c.Clustering.NameTemplate)
+	integer("ERA_SUB_ERA_DEPTH", &c.Clustering.SubEraDepth)
+	str("SPOTIFY_USER_ID", &c.CLI.UserID)
+	str("SPOTIFY_REFRESH_TOKEN", &c.CLI.RefreshToken)
+	str("SPOTIFY_CREDENTIALS", &c.CLI.CredentialsFile)
+
+	return errors.Join(errs...)
+}
+
+// ValidationError maps config keys, such as "server.addr", to problems with
+// their values.
+type ValidationError map[string]string
+
+func (e ValidationError) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	msgs := make([]string, len(keys))
+	for i, key := range keys {
+		msgs[i] = key + ": " + e[key]
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks every setting. Credentials aren't required here, since
+// commands that don't talk to Spotify or Last.fm run without them.
+// Returns a ValidationError, or nil if the config is valid.
+func (c *Config) Validate() error {
+	errs := make(ValidationError)
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		errs["server.addr"] = "must be host:port"
+	}
+	if msg := checkBaseURL(c.Server); msg != "" {
+		errs["server.base_url"] = msg
+	}
+	positive := func(key string, d time.Duration) {
+		if d <= 0 {
+			errs[key] = "must be positive"
+		}
+	}
+	positive("server.read_timeout", c.Server.ReadTimeout)
+	positive("server.write_timeout", c.Server.WriteTimeout)
+	positive("server.idle_timeout", c.Server.IdleTimeout)
+	positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+
+	if _, err := c.Database.Keyring(); err != nil {
+		errs["database.token_encryption_keys"] = err.Error()
+	}
+	if c.Sync.Cooldown < 0 {
+		errs["sync.cooldown"] = "must not be negative"
+	}
+	if c.Tags.Concurrency < 1 || c.Tags.Concurrency > MaxTagConcurrency {
+		errs["tags.concurrency"] = fmt.Sprintf("must be between 1 and %d", MaxTagConcurrency)
+	}
+	positive("tags.cache_ttl", c.Tags.CacheTTL)
+
+	var settingsErrs eras.ValidationError
+	if err := c.Clustering.settings().Validate(); errors.As(err, &settingsErrs) {
+		for field, msg := range settingsErrs {
+			errs["clustering."+field] = msg
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkBaseURL returns a problem with the server's public URL, or "".
+func checkBaseURL(s Server) string {
+	if s.BaseURL == "" {
+		// Derived from the listen address, which must name a reachable host
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err == nil && (host == "" || net.ParseIP(host).IsUnspecified()) {
+			return "must be set when server.addr listens on all interfaces"
+		}
+		return ""
+	}
+
+	u, err := url.Parse(s.BaseURL)
+	switch {
+	case err != nil:
+		return "must be a URL"
+	case u.Scheme != "http" && u.Scheme != "https":
+		return "must be an http or https URL"
+	case u.Host == "":
+		return "must include a host"
+	case u.RawQuery != "" || u.Fragment != "":
+		return "must not have a query or fragment"
+	}
+	return ""
+}
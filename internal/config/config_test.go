@@ -0,0 +1,202 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env returns an environment lookup over vars.
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+// writeFile writes a config file into a temporary directory.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := load("", env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Addr != DefaultAddr {
+		t.Errorf("Addr = %q, want %q", cfg.Server.Addr, DefaultAddr)
+	}
+	if got := cfg.Server.RedirectURI(); got != "http://127.0.0.1:8080/callback" {
+		t.Errorf("RedirectURI() = %q, want the loopback callback", got)
+	}
+	if cfg.Sync.Cooldown != time.Hour || cfg.Tags.Concurrency != 5 || cfg.Tags.CacheTTL != 30*24*time.Hour {
+		t.Errorf("defaults = %+v %+v, want 1h cooldown, 5 lookups, 30-day cache", cfg.Sync, cfg.Tags)
+	}
+	if cfg.Clustering.NumClusters != 3 {
+		t.Errorf("NumClusters = %d, want 3", cfg.Clustering.NumClusters)
+	}
+}
+
+func TestLoad_YAMLWithEnvOverrides(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  addr: 0.0.0.0:9000
+  base_url: https://eras.example.com/
+  write_timeout: 30s
+database:
+  url: postgres://file
+tags:
+  concurrency: 8
+clustering:
+  num_clusters: 6
+  name_template: "{season} {year}"
+`)
+	cfg, err := load(path, env(map[string]string{
+		"DATABASE_URL":          "postgres://env",
+		"TAG_CACHE_TTL":         "168h",
+		"ERA_TIME_WEIGHT":       "0.5",
+		"SPOTIFY_ID":            "client",
+		"LASTFM_API_KEY":        "key",
+		"ERA_NUM_CLUSTERS":      "",
+		"TOKEN_ENCRYPTION_KEYS": "k1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+		"SPOTIFY_USER_ID":       "alice",
+		"SPOTIFY_CREDENTIALS":   "/tmp/credentials.json",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cfg.Server.RedirectURI(); got != "https://eras.example.com/callback" {
+		t.Errorf("RedirectURI() = %q", got)
+	}
+	if cfg.Server.WriteTimeout != 30*time.Second || cfg.Server.ReadTimeout != DefaultReadTimeout {
+		t.Errorf("timeouts = %s/%s, want the file's write timeout and the default read timeout",
+			cfg.Server.ReadTimeout, cfg.Server.WriteTimeout)
+	}
+	if cfg.Database.URL != "postgres://env" {
+		t.Errorf("Database.URL = %q, want the environment to win", cfg.Database.URL)
+	}
+	if cfg.Tags.Concurrency != 8 || cfg.Tags.CacheTTL != 168*time.Hour {
+		t.Errorf("Tags = %+v", cfg.Tags)
+	}
+	if cfg.Spotify.ClientID != "client" || cfg.LastFM.APIKey != "key" {
+		t.Errorf("credentials = %+v %+v", cfg.Spotify, cfg.LastFM)
+	}
+	if cfg.CLI.UserID != "alice" || cfg.CLI.CredentialsFile != "/tmp/credentials.json" || cfg.CLI.RefreshToken != "" {
+		t.Errorf("CLI = %+v, want the user and credential file from the environment", cfg.CLI)
+	}
+	if keys, err := cfg.Database.Keyring(); err != nil || keys.PrimaryKeyID() != "k1" {
+		t.Errorf("Keyring() = %v, %v, want k1 from the environment", keys, err)
+	}
+
+	clusterCfg := cfg.Clustering.TagClusterConfig()
+	if clusterCfg.NumClusters != 6 || clusterCfg.TimeWeight != 0.5 || clusterCfg.NameTemplate != "{season} {year}" {
+		t.Errorf("TagClusterConfig() = %+v", clusterCfg)
+	}
+	if clusterCfg.MaxTags != 50 {
+		t.Errorf("MaxTags = %d, want the default", clusterCfg.MaxTags)
+	}
+}
+
+func TestLoad_TOML(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[server]
+addr = "127.0.0.1:9090"
+shutdown_timeout = "30s"
+
+[sync]
+cooldown = "15m"
+`)
+	cfg, err := load(path, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.PublicURL() != "http://127.0.0.1:9090" {
+		t.Errorf("PublicURL() = %q", cfg.Server.PublicURL())
+	}
+	if cfg.Server.ShutdownTimeout != 30*time.Second || cfg.Sync.Cooldown != 15*time.Minute {
+		t.Errorf("ShutdownTimeout = %s, Cooldown = %s", cfg.Server.ShutdownTimeout, cfg.Sync.Cooldown)
+	}
+}
+
+func TestLoad_FileErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown YAML key": writeFile(t, "typo.yaml", "server:\n  adr: :8080\n"),
+		"unknown TOML key": writeFile(t, "typo.toml", "[tags]\nconcurency = 3\n"),
+		"bad duration":     writeFile(t, "bad.yaml", "sync:\n  cooldown: soon\n"),
+		"unsupported":      writeFile(t, "config.json", "{}"),
+		"missing file":     filepath.Join(t.TempDir(), "missing.yaml"),
+	}
+	for name, path := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := load(path, env(nil)); err == nil {
+				t.Errorf("load(%s) succeeded, want error", filepath.Base(path))
+			}
+		})
+	}
+
+	// An empty file keeps the defaults
+	if _, err := load(writeFile(t, "empty.yaml", "\n"), env(nil)); err != nil {
+		t.Errorf("load(empty.yaml) error = %v", err)
+	}
+}
+
+func TestLoad_BadEnv(t *testing.T) {
+	_, err := load("", env(map[string]string{"TAG_CONCURRENCY": "many", "SYNC_COOLDOWN": "1 hour"}))
+	if err == nil {
+		t.Fatal("load() succeeded, want error")
+	}
+	for _, name := range []string{"TAG_CONCURRENCY", "SYNC_COOLDOWN"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q doesn't name %s", err, name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		key    string
+	}{
+		{"addr without port", func(c *Config) { c.Server.Addr = "localhost" }, "server.addr"},
+		{"all interfaces without base URL", func(c *Config) { c.Server.Addr = ":8080" }, "server.base_url"},
+		{"base URL scheme", func(c *Config) { c.Server.BaseURL = "ftp://example.com" }, "server.base_url"},
+		{"base URL query", func(c *Config) { c.Server.BaseURL = "https://example.com/?x=1" }, "server.base_url"},
+		{"zero timeout", func(c *Config) { c.Server.ReadTimeout = 0 }, "server.read_timeout"},
+		{"token keys", func(c *Config) { c.Database.TokenEncryptionKeys = "k1:not-base64!" }, "database.token_encryption_keys"},
+		{"negative cooldown", func(c *Config) { c.Sync.Cooldown = -time.Minute }, "sync.cooldown"},
+		{"concurrency", func(c *Config) { c.Tags.Concurrency = 0 }, "tags.concurrency"},
+		{"cache TTL", func(c *Config) { c.Tags.CacheTTL = 0 }, "tags.cache_ttl"},
+		{"clusters", func(c *Config) { c.Clustering.NumClusters = 0 }, "clustering.num_clusters"},
+		{"name template", func(c *Config) { c.Clustering.NameTemplate = "{bogus}" }, "clustering.name_template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+
+			var errs ValidationError
+			if err := cfg.Validate(); !errors.As(err, &errs) {
+				t.Fatalf("Validate() error = %v, want ValidationError", err)
+			}
+			if _, ok := errs[tt.key]; !ok || len(errs) != 1 {
+				t.Errorf("Validate() = %v, want only %s", errs, tt.key)
+			}
+		})
+	}
+
+	// Listening on all interfaces is fine behind a public URL
+	cfg := Default()
+	cfg.Server.Addr = ":8080"
+	cfg.Server.BaseURL = "https://eras.example.com"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pgArtistRepository implements ArtistRepository with PostgreSQL.
+type pgArtistRepository struct {
+	pool *pgxpool.Pool
+}
+
+// UpsertBatch inserts or updates multiple artists efficiently.
+func (r *pgArtistRepository) UpsertBatch(ctx context.Context, artists []Artist) error {
+	if len(artists) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO artists (id, name, created_at)
+		SELECT id, name, NOW() FROM unnest($1::text[], $2::text[]) AS a(id, name)
+		ON CONFLICT (id) DO UPDATE SET
+			name = EXCLUDED.name
+	`
+
+	ids := make([]string, len(artists))
+	names := make([]string, len(artists))
+	for i, a := range artists {
+		ids[i] = a.ID
+		names[i] = a.Name
+	}
+
+	_, err := r.pool.Exec(ctx, query, ids, names)
+	if err != nil {
+		return fmt.Errorf("batch upserting artists: %w", err)
+	}
+	return nil
+}
+
+// ReplaceTrackArtists sets the credited artists for the given tracks.
+// Existing credits for every track in links are replaced, so artists removed
+// from a track on Spotify are removed here too.
+func (r *pgArtistRepository) ReplaceTrackArtists(ctx context.Context, links []TrackArtist) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	trackIDs := make([]string, len(links))
+	artistIDs := make([]string, len(links))
+	positions := make([]int, len(links))
+	for i, l := range links {
+		trackIDs[i] = l.TrackID
+		artistIDs[i] = l.ArtistID
+		positions[i] = l.Position
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM track_artists WHERE track_id = ANY($1)`, trackIDs)
+	if err != nil {
+		return fmt.Errorf("deleting track artists: %w", err)
+	}
+
+	query := `
+		INSERT INTO track_artists (track_id, artist_id, position)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::int[])
+		ON CONFLICT (track_id, artist_id) DO NOTHING
+	`
+	_, err = tx.Exec(ctx, query, trackIDs, artistIDs, positions)
+	if err != nil {
+		return fmt.Errorf("inserting track artists: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// GetForTracks retrieves the credited artists for multiple tracks, returning a
+// map of track ID to artists ordered by credit position (primary artist first).
+func (r *pgArtistRepository) GetForTracks(ctx context.Context, trackIDs []string) (map[string][]Artist, error) {
+	if len(trackIDs) == 0 {
+		return make(map[string][]Artist), nil
+	}
+
+	query := `
+		SELECT ta.track_id, a.id, a.name, a.created_at
+		FROM track_artists ta
+		JOIN artists a ON a.id = ta.artist_id
+		WHERE ta.track_id = ANY($1)
+		ORDER BY ta.track_id, ta.position
+	`
+	rows, err := r.pool.Query(ctx, query, trackIDs)
+	if err != nil {
+		return nil, fmt.Errorf("querying track artists: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]Artist)
+	for rows.Next() {
+		var trackID string
+		var artist Artist
+		if err := rows.Scan(
+			&trackID,
+			&artist.ID,
+			&artist.Name,
+			&artist.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scanning artist: %w", err)
+		}
+		result[trackID] = append(result[trackID], artist)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,212 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Token encryption errors.
+var (
+	ErrNoKeyring  = errors.New("token is encrypted but no encryption keys are configured")
+	ErrUnknownKey = errors.New("token is encrypted with an unknown key")
+)
+
+// encryptedPrefix marks a column value sealed by a Keyring. Values without
+// it are plaintext written before encryption was enabled.
+const encryptedPrefix = "enc:v1:"
+
+// keySize is the length of key-encryption and data keys: AES-256.
+const keySize = 32
+
+// Keyring encrypts OAuth tokens at rest with envelope encryption. Each value
+// gets a fresh random data key that encrypts it with AES-GCM; the data key
+// is in turn encrypted (wrapped) with a key-encryption key from the ring and
+// stored next to the value with that key's ID. New values always use the
+// primary key. Older keys stay on the ring so values sealed with them can
+// still be read until RotateTokens re-encrypts them.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring creates a keyring from 32-byte keys by ID. primary names the
+// key used to encrypt new values.
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %q is not in the keyring", primary)
+	}
+	k := &Keyring{primary: primary, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || strings.ContainsAny(id, ":,") {
+			return nil, fmt.Errorf("invalid key ID %q: must be non-empty without ':' or ','", id)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("key %q is %d bytes, want %d", id, len(key), keySize)
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		k.keys[id] = aead
+	}
+	return k, nil
+}
+
+// ParseKeyring parses a keyring from a comma-separated list of
+// "<id>:<base64 key>" entries. The first entry is the primary key, so a new
+// key is rotated in by putting it first. An empty spec returns a nil
+// keyring, which leaves tokens unencrypted.
+func ParseKeyring(spec string) (*Keyring, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	var primary string
+	keys := make(map[string][]byte)
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid key entry %q: want <id>:<base64 key>", entry)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, fmt.Errorf("duplicate key ID %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("decoding key %q: %w", id, err)
+		}
+		keys[id] = key
+		if primary == "" {
+			primary = id
+		}
+	}
+	return NewKeyring(primary, keys)
+}
+
+// PrimaryKeyID returns the ID of the key new values are encrypted with.
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary
+}
+
+// Seal encrypts plaintext with a new data key wrapped by the primary key.
+// A nil keyring returns plaintext unchanged, as does an empty value.
+func (k *Keyring) Seal(plaintext string) (string, error) {
+	if k == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("generating data key: %w", err)
+	}
+	data, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	// The key ID is authenticated with the wrapped data key, so a value
+	// can't be made to unwrap with a different key
+	wrapped, err := seal(k.keys[k.primary], dataKey, []byte(k.primary))
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(data, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	return encryptedPrefix + k.primary + ":" + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(ciphertext), nil
+}
+
+// Open decrypts a value sealed by Seal. Plaintext values are returned
+// unchanged so rows written before encryption was enabled stay readable.
+func (k *Keyring) Open(value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+	if k == nil {
+		return "", ErrNoKeyring
+	}
+
+	parts := strings.Split(rest, ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted token")
+	}
+	id := parts[0]
+	kek, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+
+	enc := base64.RawURLEncoding
+	wrapped, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("decoding data key: %w", err)
+	}
+	ciphertext, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("decoding token: %w", err)
+	}
+
+	dataKey, err := open(kek, wrapped, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("unwrapping data key: %w", err)
+	}
+	data, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(data, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting token: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether value should be re-encrypted: it is
+// plaintext, or sealed with a key other than the primary key.
+func (k *Keyring) NeedsRotation(value string) bool {
+	if k == nil || value == "" {
+		return false
+	}
+	return !strings.HasPrefix(value, encryptedPrefix+k.primary+":")
+}
+
+// newGCM returns an AES-GCM cipher for key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating GCM: %w", err)
+	}
+	return aead, nil
+}
+
+// seal encrypts plaintext with a random nonce, returning nonce||ciphertext.
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// open decrypts nonce||ciphertext produced by seal.
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testKey returns a base64 key of 32 copies of b.
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, keySize))
+}
+
+func mustParseKeyring(t *testing.T, spec string) *Keyring {
+	t.Helper()
+	keys, err := ParseKeyring(spec)
+	if err != nil {
+		t.Fatalf("ParseKeyring(%q) error = %v", spec, err)
+	}
+	return keys
+}
+
+func TestKeyring_RoundTrip(t *testing.T) {
+	keys := mustParseKeyring(t, "k1:"+testKey(1))
+
+	sealed, err := keys.Seal("BQD-access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, "enc:v1:k1:") || strings.Contains(sealed, "BQD-access-token") {
+		t.Errorf("Seal() = %q, want an encrypted value under k1", sealed)
+	}
+	again, _ := keys.Seal("BQD-access-token")
+	if again == sealed {
+		t.Error("sealing the same token twice gave the same ciphertext")
+	}
+
+	opened, err := keys.Open(sealed)
+	if err != nil || opened != "BQD-access-token" {
+		t.Errorf("Open() = %q, %v, want the token", opened, err)
+	}
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	old := mustParseKeyring(t, "k1:"+testKey(1))
+	sealed, err := old.Seal("refresh-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// k2 is rotated in as the primary key; k1 stays to read old values
+	rotated := mustParseKeyring(t, "k2:"+testKey(2)+", k1:"+testKey(1))
+	if rotated.PrimaryKeyID() != "k2" {
+		t.Errorf("PrimaryKeyID() = %q, want k2", rotated.PrimaryKeyID())
+	}
+	if opened, err := rotated.Open(sealed); err != nil || opened != "refresh-token" {
+		t.Errorf("Open() with rotated keyring = %q, %v, want the token", opened, err)
+	}
+	if !rotated.NeedsRotation(sealed) {
+		t.Error("value under the old key doesn't need rotation")
+	}
+	resealed, _ := rotated.Seal("refresh-token")
+	if rotated.NeedsRotation(resealed) {
+		t.Error("value under the primary key needs rotation")
+	}
+
+	// Once k1 is dropped, its values can't be read
+	dropped := mustParseKeyring(t, "k2:"+testKey(2))
+	if _, err := dropped.Open(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Open() after dropping k1 error = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestKeyring_Plaintext(t *testing.T) {
+	keys := mustParseKeyring(t, "k1:"+testKey(1))
+
+	// Rows written before encryption was enabled stay readable
+	if opened, err := keys.Open("legacy-token"); err != nil || opened != "legacy-token" {
+		t.Errorf("Open(plaintext) = %q, %v, want it unchanged", opened, err)
+	}
+	if !keys.NeedsRotation("legacy-token") {
+		t.Error("plaintext doesn't need rotation")
+	}
+	if keys.NeedsRotation("") {
+		t.Error("empty token needs rotation")
+	}
+
+	// Without a keyring nothing is encrypted, and encrypted values fail
+	var none *Keyring
+	if sealed, err := none.Seal("token"); err != nil || sealed != "token" {
+		t.Errorf("nil Seal() = %q, %v, want plaintext", sealed, err)
+	}
+	sealed, _ := keys.Seal("token")
+	if _, err := none.Open(sealed); !errors.Is(err, ErrNoKeyring) {
+		t.Errorf("nil Open() error = %v, want ErrNoKeyring", err)
+	}
+}
+
+func TestKeyring_Tampered(t *testing.T) {
+	keys := mustParseKeyring(t, "k1:"+testKey(1))
+	sealed, err := keys.Seal("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Claiming another key ID fails authentication of the wrapped data key
+	other := mustParseKeyring(t, "k1:"+testKey(1)+",k2:"+testKey(1))
+	relabeled := strings.Replace(sealed, ":k1:", ":k2:", 1)
+	if _, err := other.Open(relabeled); err == nil {
+		t.Error("Open() accepted a value relabeled with another key ID")
+	}
+
+	flipped := []byte(sealed)
+	flipped[len(flipped)-2] ^= 'A' ^ 'B'
+	if _, err := keys.Open(string(flipped)); err == nil {
+		t.Error("Open() accepted a modified ciphertext")
+	}
+	if _, err := keys.Open("enc:v1:k1:garbage"); err == nil {
+		t.Error("Open() accepted a malformed value")
+	}
+}
+
+func TestParseKeyring_Errors(t *testing.T) {
+	tests := map[string]string{
+		"missing separator": testKey(1),
+		"bad base64":        "k1:not base64!",
+		"short key":         "k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"empty ID":          ":" + testKey(1),
+		"duplicate ID":      "k1:" + testKey(1) + ",k1:" + testKey(2),
+	}
+	for name, spec := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseKeyring(spec); err == nil {
+				t.Errorf("ParseKeyring(%q) succeeded, want error", spec)
+			}
+		})
+	}
+
+	if keys, err := ParseKeyring("  "); keys != nil || err != nil {
+		t.Errorf("ParseKeyring(blank) = %v, %v, want no keyring", keys, err)
+	}
+}
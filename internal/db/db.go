@@ -1,10 +1,13 @@
-// Package db provides PostgreSQL database access for the Spotify Era Organizer.
+// Package db provides database access for the Spotify Era Organizer,
+// backed by PostgreSQL or, for single-user self-hosting, SQLite.
 package db
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -14,13 +17,27 @@ var (
 	ErrNotFound = errors.New("not found")
 )
 
-// DB wraps a PostgreSQL connection pool.
+// Storage backends, as returned by DB.Driver.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// DB wraps a PostgreSQL connection pool or a SQLite database.
 type DB struct {
-	pool *pgxpool.Pool
+	pool   *pgxpool.Pool // nil for SQLite
+	sqlite *sql.DB       // nil for PostgreSQL
+	keys   *Keyring
 }
 
-// New creates a new database connection pool.
+// New connects to the database at databaseURL. URLs starting with
+// sqlite:// open a SQLite database file, e.g. sqlite://eras.db; any other
+// URL is a PostgreSQL connection string.
 func New(ctx context.Context, databaseURL string) (*DB, error) {
+	if strings.HasPrefix(databaseURL, sqliteScheme) {
+		return openSQLite(ctx, strings.TrimPrefix(databaseURL, sqliteScheme))
+	}
+
 	config, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("parsing database URL: %w", err)
@@ -42,35 +59,101 @@ func New(ctx context.Context, databaseURL string) (*DB, error) {
 
 // Close closes the database connection pool.
 func (db *DB) Close() {
+	if db.sqlite != nil {
+		db.sqlite.Close()
+		return
+	}
 	db.pool.Close()
 }
 
-// Pool returns the underlying connection pool for advanced operations.
+// Driver returns the storage backend: DriverPostgres or DriverSQLite.
+func (db *DB) Driver() string {
+	if db.sqlite != nil {
+		return DriverSQLite
+	}
+	return DriverPostgres
+}
+
+// SetKeyring sets the keys OAuth tokens are encrypted with. Without a
+// keyring tokens are stored in plaintext.
+func (db *DB) SetKeyring(keys *Keyring) {
+	db.keys = keys
+}
+
+// Pool returns the underlying connection pool for advanced operations. It
+// is nil for SQLite.
 func (db *DB) Pool() *pgxpool.Pool {
 	return db.pool
 }
 
 // Users returns a UserRepository.
-func (db *DB) Users() *UserRepository {
-	return &UserRepository{pool: db.pool}
+func (db *DB) Users() UserRepository {
+	if db.sqlite != nil {
+		return &sqliteUserRepository{db: db.sqlite, keys: db.keys}
+	}
+	return &pgUserRepository{pool: db.pool, keys: db.keys}
 }
 
 // Sessions returns a SessionRepository.
-func (db *DB) Sessions() *SessionRepository {
-	return &SessionRepository{pool: db.pool}
+func (db *DB) Sessions() SessionRepository {
+	if db.sqlite != nil {
+		return &sqliteSessionRepository{db: db.sqlite, keys: db.keys}
+	}
+	return &pgSessionRepository{pool: db.pool, keys: db.keys}
 }
 
 // Tracks returns a TrackRepository.
-func (db *DB) Tracks() *TrackRepository {
-	return &TrackRepository{pool: db.pool}
+func (db *DB) Tracks() TrackRepository {
+	if db.sqlite != nil {
+		return &sqliteTrackRepository{db: db.sqlite}
+	}
+	return &pgTrackRepository{pool: db.pool}
+}
+
+// Artists returns an ArtistRepository.
+func (db *DB) Artists() ArtistRepository {
+	if db.sqlite != nil {
+		return &sqliteArtistRepository{db: db.sqlite}
+	}
+	return &pgArtistRepository{pool: db.pool}
 }
 
 // Tags returns a TagRepository.
-func (db *DB) Tags() *TagRepository {
-	return &TagRepository{pool: db.pool}
+func (db *DB) Tags() TagRepository {
+	if db.sqlite != nil {
+		return &sqliteTagRepository{db: db.sqlite}
+	}
+	return &pgTagRepository{pool: db.pool}
+}
+
+// Plays returns a PlayRepository.
+func (db *DB) Plays() PlayRepository {
+	if db.sqlite != nil {
+		return &sqlitePlayRepository{db: db.sqlite}
+	}
+	return &pgPlayRepository{pool: db.pool}
 }
 
 // Eras returns an EraRepository.
-func (db *DB) Eras() *EraRepository {
-	return &EraRepository{pool: db.pool}
+func (db *DB) Eras() EraRepository {
+	if db.sqlite != nil {
+		return &sqliteEraRepository{db: db.sqlite}
+	}
+	return &pgEraRepository{pool: db.pool}
+}
+
+// Settings returns a SettingsRepository.
+func (db *DB) Settings() SettingsRepository {
+	if db.sqlite != nil {
+		return &sqliteSettingsRepository{db: db.sqlite}
+	}
+	return &pgSettingsRepository{pool: db.pool}
+}
+
+// Housekeeping returns a HousekeepingRepository.
+func (db *DB) Housekeeping() HousekeepingRepository {
+	if db.sqlite != nil {
+		return &sqliteHousekeepingRepository{db: db.sqlite}
+	}
+	return &pgHousekeepingRepository{pool: db.pool}
 }
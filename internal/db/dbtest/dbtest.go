@@ -0,0 +1,113 @@
+// Package dbtest provides in-memory implementations of the db repositories,
+// so services can be tested without a database. They follow the same rules
+// as the SQL implementations: ownership checks, ErrNotFound, ordering and
+// the effects of cascading deletes. NewSQLite opens a real SQLite database
+// for tests that need the SQL repositories themselves.
+package dbtest
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// Store holds users, their libraries, tags, plays, eras and settings in
+// memory. Its accessors mirror *db.DB's, so it can stand in for the
+// database wherever a service takes the repositories it needs. The zero
+// value is not usable; create one with NewStore. It is safe for concurrent
+// use.
+type Store struct {
+	mu sync.Mutex
+
+	users    map[string]*userRow
+	tracks   map[string]db.Track
+	upserted map[string]time.Time            // Track ID to last upsert
+	library  map[string]map[string]time.Time // User ID to track ID to added_at
+	artists  map[string]db.Artist
+	credits  map[string][]db.TrackArtist // Track ID to credits
+	tags     map[string][]db.TrackTag    // Track ID to Last.fm tags
+	userTags []db.UserTrackTag
+	plays    []db.Play
+	nextPlay int64
+	eras     map[uuid.UUID]db.Era
+	eraOrder []uuid.UUID // Era IDs in creation order
+	members  map[uuid.UUID][]db.EraTrack
+	settings map[string]db.UserSettings
+}
+
+// userRow is a stored user with their Spotify token, if any.
+type userRow struct {
+	user         db.User
+	token        *db.UserToken
+	syncFailedAt *time.Time
+}
+
+// NewStore creates an empty store.
+func NewStore() *Store {
+	return &Store{
+		users:    make(map[string]*userRow),
+		tracks:   make(map[string]db.Track),
+		upserted: make(map[string]time.Time),
+		library:  make(map[string]map[string]time.Time),
+		artists:  make(map[string]db.Artist),
+		credits:  make(map[string][]db.TrackArtist),
+		tags:     make(map[string][]db.TrackTag),
+		eras:     make(map[uuid.UUID]db.Era),
+		members:  make(map[uuid.UUID][]db.EraTrack),
+		settings: make(map[string]db.UserSettings),
+	}
+}
+
+// Users returns the user repository.
+func (s *Store) Users() db.UserRepository {
+	return &userRepository{s}
+}
+
+// Tracks returns the track repository.
+func (s *Store) Tracks() db.TrackRepository {
+	return &trackRepository{s}
+}
+
+// Artists returns the artist repository.
+func (s *Store) Artists() db.ArtistRepository {
+	return &artistRepository{s}
+}
+
+// Tags returns the tag repository.
+func (s *Store) Tags() db.TagRepository {
+	return &tagRepository{s}
+}
+
+// Plays returns the play repository.
+func (s *Store) Plays() db.PlayRepository {
+	return &playRepository{s}
+}
+
+// Eras returns the era repository.
+func (s *Store) Eras() db.EraRepository {
+	return &eraRepository{s}
+}
+
+// Settings returns the settings repository.
+func (s *Store) Settings() db.SettingsRepository {
+	return &settingsRepository{s}
+}
+
+// inLibrary reports whether the track is in the user's library. The caller
+// must hold s.mu.
+func (s *Store) inLibrary(userID, trackID string) bool {
+	_, ok := s.library[userID][trackID]
+	return ok
+}
+
+// copyEra returns era with its slices copied, so callers can't modify the
+// stored era.
+func copyEra(era db.Era) db.Era {
+	era.TopTags = slices.Clone(era.TopTags)
+	era.TagWeights = slices.Clone(era.TagWeights)
+	return era
+}
@@ -0,0 +1,256 @@
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// eraRepository implements db.EraRepository in memory. Lookups by ID are
+// scoped to the owner like the SQL implementations: another user's era is
+// missing.
+type eraRepository struct {
+	s *Store
+}
+
+func (r *eraRepository) Create(ctx context.Context, era *db.Era, trackIDs []string) error {
+	tracks := make([]db.EraTrack, len(trackIDs))
+	for i, id := range trackIDs {
+		tracks[i] = db.EraTrack{TrackID: id}
+	}
+	return r.CreateWithTracks(ctx, era, tracks)
+}
+
+func (r *eraRepository) CreateWithTracks(_ context.Context, era *db.Era, tracks []db.EraTrack) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.createEra(era, tracks)
+}
+
+func (r *eraRepository) ReplaceForUser(_ context.Context, userID string, eras []db.Era, tracks [][]db.EraTrack) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[userID]; !ok {
+		return db.ErrNotFound
+	}
+
+	// Keep the current eras to restore if a create fails, like a rollback
+	saved, savedOrder, savedMembers := maps.Clone(r.s.eras), slices.Clone(r.s.eraOrder), maps.Clone(r.s.members)
+	r.s.deleteEras(func(era db.Era) bool { return era.UserID == userID })
+	for i := range eras {
+		if err := r.s.createEra(&eras[i], tracks[i]); err != nil {
+			r.s.eras, r.s.eraOrder, r.s.members = saved, savedOrder, savedMembers
+			return fmt.Errorf("creating era %q: %w", eras[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// createEra inserts an era and its tracks, checking references like the
+// database's foreign keys. The caller must hold s.mu.
+func (s *Store) createEra(era *db.Era, tracks []db.EraTrack) error {
+	if _, ok := s.users[era.UserID]; !ok {
+		return errForeignKey("eras", "user_id", era.UserID)
+	}
+	if era.ParentID != nil {
+		if _, ok := s.eras[*era.ParentID]; !ok {
+			return errForeignKey("eras", "parent_id", era.ParentID.String())
+		}
+	}
+	for _, t := range tracks {
+		if _, ok := s.tracks[t.TrackID]; !ok {
+			return errForeignKey("era_tracks", "track_id", t.TrackID)
+		}
+	}
+	if era.ID == uuid.Nil {
+		era.ID = uuid.New()
+	}
+	if _, ok := s.eras[era.ID]; ok {
+		return fmt.Errorf("inserting era: era %s already exists", era.ID)
+	}
+
+	era.CreatedAt = time.Now()
+	s.eras[era.ID] = copyEra(*era)
+	s.eraOrder = append(s.eraOrder, era.ID)
+	members := make([]db.EraTrack, len(tracks))
+	for i, t := range tracks {
+		members[i] = db.EraTrack{EraID: era.ID, TrackID: t.TrackID, ReasonTags: slices.Clone(t.ReasonTags)}
+	}
+	s.members[era.ID] = members
+	return nil
+}
+
+// owned returns one of the user's eras. The caller must hold s.mu.
+func (s *Store) owned(userID string, id uuid.UUID) (db.Era, bool) {
+	era, ok := s.eras[id]
+	return era, ok && era.UserID == userID
+}
+
+func (r *eraRepository) GetForOwner(_ context.Context, userID string, id uuid.UUID) (*db.Era, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	era, ok := r.s.owned(userID, id)
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	era = copyEra(era)
+	return &era, nil
+}
+
+func (r *eraRepository) GetForUser(_ context.Context, userID string) ([]db.Era, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var eras []db.Era
+	depths := make(map[uuid.UUID]int)
+	for _, id := range r.s.eraOrder {
+		era := r.s.eras[id]
+		if era.UserID != userID {
+			continue
+		}
+		for parent := era.ParentID; parent != nil; parent = r.s.eras[*parent].ParentID {
+			depths[id]++
+		}
+		eras = append(eras, copyEra(era))
+	}
+
+	// Parents before their sub-eras, then newest first
+	sort.SliceStable(eras, func(i, j int) bool {
+		if di, dj := depths[eras[i].ID], depths[eras[j].ID]; di != dj {
+			return di < dj
+		}
+		return eras[i].StartDate.After(eras[j].StartDate)
+	})
+	return eras, nil
+}
+
+func (r *eraRepository) GetTracksForOwner(_ context.Context, userID string, eraID uuid.UUID) ([]db.Track, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.owned(userID, eraID); !ok {
+		return nil, nil
+	}
+	var tracks []db.Track
+	for _, t := range r.s.members[eraID] {
+		tracks = append(tracks, r.s.tracks[t.TrackID])
+	}
+	return tracks, nil
+}
+
+func (r *eraRepository) GetTrackReasonsForOwner(_ context.Context, userID string, eraID uuid.UUID) (map[string][]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	reasons := make(map[string][]string)
+	if _, ok := r.s.owned(userID, eraID); !ok {
+		return reasons, nil
+	}
+	for _, t := range r.s.members[eraID] {
+		if len(t.ReasonTags) > 0 {
+			reasons[t.TrackID] = slices.Clone(t.ReasonTags)
+		}
+	}
+	return reasons, nil
+}
+
+func (r *eraRepository) GetTrackIDsForUser(_ context.Context, userID string) (map[uuid.UUID][]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	trackIDs := make(map[uuid.UUID][]string)
+	for id, era := range r.s.eras {
+		if era.UserID != userID {
+			continue
+		}
+		for _, t := range r.s.members[id] {
+			trackIDs[id] = append(trackIDs[id], t.TrackID)
+		}
+	}
+	return trackIDs, nil
+}
+
+func (r *eraRepository) GetTrackCountForOwner(_ context.Context, userID string, eraID uuid.UUID) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.owned(userID, eraID); !ok {
+		return 0, nil
+	}
+	return len(r.s.members[eraID]), nil
+}
+
+func (r *eraRepository) UpdatePlaylistIDForOwner(_ context.Context, userID string, eraID uuid.UUID, playlistID string) error {
+	return r.s.updateEra(userID, eraID, func(era *db.Era) {
+		era.PlaylistID = &playlistID
+	})
+}
+
+func (r *eraRepository) UpdateNameOverrideForOwner(_ context.Context, userID string, eraID uuid.UUID, name *string) error {
+	return r.s.updateEra(userID, eraID, func(era *db.Era) {
+		era.NameOverride = name
+	})
+}
+
+// updateEra applies update to one of the user's eras. Returns
+// db.ErrNotFound if the era doesn't exist or belongs to another user.
+func (s *Store) updateEra(userID string, eraID uuid.UUID, update func(era *db.Era)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	era, ok := s.owned(userID, eraID)
+	if !ok {
+		return db.ErrNotFound
+	}
+	update(&era)
+	s.eras[eraID] = era
+	return nil
+}
+
+func (r *eraRepository) DeleteForUser(_ context.Context, userID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.deleteEras(func(era db.Era) bool { return era.UserID == userID })
+	return nil
+}
+
+func (r *eraRepository) DeleteForOwner(_ context.Context, userID string, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.owned(userID, id); !ok {
+		return db.ErrNotFound
+	}
+	r.s.deleteEras(func(era db.Era) bool { return era.ID == id })
+	return nil
+}
+
+// deleteEras removes the eras that match, their tracks and, as the
+// database cascades, their sub-eras at every depth. The caller must hold
+// s.mu.
+func (s *Store) deleteEras(match func(era db.Era) bool) {
+	deleted := make(map[uuid.UUID]bool)
+	// eraOrder has parents before their sub-eras
+	for _, id := range s.eraOrder {
+		era := s.eras[id]
+		if match(era) || (era.ParentID != nil && deleted[*era.ParentID]) {
+			deleted[id] = true
+		}
+	}
+	s.eraOrder = slices.DeleteFunc(s.eraOrder, func(id uuid.UUID) bool { return deleted[id] })
+	for id := range deleted {
+		delete(s.eras, id)
+		delete(s.members, id)
+	}
+}
@@ -0,0 +1,446 @@
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// errForeignKey reports a reference to a missing row, which the database
+// rejects with a foreign key violation.
+func errForeignKey(table, column, value string) error {
+	return fmt.Errorf("%s: no row for %s %q: violates foreign key constraint", table, column, value)
+}
+
+// trackRepository implements db.TrackRepository in memory.
+type trackRepository struct {
+	s *Store
+}
+
+func (r *trackRepository) Upsert(_ context.Context, track *db.Track) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	track.CreatedAt = r.s.upsertTrack(*track, time.Now(), true)
+	return nil
+}
+
+func (r *trackRepository) UpsertBatch(_ context.Context, tracks []db.Track) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	for _, t := range tracks {
+		r.s.upsertTrack(t, now, true)
+	}
+	return nil
+}
+
+func (r *trackRepository) InsertBatchIfAbsent(_ context.Context, tracks []db.Track) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	for _, t := range tracks {
+		r.s.upsertTrack(t, now, false)
+	}
+	return nil
+}
+
+// upsertTrack inserts a track created at now, or updates its metadata if it
+// exists and update is set. Either way the track counts as upserted at now.
+// Returns the stored track's creation time. The caller must hold s.mu.
+func (s *Store) upsertTrack(track db.Track, now time.Time, update bool) time.Time {
+	s.upserted[track.ID] = now
+	prev, ok := s.tracks[track.ID]
+	if ok && !update {
+		return prev.CreatedAt
+	}
+	track.CreatedAt = now
+	if ok {
+		track.CreatedAt = prev.CreatedAt
+	}
+	s.tracks[track.ID] = track
+	return track.CreatedAt
+}
+
+func (r *trackRepository) Get(_ context.Context, id string) (*db.Track, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	track, ok := r.s.tracks[id]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	return &track, nil
+}
+
+func (r *trackRepository) GetUserTracks(ctx context.Context, userID string) ([]db.Track, error) {
+	_, tracks, err := r.GetUserTracksWithAddedAt(ctx, userID)
+	return tracks, err
+}
+
+func (r *trackRepository) GetUserTracksWithAddedAt(_ context.Context, userID string) ([]db.UserTrack, []db.Track, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var userTracks []db.UserTrack
+	for trackID, addedAt := range r.s.library[userID] {
+		userTracks = append(userTracks, db.UserTrack{UserID: userID, TrackID: trackID, AddedAt: addedAt})
+	}
+	sort.Slice(userTracks, func(i, j int) bool {
+		if !userTracks[i].AddedAt.Equal(userTracks[j].AddedAt) {
+			return userTracks[i].AddedAt.After(userTracks[j].AddedAt)
+		}
+		return userTracks[i].TrackID < userTracks[j].TrackID
+	})
+
+	var tracks []db.Track
+	for _, ut := range userTracks {
+		tracks = append(tracks, r.s.tracks[ut.TrackID])
+	}
+	return userTracks, tracks, nil
+}
+
+func (r *trackRepository) LinkToUser(ctx context.Context, userID, trackID string, addedAt time.Time) error {
+	return r.LinkBatchToUser(ctx, userID, []db.UserTrack{{UserID: userID, TrackID: trackID, AddedAt: addedAt}})
+}
+
+func (r *trackRepository) LinkBatchToUser(_ context.Context, userID string, tracks []db.UserTrack) error {
+	return r.s.link(userID, tracks, true)
+}
+
+func (r *trackRepository) LinkBatchToUserIfAbsent(_ context.Context, userID string, tracks []db.UserTrack) error {
+	return r.s.link(userID, tracks, false)
+}
+
+// link adds tracks to a user's library, updating the added_at of tracks
+// already in it if update is set. Like a transaction, nothing is linked if
+// any user or track doesn't exist.
+func (s *Store) link(userID string, tracks []db.UserTrack, update bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(tracks) == 0 {
+		return nil
+	}
+	if _, ok := s.users[userID]; !ok {
+		return errForeignKey("user_tracks", "user_id", userID)
+	}
+	for _, t := range tracks {
+		if _, ok := s.tracks[t.TrackID]; !ok {
+			return errForeignKey("user_tracks", "track_id", t.TrackID)
+		}
+	}
+
+	if s.library[userID] == nil {
+		s.library[userID] = make(map[string]time.Time)
+	}
+	for _, t := range tracks {
+		if _, ok := s.library[userID][t.TrackID]; ok && !update {
+			continue
+		}
+		s.library[userID][t.TrackID] = t.AddedAt
+	}
+	return nil
+}
+
+func (r *trackRepository) UnlinkAllFromUser(_ context.Context, userID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	delete(r.s.library, userID)
+	return nil
+}
+
+func (r *trackRepository) DeleteOrphanTags(_ context.Context, cutoff time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var removed int64
+	for _, id := range r.s.orphans(cutoff) {
+		removed += int64(len(r.s.tags[id]))
+		delete(r.s.tags, id)
+	}
+	return removed, nil
+}
+
+func (r *trackRepository) DeleteOrphans(_ context.Context, cutoff time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	orphans := r.s.orphans(cutoff)
+	for _, id := range orphans {
+		delete(r.s.tracks, id)
+		delete(r.s.upserted, id)
+		delete(r.s.tags, id)
+		delete(r.s.credits, id)
+	}
+	return int64(len(orphans)), nil
+}
+
+// orphans returns the tracks last upserted before cutoff that nothing refers to:
+// no user has liked, tagged or played them and no era contains them. The
+// caller must hold s.mu.
+func (s *Store) orphans(cutoff time.Time) []string {
+	used := make(map[string]bool)
+	for _, tracks := range s.library {
+		for id := range tracks {
+			used[id] = true
+		}
+	}
+	for _, t := range s.userTags {
+		used[t.TrackID] = true
+	}
+	for _, tracks := range s.members {
+		for _, t := range tracks {
+			used[t.TrackID] = true
+		}
+	}
+	for _, p := range s.plays {
+		if p.TrackID != nil {
+			used[*p.TrackID] = true
+		}
+	}
+
+	var ids []string
+	for id := range s.tracks {
+		if s.upserted[id].Before(cutoff) && !used[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// artistRepository implements db.ArtistRepository in memory.
+type artistRepository struct {
+	s *Store
+}
+
+func (r *artistRepository) UpsertBatch(_ context.Context, artists []db.Artist) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	for _, a := range artists {
+		a.CreatedAt = now
+		if prev, ok := r.s.artists[a.ID]; ok {
+			a.CreatedAt = prev.CreatedAt
+		}
+		r.s.artists[a.ID] = a
+	}
+	return nil
+}
+
+func (r *artistRepository) ReplaceTrackArtists(_ context.Context, links []db.TrackArtist) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, l := range links {
+		if _, ok := r.s.tracks[l.TrackID]; !ok {
+			return errForeignKey("track_artists", "track_id", l.TrackID)
+		}
+		if _, ok := r.s.artists[l.ArtistID]; !ok {
+			return errForeignKey("track_artists", "artist_id", l.ArtistID)
+		}
+	}
+
+	for _, l := range links {
+		delete(r.s.credits, l.TrackID)
+	}
+	for _, l := range links {
+		credited := slices.ContainsFunc(r.s.credits[l.TrackID], func(c db.TrackArtist) bool {
+			return c.ArtistID == l.ArtistID
+		})
+		if !credited {
+			r.s.credits[l.TrackID] = append(r.s.credits[l.TrackID], l)
+		}
+	}
+	return nil
+}
+
+func (r *artistRepository) GetForTracks(_ context.Context, trackIDs []string) (map[string][]db.Artist, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	result := make(map[string][]db.Artist)
+	for _, id := range trackIDs {
+		credits := slices.Clone(r.s.credits[id])
+		sort.Slice(credits, func(i, j int) bool { return credits[i].Position < credits[j].Position })
+		for _, c := range credits {
+			result[id] = append(result[id], r.s.artists[c.ArtistID])
+		}
+	}
+	return result, nil
+}
+
+// tagRepository implements db.TagRepository in memory.
+type tagRepository struct {
+	s *Store
+}
+
+func (r *tagRepository) UpsertBatch(_ context.Context, tags []db.TrackTag) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, t := range tags {
+		if _, ok := r.s.tracks[t.TrackID]; !ok {
+			return errForeignKey("track_tags", "track_id", t.TrackID)
+		}
+	}
+	for _, t := range tags {
+		i := slices.IndexFunc(r.s.tags[t.TrackID], func(prev db.TrackTag) bool {
+			return prev.TagName == t.TagName
+		})
+		if i >= 0 {
+			r.s.tags[t.TrackID][i] = t
+		} else {
+			r.s.tags[t.TrackID] = append(r.s.tags[t.TrackID], t)
+		}
+	}
+	return nil
+}
+
+func (r *tagRepository) GetForTrack(ctx context.Context, trackID string) ([]db.TrackTag, error) {
+	tags, err := r.GetForTracks(ctx, []string{trackID})
+	if err != nil {
+		return nil, err
+	}
+	return tags[trackID], nil
+}
+
+func (r *tagRepository) GetForTracks(_ context.Context, trackIDs []string) (map[string][]db.TrackTag, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	result := make(map[string][]db.TrackTag)
+	for _, id := range trackIDs {
+		if len(r.s.tags[id]) == 0 {
+			continue
+		}
+		tags := slices.Clone(r.s.tags[id])
+		sort.SliceStable(tags, func(i, j int) bool { return tags[i].TagCount > tags[j].TagCount })
+		result[id] = tags
+	}
+	return result, nil
+}
+
+func (r *tagRepository) GetStale(_ context.Context, olderThan time.Time, limit int) ([]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var ids []string
+	for id, tags := range r.s.tags {
+		if len(ids) == limit {
+			break
+		}
+		if slices.ContainsFunc(tags, func(t db.TrackTag) bool { return t.FetchedAt.Before(olderThan) }) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *tagRepository) GetTracksWithoutTags(_ context.Context, trackIDs []string) ([]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var ids []string
+	for _, id := range trackIDs {
+		if len(r.s.tags[id]) == 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *tagRepository) GetTracksWithStaleTags(_ context.Context, trackIDs []string, olderThan time.Time) ([]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var ids []string
+	for _, id := range trackIDs {
+		tags := r.s.tags[id]
+		if len(tags) == 0 {
+			continue
+		}
+		newest := slices.MaxFunc(tags, func(a, b db.TrackTag) int { return a.FetchedAt.Compare(b.FetchedAt) })
+		if newest.FetchedAt.Before(olderThan) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *tagRepository) DeleteForTrack(_ context.Context, trackID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	delete(r.s.tags, trackID)
+	return nil
+}
+
+func (r *tagRepository) AddUserTag(_ context.Context, userID, trackID, tagName string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if !r.s.inLibrary(userID, trackID) {
+		return db.ErrNotFound
+	}
+	r.s.addUserTag(db.UserTrackTag{UserID: userID, TrackID: trackID, TagName: tagName, CreatedAt: time.Now()})
+	return nil
+}
+
+// addUserTag stores a manual tag unless the user already gave it to the
+// track. The caller must hold s.mu.
+func (s *Store) addUserTag(tag db.UserTrackTag) {
+	exists := slices.ContainsFunc(s.userTags, func(t db.UserTrackTag) bool {
+		return t.UserID == tag.UserID && t.TrackID == tag.TrackID && t.TagName == tag.TagName
+	})
+	if !exists {
+		s.userTags = append(s.userTags, tag)
+	}
+}
+
+func (r *tagRepository) RemoveUserTag(_ context.Context, userID, trackID, tagName string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.userTags = slices.DeleteFunc(r.s.userTags, func(t db.UserTrackTag) bool {
+		return t.UserID == userID && t.TrackID == trackID && t.TagName == tagName
+	})
+	return nil
+}
+
+func (r *tagRepository) GetUserTagsForTracks(_ context.Context, userID string, trackIDs []string) (map[string][]db.UserTrackTag, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	result := make(map[string][]db.UserTrackTag)
+	for _, t := range r.s.userTags {
+		if t.UserID == userID && slices.Contains(trackIDs, t.TrackID) {
+			result[t.TrackID] = append(result[t.TrackID], t)
+		}
+	}
+	for _, tags := range result {
+		sort.SliceStable(tags, func(i, j int) bool { return tags[i].CreatedAt.Before(tags[j].CreatedAt) })
+	}
+	return result, nil
+}
+
+func (r *tagRepository) InsertUserTagsBatch(_ context.Context, userID string, tags []db.UserTrackTag) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, t := range tags {
+		if r.s.inLibrary(userID, t.TrackID) {
+			t.UserID = userID
+			r.s.addUserTag(t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package dbtest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// playRepository implements db.PlayRepository in memory.
+type playRepository struct {
+	s *Store
+}
+
+func (r *playRepository) InsertBatch(_ context.Context, userID string, plays []db.Play) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[userID]; !ok && len(plays) > 0 {
+		return 0, errForeignKey("plays", "user_id", userID)
+	}
+
+	var inserted int64
+	now := time.Now()
+	for _, p := range plays {
+		if r.s.hasPlay(userID, p) {
+			continue
+		}
+		if p.TrackID != nil {
+			if _, ok := r.s.tracks[*p.TrackID]; !ok {
+				p.TrackID = nil
+			}
+		}
+		r.s.nextPlay++
+		p.ID = r.s.nextPlay
+		p.UserID = userID
+		p.CreatedAt = now
+		r.s.plays = append(r.s.plays, p)
+		inserted++
+	}
+	return inserted, nil
+}
+
+// hasPlay reports whether the user's play is already recorded: plays are
+// unique by time, artist and track name. The caller must hold s.mu.
+func (s *Store) hasPlay(userID string, play db.Play) bool {
+	for _, p := range s.plays {
+		if p.UserID == userID && p.PlayedAt.Equal(play.PlayedAt) &&
+			p.ArtistName == play.ArtistName && p.TrackName == play.TrackName {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *playRepository) MatchTracks(_ context.Context, userID string) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var matched int64
+	for i := range r.s.plays {
+		p := &r.s.plays[i]
+		if p.UserID != userID || p.TrackID != nil {
+			continue
+		}
+		if id, ok := r.s.matchPlay(userID, *p); ok {
+			p.TrackID = &id
+			matched++
+		}
+	}
+	return matched, nil
+}
+
+// matchPlay finds the track in the user's library with the play's name and
+// one of its artists, ignoring case. Of several matches, the lowest track
+// ID wins. The caller must hold s.mu.
+func (s *Store) matchPlay(userID string, play db.Play) (string, bool) {
+	var match string
+	for id := range s.library[userID] {
+		track := s.tracks[id]
+		if !strings.EqualFold(track.Name, play.TrackName) || !s.creditedTo(track, play.ArtistName) {
+			continue
+		}
+		if match == "" || id < match {
+			match = id
+		}
+	}
+	return match, match != ""
+}
+
+// creditedTo reports whether the artist, ignoring case, is the track's
+// display artist or one of its credited artists. The caller must hold s.mu.
+func (s *Store) creditedTo(track db.Track, artist string) bool {
+	if strings.EqualFold(track.Artist, artist) {
+		return true
+	}
+	for _, c := range s.credits[track.ID] {
+		if strings.EqualFold(s.artists[c.ArtistID].Name, artist) {
+			return true
+		}
+	}
+	return false
+}
+
+// libraryPlays returns the user's plays of tracks in their library, in
+// chronological order. The caller must hold s.mu.
+func (s *Store) libraryPlays(userID string) []db.Play {
+	var plays []db.Play
+	for _, p := range s.plays {
+		if p.UserID == userID && p.TrackID != nil && s.inLibrary(userID, *p.TrackID) {
+			plays = append(plays, p)
+		}
+	}
+	sort.SliceStable(plays, func(i, j int) bool { return plays[i].PlayedAt.Before(plays[j].PlayedAt) })
+	return plays
+}
+
+func (r *playRepository) GetPlayTimesForUser(_ context.Context, userID string) (map[string][]time.Time, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	result := make(map[string][]time.Time)
+	for _, p := range r.s.libraryPlays(userID) {
+		result[*p.TrackID] = append(result[*p.TrackID], p.PlayedAt)
+	}
+	return result, nil
+}
+
+func (r *playRepository) CountMatchedForUser(_ context.Context, userID string) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return len(r.s.libraryPlays(userID)), nil
+}
+
+func (r *playRepository) LatestPlayedAt(_ context.Context, userID, source string) (*time.Time, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var latest *time.Time
+	for _, p := range r.s.plays {
+		if p.UserID == userID && p.Source == source && (latest == nil || p.PlayedAt.After(*latest)) {
+			playedAt := p.PlayedAt
+			latest = &playedAt
+		}
+	}
+	return latest, nil
+}
+
+func (r *playRepository) GetForUser(_ context.Context, userID string) ([]db.Play, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var plays []db.Play
+	for _, p := range r.s.plays {
+		if p.UserID == userID {
+			plays = append(plays, p)
+		}
+	}
+	sort.SliceStable(plays, func(i, j int) bool {
+		if !plays[i].PlayedAt.Equal(plays[j].PlayedAt) {
+			return plays[i].PlayedAt.Before(plays[j].PlayedAt)
+		}
+		return plays[i].ID < plays[j].ID
+	})
+	return plays, nil
+}
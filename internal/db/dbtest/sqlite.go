@@ -0,0 +1,32 @@
+package dbtest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/migrations"
+)
+
+// NewSQLite opens a SQLite database in a temporary directory with every
+// migration applied, for tests that need the real SQL repositories. It is
+// closed when the test ends.
+func NewSQLite(t testing.TB) *db.DB {
+	t.Helper()
+	ctx := context.Background()
+	database, err := db.New(ctx, "sqlite://"+filepath.Join(t.TempDir(), "eras.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.Close)
+
+	all, err := db.LoadMigrations(migrations.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Migrate(ctx, all); err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
@@ -0,0 +1,194 @@
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// userRepository implements db.UserRepository in memory. Tokens are stored
+// in plain text.
+type userRepository struct {
+	s *Store
+}
+
+func (r *userRepository) Create(_ context.Context, user *db.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[user.ID]; ok {
+		return fmt.Errorf("inserting user: user %s already exists", user.ID)
+	}
+	now := time.Now()
+	user.CreatedAt, user.UpdatedAt = now, now
+	r.s.users[user.ID] = &userRow{user: *user}
+	return nil
+}
+
+func (r *userRepository) Get(_ context.Context, id string) (*db.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row, ok := r.s.users[id]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	user := row.user
+	return &user, nil
+}
+
+func (r *userRepository) Upsert(_ context.Context, user *db.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	row, ok := r.s.users[user.ID]
+	if !ok {
+		row = &userRow{user: db.User{ID: user.ID, CreatedAt: now}}
+		r.s.users[user.ID] = row
+	}
+	row.user.DisplayName = user.DisplayName
+	row.user.Email = user.Email
+	row.user.UpdatedAt = now
+	user.CreatedAt, user.UpdatedAt = row.user.CreatedAt, row.user.UpdatedAt
+	return nil
+}
+
+func (r *userRepository) UpdateLastSync(_ context.Context, id string, syncTime time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row, ok := r.s.users[id]
+	if !ok {
+		return db.ErrNotFound
+	}
+	row.user.LastSyncAt = &syncTime
+	row.user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *userRepository) EnsureExists(_ context.Context, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[id]; !ok {
+		now := time.Now()
+		r.s.users[id] = &userRow{user: db.User{ID: id, DisplayName: id, CreatedAt: now, UpdatedAt: now}}
+	}
+	return nil
+}
+
+func (r *userRepository) SaveToken(_ context.Context, id, accessToken, refreshToken string, expiry time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row, ok := r.s.users[id]
+	if !ok {
+		return db.ErrNotFound
+	}
+	row.token = &db.UserToken{AccessToken: accessToken, RefreshToken: refreshToken, TokenExpiry: expiry}
+	row.user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *userRepository) GetToken(_ context.Context, id string) (*db.UserToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row, ok := r.s.users[id]
+	if !ok || row.token == nil {
+		return nil, db.ErrNotFound
+	}
+	token := *row.token
+	return &token, nil
+}
+
+func (r *userRepository) ClearToken(_ context.Context, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row, ok := r.s.users[id]
+	if !ok {
+		return db.ErrNotFound
+	}
+	row.token = nil
+	row.user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *userRepository) RecordSyncFailure(_ context.Context, id string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row, ok := r.s.users[id]
+	if !ok {
+		return db.ErrNotFound
+	}
+	row.syncFailedAt = &at
+	row.user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *userRepository) ListScheduled(_ context.Context) ([]db.ScheduledUser, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var users []db.ScheduledUser
+	for id, row := range r.s.users {
+		if row.token == nil {
+			continue
+		}
+		user := db.ScheduledUser{ID: id, LastSyncAt: row.user.LastSyncAt, SyncFailedAt: row.syncFailedAt}
+		if settings, ok := r.s.settings[id]; ok {
+			user.SyncIntervalHours = settings.SyncIntervalHours
+		}
+		users = append(users, user)
+	}
+
+	// Never-synced users first, then least recently synced
+	sort.Slice(users, func(i, j int) bool {
+		a, b := users[i].LastSyncAt, users[j].LastSyncAt
+		switch {
+		case a == nil && b == nil:
+			return users[i].ID < users[j].ID
+		case a == nil || b == nil:
+			return a == nil
+		case !a.Equal(*b):
+			return a.Before(*b)
+		}
+		return users[i].ID < users[j].ID
+	})
+	return users, nil
+}
+
+// settingsRepository implements db.SettingsRepository in memory.
+type settingsRepository struct {
+	s *Store
+}
+
+func (r *settingsRepository) Get(_ context.Context, userID string) (*db.UserSettings, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	settings, ok := r.s.settings[userID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	return &settings, nil
+}
+
+func (r *settingsRepository) Upsert(_ context.Context, settings *db.UserSettings) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	settings.CreatedAt, settings.UpdatedAt = now, now
+	if prev, ok := r.s.settings[settings.UserID]; ok {
+		settings.CreatedAt = prev.CreatedAt
+	}
+	r.s.settings[settings.UserID] = *settings
+	return nil
+}
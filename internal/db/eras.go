@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 
@@ -10,29 +11,133 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// EraRepository handles era database operations.
-type EraRepository struct {
+// pgEraRepository implements EraRepository with PostgreSQL.
+type pgEraRepository struct {
 	pool *pgxpool.Pool
 }
 
+// eraColumns are the columns scanned by scanEra.
+const eraColumns = `id, user_id, name, top_tags, start_date, end_date, playlist_id, created_at,
+	cohesion, silhouette, tag_weights, parent_id, name_override`
+
+// scanEra scans a row selected with eraColumns.
+func scanEra(row pgx.Row) (Era, error) {
+	var era Era
+	var tagWeights []byte
+	err := row.Scan(
+		&era.ID,
+		&era.UserID,
+		&era.Name,
+		&era.TopTags,
+		&era.StartDate,
+		&era.EndDate,
+		&era.PlaylistID,
+		&era.CreatedAt,
+		&era.Cohesion,
+		&era.Silhouette,
+		&tagWeights,
+		&era.ParentID,
+		&era.NameOverride,
+	)
+	if err != nil {
+		return era, err
+	}
+	if tagWeights != nil {
+		if err := json.Unmarshal(tagWeights, &era.TagWeights); err != nil {
+			return era, fmt.Errorf("decoding tag weights: %w", err)
+		}
+	}
+	return era, nil
+}
+
 // Create inserts a new era with its associated tracks.
-func (r *EraRepository) Create(ctx context.Context, era *Era, trackIDs []string) error {
+func (r *pgEraRepository) Create(ctx context.Context, era *Era, trackIDs []string) error {
+	tracks := make([]EraTrack, len(trackIDs))
+	for i, id := range trackIDs {
+		tracks[i] = EraTrack{TrackID: id}
+	}
+	return r.CreateWithTracks(ctx, era, tracks)
+}
+
+// CreateWithTracks inserts a new era with its tracks and the tags
+// explaining why each track belongs to it. A sub-era's parent must be
+// created first.
+func (r *pgEraRepository) CreateWithTracks(ctx context.Context, era *Era, tracks []EraTrack) error {
 	tx, err := r.pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("beginning transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
+	if err := insertEra(ctx, tx, era, tracks); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// ReplaceForUser deletes a user's eras and creates the given ones, with
+// tracks[i] the tracks of eras[i], in one transaction: if any era fails the
+// user keeps their previous eras. Parents must come before their sub-eras.
+// Concurrent replacements for the same user run one after the other.
+// Returns ErrNotFound if the user doesn't exist.
+func (r *pgEraRepository) ReplaceForUser(ctx context.Context, userID string, eras []Era, tracks [][]EraTrack) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the user so a concurrent replacement waits, then sees our eras
+	var locked string
+	err = tx.QueryRow(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("locking user: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM eras WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("deleting user eras: %w", err)
+	}
+	for i := range eras {
+		if err := insertEra(ctx, tx, &eras[i], tracks[i]); err != nil {
+			return fmt.Errorf("creating era %q: %w", eras[i].Name, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// insertEra inserts an era and its tracks within tx, generating its ID if
+// unset.
+func insertEra(ctx context.Context, tx pgx.Tx, era *Era, tracks []EraTrack) error {
+	var tagWeights []byte
+	if len(era.TagWeights) > 0 {
+		var err error
+		tagWeights, err = json.Marshal(era.TagWeights)
+		if err != nil {
+			return fmt.Errorf("encoding tag weights: %w", err)
+		}
+	}
+
 	// Insert era
 	eraQuery := `
-		INSERT INTO eras (id, user_id, name, top_tags, start_date, end_date, playlist_id, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		INSERT INTO eras (id, user_id, name, top_tags, start_date, end_date, playlist_id, created_at,
+			cohesion, silhouette, tag_weights, parent_id, name_override)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), $8, $9, $10, $11, $12)
 		RETURNING created_at
 	`
 	if era.ID == uuid.Nil {
 		era.ID = uuid.New()
 	}
-	err = tx.QueryRow(ctx, eraQuery,
+	err := tx.QueryRow(ctx, eraQuery,
 		era.ID,
 		era.UserID,
 		era.Name,
@@ -40,47 +145,50 @@ func (r *EraRepository) Create(ctx context.Context, era *Era, trackIDs []string)
 		era.StartDate,
 		era.EndDate,
 		era.PlaylistID,
+		era.Cohesion,
+		era.Silhouette,
+		tagWeights,
+		era.ParentID,
+		era.NameOverride,
 	).Scan(&era.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("inserting era: %w", err)
 	}
 
-	// Insert era_tracks
-	if len(trackIDs) > 0 {
+	// Insert era_tracks; reasons are passed as a JSON object keyed by track ID
+	if len(tracks) > 0 {
+		trackIDs := make([]string, len(tracks))
+		reasons := make(map[string][]string)
+		for i, t := range tracks {
+			trackIDs[i] = t.TrackID
+			if len(t.ReasonTags) > 0 {
+				reasons[t.TrackID] = t.ReasonTags
+			}
+		}
+		reasonsJSON, err := json.Marshal(reasons)
+		if err != nil {
+			return fmt.Errorf("encoding track reasons: %w", err)
+		}
+
 		tracksQuery := `
-			INSERT INTO era_tracks (era_id, track_id)
-			SELECT $1, unnest($2::text[])
+			INSERT INTO era_tracks (era_id, track_id, reason_tags)
+			SELECT $1, t.id, ARRAY(SELECT jsonb_array_elements_text($3::jsonb -> t.id))
+			FROM unnest($2::text[]) AS t(id)
 		`
-		_, err = tx.Exec(ctx, tracksQuery, era.ID, trackIDs)
+		_, err = tx.Exec(ctx, tracksQuery, era.ID, trackIDs, string(reasonsJSON))
 		if err != nil {
 			return fmt.Errorf("inserting era tracks: %w", err)
 		}
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("committing transaction: %w", err)
-	}
 	return nil
 }
 
-// Get retrieves an era by ID.
-func (r *EraRepository) Get(ctx context.Context, id uuid.UUID) (*Era, error) {
-	query := `
-		SELECT id, user_id, name, top_tags, start_date, end_date, playlist_id, created_at
-		FROM eras
-		WHERE id = $1
-	`
-	var era Era
-	err := r.pool.QueryRow(ctx, query, id).Scan(
-		&era.ID,
-		&era.UserID,
-		&era.Name,
-		&era.TopTags,
-		&era.StartDate,
-		&era.EndDate,
-		&era.PlaylistID,
-		&era.CreatedAt,
-	)
+// GetForOwner retrieves one of a user's eras by ID. Returns ErrNotFound if
+// the era doesn't exist or belongs to another user.
+func (r *pgEraRepository) GetForOwner(ctx context.Context, userID string, id uuid.UUID) (*Era, error) {
+	query := `SELECT ` + eraColumns + ` FROM eras WHERE id = $1 AND user_id = $2`
+	era, err := scanEra(r.pool.QueryRow(ctx, query, id, userID))
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, ErrNotFound
 	}
@@ -90,13 +198,23 @@ func (r *EraRepository) Get(ctx context.Context, id uuid.UUID) (*Era, error) {
 	return &era, nil
 }
 
-// GetForUser retrieves all eras for a user, ordered by start date desc.
-func (r *EraRepository) GetForUser(ctx context.Context, userID string) ([]Era, error) {
+// GetForUser retrieves all eras for a user, including sub-eras. Eras are
+// ordered by depth, so parents come before their sub-eras, then by start
+// date desc.
+func (r *pgEraRepository) GetForUser(ctx context.Context, userID string) ([]Era, error) {
 	query := `
-		SELECT id, user_id, name, top_tags, start_date, end_date, playlist_id, created_at
-		FROM eras
-		WHERE user_id = $1
-		ORDER BY start_date DESC
+		WITH RECURSIVE tree AS (
+			SELECT eras.*, 0 AS depth
+			FROM eras
+			WHERE user_id = $1 AND parent_id IS NULL
+			UNION ALL
+			SELECT e.*, tree.depth + 1
+			FROM eras e
+			JOIN tree ON e.parent_id = tree.id
+		)
+		SELECT ` + eraColumns + `
+		FROM tree
+		ORDER BY depth, start_date DESC
 	`
 	rows, err := r.pool.Query(ctx, query, userID)
 	if err != nil {
@@ -106,17 +224,8 @@ func (r *EraRepository) GetForUser(ctx context.Context, userID string) ([]Era, e
 
 	var eras []Era
 	for rows.Next() {
-		var era Era
-		if err := rows.Scan(
-			&era.ID,
-			&era.UserID,
-			&era.Name,
-			&era.TopTags,
-			&era.StartDate,
-			&era.EndDate,
-			&era.PlaylistID,
-			&era.CreatedAt,
-		); err != nil {
+		era, err := scanEra(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scanning era: %w", err)
 		}
 		eras = append(eras, era)
@@ -124,15 +233,17 @@ func (r *EraRepository) GetForUser(ctx context.Context, userID string) ([]Era, e
 	return eras, rows.Err()
 }
 
-// GetTracks retrieves all tracks for an era.
-func (r *EraRepository) GetTracks(ctx context.Context, eraID uuid.UUID) ([]Track, error) {
+// GetTracksForOwner retrieves all tracks for one of a user's eras. An era
+// that belongs to another user has no tracks.
+func (r *pgEraRepository) GetTracksForOwner(ctx context.Context, userID string, eraID uuid.UUID) ([]Track, error) {
 	query := `
 		SELECT t.id, t.name, t.artist, t.album, t.album_id, t.duration_ms, t.created_at
 		FROM tracks t
 		JOIN era_tracks et ON t.id = et.track_id
-		WHERE et.era_id = $1
+		JOIN eras e ON e.id = et.era_id
+		WHERE et.era_id = $1 AND e.user_id = $2
 	`
-	rows, err := r.pool.Query(ctx, query, eraID)
+	rows, err := r.pool.Query(ctx, query, eraID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("querying era tracks: %w", err)
 	}
@@ -157,21 +268,84 @@ func (r *EraRepository) GetTracks(ctx context.Context, eraID uuid.UUID) ([]Track
 	return tracks, rows.Err()
 }
 
-// GetTrackCount returns the number of tracks in an era.
-func (r *EraRepository) GetTrackCount(ctx context.Context, eraID uuid.UUID) (int, error) {
-	query := `SELECT COUNT(*) FROM era_tracks WHERE era_id = $1`
+// GetTrackReasonsForOwner returns the tags explaining each track's
+// membership in one of a user's eras, keyed by track ID. Tracks without
+// reasons are omitted, as are all tracks of another user's era.
+func (r *pgEraRepository) GetTrackReasonsForOwner(ctx context.Context, userID string, eraID uuid.UUID) (map[string][]string, error) {
+	query := `
+		SELECT et.track_id, et.reason_tags
+		FROM era_tracks et
+		JOIN eras e ON e.id = et.era_id
+		WHERE et.era_id = $1 AND e.user_id = $2 AND cardinality(et.reason_tags) > 0
+	`
+	rows, err := r.pool.Query(ctx, query, eraID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying track reasons: %w", err)
+	}
+	defer rows.Close()
+
+	reasons := make(map[string][]string)
+	for rows.Next() {
+		var trackID string
+		var tags []string
+		if err := rows.Scan(&trackID, &tags); err != nil {
+			return nil, fmt.Errorf("scanning track reasons: %w", err)
+		}
+		reasons[trackID] = tags
+	}
+	return reasons, rows.Err()
+}
+
+// GetTrackIDsForUser returns the track IDs of each of a user's eras,
+// including sub-eras, keyed by era ID.
+func (r *pgEraRepository) GetTrackIDsForUser(ctx context.Context, userID string) (map[uuid.UUID][]string, error) {
+	query := `
+		SELECT et.era_id, et.track_id
+		FROM era_tracks et
+		JOIN eras e ON e.id = et.era_id
+		WHERE e.user_id = $1
+	`
+	rows, err := r.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying era track IDs: %w", err)
+	}
+	defer rows.Close()
+
+	trackIDs := make(map[uuid.UUID][]string)
+	for rows.Next() {
+		var eraID uuid.UUID
+		var trackID string
+		if err := rows.Scan(&eraID, &trackID); err != nil {
+			return nil, fmt.Errorf("scanning era track ID: %w", err)
+		}
+		trackIDs[eraID] = append(trackIDs[eraID], trackID)
+	}
+	return trackIDs, rows.Err()
+}
+
+// GetTrackCountForOwner returns the number of tracks in one of a user's
+// eras, or 0 for another user's era.
+func (r *pgEraRepository) GetTrackCountForOwner(ctx context.Context, userID string, eraID uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM era_tracks et
+		JOIN eras e ON e.id = et.era_id
+		WHERE et.era_id = $1 AND e.user_id = $2
+	`
 	var count int
-	err := r.pool.QueryRow(ctx, query, eraID).Scan(&count)
+	err := r.pool.QueryRow(ctx, query, eraID, userID).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("counting era tracks: %w", err)
 	}
 	return count, nil
 }
 
-// UpdatePlaylistID sets the Spotify playlist ID for an era.
-func (r *EraRepository) UpdatePlaylistID(ctx context.Context, eraID uuid.UUID, playlistID string) error {
-	query := `UPDATE eras SET playlist_id = $2 WHERE id = $1`
-	result, err := r.pool.Exec(ctx, query, eraID, playlistID)
+// UpdatePlaylistIDForOwner sets the Spotify playlist ID for one of a user's
+// eras. Returns ErrNotFound if the era doesn't exist or belongs to another
+// user.
+func (r *pgEraRepository) UpdatePlaylistIDForOwner(ctx context.Context, userID string, eraID uuid.UUID, playlistID string) error {
+	query := `UPDATE eras SET playlist_id = $3 WHERE id = $1 AND user_id = $2`
+	result, err := r.pool.Exec(ctx, query, eraID, userID, playlistID)
 	if err != nil {
 		return fmt.Errorf("updating playlist ID: %w", err)
 	}
@@ -181,8 +355,23 @@ func (r *EraRepository) UpdatePlaylistID(ctx context.Context, eraID uuid.UUID, p
 	return nil
 }
 
+// UpdateNameOverrideForOwner sets the user's name for one of their eras, or
+// clears it if name is nil. Returns ErrNotFound if the era doesn't exist or
+// belongs to another user.
+func (r *pgEraRepository) UpdateNameOverrideForOwner(ctx context.Context, userID string, eraID uuid.UUID, name *string) error {
+	query := `UPDATE eras SET name_override = $3 WHERE id = $1 AND user_id = $2`
+	result, err := r.pool.Exec(ctx, query, eraID, userID, name)
+	if err != nil {
+		return fmt.Errorf("updating name override: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // DeleteForUser removes all eras for a user.
-func (r *EraRepository) DeleteForUser(ctx context.Context, userID string) error {
+func (r *pgEraRepository) DeleteForUser(ctx context.Context, userID string) error {
 	query := `DELETE FROM eras WHERE user_id = $1`
 	_, err := r.pool.Exec(ctx, query, userID)
 	if err != nil {
@@ -191,10 +380,11 @@ func (r *EraRepository) DeleteForUser(ctx context.Context, userID string) error
 	return nil
 }
 
-// Delete removes an era by ID.
-func (r *EraRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM eras WHERE id = $1`
-	result, err := r.pool.Exec(ctx, query, id)
+// DeleteForOwner removes one of a user's eras by ID. Returns ErrNotFound if
+// the era doesn't exist or belongs to another user.
+func (r *pgEraRepository) DeleteForOwner(ctx context.Context, userID string, id uuid.UUID) error {
+	query := `DELETE FROM eras WHERE id = $1 AND user_id = $2`
+	result, err := r.pool.Exec(ctx, query, id, userID)
 	if err != nil {
 		return fmt.Errorf("deleting era: %w", err)
 	}
@@ -0,0 +1,14 @@
+package db
+
+import "database/sql"
+
+// Helpers from the package's own tests, for the db_test package.
+var (
+	MustParseKeyring = mustParseKeyring
+	TestKey          = testKey
+)
+
+// SQLiteDB returns the SQLite handle, for tests that change rows directly.
+func (d *DB) SQLiteDB() *sql.DB {
+	return d.sqlite
+}
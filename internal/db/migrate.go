@@ -0,0 +1,425 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Migration errors.
+var (
+	// ErrDirtySchema is returned when a previous migration failed part way.
+	// Fix the schema by hand, then record its version with ForceVersion.
+	ErrDirtySchema = errors.New("database schema is dirty")
+	// ErrSchemaTooNew is returned when the database has migrations this
+	// binary doesn't know about, i.e. a newer release migrated it.
+	ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+	// ErrSchemaOutdated is returned by CheckSchema when migrations are
+	// pending.
+	ErrSchemaOutdated = errors.New("database schema is out of date")
+)
+
+// migrationLockID is the pg_advisory_lock key held while migrating, so
+// replicas starting together apply each migration once. It is arbitrary,
+// and only needs to differ from other apps' locks in the same database.
+const migrationLockID int64 = 5_142_713_801
+
+// Migration is one numbered schema change.
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// migrationFile matches golang-migrate file names, e.g.
+// 000001_create_users.up.sql.
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadMigrations reads the migrations in fsys, ordered by version. Every
+// version needs an up file; down files are optional.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migration %s: invalid version", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %s: %w", entry.Name(), err)
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// SchemaStatus describes the database schema relative to the migrations.
+type SchemaStatus struct {
+	Version uint64 `json:"version"` // Last applied migration; 0 for none
+	Dirty   bool   `json:"dirty"`   // Version failed part way
+	Latest  uint64 `json:"latest"`  // Newest known migration
+	Pending int    `json:"pending"` // Known migrations not yet applied
+}
+
+// check returns ErrDirtySchema or ErrSchemaTooNew if the schema can't be
+// used or migrated.
+func (s *SchemaStatus) check() error {
+	switch {
+	case s.Dirty:
+		return fmt.Errorf("%w at version %d", ErrDirtySchema, s.Version)
+	case s.Version > s.Latest:
+		return fmt.Errorf("%w: at version %d, latest known is %d", ErrSchemaTooNew, s.Version, s.Latest)
+	}
+	return nil
+}
+
+// newSchemaStatus compares the recorded version with the migrations.
+func newSchemaStatus(version uint64, dirty bool, migrations []Migration) *SchemaStatus {
+	status := &SchemaStatus{Version: version, Dirty: dirty}
+	for _, m := range migrations {
+		status.Latest = m.Version
+		if m.Version > version {
+			status.Pending++
+		}
+	}
+	return status
+}
+
+// The version table is golang-migrate's, so databases migrated with the
+// migrate CLI carry on from where it left off.
+const createVersionTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL PRIMARY KEY,
+		dirty   BOOLEAN NOT NULL
+	)
+`
+
+// selectVersion reads the recorded version.
+const selectVersion = `SELECT version, dirty FROM schema_migrations LIMIT 1`
+
+// SchemaStatus reports the schema version without changing anything.
+func (db *DB) SchemaStatus(ctx context.Context, migrations []Migration) (*SchemaStatus, error) {
+	var exists bool
+	var err error
+	if db.sqlite != nil {
+		err = db.sqlite.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`,
+		).Scan(&exists)
+	} else {
+		err = db.pool.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("checking version table: %w", err)
+	}
+	if !exists {
+		return newSchemaStatus(0, false, migrations), nil
+	}
+
+	var version uint64
+	var dirty bool
+	if db.sqlite != nil {
+		version, dirty, err = readVersion(db.sqlite.QueryRowContext(ctx, selectVersion))
+	} else {
+		version, dirty, err = readVersion(db.pool.QueryRow(ctx, selectVersion))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newSchemaStatus(version, dirty, migrations), nil
+}
+
+// CheckSchema returns an error unless every migration has been applied
+// cleanly: ErrDirtySchema, ErrSchemaTooNew or ErrSchemaOutdated.
+func (db *DB) CheckSchema(ctx context.Context, migrations []Migration) (*SchemaStatus, error) {
+	status, err := db.SchemaStatus(ctx, migrations)
+	if err != nil {
+		return nil, err
+	}
+	if err := status.check(); err != nil {
+		return status, err
+	}
+	if status.Pending > 0 {
+		return status, fmt.Errorf("%w: at version %d, %d migrations pending", ErrSchemaOutdated, status.Version, status.Pending)
+	}
+	return status, nil
+}
+
+// Migrate applies the pending migrations in order and returns the new
+// status. It holds an advisory lock throughout, so concurrent callers wait
+// and then find nothing left to do. Each migration is recorded as dirty
+// while it runs, so one that fails part way stops later runs until the
+// schema is repaired.
+func (db *DB) Migrate(ctx context.Context, migrations []Migration) (*SchemaStatus, error) {
+	var status *SchemaStatus
+	err := db.withMigrationLock(ctx, func(conn migrationConn) error {
+		version, dirty, err := conn.readVersion(ctx)
+		if err != nil {
+			return err
+		}
+		status = newSchemaStatus(version, dirty, migrations)
+		if err := status.check(); err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if m.Version <= version {
+				continue
+			}
+			if err := runMigration(ctx, conn, m.Version, m.Up); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			status.Version = m.Version
+			status.Pending--
+		}
+		return nil
+	})
+	return status, err
+}
+
+// MigrateDown reverts the last steps applied migrations and returns the new
+// status.
+func (db *DB) MigrateDown(ctx context.Context, migrations []Migration, steps int) (*SchemaStatus, error) {
+	var status *SchemaStatus
+	err := db.withMigrationLock(ctx, func(conn migrationConn) error {
+		version, dirty, err := conn.readVersion(ctx)
+		if err != nil {
+			return err
+		}
+		status = newSchemaStatus(version, dirty, migrations)
+		if err := status.check(); err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if m.Version > status.Version {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+			}
+			previous := uint64(0)
+			if i > 0 {
+				previous = migrations[i-1].Version
+			}
+			// Recorded as dirty at the version being reverted, as golang-migrate does
+			if err := conn.setVersion(ctx, m.Version, true); err != nil {
+				return err
+			}
+			if err := conn.exec(ctx, m.Down); err != nil {
+				return fmt.Errorf("reverting migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			if err := conn.setVersion(ctx, previous, false); err != nil {
+				return err
+			}
+			status.Version = previous
+			status.Pending++
+			steps--
+		}
+		return nil
+	})
+	return status, err
+}
+
+// ForceVersion records version as applied and clean without running any
+// migration, after a dirty schema has been repaired by hand.
+func (db *DB) ForceVersion(ctx context.Context, version uint64) error {
+	return db.withMigrationLock(ctx, func(conn migrationConn) error {
+		return conn.setVersion(ctx, version, false)
+	})
+}
+
+// migrationConn is the connection migrations run on while the migration
+// lock is held.
+type migrationConn interface {
+	// readVersion returns the recorded version, or 0 if none is recorded.
+	readVersion(ctx context.Context) (uint64, bool, error)
+	// setVersion replaces the recorded version. Version 0 records none.
+	setVersion(ctx context.Context, version uint64, dirty bool) error
+	// exec runs the statements of a migration file.
+	exec(ctx context.Context, sql string) error
+}
+
+// withMigrationLock runs fn on one connection while holding the migration
+// lock, creating the version table first.
+func (db *DB) withMigrationLock(ctx context.Context, fn func(conn migrationConn) error) error {
+	if db.sqlite != nil {
+		return db.withSQLiteMigrationLock(ctx, fn)
+	}
+
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	// Session-level locks belong to the connection, so lock and unlock on it
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	defer func() {
+		// Unlock even if ctx was cancelled, or the pooled connection keeps the lock
+		if _, err := conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+			conn.Conn().Close(context.WithoutCancel(ctx))
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, createVersionTable); err != nil {
+		return fmt.Errorf("creating version table: %w", err)
+	}
+	return fn(pgMigrationConn{conn})
+}
+
+// withSQLiteMigrationLock runs fn on the SQLite database's only
+// connection, which keeps every other query in the process waiting until
+// it is done. SQLite has no advisory locks, so servers in other processes
+// must not share the database file.
+func (db *DB) withSQLiteMigrationLock(ctx context.Context, fn func(conn migrationConn) error) error {
+	conn, err := db.sqlite.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, createVersionTable); err != nil {
+		return fmt.Errorf("creating version table: %w", err)
+	}
+	return fn(sqliteMigrationConn{conn})
+}
+
+// readVersion scans the row selectVersion returns. No row means no
+// version is recorded.
+func readVersion(row interface{ Scan(dest ...any) error }) (uint64, bool, error) {
+	var version int64
+	var dirty bool
+	err := row.Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("reading schema version: %w", err)
+	}
+	return uint64(version), dirty, nil
+}
+
+// runMigration runs one up migration, marking its version dirty until it
+// succeeds.
+func runMigration(ctx context.Context, conn migrationConn, version uint64, sql string) error {
+	if err := conn.setVersion(ctx, version, true); err != nil {
+		return err
+	}
+	if err := conn.exec(ctx, sql); err != nil {
+		return err
+	}
+	return conn.setVersion(ctx, version, false)
+}
+
+// pgMigrationConn is a PostgreSQL migrationConn.
+type pgMigrationConn struct {
+	conn *pgxpool.Conn
+}
+
+func (c pgMigrationConn) readVersion(ctx context.Context) (uint64, bool, error) {
+	return readVersion(c.conn.QueryRow(ctx, selectVersion))
+}
+
+func (c pgMigrationConn) setVersion(ctx context.Context, version uint64, dirty bool) error {
+	err := pgx.BeginFunc(ctx, c.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `TRUNCATE schema_migrations`); err != nil {
+			return err
+		}
+		if version == 0 {
+			return nil
+		}
+		_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, int64(version), dirty)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("recording schema version %d: %w", version, err)
+	}
+	return nil
+}
+
+// exec runs sql without arguments, so Exec uses the simple protocol, which
+// allows the several statements a migration file holds.
+func (c pgMigrationConn) exec(ctx context.Context, sql string) error {
+	_, err := c.conn.Exec(ctx, sql)
+	return err
+}
+
+// sqliteMigrationConn is a SQLite migrationConn.
+type sqliteMigrationConn struct {
+	conn *sql.Conn
+}
+
+func (c sqliteMigrationConn) readVersion(ctx context.Context) (uint64, bool, error) {
+	return readVersion(c.conn.QueryRowContext(ctx, selectVersion))
+}
+
+func (c sqliteMigrationConn) setVersion(ctx context.Context, version uint64, dirty bool) error {
+	tx, err := c.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("recording schema version %d: %w", version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return fmt.Errorf("recording schema version %d: %w", version, err)
+	}
+	if version > 0 {
+		_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, int64(version), dirty)
+		if err != nil {
+			return fmt.Errorf("recording schema version %d: %w", version, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("recording schema version %d: %w", version, err)
+	}
+	return nil
+}
+
+// exec runs sql without arguments, which lets the driver run every
+// statement in it rather than only the first.
+func (c sqliteMigrationConn) exec(ctx context.Context, sql string) error {
+	_, err := c.conn.ExecContext(ctx, sql)
+	return err
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_add_plays.up.sql":      {Data: []byte("CREATE TABLE plays ();")},
+		"000002_add_plays.down.sql":    {Data: []byte("DROP TABLE plays;")},
+		"000001_create_users.up.sql":   {Data: []byte("CREATE TABLE users ();")},
+		"000001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"000010_no_down.up.sql":        {Data: []byte("SELECT 1;")},
+		"README.md":                    {Data: []byte("not a migration")},
+	}
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		version uint64
+		name    string
+	}{{1, "create_users"}, {2, "add_plays"}, {10, "no_down"}}
+	if len(migrations) != len(want) {
+		t.Fatalf("got %d migrations, want %d", len(migrations), len(want))
+	}
+	for i, w := range want {
+		if migrations[i].Version != w.version || migrations[i].Name != w.name {
+			t.Errorf("migrations[%d] = %d_%s, want %d_%s", i, migrations[i].Version, migrations[i].Name, w.version, w.name)
+		}
+	}
+	if migrations[0].Up != "CREATE TABLE users ();" || migrations[0].Down != "DROP TABLE users;" {
+		t.Errorf("migrations[0] = %+v", migrations[0])
+	}
+	if migrations[2].Down != "" {
+		t.Errorf("migration without a down file has Down %q", migrations[2].Down)
+	}
+}
+
+func TestLoadMigrations_Errors(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"down without up": {"000001_users.down.sql": {Data: []byte("DROP TABLE users;")}},
+		"two names": {
+			"000001_users.up.sql":  {Data: []byte("SELECT 1;")},
+			"000001_people.up.sql": {Data: []byte("SELECT 1;")},
+		},
+		"version zero": {"000000_init.up.sql": {Data: []byte("SELECT 1;")}},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadMigrations(fsys); err == nil {
+				t.Error("LoadMigrations() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestSchemaStatus(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}, {Version: 5}}
+
+	tests := []struct {
+		name    string
+		version uint64
+		dirty   bool
+		pending int
+		wantErr error
+	}{
+		{"empty database", 0, false, 3, nil},
+		{"part way", 2, false, 1, nil},
+		{"current", 5, false, 0, nil},
+		{"dirty", 2, true, 1, ErrDirtySchema},
+		{"too new", 6, false, 0, ErrSchemaTooNew},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := newSchemaStatus(tt.version, tt.dirty, migrations)
+			if status.Latest != 5 || status.Pending != tt.pending {
+				t.Errorf("status = %+v, want latest 5 and %d pending", status, tt.pending)
+			}
+			if err := status.check(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("check() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
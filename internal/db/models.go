@@ -16,6 +16,22 @@ type User struct {
 	LastSyncAt  *time.Time // nullable
 }
 
+// UserToken is a Spotify OAuth token stored for headless access.
+type UserToken struct {
+	AccessToken  string
+	RefreshToken string
+	TokenExpiry  time.Time
+}
+
+// ScheduledUser is a user with a stored Spotify token that the scheduler
+// can sync in the background.
+type ScheduledUser struct {
+	ID                string
+	LastSyncAt        *time.Time // nullable
+	SyncFailedAt      *time.Time // Last failed scheduled sync; nullable
+	SyncIntervalHours *int       // From user_settings; nil for the default
+}
+
 // Session represents an authenticated web session.
 type Session struct {
 	ID           string
@@ -31,13 +47,27 @@ type Session struct {
 type Track struct {
 	ID         string
 	Name       string
-	Artist     string
+	Artist     string  // Comma-separated artist names (for display)
 	Album      *string // nullable
 	AlbumID    *string // nullable
 	DurationMs *int    // nullable
 	CreatedAt  time.Time
 }
 
+// Artist represents a Spotify artist.
+type Artist struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time
+}
+
+// TrackArtist represents an artist credited on a track.
+type TrackArtist struct {
+	TrackID  string
+	ArtistID string
+	Position int // Credit order (0 = primary artist)
+}
+
 // UserTrack represents a user's liked track with timestamp.
 type UserTrack struct {
 	UserID  string
@@ -54,20 +84,98 @@ type TrackTag struct {
 	FetchedAt time.Time
 }
 
-// Era represents a detected mood era.
-type Era struct {
-	ID         uuid.UUID
+// UserTrackTag represents a tag manually assigned to a track by a user.
+// Unlike TrackTag, these are private to the user and never expire.
+type UserTrackTag struct {
+	UserID    string
+	TrackID   string
+	TagName   string
+	CreatedAt time.Time
+}
+
+// Play sources.
+const (
+	PlaySourceSpotify = "spotify"
+	PlaySourceLastFM  = "lastfm"
+)
+
+// Play represents a single listen from a user's streaming history.
+type Play struct {
+	ID         int64
 	UserID     string
-	Name       string
-	TopTags    []string
-	StartDate  time.Time
-	EndDate    time.Time
-	PlaylistID *string // nullable - Spotify playlist ID if created
+	TrackID    *string // nullable - set once matched to a known track
+	ArtistName string
+	TrackName  string
+	PlayedAt   time.Time
+	MsPlayed   int
+	Source     string // PlaySourceSpotify or PlaySourceLastFM
 	CreatedAt  time.Time
 }
 
+// UserSettings holds a user's analysis configuration.
+// Nil fields fall back to the built-in defaults.
+type UserSettings struct {
+	UserID            string
+	NumClusters       *int
+	MinClusterSize    *int
+	MaxTags           *int
+	ManualTagWeight   *float64
+	Basis             *string // "likes" or "plays"; nil picks automatically
+	TimeWeight        *float64
+	NameTemplate      *string
+	SubEraDepth       *int
+	SyncIntervalHours *int // Hours between scheduled syncs; nil for the default, 0 for none
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// Era represents a detected mood era.
+type Era struct {
+	ID           uuid.UUID
+	UserID       string
+	Name         string
+	TopTags      []string
+	StartDate    time.Time
+	EndDate      time.Time
+	PlaylistID   *string    // nullable - Spotify playlist ID if created
+	NameOverride *string    // nullable - name given by the user
+	ParentID     *uuid.UUID // nullable - parent era of a sub-era
+	CreatedAt    time.Time
+
+	// Quality metrics; nil for eras detected before metrics were stored
+	Cohesion   *float64       // Mean distance from tracks to the centroid
+	Silhouette *float64       // Mean simplified silhouette, -1 to 1
+	TagWeights []EraTagWeight // Centroid tag weights, heaviest first
+}
+
+// DisplayName returns the user's name for the era, or the generated name if
+// they haven't given one.
+func (e Era) DisplayName() string {
+	if e.NameOverride != nil {
+		return *e.NameOverride
+	}
+	return e.Name
+}
+
+// EraTagWeight is a tag's weight in an era's centroid.
+type EraTagWeight struct {
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+}
+
 // EraTrack represents a track belonging to an era.
 type EraTrack struct {
-	EraID   uuid.UUID
-	TrackID string
+	EraID      uuid.UUID
+	TrackID    string
+	ReasonTags []string // Tags that most tie the track to the era
+}
+
+// HousekeepingRun records one run of a housekeeping task.
+type HousekeepingRun struct {
+	ID         int64
+	Task       string
+	Removed    int64   // Rows or entries the task removed
+	Error      *string // nullable - set if the task failed
+	StartedAt  time.Time
+	FinishedAt time.Time
 }
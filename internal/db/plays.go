@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pgPlayRepository implements PlayRepository with PostgreSQL.
+type pgPlayRepository struct {
+	pool *pgxpool.Pool
+}
+
+// InsertBatch stores plays for a user, skipping plays already recorded.
+// A play's TrackID is kept only if the track exists; otherwise it is stored
+// unmatched. Returns the number of new plays.
+func (r *pgPlayRepository) InsertBatch(ctx context.Context, userID string, plays []Play) (int64, error) {
+	if len(plays) == 0 {
+		return 0, nil
+	}
+
+	query := `
+		INSERT INTO plays (user_id, track_id, artist_name, track_name, played_at, ms_played, source)
+		SELECT $1, t.id, p.artist_name, p.track_name, p.played_at, p.ms_played, p.source
+		FROM unnest($2::text[], $3::text[], $4::text[], $5::timestamptz[], $6::int[], $7::text[])
+			AS p(track_id, artist_name, track_name, played_at, ms_played, source)
+		LEFT JOIN tracks t ON t.id = p.track_id
+		ON CONFLICT (user_id, played_at, artist_name, track_name) DO NOTHING
+	`
+
+	trackIDs := make([]*string, len(plays))
+	artistNames := make([]string, len(plays))
+	trackNames := make([]string, len(plays))
+	playedAts := make([]time.Time, len(plays))
+	msPlayed := make([]int, len(plays))
+	sources := make([]string, len(plays))
+
+	for i, p := range plays {
+		trackIDs[i] = p.TrackID
+		artistNames[i] = p.ArtistName
+		trackNames[i] = p.TrackName
+		playedAts[i] = p.PlayedAt
+		msPlayed[i] = p.MsPlayed
+		sources[i] = p.Source
+	}
+
+	tag, err := r.pool.Exec(ctx, query, userID, trackIDs, artistNames, trackNames, playedAts, msPlayed, sources)
+	if err != nil {
+		return 0, fmt.Errorf("batch inserting plays: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// MatchTracks links a user's unmatched plays to tracks in their library by
+// case-insensitive track and artist name. Any credited artist matches, since
+// sources report artists differently. Returns the number of plays matched.
+func (r *pgPlayRepository) MatchTracks(ctx context.Context, userID string) (int64, error) {
+	query := `
+		UPDATE plays p SET track_id = m.track_id
+		FROM (
+			SELECT DISTINCT ON (p2.id) p2.id, t.id AS track_id
+			FROM plays p2
+			JOIN tracks t ON lower(t.name) = lower(p2.track_name)
+			JOIN user_tracks ut ON ut.track_id = t.id AND ut.user_id = p2.user_id
+			WHERE p2.user_id = $1
+			  AND p2.track_id IS NULL
+			  AND (
+				lower(t.artist) = lower(p2.artist_name)
+				OR EXISTS (
+					SELECT 1 FROM track_artists ta
+					JOIN artists a ON a.id = ta.artist_id
+					WHERE ta.track_id = t.id AND lower(a.name) = lower(p2.artist_name)
+				)
+			  )
+			ORDER BY p2.id, t.id
+		) m
+		WHERE p.id = m.id
+	`
+	tag, err := r.pool.Exec(ctx, query, userID)
+	if err != nil {
+		return 0, fmt.Errorf("matching plays to tracks: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetPlayTimesForUser retrieves when each track in a user's library was
+// played, returning a map of track ID to play times in chronological order.
+// Plays of tracks outside the library are excluded.
+func (r *pgPlayRepository) GetPlayTimesForUser(ctx context.Context, userID string) (map[string][]time.Time, error) {
+	query := `
+		SELECT p.track_id, p.played_at
+		FROM plays p
+		JOIN user_tracks ut ON ut.track_id = p.track_id AND ut.user_id = p.user_id
+		WHERE p.user_id = $1
+		ORDER BY p.played_at
+	`
+	rows, err := r.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying plays: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]time.Time)
+	for rows.Next() {
+		var trackID string
+		var playedAt time.Time
+		if err := rows.Scan(&trackID, &playedAt); err != nil {
+			return nil, fmt.Errorf("scanning play: %w", err)
+		}
+		result[trackID] = append(result[trackID], playedAt)
+	}
+	return result, rows.Err()
+}
+
+// CountMatchedForUser returns the number of a user's plays linked to tracks
+// in their library.
+func (r *pgPlayRepository) CountMatchedForUser(ctx context.Context, userID string) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM plays p
+		JOIN user_tracks ut ON ut.track_id = p.track_id AND ut.user_id = p.user_id
+		WHERE p.user_id = $1
+	`
+	var count int
+	if err := r.pool.QueryRow(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("counting plays: %w", err)
+	}
+	return count, nil
+}
+
+// LatestPlayedAt returns the time of a user's most recent play from a source,
+// or nil if there are none. Importers use it as a cursor to resume.
+func (r *pgPlayRepository) LatestPlayedAt(ctx context.Context, userID, source string) (*time.Time, error) {
+	query := `SELECT MAX(played_at) FROM plays WHERE user_id = $1 AND source = $2`
+	var latest *time.Time
+	if err := r.pool.QueryRow(ctx, query, userID, source).Scan(&latest); err != nil {
+		return nil, fmt.Errorf("getting latest play: %w", err)
+	}
+	return latest, nil
+}
+
+// GetForUser retrieves all of a user's plays in chronological order.
+func (r *pgPlayRepository) GetForUser(ctx context.Context, userID string) ([]Play, error) {
+	query := `
+		SELECT id, user_id, track_id, artist_name, track_name, played_at, ms_played, source, created_at
+		FROM plays
+		WHERE user_id = $1
+		ORDER BY played_at, id
+	`
+	rows, err := r.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying plays: %w", err)
+	}
+	defer rows.Close()
+
+	var plays []Play
+	for rows.Next() {
+		var play Play
+		if err := rows.Scan(
+			&play.ID,
+			&play.UserID,
+			&play.TrackID,
+			&play.ArtistName,
+			&play.TrackName,
+			&play.PlayedAt,
+			&play.MsPlayed,
+			&play.Source,
+			&play.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scanning play: %w", err)
+		}
+		plays = append(plays, play)
+	}
+	return plays, rows.Err()
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The repositories are implemented for each storage backend: pg*
+// types for PostgreSQL and sqlite* types for SQLite. DB's accessors return
+// the ones for the backend it was opened with.
+
+// UserRepository handles user database operations.
+type UserRepository interface {
+	// Create inserts a new user.
+	Create(ctx context.Context, user *User) error
+	// Get retrieves a user by ID.
+	Get(ctx context.Context, id string) (*User, error)
+	// Upsert creates or updates a user.
+	Upsert(ctx context.Context, user *User) error
+	// UpdateLastSync updates the last sync timestamp for a user.
+	UpdateLastSync(ctx context.Context, id string, syncTime time.Time) error
+	// EnsureExists creates a bare user record if one doesn't exist yet.
+	EnsureExists(ctx context.Context, id string) error
+	// SaveToken stores a Spotify OAuth token for the user.
+	SaveToken(ctx context.Context, id, accessToken, refreshToken string, expiry time.Time) error
+	// GetToken retrieves the user's stored Spotify OAuth token, or
+	// ErrNotFound if there is none.
+	GetToken(ctx context.Context, id string) (*UserToken, error)
+	// ClearToken removes the user's stored Spotify OAuth token, so they
+	// aren't scheduled again until they log in.
+	ClearToken(ctx context.Context, id string) error
+	// RecordSyncFailure records when a scheduled sync of the user failed.
+	RecordSyncFailure(ctx context.Context, id string, at time.Time) error
+	// ListScheduled returns every user with a stored refresh token, least
+	// recently synced first.
+	ListScheduled(ctx context.Context) ([]ScheduledUser, error)
+}
+
+// SessionRepository handles session database operations.
+type SessionRepository interface {
+	// Create inserts a new session.
+	Create(ctx context.Context, session *Session) error
+	// Get retrieves an unexpired session by ID.
+	Get(ctx context.Context, id string) (*Session, error)
+	// Delete removes a session by ID.
+	Delete(ctx context.Context, id string) error
+	// UpdateToken updates the OAuth tokens for a session.
+	UpdateToken(ctx context.Context, id, accessToken, refreshToken string, expiry time.Time) error
+	// DeleteExpired removes all expired sessions.
+	DeleteExpired(ctx context.Context) (int64, error)
+	// DeleteForUser removes all sessions for a user.
+	DeleteForUser(ctx context.Context, userID string) error
+}
+
+// TrackRepository handles track database operations.
+type TrackRepository interface {
+	// Upsert creates or updates a track.
+	Upsert(ctx context.Context, track *Track) error
+	// UpsertBatch inserts or updates multiple tracks.
+	UpsertBatch(ctx context.Context, tracks []Track) error
+	// InsertBatchIfAbsent inserts tracks that don't exist yet.
+	InsertBatchIfAbsent(ctx context.Context, tracks []Track) error
+	// Get retrieves a track by ID.
+	Get(ctx context.Context, id string) (*Track, error)
+	// GetUserTracks retrieves all liked tracks for a user, newest first.
+	GetUserTracks(ctx context.Context, userID string) ([]Track, error)
+	// GetUserTracksWithAddedAt retrieves all liked tracks for a user with
+	// their added_at timestamps.
+	GetUserTracksWithAddedAt(ctx context.Context, userID string) ([]UserTrack, []Track, error)
+	// LinkToUser links a track to a user's library.
+	LinkToUser(ctx context.Context, userID, trackID string, addedAt time.Time) error
+	// LinkBatchToUser links multiple tracks to a user's library.
+	LinkBatchToUser(ctx context.Context, userID string, tracks []UserTrack) error
+	// LinkBatchToUserIfAbsent links tracks to a user's library without
+	// changing the added_at of tracks already linked.
+	LinkBatchToUserIfAbsent(ctx context.Context, userID string, tracks []UserTrack) error
+	// UnlinkAllFromUser removes all tracks from a user's library.
+	UnlinkAllFromUser(ctx context.Context, userID string) error
+	// DeleteOrphanTags removes the Last.fm tags of orphaned tracks last
+	// upserted before cutoff.
+	DeleteOrphanTags(ctx context.Context, cutoff time.Time) (int64, error)
+	// DeleteOrphans removes orphaned tracks last upserted before cutoff.
+	DeleteOrphans(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// ArtistRepository handles artist database operations.
+type ArtistRepository interface {
+	// UpsertBatch inserts or updates multiple artists.
+	UpsertBatch(ctx context.Context, artists []Artist) error
+	// ReplaceTrackArtists sets the credited artists for the given tracks.
+	ReplaceTrackArtists(ctx context.Context, links []TrackArtist) error
+	// GetForTracks retrieves the credited artists for multiple tracks, in
+	// credit order.
+	GetForTracks(ctx context.Context, trackIDs []string) (map[string][]Artist, error)
+}
+
+// TagRepository handles track tag database operations.
+type TagRepository interface {
+	// UpsertBatch inserts or updates multiple Last.fm tags.
+	UpsertBatch(ctx context.Context, tags []TrackTag) error
+	// GetForTrack retrieves all tags for a track.
+	GetForTrack(ctx context.Context, trackID string) ([]TrackTag, error)
+	// GetForTracks retrieves tags for multiple tracks, keyed by track ID.
+	GetForTracks(ctx context.Context, trackIDs []string) (map[string][]TrackTag, error)
+	// GetStale returns track IDs with tags older than the given time.
+	GetStale(ctx context.Context, olderThan time.Time, limit int) ([]string, error)
+	// GetTracksWithoutTags returns the track IDs among trackIDs that have
+	// no tags.
+	GetTracksWithoutTags(ctx context.Context, trackIDs []string) ([]string, error)
+	// GetTracksWithStaleTags returns the track IDs among trackIDs whose
+	// newest tag was fetched before olderThan.
+	GetTracksWithStaleTags(ctx context.Context, trackIDs []string, olderThan time.Time) ([]string, error)
+	// DeleteForTrack removes all tags for a track.
+	DeleteForTrack(ctx context.Context, trackID string) error
+	// AddUserTag assigns a manual tag to a track in the user's library, or
+	// returns ErrNotFound if the track isn't in it.
+	AddUserTag(ctx context.Context, userID, trackID, tagName string) error
+	// RemoveUserTag removes a manual tag from a track.
+	RemoveUserTag(ctx context.Context, userID, trackID, tagName string) error
+	// GetUserTagsForTracks retrieves a user's manual tags for multiple
+	// tracks, keyed by track ID.
+	GetUserTagsForTracks(ctx context.Context, userID string, trackIDs []string) (map[string][]UserTrackTag, error)
+	// InsertUserTagsBatch restores manual tags with their original creation
+	// times.
+	InsertUserTagsBatch(ctx context.Context, userID string, tags []UserTrackTag) error
+}
+
+// PlayRepository handles listening history database operations.
+type PlayRepository interface {
+	// InsertBatch stores plays for a user, skipping plays already recorded,
+	// and returns the number of new plays.
+	InsertBatch(ctx context.Context, userID string, plays []Play) (int64, error)
+	// MatchTracks links a user's unmatched plays to tracks in their library
+	// and returns the number of plays matched.
+	MatchTracks(ctx context.Context, userID string) (int64, error)
+	// GetPlayTimesForUser retrieves when each track in a user's library was
+	// played, keyed by track ID.
+	GetPlayTimesForUser(ctx context.Context, userID string) (map[string][]time.Time, error)
+	// CountMatchedForUser returns the number of a user's plays linked to
+	// tracks in their library.
+	CountMatchedForUser(ctx context.Context, userID string) (int, error)
+	// LatestPlayedAt returns the time of a user's most recent play from a
+	// source, or nil if there are none.
+	LatestPlayedAt(ctx context.Context, userID, source string) (*time.Time, error)
+	// GetForUser retrieves all of a user's plays in chronological order.
+	GetForUser(ctx context.Context, userID string) ([]Play, error)
+}
+
+// EraRepository handles era database operations.
+type EraRepository interface {
+	// Create inserts a new era with its associated tracks.
+	Create(ctx context.Context, era *Era, trackIDs []string) error
+	// CreateWithTracks inserts a new era with its tracks and their reason
+	// tags.
+	CreateWithTracks(ctx context.Context, era *Era, tracks []EraTrack) error
+	// GetForOwner retrieves one of a user's eras by ID.
+	GetForOwner(ctx context.Context, userID string, id uuid.UUID) (*Era, error)
+	// GetForUser retrieves all eras for a user, parents before sub-eras.
+	GetForUser(ctx context.Context, userID string) ([]Era, error)
+	// GetTracksForOwner retrieves all tracks for one of a user's eras.
+	GetTracksForOwner(ctx context.Context, userID string, eraID uuid.UUID) ([]Track, error)
+	// GetTrackReasonsForOwner returns the reason tags of each track in one
+	// of a user's eras, keyed by track ID.
+	GetTrackReasonsForOwner(ctx context.Context, userID string, eraID uuid.UUID) (map[string][]string, error)
+	// GetTrackIDsForUser returns the track IDs of each of a user's eras,
+	// keyed by era ID.
+	GetTrackIDsForUser(ctx context.Context, userID string) (map[uuid.UUID][]string, error)
+	// GetTrackCountForOwner returns the number of tracks in one of a user's
+	// eras.
+	GetTrackCountForOwner(ctx context.Context, userID string, eraID uuid.UUID) (int, error)
+	// UpdatePlaylistIDForOwner sets the Spotify playlist ID for one of a
+	// user's eras.
+	UpdatePlaylistIDForOwner(ctx context.Context, userID string, eraID uuid.UUID, playlistID string) error
+	// UpdateNameOverrideForOwner sets or clears the user's name for one of
+	// their eras.
+	UpdateNameOverrideForOwner(ctx context.Context, userID string, eraID uuid.UUID, name *string) error
+	// ReplaceForUser atomically deletes a user's eras and creates the
+	// given ones, with tracks[i] the tracks of eras[i]. Parents must come
+	// before their sub-eras.
+	ReplaceForUser(ctx context.Context, userID string, eras []Era, tracks [][]EraTrack) error
+	// DeleteForUser removes all eras for a user.
+	DeleteForUser(ctx context.Context, userID string) error
+	// DeleteForOwner removes one of a user's eras by ID.
+	DeleteForOwner(ctx context.Context, userID string, id uuid.UUID) error
+}
+
+// SettingsRepository handles user settings database operations.
+type SettingsRepository interface {
+	// Get retrieves a user's settings, or ErrNotFound if they never saved
+	// any.
+	Get(ctx context.Context, userID string) (*UserSettings, error)
+	// Upsert creates or replaces a user's settings.
+	Upsert(ctx context.Context, settings *UserSettings) error
+}
+
+// HousekeepingRepository records housekeeping task runs.
+type HousekeepingRepository interface {
+	// Record inserts a task run and sets its ID.
+	Record(ctx context.Context, run *HousekeepingRun) error
+	// ListRecent returns up to limit task runs, most recent first.
+	ListRecent(ctx context.Context, limit int) ([]HousekeepingRun, error)
+}
@@ -10,22 +10,29 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// SessionRepository handles session database operations.
-type SessionRepository struct {
+// pgSessionRepository implements SessionRepository with PostgreSQL.
+type pgSessionRepository struct {
 	pool *pgxpool.Pool
+	keys *Keyring
 }
 
-// Create inserts a new session.
-func (r *SessionRepository) Create(ctx context.Context, session *Session) error {
+// Create inserts a new session. Its tokens are encrypted if a keyring is
+// set.
+func (r *pgSessionRepository) Create(ctx context.Context, session *Session) error {
+	accessToken, refreshToken, err := sealTokens(r.keys, session.AccessToken, session.RefreshToken)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		INSERT INTO sessions (id, user_id, access_token, refresh_token, token_expiry, created_at, expires_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
-	_, err := r.pool.Exec(ctx, query,
+	_, err = r.pool.Exec(ctx, query,
 		session.ID,
 		session.UserID,
-		session.AccessToken,
-		session.RefreshToken,
+		accessToken,
+		refreshToken,
 		session.TokenExpiry,
 		session.CreatedAt,
 		session.ExpiresAt,
@@ -37,7 +44,7 @@ func (r *SessionRepository) Create(ctx context.Context, session *Session) error
 }
 
 // Get retrieves a session by ID.
-func (r *SessionRepository) Get(ctx context.Context, id string) (*Session, error) {
+func (r *pgSessionRepository) Get(ctx context.Context, id string) (*Session, error) {
 	query := `
 		SELECT id, user_id, access_token, refresh_token, token_expiry, created_at, expires_at
 		FROM sessions
@@ -59,11 +66,15 @@ func (r *SessionRepository) Get(ctx context.Context, id string) (*Session, error
 	if err != nil {
 		return nil, fmt.Errorf("querying session: %w", err)
 	}
+	session.AccessToken, session.RefreshToken, err = openTokens(r.keys, session.AccessToken, session.RefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("session %s: %w", id, err)
+	}
 	return &session, nil
 }
 
 // Delete removes a session by ID.
-func (r *SessionRepository) Delete(ctx context.Context, id string) error {
+func (r *pgSessionRepository) Delete(ctx context.Context, id string) error {
 	query := `DELETE FROM sessions WHERE id = $1`
 	_, err := r.pool.Exec(ctx, query, id)
 	if err != nil {
@@ -73,7 +84,12 @@ func (r *SessionRepository) Delete(ctx context.Context, id string) error {
 }
 
 // UpdateToken updates the OAuth tokens for a session.
-func (r *SessionRepository) UpdateToken(ctx context.Context, id, accessToken, refreshToken string, expiry time.Time) error {
+func (r *pgSessionRepository) UpdateToken(ctx context.Context, id, accessToken, refreshToken string, expiry time.Time) error {
+	accessToken, refreshToken, err := sealTokens(r.keys, accessToken, refreshToken)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE sessions
 		SET access_token = $2, refresh_token = $3, token_expiry = $4
@@ -90,7 +106,7 @@ func (r *SessionRepository) UpdateToken(ctx context.Context, id, accessToken, re
 }
 
 // DeleteExpired removes all expired sessions.
-func (r *SessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
+func (r *pgSessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
 	query := `DELETE FROM sessions WHERE expires_at <= NOW()`
 	result, err := r.pool.Exec(ctx, query)
 	if err != nil {
@@ -100,7 +116,7 @@ func (r *SessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
 }
 
 // DeleteForUser removes all sessions for a user.
-func (r *SessionRepository) DeleteForUser(ctx context.Context, userID string) error {
+func (r *pgSessionRepository) DeleteForUser(ctx context.Context, userID string) error {
 	query := `DELETE FROM sessions WHERE user_id = $1`
 	_, err := r.pool.Exec(ctx, query, userID)
 	if err != nil {
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pgSettingsRepository implements SettingsRepository with PostgreSQL.
+type pgSettingsRepository struct {
+	pool *pgxpool.Pool
+}
+
+// Get retrieves a user's settings.
+// Returns ErrNotFound if the user has never saved settings.
+func (r *pgSettingsRepository) Get(ctx context.Context, userID string) (*UserSettings, error) {
+	query := `
+		SELECT user_id, num_clusters, min_cluster_size, max_tags, manual_tag_weight,
+			basis, time_weight, name_template, sub_era_depth, sync_interval_hours,
+			created_at, updated_at
+		FROM user_settings
+		WHERE user_id = $1
+	`
+	var settings UserSettings
+	err := r.pool.QueryRow(ctx, query, userID).Scan(
+		&settings.UserID,
+		&settings.NumClusters,
+		&settings.MinClusterSize,
+		&settings.MaxTags,
+		&settings.ManualTagWeight,
+		&settings.Basis,
+		&settings.TimeWeight,
+		&settings.NameTemplate,
+		&settings.SubEraDepth,
+		&settings.SyncIntervalHours,
+		&settings.CreatedAt,
+		&settings.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying user settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// Upsert creates or replaces a user's settings.
+func (r *pgSettingsRepository) Upsert(ctx context.Context, settings *UserSettings) error {
+	query := `
+		INSERT INTO user_settings (user_id, num_clusters, min_cluster_size, max_tags,
+			manual_tag_weight, basis, time_weight, name_template, sub_era_depth, sync_interval_hours,
+			created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			num_clusters = EXCLUDED.num_clusters,
+			min_cluster_size = EXCLUDED.min_cluster_size,
+			max_tags = EXCLUDED.max_tags,
+			manual_tag_weight = EXCLUDED.manual_tag_weight,
+			basis = EXCLUDED.basis,
+			time_weight = EXCLUDED.time_weight,
+			name_template = EXCLUDED.name_template,
+			sub_era_depth = EXCLUDED.sub_era_depth,
+			sync_interval_hours = EXCLUDED.sync_interval_hours,
+			updated_at = NOW()
+		RETURNING created_at, updated_at
+	`
+	err := r.pool.QueryRow(ctx, query,
+		settings.UserID,
+		settings.NumClusters,
+		settings.MinClusterSize,
+		settings.MaxTags,
+		settings.ManualTagWeight,
+		settings.Basis,
+		settings.TimeWeight,
+		settings.NameTemplate,
+		settings.SubEraDepth,
+		settings.SyncIntervalHours,
+	).Scan(&settings.CreatedAt, &settings.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("upserting user settings: %w", err)
+	}
+	return nil
+}
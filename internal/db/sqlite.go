@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+func init() {
+	// SQLite's lower() only folds ASCII letters, where PostgreSQL's folds
+	// all of Unicode. Play matching compares names like "Sigur Rós"
+	sqlite.MustRegisterDeterministicScalarFunction("unicode_lower", 1,
+		func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			switch v := args[0].(type) {
+			case string:
+				return strings.ToLower(v), nil
+			case []byte:
+				return strings.ToLower(string(v)), nil
+			}
+			return args[0], nil
+		})
+}
+
+// sqliteScheme prefixes SQLite database URLs, e.g. sqlite://eras.db or
+// sqlite:///var/lib/eras/eras.db.
+const sqliteScheme = "sqlite://"
+
+// sqliteParams configure every connection. Foreign keys are off by default
+// in SQLite and the ON DELETE CASCADEs depend on them; the busy timeout
+// makes another process's writes wait rather than fail. Times are written
+// in a fixed format, which sorts chronologically as long as they are UTC.
+const sqliteParams = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite"
+
+// openSQLite opens the SQLite database file at path, creating it if needed.
+func openSQLite(ctx context.Context, path string) (*DB, error) {
+	if path == "" {
+		return nil, errors.New("parsing database URL: no SQLite file, want e.g. sqlite://eras.db")
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	database, err := sql.Open("sqlite", "file:"+path+sep+sqliteParams)
+	if err != nil {
+		return nil, fmt.Errorf("opening SQLite database: %w", err)
+	}
+
+	// SQLite allows one writer at a time, so a single connection queues
+	// writes instead of failing them. It also keeps sqlite://:memory: one
+	// database rather than one per connection
+	database.SetMaxOpenConns(1)
+
+	if err := database.PingContext(ctx); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("opening SQLite database: %w", err)
+	}
+	return &DB{sqlite: database}, nil
+}
+
+// sqliteQuerier is a *sql.DB, *sql.Conn or *sql.Tx. With a single
+// connection, a transaction's queries must go through the *sql.Tx, and
+// rows must be closed before the next query.
+type sqliteQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// sqliteExec runs a statement with sqliteArgs.
+func sqliteExec(ctx context.Context, q sqliteQuerier, query string, args ...any) (sql.Result, error) {
+	return q.ExecContext(ctx, query, sqliteArgs(args)...)
+}
+
+// sqliteQuery runs a query with sqliteArgs.
+func sqliteQuery(ctx context.Context, q sqliteQuerier, query string, args ...any) (*sql.Rows, error) {
+	return q.QueryContext(ctx, query, sqliteArgs(args)...)
+}
+
+// sqliteQueryRow runs a single-row query with sqliteArgs.
+func sqliteQueryRow(ctx context.Context, q sqliteQuerier, query string, args ...any) *sql.Row {
+	return q.QueryRowContext(ctx, query, sqliteArgs(args)...)
+}
+
+// sqliteArgs converts times to UTC. SQLite stores them as text, which only
+// compares chronologically within one time zone.
+func sqliteArgs(args []any) []any {
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			args[i] = v.UTC()
+		case *time.Time:
+			if v != nil {
+				args[i] = v.UTC()
+			}
+		}
+	}
+	return args
+}
+
+// sqliteTx runs fn in a transaction, committing if it returns nil.
+func sqliteTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// rowsAffected returns a result's affected row count, or 0 if the driver
+// can't tell.
+func rowsAffected(result sql.Result) int64 {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// textArray stores a string slice as a JSON array, SQLite's equivalent of
+// PostgreSQL's TEXT[]. Queries read its elements with json_each, e.g.
+// WHERE id IN (SELECT value FROM json_each($1)) in place of = ANY($1).
+type textArray []string
+
+// Value encodes the array as JSON. A nil array is stored as [].
+func (a textArray) Value() (driver.Value, error) {
+	if a == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal([]string(a))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan decodes a JSON array. NULL decodes to nil.
+func (a *textArray) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("scanning text array: unsupported type %T", src)
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("decoding text array: %w", err)
+	}
+	*a = values
+	return nil
+}
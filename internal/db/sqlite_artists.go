@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// sqliteArtistRepository implements ArtistRepository with SQLite.
+type sqliteArtistRepository struct {
+	db *sql.DB
+}
+
+// UpsertBatch inserts or updates multiple artists in one transaction.
+func (r *sqliteArtistRepository) UpsertBatch(ctx context.Context, artists []Artist) error {
+	if len(artists) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO artists (id, name, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name
+	`
+	now := time.Now()
+	err := sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, a := range artists {
+			if _, err := sqliteExec(ctx, tx, query, a.ID, a.Name, now); err != nil {
+				return fmt.Errorf("artist %s: %w", a.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("batch upserting artists: %w", err)
+	}
+	return nil
+}
+
+// ReplaceTrackArtists sets the credited artists for the given tracks.
+// Existing credits for every track in links are replaced, so artists removed
+// from a track on Spotify are removed here too.
+func (r *sqliteArtistRepository) ReplaceTrackArtists(ctx context.Context, links []TrackArtist) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	trackIDs := make(textArray, len(links))
+	for i, l := range links {
+		trackIDs[i] = l.TrackID
+	}
+
+	return sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := sqliteExec(ctx, tx,
+			`DELETE FROM track_artists WHERE track_id IN (SELECT value FROM json_each($1))`, trackIDs)
+		if err != nil {
+			return fmt.Errorf("deleting track artists: %w", err)
+		}
+
+		query := `
+			INSERT INTO track_artists (track_id, artist_id, position)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (track_id, artist_id) DO NOTHING
+		`
+		for _, l := range links {
+			if _, err := sqliteExec(ctx, tx, query, l.TrackID, l.ArtistID, l.Position); err != nil {
+				return fmt.Errorf("inserting track artists: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetForTracks retrieves the credited artists for multiple tracks, returning a
+// map of track ID to artists ordered by credit position (primary artist first).
+func (r *sqliteArtistRepository) GetForTracks(ctx context.Context, trackIDs []string) (map[string][]Artist, error) {
+	if len(trackIDs) == 0 {
+		return make(map[string][]Artist), nil
+	}
+
+	query := `
+		SELECT ta.track_id, a.id, a.name, a.created_at
+		FROM track_artists ta
+		JOIN artists a ON a.id = ta.artist_id
+		WHERE ta.track_id IN (SELECT value FROM json_each($1))
+		ORDER BY ta.track_id, ta.position
+	`
+	rows, err := sqliteQuery(ctx, r.db, query, textArray(trackIDs))
+	if err != nil {
+		return nil, fmt.Errorf("querying track artists: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]Artist)
+	for rows.Next() {
+		var trackID string
+		var artist Artist
+		if err := rows.Scan(
+			&trackID,
+			&artist.ID,
+			&artist.Name,
+			&artist.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scanning artist: %w", err)
+		}
+		result[trackID] = append(result[trackID], artist)
+	}
+	return result, rows.Err()
+}
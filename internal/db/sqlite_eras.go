@@ -0,0 +1,356 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sqliteEraRepository implements EraRepository with SQLite. Top tags and
+// reason tags are stored as textArray JSON, tag weights as JSON text.
+type sqliteEraRepository struct {
+	db *sql.DB
+}
+
+// sqliteScanEra scans a row selected with eraColumns.
+func sqliteScanEra(row interface{ Scan(dest ...any) error }) (Era, error) {
+	var era Era
+	var tagWeights *string
+	err := row.Scan(
+		&era.ID,
+		&era.UserID,
+		&era.Name,
+		(*textArray)(&era.TopTags),
+		&era.StartDate,
+		&era.EndDate,
+		&era.PlaylistID,
+		&era.CreatedAt,
+		&era.Cohesion,
+		&era.Silhouette,
+		&tagWeights,
+		&era.ParentID,
+		&era.NameOverride,
+	)
+	if err != nil {
+		return era, err
+	}
+	if tagWeights != nil {
+		if err := json.Unmarshal([]byte(*tagWeights), &era.TagWeights); err != nil {
+			return era, fmt.Errorf("decoding tag weights: %w", err)
+		}
+	}
+	return era, nil
+}
+
+// Create inserts a new era with its associated tracks.
+func (r *sqliteEraRepository) Create(ctx context.Context, era *Era, trackIDs []string) error {
+	tracks := make([]EraTrack, len(trackIDs))
+	for i, id := range trackIDs {
+		tracks[i] = EraTrack{TrackID: id}
+	}
+	return r.CreateWithTracks(ctx, era, tracks)
+}
+
+// CreateWithTracks inserts a new era with its tracks and the tags
+// explaining why each track belongs to it. A sub-era's parent must be
+// created first.
+func (r *sqliteEraRepository) CreateWithTracks(ctx context.Context, era *Era, tracks []EraTrack) error {
+	return sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		return sqliteInsertEra(ctx, tx, era, tracks)
+	})
+}
+
+// ReplaceForUser deletes a user's eras and creates the given ones, with
+// tracks[i] the tracks of eras[i], in one transaction: if any era fails the
+// user keeps their previous eras. Parents must come before their sub-eras.
+// Returns ErrNotFound if the user doesn't exist.
+func (r *sqliteEraRepository) ReplaceForUser(ctx context.Context, userID string, eras []Era, tracks [][]EraTrack) error {
+	return sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		var exists bool
+		err := sqliteQueryRow(ctx, tx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("checking user: %w", err)
+		}
+		if !exists {
+			return ErrNotFound
+		}
+
+		if _, err := sqliteExec(ctx, tx, `DELETE FROM eras WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("deleting user eras: %w", err)
+		}
+		for i := range eras {
+			if err := sqliteInsertEra(ctx, tx, &eras[i], tracks[i]); err != nil {
+				return fmt.Errorf("creating era %q: %w", eras[i].Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// sqliteInsertEra inserts an era and its tracks within tx, generating its
+// ID if unset.
+func sqliteInsertEra(ctx context.Context, tx *sql.Tx, era *Era, tracks []EraTrack) error {
+	var tagWeights *string
+	if len(era.TagWeights) > 0 {
+		data, err := json.Marshal(era.TagWeights)
+		if err != nil {
+			return fmt.Errorf("encoding tag weights: %w", err)
+		}
+		encoded := string(data)
+		tagWeights = &encoded
+	}
+	if era.ID == uuid.Nil {
+		era.ID = uuid.New()
+	}
+
+	eraQuery := `
+		INSERT INTO eras (id, user_id, name, top_tags, start_date, end_date, playlist_id, created_at,
+			cohesion, silhouette, tag_weights, parent_id, name_override)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING created_at
+	`
+	err := sqliteQueryRow(ctx, tx, eraQuery,
+		era.ID,
+		era.UserID,
+		era.Name,
+		textArray(era.TopTags),
+		era.StartDate,
+		era.EndDate,
+		era.PlaylistID,
+		time.Now(),
+		era.Cohesion,
+		era.Silhouette,
+		tagWeights,
+		era.ParentID,
+		era.NameOverride,
+	).Scan(&era.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("inserting era: %w", err)
+	}
+
+	tracksQuery := `INSERT INTO era_tracks (era_id, track_id, reason_tags) VALUES ($1, $2, $3)`
+	for _, t := range tracks {
+		if _, err := sqliteExec(ctx, tx, tracksQuery, era.ID, t.TrackID, textArray(t.ReasonTags)); err != nil {
+			return fmt.Errorf("inserting era tracks: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetForOwner retrieves one of a user's eras by ID. Returns ErrNotFound if
+// the era doesn't exist or belongs to another user.
+func (r *sqliteEraRepository) GetForOwner(ctx context.Context, userID string, id uuid.UUID) (*Era, error) {
+	query := `SELECT ` + eraColumns + ` FROM eras WHERE id = $1 AND user_id = $2`
+	era, err := sqliteScanEra(sqliteQueryRow(ctx, r.db, query, id, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying era: %w", err)
+	}
+	return &era, nil
+}
+
+// GetForUser retrieves all eras for a user, including sub-eras. Eras are
+// ordered by depth, so parents come before their sub-eras, then by start
+// date desc.
+func (r *sqliteEraRepository) GetForUser(ctx context.Context, userID string) ([]Era, error) {
+	// The tree holds only IDs and depths: columns selected through a CTE
+	// lose their declared types, so times would be read back as text
+	query := `
+		WITH RECURSIVE tree (era_id, depth) AS (
+			SELECT id, 0
+			FROM eras
+			WHERE user_id = $1 AND parent_id IS NULL
+			UNION ALL
+			SELECT e.id, tree.depth + 1
+			FROM eras e
+			JOIN tree ON e.parent_id = tree.era_id
+		)
+		SELECT ` + eraColumns + `
+		FROM tree
+		JOIN eras ON eras.id = tree.era_id
+		ORDER BY depth, start_date DESC
+	`
+	rows, err := sqliteQuery(ctx, r.db, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying user eras: %w", err)
+	}
+	defer rows.Close()
+
+	var eras []Era
+	for rows.Next() {
+		era, err := sqliteScanEra(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning era: %w", err)
+		}
+		eras = append(eras, era)
+	}
+	return eras, rows.Err()
+}
+
+// GetTracksForOwner retrieves all tracks for one of a user's eras. An era
+// that belongs to another user has no tracks.
+func (r *sqliteEraRepository) GetTracksForOwner(ctx context.Context, userID string, eraID uuid.UUID) ([]Track, error) {
+	query := `
+		SELECT t.id, t.name, t.artist, t.album, t.album_id, t.duration_ms, t.created_at
+		FROM tracks t
+		JOIN era_tracks et ON t.id = et.track_id
+		JOIN eras e ON e.id = et.era_id
+		WHERE et.era_id = $1 AND e.user_id = $2
+	`
+	rows, err := sqliteQuery(ctx, r.db, query, eraID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying era tracks: %w", err)
+	}
+	defer rows.Close()
+
+	var tracks []Track
+	for rows.Next() {
+		var track Track
+		if err := rows.Scan(
+			&track.ID,
+			&track.Name,
+			&track.Artist,
+			&track.Album,
+			&track.AlbumID,
+			&track.DurationMs,
+			&track.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scanning track: %w", err)
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, rows.Err()
+}
+
+// GetTrackReasonsForOwner returns the tags explaining each track's
+// membership in one of a user's eras, keyed by track ID. Tracks without
+// reasons are omitted, as are all tracks of another user's era.
+func (r *sqliteEraRepository) GetTrackReasonsForOwner(ctx context.Context, userID string, eraID uuid.UUID) (map[string][]string, error) {
+	query := `
+		SELECT et.track_id, et.reason_tags
+		FROM era_tracks et
+		JOIN eras e ON e.id = et.era_id
+		WHERE et.era_id = $1 AND e.user_id = $2 AND json_array_length(et.reason_tags) > 0
+	`
+	rows, err := sqliteQuery(ctx, r.db, query, eraID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying track reasons: %w", err)
+	}
+	defer rows.Close()
+
+	reasons := make(map[string][]string)
+	for rows.Next() {
+		var trackID string
+		var tags textArray
+		if err := rows.Scan(&trackID, &tags); err != nil {
+			return nil, fmt.Errorf("scanning track reasons: %w", err)
+		}
+		reasons[trackID] = tags
+	}
+	return reasons, rows.Err()
+}
+
+// GetTrackIDsForUser returns the track IDs of each of a user's eras,
+// including sub-eras, keyed by era ID.
+func (r *sqliteEraRepository) GetTrackIDsForUser(ctx context.Context, userID string) (map[uuid.UUID][]string, error) {
+	query := `
+		SELECT et.era_id, et.track_id
+		FROM era_tracks et
+		JOIN eras e ON e.id = et.era_id
+		WHERE e.user_id = $1
+	`
+	rows, err := sqliteQuery(ctx, r.db, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying era track IDs: %w", err)
+	}
+	defer rows.Close()
+
+	trackIDs := make(map[uuid.UUID][]string)
+	for rows.Next() {
+		var eraID uuid.UUID
+		var trackID string
+		if err := rows.Scan(&eraID, &trackID); err != nil {
+			return nil, fmt.Errorf("scanning era track ID: %w", err)
+		}
+		trackIDs[eraID] = append(trackIDs[eraID], trackID)
+	}
+	return trackIDs, rows.Err()
+}
+
+// GetTrackCountForOwner returns the number of tracks in one of a user's
+// eras, or 0 for another user's era.
+func (r *sqliteEraRepository) GetTrackCountForOwner(ctx context.Context, userID string, eraID uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM era_tracks et
+		JOIN eras e ON e.id = et.era_id
+		WHERE et.era_id = $1 AND e.user_id = $2
+	`
+	var count int
+	err := sqliteQueryRow(ctx, r.db, query, eraID, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("counting era tracks: %w", err)
+	}
+	return count, nil
+}
+
+// UpdatePlaylistIDForOwner sets the Spotify playlist ID for one of a user's
+// eras. Returns ErrNotFound if the era doesn't exist or belongs to another
+// user.
+func (r *sqliteEraRepository) UpdatePlaylistIDForOwner(ctx context.Context, userID string, eraID uuid.UUID, playlistID string) error {
+	query := `UPDATE eras SET playlist_id = $3 WHERE id = $1 AND user_id = $2`
+	result, err := sqliteExec(ctx, r.db, query, eraID, userID, playlistID)
+	if err != nil {
+		return fmt.Errorf("updating playlist ID: %w", err)
+	}
+	if rowsAffected(result) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpdateNameOverrideForOwner sets the user's name for one of their eras, or
+// clears it if name is nil. Returns ErrNotFound if the era doesn't exist or
+// belongs to another user.
+func (r *sqliteEraRepository) UpdateNameOverrideForOwner(ctx context.Context, userID string, eraID uuid.UUID, name *string) error {
+	query := `UPDATE eras SET name_override = $3 WHERE id = $1 AND user_id = $2`
+	result, err := sqliteExec(ctx, r.db, query, eraID, userID, name)
+	if err != nil {
+		return fmt.Errorf("updating name override: %w", err)
+	}
+	if rowsAffected(result) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteForUser removes all eras for a user.
+func (r *sqliteEraRepository) DeleteForUser(ctx context.Context, userID string) error {
+	query := `DELETE FROM eras WHERE user_id = $1`
+	_, err := sqliteExec(ctx, r.db, query, userID)
+	if err != nil {
+		return fmt.Errorf("deleting user eras: %w", err)
+	}
+	return nil
+}
+
+// DeleteForOwner removes one of a user's eras by ID. Returns ErrNotFound if
+// the era doesn't exist or belongs to another user.
+func (r *sqliteEraRepository) DeleteForOwner(ctx context.Context, userID string, id uuid.UUID) error {
+	query := `DELETE FROM eras WHERE id = $1 AND user_id = $2`
+	result, err := sqliteExec(ctx, r.db, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("deleting era: %w", err)
+	}
+	if rowsAffected(result) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
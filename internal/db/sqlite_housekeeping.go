@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// sqliteHousekeepingRepository implements HousekeepingRepository with
+// SQLite.
+type sqliteHousekeepingRepository struct {
+	db *sql.DB
+}
+
+// Record inserts a task run and sets its ID.
+func (r *sqliteHousekeepingRepository) Record(ctx context.Context, run *HousekeepingRun) error {
+	query := `
+		INSERT INTO housekeeping_runs (task, removed, error, started_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	err := sqliteQueryRow(ctx, r.db, query, run.Task, run.Removed, run.Error, run.StartedAt, run.FinishedAt).Scan(&run.ID)
+	if err != nil {
+		return fmt.Errorf("recording housekeeping run: %w", err)
+	}
+	return nil
+}
+
+// ListRecent returns up to limit task runs, most recent first.
+func (r *sqliteHousekeepingRepository) ListRecent(ctx context.Context, limit int) ([]HousekeepingRun, error) {
+	query := `
+		SELECT id, task, removed, error, started_at, finished_at
+		FROM housekeeping_runs
+		ORDER BY started_at DESC, id DESC
+		LIMIT $1
+	`
+	rows, err := sqliteQuery(ctx, r.db, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying housekeeping runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []HousekeepingRun
+	for rows.Next() {
+		var run HousekeepingRun
+		if err := rows.Scan(&run.ID, &run.Task, &run.Removed, &run.Error, &run.StartedAt, &run.FinishedAt); err != nil {
+			return nil, fmt.Errorf("scanning housekeeping run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating housekeeping runs: %w", err)
+	}
+	return runs, nil
+}
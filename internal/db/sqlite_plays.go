@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sqlitePlayRepository implements PlayRepository with SQLite.
+type sqlitePlayRepository struct {
+	db *sql.DB
+}
+
+// InsertBatch stores plays for a user, skipping plays already recorded.
+// A play's TrackID is kept only if the track exists; otherwise it is stored
+// unmatched. Returns the number of new plays.
+func (r *sqlitePlayRepository) InsertBatch(ctx context.Context, userID string, plays []Play) (int64, error) {
+	if len(plays) == 0 {
+		return 0, nil
+	}
+
+	query := `
+		INSERT INTO plays (user_id, track_id, artist_name, track_name, played_at, ms_played, source, created_at)
+		VALUES ($1, (SELECT id FROM tracks WHERE id = $2), $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, played_at, artist_name, track_name) DO NOTHING
+	`
+	var inserted int64
+	now := time.Now()
+	err := sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, p := range plays {
+			result, err := sqliteExec(ctx, tx, query,
+				userID, p.TrackID, p.ArtistName, p.TrackName, p.PlayedAt, p.MsPlayed, p.Source, now)
+			if err != nil {
+				return err
+			}
+			inserted += rowsAffected(result)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("batch inserting plays: %w", err)
+	}
+	return inserted, nil
+}
+
+// MatchTracks links a user's unmatched plays to tracks in their library by
+// case-insensitive track and artist name. Any credited artist matches, since
+// sources report artists differently. Returns the number of plays matched.
+func (r *sqlitePlayRepository) MatchTracks(ctx context.Context, userID string) (int64, error) {
+	query := `
+		UPDATE plays AS p SET track_id = m.track_id
+		FROM (
+			SELECT p2.id, MIN(t.id) AS track_id
+			FROM plays p2
+			JOIN tracks t ON unicode_lower(t.name) = unicode_lower(p2.track_name)
+			JOIN user_tracks ut ON ut.track_id = t.id AND ut.user_id = p2.user_id
+			WHERE p2.user_id = $1
+			  AND p2.track_id IS NULL
+			  AND (
+				unicode_lower(t.artist) = unicode_lower(p2.artist_name)
+				OR EXISTS (
+					SELECT 1 FROM track_artists ta
+					JOIN artists a ON a.id = ta.artist_id
+					WHERE ta.track_id = t.id AND unicode_lower(a.name) = unicode_lower(p2.artist_name)
+				)
+			  )
+			GROUP BY p2.id
+		) AS m
+		WHERE p.id = m.id
+	`
+	result, err := sqliteExec(ctx, r.db, query, userID)
+	if err != nil {
+		return 0, fmt.Errorf("matching plays to tracks: %w", err)
+	}
+	return rowsAffected(result), nil
+}
+
+// GetPlayTimesForUser retrieves when each track in a user's library was
+// played, returning a map of track ID to play times in chronological order.
+// Plays of tracks outside the library are excluded.
+func (r *sqlitePlayRepository) GetPlayTimesForUser(ctx context.Context, userID string) (map[string][]time.Time, error) {
+	query := `
+		SELECT p.track_id, p.played_at
+		FROM plays p
+		JOIN user_tracks ut ON ut.track_id = p.track_id AND ut.user_id = p.user_id
+		WHERE p.user_id = $1
+		ORDER BY p.played_at
+	`
+	rows, err := sqliteQuery(ctx, r.db, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying plays: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]time.Time)
+	for rows.Next() {
+		var trackID string
+		var playedAt time.Time
+		if err := rows.Scan(&trackID, &playedAt); err != nil {
+			return nil, fmt.Errorf("scanning play: %w", err)
+		}
+		result[trackID] = append(result[trackID], playedAt)
+	}
+	return result, rows.Err()
+}
+
+// CountMatchedForUser returns the number of a user's plays linked to tracks
+// in their library.
+func (r *sqlitePlayRepository) CountMatchedForUser(ctx context.Context, userID string) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM plays p
+		JOIN user_tracks ut ON ut.track_id = p.track_id AND ut.user_id = p.user_id
+		WHERE p.user_id = $1
+	`
+	var count int
+	if err := sqliteQueryRow(ctx, r.db, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("counting plays: %w", err)
+	}
+	return count, nil
+}
+
+// LatestPlayedAt returns the time of a user's most recent play from a source,
+// or nil if there are none. Importers use it as a cursor to resume.
+func (r *sqlitePlayRepository) LatestPlayedAt(ctx context.Context, userID, source string) (*time.Time, error) {
+	// Ordering rather than MAX(), whose result has no declared type and so
+	// would be read back as text instead of a time
+	query := `
+		SELECT played_at FROM plays
+		WHERE user_id = $1 AND source = $2
+		ORDER BY played_at DESC
+		LIMIT 1
+	`
+	var latest time.Time
+	err := sqliteQueryRow(ctx, r.db, query, userID, source).Scan(&latest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting latest play: %w", err)
+	}
+	return &latest, nil
+}
+
+// GetForUser retrieves all of a user's plays in chronological order.
+func (r *sqlitePlayRepository) GetForUser(ctx context.Context, userID string) ([]Play, error) {
+	query := `
+		SELECT id, user_id, track_id, artist_name, track_name, played_at, ms_played, source, created_at
+		FROM plays
+		WHERE user_id = $1
+		ORDER BY played_at, id
+	`
+	rows, err := sqliteQuery(ctx, r.db, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying plays: %w", err)
+	}
+	defer rows.Close()
+
+	var plays []Play
+	for rows.Next() {
+		var play Play
+		if err := rows.Scan(
+			&play.ID,
+			&play.UserID,
+			&play.TrackID,
+			&play.ArtistName,
+			&play.TrackName,
+			&play.PlayedAt,
+			&play.MsPlayed,
+			&play.Source,
+			&play.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scanning play: %w", err)
+		}
+		plays = append(plays, play)
+	}
+	return plays, rows.Err()
+}
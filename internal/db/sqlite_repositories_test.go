@@ -0,0 +1,481 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/db/dbtest"
+	"github.com/justestif/go-spotify-era-organizer/migrations"
+)
+
+// addLibrary creates a user with liked tracks, the first liked first.
+func addLibrary(t *testing.T, database *db.DB, userID string, tracks ...db.Track) {
+	t.Helper()
+	ctx := context.Background()
+	if err := database.Users().EnsureExists(ctx, userID); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Tracks().UpsertBatch(ctx, tracks); err != nil {
+		t.Fatal(err)
+	}
+	links := make([]db.UserTrack, len(tracks))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, track := range tracks {
+		links[i] = db.UserTrack{TrackID: track.ID, AddedAt: start.AddDate(0, 0, i)}
+	}
+	if err := database.Tracks().LinkBatchToUser(ctx, userID, links); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSQLite_Migrations(t *testing.T) {
+	ctx := context.Background()
+	database := dbtest.NewSQLite(t)
+	all, err := db.LoadMigrations(migrations.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := database.CheckSchema(ctx, all)
+	if err != nil {
+		t.Fatalf("CheckSchema() error = %v", err)
+	}
+	if status.Version != all[len(all)-1].Version || status.Pending != 0 {
+		t.Errorf("status = %+v, want fully migrated", status)
+	}
+
+	status, err = database.MigrateDown(ctx, all, len(all))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != 0 {
+		t.Errorf("Version after migrating down = %d, want 0", status.Version)
+	}
+	if _, err := database.CheckSchema(ctx, all); !errors.Is(err, db.ErrSchemaOutdated) {
+		t.Errorf("CheckSchema() error = %v, want ErrSchemaOutdated", err)
+	}
+	if err := database.Users().EnsureExists(ctx, "alice"); err == nil {
+		t.Error("users table survived migrating down")
+	}
+
+	if _, err := database.Migrate(ctx, all); err != nil {
+		t.Fatalf("migrating up again: %v", err)
+	}
+	if err := database.Users().EnsureExists(ctx, "alice"); err != nil {
+		t.Errorf("EnsureExists() after migrating up = %v", err)
+	}
+}
+
+func TestSQLite_UsersAndSessions(t *testing.T) {
+	ctx := context.Background()
+	database := dbtest.NewSQLite(t)
+	database.SetKeyring(db.MustParseKeyring(t, "k1:"+db.TestKey(1)))
+
+	user := &db.User{ID: "alice", DisplayName: "Alice"}
+	if err := database.Users().Upsert(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if user.CreatedAt.IsZero() {
+		t.Error("Upsert() didn't set CreatedAt")
+	}
+	if _, err := database.Users().GetToken(ctx, "alice"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetToken() before saving error = %v, want ErrNotFound", err)
+	}
+
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := database.Users().SaveToken(ctx, "alice", "access", "refresh", expiry); err != nil {
+		t.Fatal(err)
+	}
+	token, err := database.Users().GetToken(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" || !token.TokenExpiry.Equal(expiry) {
+		t.Errorf("GetToken() = %+v", token)
+	}
+	if err := database.Users().SaveToken(ctx, "nobody", "a", "r", expiry); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("SaveToken(nobody) error = %v, want ErrNotFound", err)
+	}
+
+	scheduled, err := database.Users().ListScheduled(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scheduled) != 1 || scheduled[0].ID != "alice" || scheduled[0].LastSyncAt != nil {
+		t.Errorf("ListScheduled() = %+v, want alice, never synced", scheduled)
+	}
+
+	// Times in another zone compare correctly once stored
+	zone := time.FixedZone("UTC+10", 10*60*60)
+	now := time.Now().In(zone)
+	sessions := database.Sessions()
+	for _, s := range []*db.Session{
+		{ID: "live", UserID: "alice", AccessToken: "a", RefreshToken: "r", TokenExpiry: now, CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{ID: "expired", UserID: "alice", AccessToken: "a", RefreshToken: "r", TokenExpiry: now, CreatedAt: now, ExpiresAt: now.Add(-time.Minute)},
+	} {
+		if err := sessions.Create(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := sessions.Get(ctx, "live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccessToken != "a" || got.RefreshToken != "r" {
+		t.Errorf("Get() tokens = %q, %q, want them decrypted", got.AccessToken, got.RefreshToken)
+	}
+	if _, err := sessions.Get(ctx, "expired"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("Get(expired) error = %v, want ErrNotFound", err)
+	}
+	if n, err := sessions.DeleteExpired(ctx); err != nil || n != 1 {
+		t.Errorf("DeleteExpired() = %d, %v, want 1", n, err)
+	}
+
+	// Rotating to a new key re-encrypts the user and the remaining session
+	database.SetKeyring(db.MustParseKeyring(t, "k2:"+db.TestKey(2)+",k1:"+db.TestKey(1)))
+	rotation, err := database.RotateTokens(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotation.Users != 1 || rotation.Sessions != 1 {
+		t.Errorf("RotateTokens() = %+v, want 1 user and 1 session", rotation)
+	}
+	if rotation, _ := database.RotateTokens(ctx); rotation.Users != 0 || rotation.Sessions != 0 {
+		t.Errorf("second RotateTokens() = %+v, want nothing left", rotation)
+	}
+
+	// Deleting the user cascades to its sessions
+	if _, err := database.SQLiteDB().ExecContext(ctx, `DELETE FROM users WHERE id = 'alice'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sessions.Get(ctx, "live"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("Get() after deleting the user error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLite_LibraryTagsAndPlays(t *testing.T) {
+	ctx := context.Background()
+	database := dbtest.NewSQLite(t)
+	addLibrary(t, database, "alice",
+		db.Track{ID: "t1", Name: "Hoppípolla", Artist: "Sigur Rós"},
+		db.Track{ID: "t2", Name: "Svefn-g-englar", Artist: "Sigur Rós"},
+	)
+
+	userTracks, tracks, err := database.Tracks().GetUserTracksWithAddedAt(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 2 || tracks[0].ID != "t2" || !userTracks[1].AddedAt.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("GetUserTracksWithAddedAt() = %+v, %+v, want newest first", userTracks, tracks)
+	}
+
+	// Artists
+	if err := database.Artists().UpsertBatch(ctx, []db.Artist{{ID: "a1", Name: "Sigur Rós"}, {ID: "a2", Name: "Jónsi"}}); err != nil {
+		t.Fatal(err)
+	}
+	links := []db.TrackArtist{{TrackID: "t1", ArtistID: "a2", Position: 1}, {TrackID: "t1", ArtistID: "a1", Position: 0}}
+	if err := database.Artists().ReplaceTrackArtists(ctx, links); err != nil {
+		t.Fatal(err)
+	}
+	credits, err := database.Artists().GetForTracks(ctx, []string{"t1", "t2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(credits["t1"]) != 2 || credits["t1"][0].ID != "a1" || len(credits["t2"]) != 0 {
+		t.Errorf("GetForTracks() = %+v, want t1's artists in credit order", credits)
+	}
+
+	// Last.fm and manual tags
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	err = database.Tags().UpsertBatch(ctx, []db.TrackTag{
+		{TrackID: "t1", TagName: "post-rock", TagCount: 100, Source: "track", FetchedAt: old},
+		{TrackID: "t1", TagName: "icelandic", TagCount: 80, Source: "artist", FetchedAt: old},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	without, err := database.Tags().GetTracksWithoutTags(ctx, []string{"t1", "t2"})
+	if err != nil || !reflect.DeepEqual(without, []string{"t2"}) {
+		t.Errorf("GetTracksWithoutTags() = %v, %v, want [t2]", without, err)
+	}
+	stale, err := database.Tags().GetTracksWithStaleTags(ctx, []string{"t1", "t2"}, time.Now().Add(-30*24*time.Hour))
+	if err != nil || !reflect.DeepEqual(stale, []string{"t1"}) {
+		t.Errorf("GetTracksWithStaleTags() = %v, %v, want [t1]", stale, err)
+	}
+	trackTags, err := database.Tags().GetForTrack(ctx, "t1")
+	if err != nil || len(trackTags) != 2 || trackTags[0].TagName != "post-rock" {
+		t.Errorf("GetForTrack() = %+v, %v, want most popular first", trackTags, err)
+	}
+	if err := database.Tags().AddUserTag(ctx, "alice", "t1", "winter"); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Tags().AddUserTag(ctx, "alice", "t1", "winter"); err != nil {
+		t.Errorf("adding a tag twice error = %v", err)
+	}
+	if err := database.Tags().AddUserTag(ctx, "alice", "missing", "winter"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("AddUserTag(missing track) error = %v, want ErrNotFound", err)
+	}
+	userTags, err := database.Tags().GetUserTagsForTracks(ctx, "alice", []string{"t1", "t2"})
+	if err != nil || len(userTags["t1"]) != 1 {
+		t.Errorf("GetUserTagsForTracks() = %+v, %v, want one tag on t1", userTags, err)
+	}
+
+	// Plays: duplicates are skipped, and names match case-insensitively,
+	// beyond ASCII too
+	playedAt := time.Date(2024, 2, 1, 20, 0, 0, 0, time.UTC)
+	plays := []db.Play{
+		{ArtistName: "SIGUR RÓS", TrackName: "HOPPÍPOLLA", PlayedAt: playedAt, Source: "lastfm"},
+		{ArtistName: "Jónsi", TrackName: "hoppípolla", PlayedAt: playedAt.Add(time.Hour), Source: "lastfm"},
+		{ArtistName: "Someone", TrackName: "Unknown", PlayedAt: playedAt.Add(2 * time.Hour), Source: "lastfm"},
+	}
+	if n, err := database.Plays().InsertBatch(ctx, "alice", plays); err != nil || n != 3 {
+		t.Errorf("InsertBatch() = %d, %v, want 3", n, err)
+	}
+	if n, err := database.Plays().InsertBatch(ctx, "alice", plays); err != nil || n != 0 {
+		t.Errorf("InsertBatch() again = %d, %v, want 0", n, err)
+	}
+	if n, err := database.Plays().MatchTracks(ctx, "alice"); err != nil || n != 2 {
+		t.Errorf("MatchTracks() = %d, %v, want 2", n, err)
+	}
+	if n, err := database.Plays().CountMatchedForUser(ctx, "alice"); err != nil || n != 2 {
+		t.Errorf("CountMatchedForUser() = %d, %v, want 2", n, err)
+	}
+	latest, err := database.Plays().LatestPlayedAt(ctx, "alice", "lastfm")
+	if err != nil || latest == nil || !latest.Equal(playedAt.Add(2*time.Hour)) {
+		t.Errorf("LatestPlayedAt() = %v, %v", latest, err)
+	}
+	if latest, err := database.Plays().LatestPlayedAt(ctx, "alice", "spotify"); err != nil || latest != nil {
+		t.Errorf("LatestPlayedAt(spotify) = %v, %v, want nil", latest, err)
+	}
+
+	// Once unliked, tagged and played tracks stay, untouched ones go
+	if err := database.Tracks().InsertBatchIfAbsent(ctx, []db.Track{{ID: "t3", Name: "Orphan", Artist: "Nobody"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Tracks().UnlinkAllFromUser(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := database.Tracks().DeleteOrphans(ctx, time.Now().Add(time.Minute)); err != nil || n != 2 {
+		t.Errorf("DeleteOrphans() = %d, %v, want t2 and t3", n, err)
+	}
+	if _, err := database.Tracks().Get(ctx, "t1"); err != nil {
+		t.Errorf("Get(t1) error = %v, want the tagged track kept", err)
+	}
+}
+
+func TestSQLite_DeleteOrphans_Reupserted(t *testing.T) {
+	ctx := context.Background()
+	database := dbtest.NewSQLite(t)
+	orphans := []db.Track{
+		{ID: "t1", Name: "One", Artist: "A"},
+		{ID: "t2", Name: "Two", Artist: "B"},
+		{ID: "t3", Name: "Three", Artist: "C"},
+	}
+	if err := database.Tracks().UpsertBatch(ctx, orphans); err != nil {
+		t.Fatal(err)
+	}
+
+	// A sync re-likes t1 and an import sees t2 after the cutoff, before
+	// either is linked: both keep their grace period, only t3 goes
+	cutoff := time.Now()
+	if err := database.Tracks().UpsertBatch(ctx, orphans[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Tracks().InsertBatchIfAbsent(ctx, orphans[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := database.Tracks().DeleteOrphans(ctx, cutoff); err != nil || n != 1 {
+		t.Errorf("DeleteOrphans() = %d, %v, want only t3", n, err)
+	}
+	for _, id := range []string{"t1", "t2"} {
+		if _, err := database.Tracks().Get(ctx, id); err != nil {
+			t.Errorf("Get(%s) error = %v, want the re-upserted track kept", id, err)
+		}
+	}
+
+	// A row written without updated_at ages by created_at
+	created := time.Now().Add(-48 * time.Hour).UTC()
+	_, err := database.SQLiteDB().ExecContext(ctx,
+		`INSERT INTO tracks (id, name, artist, created_at) VALUES ('t4', 'Four', 'D', $1)`, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := database.Tracks().DeleteOrphans(ctx, time.Now().Add(-24*time.Hour)); err != nil || n != 1 {
+		t.Errorf("DeleteOrphans() = %d, %v, want t4 without updated_at", n, err)
+	}
+}
+
+func TestSQLite_Eras(t *testing.T) {
+	ctx := context.Background()
+	database := dbtest.NewSQLite(t)
+	addLibrary(t, database, "alice",
+		db.Track{ID: "t1", Name: "One", Artist: "A"},
+		db.Track{ID: "t2", Name: "Two", Artist: "B"},
+	)
+	if err := database.Users().EnsureExists(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	cohesion := 0.25
+	parent := &db.Era{
+		UserID:     "alice",
+		Name:       "Winter",
+		TopTags:    []string{"post-rock", "ambient"},
+		StartDate:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:    time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		Cohesion:   &cohesion,
+		TagWeights: []db.EraTagWeight{{Name: "post-rock", Weight: 0.8}},
+	}
+	err := database.Eras().CreateWithTracks(ctx, parent, []db.EraTrack{
+		{TrackID: "t1", ReasonTags: []string{"post-rock"}},
+		{TrackID: "t2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := &db.Era{UserID: "alice", Name: "Deep winter", TopTags: []string{"ambient"},
+		StartDate: parent.StartDate, EndDate: parent.EndDate, ParentID: &parent.ID}
+	if err := database.Eras().Create(ctx, sub, []string{"t1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	eras, err := database.Eras().GetForUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eras) != 2 || eras[0].ID != parent.ID || eras[1].ParentID == nil || *eras[1].ParentID != parent.ID {
+		t.Fatalf("GetForUser() = %+v, want the parent then its sub-era", eras)
+	}
+	got := eras[0]
+	if !reflect.DeepEqual(got.TopTags, parent.TopTags) || !reflect.DeepEqual(got.TagWeights, parent.TagWeights) ||
+		got.Cohesion == nil || *got.Cohesion != cohesion || !got.StartDate.Equal(parent.StartDate) {
+		t.Errorf("GetForUser()[0] = %+v, want %+v", got, parent)
+	}
+
+	if _, err := database.Eras().GetForOwner(ctx, "bob", parent.ID); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetForOwner(bob) error = %v, want ErrNotFound", err)
+	}
+	reasons, err := database.Eras().GetTrackReasonsForOwner(ctx, "alice", parent.ID)
+	if err != nil || !reflect.DeepEqual(reasons, map[string][]string{"t1": {"post-rock"}}) {
+		t.Errorf("GetTrackReasonsForOwner() = %v, %v", reasons, err)
+	}
+	if n, err := database.Eras().GetTrackCountForOwner(ctx, "alice", parent.ID); err != nil || n != 2 {
+		t.Errorf("GetTrackCountForOwner() = %d, %v, want 2", n, err)
+	}
+	ids, err := database.Eras().GetTrackIDsForUser(ctx, "alice")
+	if err != nil || len(ids[parent.ID]) != 2 || len(ids[sub.ID]) != 1 {
+		t.Errorf("GetTrackIDsForUser() = %v, %v", ids, err)
+	}
+
+	if err := database.Eras().UpdatePlaylistIDForOwner(ctx, "bob", parent.ID, "p1"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("UpdatePlaylistIDForOwner(bob) error = %v, want ErrNotFound", err)
+	}
+	name := "My winter"
+	if err := database.Eras().UpdateNameOverrideForOwner(ctx, "alice", parent.ID, &name); err != nil {
+		t.Fatal(err)
+	}
+	if era, _ := database.Eras().GetForOwner(ctx, "alice", parent.ID); era == nil || era.NameOverride == nil || *era.NameOverride != name {
+		t.Errorf("GetForOwner() after renaming = %+v", era)
+	}
+
+	// Deleting the parent deletes its sub-era
+	if err := database.Eras().DeleteForOwner(ctx, "alice", parent.ID); err != nil {
+		t.Fatal(err)
+	}
+	if eras, _ := database.Eras().GetForUser(ctx, "alice"); len(eras) != 0 {
+		t.Errorf("GetForUser() after delete = %+v, want none", eras)
+	}
+}
+
+func TestSQLite_ReplaceForUser(t *testing.T) {
+	ctx := context.Background()
+	database := dbtest.NewSQLite(t)
+	addLibrary(t, database, "alice",
+		db.Track{ID: "t1", Name: "One", Artist: "A"},
+		db.Track{ID: "t2", Name: "Two", Artist: "B"},
+	)
+	old := &db.Era{UserID: "alice", Name: "Old"}
+	if err := database.Eras().Create(ctx, old, []string{"t1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failing era rolls back the delete and the eras created before it
+	eras := []db.Era{{UserID: "alice", Name: "Kept"}, {UserID: "alice", Name: "Broken"}}
+	tracks := [][]db.EraTrack{{{TrackID: "t2"}}, {{TrackID: "missing"}}}
+	if err := database.Eras().ReplaceForUser(ctx, "alice", eras, tracks); err == nil {
+		t.Fatal("ReplaceForUser() with a missing track succeeded, want an error")
+	}
+	got, err := database.Eras().GetForUser(ctx, "alice")
+	if err != nil || len(got) != 1 || got[0].ID != old.ID {
+		t.Fatalf("GetForUser() after a failed replace = %+v, %v, want the old era", got, err)
+	}
+
+	// A sub-era refers to its parent's ID, set before the replace
+	parent := db.Era{ID: old.ID, UserID: "alice", Name: "Old, again"}
+	eras = []db.Era{parent, {UserID: "alice", Name: "Sub", ParentID: &parent.ID}}
+	tracks = [][]db.EraTrack{{{TrackID: "t1"}, {TrackID: "t2"}}, {{TrackID: "t2"}}}
+	if err := database.Eras().ReplaceForUser(ctx, "alice", eras, tracks); err != nil {
+		t.Fatalf("ReplaceForUser() error = %v", err)
+	}
+	got, err = database.Eras().GetForUser(ctx, "alice")
+	if err != nil || len(got) != 2 || got[0].ID != old.ID || got[0].Name != "Old, again" || got[1].ID != eras[1].ID {
+		t.Errorf("GetForUser() = %+v, %v, want the replaced parent and its sub-era", got, err)
+	}
+
+	if err := database.Eras().ReplaceForUser(ctx, "nobody", nil, nil); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("ReplaceForUser(nobody) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLite_SettingsAndHousekeeping(t *testing.T) {
+	ctx := context.Background()
+	database := dbtest.NewSQLite(t)
+	if err := database.Users().EnsureExists(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := database.Settings().Get(ctx, "alice"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("Get() error = %v, want ErrNotFound", err)
+	}
+	clusters, weight, interval := 5, 0.5, 0
+	settings := &db.UserSettings{UserID: "alice", NumClusters: &clusters, TimeWeight: &weight, SyncIntervalHours: &interval}
+	if err := database.Settings().Upsert(ctx, settings); err != nil {
+		t.Fatal(err)
+	}
+	clusters = 6
+	if err := database.Settings().Upsert(ctx, settings); err != nil {
+		t.Fatal(err)
+	}
+	got, err := database.Settings().Get(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got.NumClusters != 6 || *got.TimeWeight != 0.5 || got.MaxTags != nil || *got.SyncIntervalHours != 0 {
+		t.Errorf("Get() = %+v", got)
+	}
+
+	failure := "boom"
+	start := time.Now()
+	for i, run := range []*db.HousekeepingRun{
+		{Task: "expired_sessions", Removed: 3, StartedAt: start, FinishedAt: start},
+		{Task: "orphan_tracks", Error: &failure, StartedAt: start.Add(time.Second), FinishedAt: start.Add(time.Second)},
+	} {
+		if err := database.Housekeeping().Record(ctx, run); err != nil {
+			t.Fatal(err)
+		}
+		if run.ID != int64(i+1) {
+			t.Errorf("Record() ID = %d, want %d", run.ID, i+1)
+		}
+	}
+	runs, err := database.Housekeeping().ListRecent(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].Task != "orphan_tracks" || runs[0].Error == nil || runs[1].Removed != 3 {
+		t.Errorf("ListRecent() = %+v, want newest first", runs)
+	}
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sqliteSessionRepository implements SessionRepository with SQLite.
+type sqliteSessionRepository struct {
+	db   *sql.DB
+	keys *Keyring
+}
+
+// Create inserts a new session. Its tokens are encrypted if a keyring is
+// set.
+func (r *sqliteSessionRepository) Create(ctx context.Context, session *Session) error {
+	accessToken, refreshToken, err := sealTokens(r.keys, session.AccessToken, session.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO sessions (id, user_id, access_token, refresh_token, token_expiry, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err = sqliteExec(ctx, r.db, query,
+		session.ID,
+		session.UserID,
+		accessToken,
+		refreshToken,
+		session.TokenExpiry,
+		session.CreatedAt,
+		session.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("inserting session: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves a session by ID.
+func (r *sqliteSessionRepository) Get(ctx context.Context, id string) (*Session, error) {
+	query := `
+		SELECT id, user_id, access_token, refresh_token, token_expiry, created_at, expires_at
+		FROM sessions
+		WHERE id = $1 AND expires_at > $2
+	`
+	var session Session
+	err := sqliteQueryRow(ctx, r.db, query, id, time.Now()).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.AccessToken,
+		&session.RefreshToken,
+		&session.TokenExpiry,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying session: %w", err)
+	}
+	session.AccessToken, session.RefreshToken, err = openTokens(r.keys, session.AccessToken, session.RefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("session %s: %w", id, err)
+	}
+	return &session, nil
+}
+
+// Delete removes a session by ID.
+func (r *sqliteSessionRepository) Delete(ctx context.Context, id string) error {
+	query := `DELETE FROM sessions WHERE id = $1`
+	_, err := sqliteExec(ctx, r.db, query, id)
+	if err != nil {
+		return fmt.Errorf("deleting session: %w", err)
+	}
+	return nil
+}
+
+// UpdateToken updates the OAuth tokens for a session.
+func (r *sqliteSessionRepository) UpdateToken(ctx context.Context, id, accessToken, refreshToken string, expiry time.Time) error {
+	accessToken, refreshToken, err := sealTokens(r.keys, accessToken, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE sessions
+		SET access_token = $2, refresh_token = $3, token_expiry = $4
+		WHERE id = $1
+	`
+	result, err := sqliteExec(ctx, r.db, query, id, accessToken, refreshToken, expiry)
+	if err != nil {
+		return fmt.Errorf("updating session token: %w", err)
+	}
+	if rowsAffected(result) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteExpired removes all expired sessions.
+func (r *sqliteSessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `DELETE FROM sessions WHERE expires_at <= $1`
+	result, err := sqliteExec(ctx, r.db, query, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("deleting expired sessions: %w", err)
+	}
+	return rowsAffected(result), nil
+}
+
+// DeleteForUser removes all sessions for a user.
+func (r *sqliteSessionRepository) DeleteForUser(ctx context.Context, userID string) error {
+	query := `DELETE FROM sessions WHERE user_id = $1`
+	_, err := sqliteExec(ctx, r.db, query, userID)
+	if err != nil {
+		return fmt.Errorf("deleting user sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sqliteSettingsRepository implements SettingsRepository with SQLite.
+type sqliteSettingsRepository struct {
+	db *sql.DB
+}
+
+// Get retrieves a user's settings.
+// Returns ErrNotFound if the user has never saved settings.
+func (r *sqliteSettingsRepository) Get(ctx context.Context, userID string) (*UserSettings, error) {
+	query := `
+		SELECT user_id, num_clusters, min_cluster_size, max_tags, manual_tag_weight,
+			basis, time_weight, name_template, sub_era_depth, sync_interval_hours,
+			created_at, updated_at
+		FROM user_settings
+		WHERE user_id = $1
+	`
+	var settings UserSettings
+	err := sqliteQueryRow(ctx, r.db, query, userID).Scan(
+		&settings.UserID,
+		&settings.NumClusters,
+		&settings.MinClusterSize,
+		&settings.MaxTags,
+		&settings.ManualTagWeight,
+		&settings.Basis,
+		&settings.TimeWeight,
+		&settings.NameTemplate,
+		&settings.SubEraDepth,
+		&settings.SyncIntervalHours,
+		&settings.CreatedAt,
+		&settings.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying user settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// Upsert creates or replaces a user's settings.
+func (r *sqliteSettingsRepository) Upsert(ctx context.Context, settings *UserSettings) error {
+	query := `
+		INSERT INTO user_settings (user_id, num_clusters, min_cluster_size, max_tags,
+			manual_tag_weight, basis, time_weight, name_template, sub_era_depth, sync_interval_hours,
+			created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $11)
+		ON CONFLICT (user_id) DO UPDATE SET
+			num_clusters = excluded.num_clusters,
+			min_cluster_size = excluded.min_cluster_size,
+			max_tags = excluded.max_tags,
+			manual_tag_weight = excluded.manual_tag_weight,
+			basis = excluded.basis,
+			time_weight = excluded.time_weight,
+			name_template = excluded.name_template,
+			sub_era_depth = excluded.sub_era_depth,
+			sync_interval_hours = excluded.sync_interval_hours,
+			updated_at = excluded.updated_at
+		RETURNING created_at, updated_at
+	`
+	err := sqliteQueryRow(ctx, r.db, query,
+		settings.UserID,
+		settings.NumClusters,
+		settings.MinClusterSize,
+		settings.MaxTags,
+		settings.ManualTagWeight,
+		settings.Basis,
+		settings.TimeWeight,
+		settings.NameTemplate,
+		settings.SubEraDepth,
+		settings.SyncIntervalHours,
+		time.Now(),
+	).Scan(&settings.CreatedAt, &settings.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("upserting user settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// sqliteTagRepository implements TagRepository with SQLite.
+type sqliteTagRepository struct {
+	db *sql.DB
+}
+
+// UpsertBatch inserts or updates multiple tags in one transaction.
+func (r *sqliteTagRepository) UpsertBatch(ctx context.Context, tags []TrackTag) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO track_tags (track_id, tag_name, tag_count, source, fetched_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (track_id, tag_name) DO UPDATE SET
+			tag_count = excluded.tag_count,
+			source = excluded.source,
+			fetched_at = excluded.fetched_at
+	`
+	err := sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, t := range tags {
+			if _, err := sqliteExec(ctx, tx, query, t.TrackID, t.TagName, t.TagCount, t.Source, t.FetchedAt); err != nil {
+				return fmt.Errorf("track %s: %w", t.TrackID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("batch upserting tags: %w", err)
+	}
+	return nil
+}
+
+// GetForTrack retrieves all tags for a track.
+func (r *sqliteTagRepository) GetForTrack(ctx context.Context, trackID string) ([]TrackTag, error) {
+	tags, err := r.GetForTracks(ctx, []string{trackID})
+	if err != nil {
+		return nil, err
+	}
+	return tags[trackID], nil
+}
+
+// GetForTracks retrieves tags for multiple tracks, returning a map of track ID to tags.
+func (r *sqliteTagRepository) GetForTracks(ctx context.Context, trackIDs []string) (map[string][]TrackTag, error) {
+	if len(trackIDs) == 0 {
+		return make(map[string][]TrackTag), nil
+	}
+
+	query := `
+		SELECT track_id, tag_name, tag_count, source, fetched_at
+		FROM track_tags
+		WHERE track_id IN (SELECT value FROM json_each($1))
+		ORDER BY track_id, tag_count DESC
+	`
+	rows, err := sqliteQuery(ctx, r.db, query, textArray(trackIDs))
+	if err != nil {
+		return nil, fmt.Errorf("querying track tags: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]TrackTag)
+	for rows.Next() {
+		var tag TrackTag
+		if err := rows.Scan(
+			&tag.TrackID,
+			&tag.TagName,
+			&tag.TagCount,
+			&tag.Source,
+			&tag.FetchedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scanning tag: %w", err)
+		}
+		result[tag.TrackID] = append(result[tag.TrackID], tag)
+	}
+	return result, rows.Err()
+}
+
+// GetStale returns track IDs with tags older than the given time.
+func (r *sqliteTagRepository) GetStale(ctx context.Context, olderThan time.Time, limit int) ([]string, error) {
+	query := `
+		SELECT DISTINCT track_id
+		FROM track_tags
+		WHERE fetched_at < $1
+		LIMIT $2
+	`
+	ids, err := r.queryIDs(ctx, query, olderThan, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying stale tags: %w", err)
+	}
+	return ids, nil
+}
+
+// GetTracksWithoutTags returns track IDs that have no tags.
+func (r *sqliteTagRepository) GetTracksWithoutTags(ctx context.Context, trackIDs []string) ([]string, error) {
+	if len(trackIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT value
+		FROM json_each($1)
+		WHERE value NOT IN (SELECT DISTINCT track_id FROM track_tags)
+	`
+	ids, err := r.queryIDs(ctx, query, textArray(trackIDs))
+	if err != nil {
+		return nil, fmt.Errorf("querying tracks without tags: %w", err)
+	}
+	return ids, nil
+}
+
+// GetTracksWithStaleTags returns the track IDs among trackIDs whose newest
+// tag was fetched before olderThan.
+func (r *sqliteTagRepository) GetTracksWithStaleTags(ctx context.Context, trackIDs []string, olderThan time.Time) ([]string, error) {
+	if len(trackIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT track_id
+		FROM track_tags
+		WHERE track_id IN (SELECT value FROM json_each($1))
+		GROUP BY track_id
+		HAVING MAX(fetched_at) < $2
+	`
+	ids, err := r.queryIDs(ctx, query, textArray(trackIDs), olderThan)
+	if err != nil {
+		return nil, fmt.Errorf("querying tracks with stale tags: %w", err)
+	}
+	return ids, nil
+}
+
+// queryIDs runs a query selecting one column of track IDs.
+func (r *sqliteTagRepository) queryIDs(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := sqliteQuery(ctx, r.db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning track ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeleteForTrack removes all tags for a track.
+func (r *sqliteTagRepository) DeleteForTrack(ctx context.Context, trackID string) error {
+	query := `DELETE FROM track_tags WHERE track_id = $1`
+	_, err := sqliteExec(ctx, r.db, query, trackID)
+	if err != nil {
+		return fmt.Errorf("deleting track tags: %w", err)
+	}
+	return nil
+}
+
+// AddUserTag assigns a manual tag to a track in the user's library.
+// Returns ErrNotFound if the track is not in the user's library.
+func (r *sqliteTagRepository) AddUserTag(ctx context.Context, userID, trackID, tagName string) error {
+	query := `
+		INSERT INTO user_track_tags (user_id, track_id, tag_name, created_at)
+		SELECT $1, $2, $3, $4
+		WHERE EXISTS (SELECT 1 FROM user_tracks WHERE user_id = $1 AND track_id = $2)
+		ON CONFLICT (user_id, track_id, tag_name) DO NOTHING
+	`
+	result, err := sqliteExec(ctx, r.db, query, userID, trackID, tagName, time.Now())
+	if err != nil {
+		return fmt.Errorf("inserting user tag: %w", err)
+	}
+	if rowsAffected(result) == 0 {
+		// Either the tag already exists or the track isn't in the library
+		var exists bool
+		err := sqliteQueryRow(ctx, r.db,
+			`SELECT EXISTS (SELECT 1 FROM user_tracks WHERE user_id = $1 AND track_id = $2)`,
+			userID, trackID,
+		).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("checking user track: %w", err)
+		}
+		if !exists {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+// RemoveUserTag removes a manual tag from a track.
+func (r *sqliteTagRepository) RemoveUserTag(ctx context.Context, userID, trackID, tagName string) error {
+	query := `DELETE FROM user_track_tags WHERE user_id = $1 AND track_id = $2 AND tag_name = $3`
+	_, err := sqliteExec(ctx, r.db, query, userID, trackID, tagName)
+	if err != nil {
+		return fmt.Errorf("deleting user tag: %w", err)
+	}
+	return nil
+}
+
+// GetUserTagsForTracks retrieves a user's manual tags for multiple tracks,
+// returning a map of track ID to tags.
+func (r *sqliteTagRepository) GetUserTagsForTracks(ctx context.Context, userID string, trackIDs []string) (map[string][]UserTrackTag, error) {
+	if len(trackIDs) == 0 {
+		return make(map[string][]UserTrackTag), nil
+	}
+
+	query := `
+		SELECT user_id, track_id, tag_name, created_at
+		FROM user_track_tags
+		WHERE user_id = $1 AND track_id IN (SELECT value FROM json_each($2))
+		ORDER BY track_id, created_at
+	`
+	rows, err := sqliteQuery(ctx, r.db, query, userID, textArray(trackIDs))
+	if err != nil {
+		return nil, fmt.Errorf("querying user tags: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]UserTrackTag)
+	for rows.Next() {
+		var tag UserTrackTag
+		if err := rows.Scan(
+			&tag.UserID,
+			&tag.TrackID,
+			&tag.TagName,
+			&tag.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scanning user tag: %w", err)
+		}
+		result[tag.TrackID] = append(result[tag.TrackID], tag)
+	}
+	return result, rows.Err()
+}
+
+// InsertUserTagsBatch restores manual tags with their original creation
+// times. Tags on tracks outside the user's library and tags that already
+// exist are skipped.
+func (r *sqliteTagRepository) InsertUserTagsBatch(ctx context.Context, userID string, tags []UserTrackTag) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO user_track_tags (user_id, track_id, tag_name, created_at)
+		SELECT $1, $2, $3, $4
+		WHERE EXISTS (SELECT 1 FROM user_tracks WHERE user_id = $1 AND track_id = $2)
+		ON CONFLICT (user_id, track_id, tag_name) DO NOTHING
+	`
+	err := sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, t := range tags {
+			if _, err := sqliteExec(ctx, tx, query, userID, t.TrackID, t.TagName, t.CreatedAt); err != nil {
+				return fmt.Errorf("track %s: %w", t.TrackID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("batch inserting user tags: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNew_SQLiteURL(t *testing.T) {
+	if _, err := New(context.Background(), "sqlite://"); err == nil {
+		t.Error("New(sqlite://) succeeded, want an error for the missing file")
+	}
+
+	database, err := New(context.Background(), "sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if database.Driver() != DriverSQLite {
+		t.Errorf("Driver() = %q, want %q", database.Driver(), DriverSQLite)
+	}
+}
+
+func TestTextArray(t *testing.T) {
+	value, err := textArray(nil).Value()
+	if err != nil || value != "[]" {
+		t.Errorf("nil Value() = %v, %v, want []", value, err)
+	}
+
+	var a textArray
+	if err := a.Scan(`["rock","indie"]`); err != nil || !reflect.DeepEqual(a, textArray{"rock", "indie"}) {
+		t.Errorf("Scan() = %v, %v", a, err)
+	}
+	if err := a.Scan(nil); err != nil || a != nil {
+		t.Errorf("Scan(nil) = %v, %v, want nil", a, err)
+	}
+	if err := a.Scan(42); err == nil || !strings.Contains(err.Error(), "int") {
+		t.Errorf("Scan(42) error = %v, want an unsupported type error", err)
+	}
+}
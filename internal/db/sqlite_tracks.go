@@ -0,0 +1,252 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sqliteTrackRepository implements TrackRepository with SQLite.
+type sqliteTrackRepository struct {
+	db *sql.DB
+}
+
+// Upsert creates or updates a track.
+func (r *sqliteTrackRepository) Upsert(ctx context.Context, track *Track) error {
+	query := `
+		INSERT INTO tracks (id, name, artist, album, album_id, duration_ms, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			artist = excluded.artist,
+			album = excluded.album,
+			album_id = excluded.album_id,
+			duration_ms = excluded.duration_ms,
+			updated_at = excluded.updated_at
+		RETURNING created_at
+	`
+	err := sqliteQueryRow(ctx, r.db, query,
+		track.ID,
+		track.Name,
+		track.Artist,
+		track.Album,
+		track.AlbumID,
+		track.DurationMs,
+		time.Now(),
+	).Scan(&track.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("upserting track: %w", err)
+	}
+	return nil
+}
+
+// UpsertBatch inserts or updates multiple tracks in one transaction.
+func (r *sqliteTrackRepository) UpsertBatch(ctx context.Context, tracks []Track) error {
+	query := `
+		INSERT INTO tracks (id, name, artist, album, album_id, duration_ms, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			artist = excluded.artist,
+			album = excluded.album,
+			album_id = excluded.album_id,
+			duration_ms = excluded.duration_ms,
+			updated_at = excluded.updated_at
+	`
+	if err := r.insertBatch(ctx, query, tracks); err != nil {
+		return fmt.Errorf("batch upserting tracks: %w", err)
+	}
+	return nil
+}
+
+// InsertBatchIfAbsent inserts tracks that don't exist yet, leaving the
+// metadata of existing rows untouched. Used for sources with less metadata
+// than the Spotify API.
+func (r *sqliteTrackRepository) InsertBatchIfAbsent(ctx context.Context, tracks []Track) error {
+	query := `
+		INSERT INTO tracks (id, name, artist, album, album_id, duration_ms, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (id) DO UPDATE SET updated_at = excluded.updated_at
+	`
+	if err := r.insertBatch(ctx, query, tracks); err != nil {
+		return fmt.Errorf("batch inserting tracks: %w", err)
+	}
+	return nil
+}
+
+// insertBatch runs an insert query once per track in a transaction.
+func (r *sqliteTrackRepository) insertBatch(ctx context.Context, query string, tracks []Track) error {
+	if len(tracks) == 0 {
+		return nil
+	}
+	now := time.Now()
+	return sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, t := range tracks {
+			_, err := sqliteExec(ctx, tx, query, t.ID, t.Name, t.Artist, t.Album, t.AlbumID, t.DurationMs, now)
+			if err != nil {
+				return fmt.Errorf("track %s: %w", t.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Get retrieves a track by ID.
+func (r *sqliteTrackRepository) Get(ctx context.Context, id string) (*Track, error) {
+	query := `
+		SELECT id, name, artist, album, album_id, duration_ms, created_at
+		FROM tracks
+		WHERE id = $1
+	`
+	var track Track
+	err := sqliteQueryRow(ctx, r.db, query, id).Scan(
+		&track.ID,
+		&track.Name,
+		&track.Artist,
+		&track.Album,
+		&track.AlbumID,
+		&track.DurationMs,
+		&track.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying track: %w", err)
+	}
+	return &track, nil
+}
+
+// GetUserTracks retrieves all liked tracks for a user, ordered by added_at desc.
+func (r *sqliteTrackRepository) GetUserTracks(ctx context.Context, userID string) ([]Track, error) {
+	_, tracks, err := r.GetUserTracksWithAddedAt(ctx, userID)
+	return tracks, err
+}
+
+// GetUserTracksWithAddedAt retrieves all liked tracks for a user with their added_at timestamps.
+func (r *sqliteTrackRepository) GetUserTracksWithAddedAt(ctx context.Context, userID string) ([]UserTrack, []Track, error) {
+	query := `
+		SELECT t.id, t.name, t.artist, t.album, t.album_id, t.duration_ms, t.created_at, ut.added_at
+		FROM tracks t
+		JOIN user_tracks ut ON t.id = ut.track_id
+		WHERE ut.user_id = $1
+		ORDER BY ut.added_at DESC
+	`
+	rows, err := sqliteQuery(ctx, r.db, query, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("querying user tracks: %w", err)
+	}
+	defer rows.Close()
+
+	var userTracks []UserTrack
+	var tracks []Track
+	for rows.Next() {
+		var track Track
+		var addedAt time.Time
+		if err := rows.Scan(
+			&track.ID,
+			&track.Name,
+			&track.Artist,
+			&track.Album,
+			&track.AlbumID,
+			&track.DurationMs,
+			&track.CreatedAt,
+			&addedAt,
+		); err != nil {
+			return nil, nil, fmt.Errorf("scanning track: %w", err)
+		}
+		tracks = append(tracks, track)
+		userTracks = append(userTracks, UserTrack{
+			UserID:  userID,
+			TrackID: track.ID,
+			AddedAt: addedAt,
+		})
+	}
+	return userTracks, tracks, rows.Err()
+}
+
+// LinkToUser links a track to a user's library.
+func (r *sqliteTrackRepository) LinkToUser(ctx context.Context, userID, trackID string, addedAt time.Time) error {
+	err := r.LinkBatchToUser(ctx, userID, []UserTrack{{UserID: userID, TrackID: trackID, AddedAt: addedAt}})
+	if err != nil {
+		return fmt.Errorf("linking track to user: %w", err)
+	}
+	return nil
+}
+
+// LinkBatchToUser links multiple tracks to a user's library in one
+// transaction.
+func (r *sqliteTrackRepository) LinkBatchToUser(ctx context.Context, userID string, tracks []UserTrack) error {
+	query := `
+		INSERT INTO user_tracks (user_id, track_id, added_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, track_id) DO UPDATE SET added_at = excluded.added_at
+	`
+	if err := r.linkBatch(ctx, query, userID, tracks); err != nil {
+		return fmt.Errorf("batch linking tracks to user: %w", err)
+	}
+	return nil
+}
+
+// LinkBatchToUserIfAbsent links tracks to a user's library without changing
+// the added_at of tracks that are already linked.
+func (r *sqliteTrackRepository) LinkBatchToUserIfAbsent(ctx context.Context, userID string, tracks []UserTrack) error {
+	query := `
+		INSERT INTO user_tracks (user_id, track_id, added_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, track_id) DO NOTHING
+	`
+	if err := r.linkBatch(ctx, query, userID, tracks); err != nil {
+		return fmt.Errorf("batch linking tracks to user: %w", err)
+	}
+	return nil
+}
+
+// linkBatch runs a link query once per track in a transaction.
+func (r *sqliteTrackRepository) linkBatch(ctx context.Context, query, userID string, tracks []UserTrack) error {
+	if len(tracks) == 0 {
+		return nil
+	}
+	return sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, t := range tracks {
+			if _, err := sqliteExec(ctx, tx, query, userID, t.TrackID, t.AddedAt); err != nil {
+				return fmt.Errorf("track %s: %w", t.TrackID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// UnlinkAllFromUser removes all tracks from a user's library.
+func (r *sqliteTrackRepository) UnlinkAllFromUser(ctx context.Context, userID string) error {
+	query := `DELETE FROM user_tracks WHERE user_id = $1`
+	_, err := sqliteExec(ctx, r.db, query, userID)
+	if err != nil {
+		return fmt.Errorf("unlinking all tracks from user: %w", err)
+	}
+	return nil
+}
+
+// DeleteOrphanTags removes the Last.fm tags of orphaned tracks last
+// upserted before cutoff. Returns the number of tags removed.
+func (r *sqliteTrackRepository) DeleteOrphanTags(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `DELETE FROM track_tags WHERE track_id IN (` + orphanTracks + `)`
+	result, err := sqliteExec(ctx, r.db, query, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("deleting orphan track tags: %w", err)
+	}
+	return rowsAffected(result), nil
+}
+
+// DeleteOrphans removes orphaned tracks last upserted before cutoff, with their
+// artist credits. Returns the number of tracks removed.
+func (r *sqliteTrackRepository) DeleteOrphans(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `DELETE FROM tracks WHERE id IN (` + orphanTracks + `)`
+	result, err := sqliteExec(ctx, r.db, query, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("deleting orphan tracks: %w", err)
+	}
+	return rowsAffected(result), nil
+}
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sqliteUserRepository implements UserRepository with SQLite.
+type sqliteUserRepository struct {
+	db   *sql.DB
+	keys *Keyring
+}
+
+// Create inserts a new user.
+func (r *sqliteUserRepository) Create(ctx context.Context, user *User) error {
+	query := `
+		INSERT INTO users (id, display_name, email, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	now := time.Now()
+	_, err := sqliteExec(ctx, r.db, query, user.ID, user.DisplayName, user.Email, now, now)
+	if err != nil {
+		return fmt.Errorf("inserting user: %w", err)
+	}
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	return nil
+}
+
+// Get retrieves a user by ID.
+func (r *sqliteUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	query := `
+		SELECT id, display_name, email, created_at, updated_at, last_sync_at
+		FROM users
+		WHERE id = $1
+	`
+	var user User
+	err := sqliteQueryRow(ctx, r.db, query, id).Scan(
+		&user.ID,
+		&user.DisplayName,
+		&user.Email,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.LastSyncAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying user: %w", err)
+	}
+	return &user, nil
+}
+
+// Upsert creates or updates a user.
+func (r *sqliteUserRepository) Upsert(ctx context.Context, user *User) error {
+	query := `
+		INSERT INTO users (id, display_name, email, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (id) DO UPDATE SET
+			display_name = excluded.display_name,
+			email = excluded.email,
+			updated_at = excluded.updated_at
+		RETURNING created_at, updated_at
+	`
+	err := sqliteQueryRow(ctx, r.db, query, user.ID, user.DisplayName, user.Email, time.Now()).
+		Scan(&user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("upserting user: %w", err)
+	}
+	return nil
+}
+
+// UpdateLastSync updates the last sync timestamp for a user.
+func (r *sqliteUserRepository) UpdateLastSync(ctx context.Context, id string, syncTime time.Time) error {
+	query := `
+		UPDATE users
+		SET last_sync_at = $2, updated_at = $3
+		WHERE id = $1
+	`
+	result, err := sqliteExec(ctx, r.db, query, id, syncTime, time.Now())
+	if err != nil {
+		return fmt.Errorf("updating last sync: %w", err)
+	}
+	if rowsAffected(result) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// EnsureExists creates a bare user record if one doesn't exist yet, using the
+// ID as the display name until the user logs in. Existing users are left untouched.
+func (r *sqliteUserRepository) EnsureExists(ctx context.Context, id string) error {
+	query := `
+		INSERT INTO users (id, display_name, email, created_at, updated_at)
+		VALUES ($1, $1, '', $2, $2)
+		ON CONFLICT (id) DO NOTHING
+	`
+	_, err := sqliteExec(ctx, r.db, query, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("ensuring user: %w", err)
+	}
+	return nil
+}
+
+// SaveToken stores a Spotify OAuth token for the user, encrypted if a
+// keyring is set.
+func (r *sqliteUserRepository) SaveToken(ctx context.Context, id, accessToken, refreshToken string, expiry time.Time) error {
+	accessToken, refreshToken, err := sealTokens(r.keys, accessToken, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE users
+		SET access_token = $2, refresh_token = $3, token_expiry = $4, updated_at = $5
+		WHERE id = $1
+	`
+	result, err := sqliteExec(ctx, r.db, query, id, accessToken, refreshToken, expiry, time.Now())
+	if err != nil {
+		return fmt.Errorf("saving user token: %w", err)
+	}
+	if rowsAffected(result) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetToken retrieves the user's stored Spotify OAuth token.
+// Returns ErrNotFound if the user has no stored token.
+func (r *sqliteUserRepository) GetToken(ctx context.Context, id string) (*UserToken, error) {
+	query := `
+		SELECT access_token, refresh_token, token_expiry
+		FROM users
+		WHERE id = $1 AND refresh_token IS NOT NULL
+	`
+	var token UserToken
+	var accessToken *string
+	var expiry *time.Time
+	err := sqliteQueryRow(ctx, r.db, query, id).Scan(&accessToken, &token.RefreshToken, &expiry)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying user token: %w", err)
+	}
+	if accessToken != nil {
+		token.AccessToken = *accessToken
+	}
+	if expiry != nil {
+		token.TokenExpiry = *expiry
+	}
+	token.AccessToken, token.RefreshToken, err = openTokens(r.keys, token.AccessToken, token.RefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("user %s token: %w", id, err)
+	}
+	return &token, nil
+}
+
+// ClearToken removes the user's stored Spotify OAuth token. The user isn't
+// scheduled again until a login saves a new one.
+func (r *sqliteUserRepository) ClearToken(ctx context.Context, id string) error {
+	query := `
+		UPDATE users
+		SET access_token = NULL, refresh_token = NULL, token_expiry = NULL, updated_at = $2
+		WHERE id = $1
+	`
+	result, err := sqliteExec(ctx, r.db, query, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("clearing user token: %w", err)
+	}
+	if rowsAffected(result) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordSyncFailure records when a scheduled sync of the user failed.
+func (r *sqliteUserRepository) RecordSyncFailure(ctx context.Context, id string, at time.Time) error {
+	query := `
+		UPDATE users
+		SET sync_failed_at = $2, updated_at = $3
+		WHERE id = $1
+	`
+	result, err := sqliteExec(ctx, r.db, query, id, at, time.Now())
+	if err != nil {
+		return fmt.Errorf("recording sync failure: %w", err)
+	}
+	if rowsAffected(result) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListScheduled returns every user with a stored Spotify refresh token,
+// with their last sync and failure times and sync schedule, least recently
+// synced first.
+func (r *sqliteUserRepository) ListScheduled(ctx context.Context) ([]ScheduledUser, error) {
+	query := `
+		SELECT u.id, u.last_sync_at, u.sync_failed_at, s.sync_interval_hours
+		FROM users u
+		LEFT JOIN user_settings s ON s.user_id = u.id
+		WHERE u.refresh_token IS NOT NULL
+		ORDER BY u.last_sync_at ASC NULLS FIRST, u.id
+	`
+	rows, err := sqliteQuery(ctx, r.db, query)
+	if err != nil {
+		return nil, fmt.Errorf("querying scheduled users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []ScheduledUser
+	for rows.Next() {
+		var user ScheduledUser
+		if err := rows.Scan(&user.ID, &user.LastSyncAt, &user.SyncFailedAt, &user.SyncIntervalHours); err != nil {
+			return nil, fmt.Errorf("scanning scheduled user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating scheduled users: %w", err)
+	}
+	return users, nil
+}
@@ -8,13 +8,13 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// TagRepository handles track tag database operations.
-type TagRepository struct {
+// pgTagRepository implements TagRepository with PostgreSQL.
+type pgTagRepository struct {
 	pool *pgxpool.Pool
 }
 
 // UpsertBatch inserts or updates multiple tags efficiently.
-func (r *TagRepository) UpsertBatch(ctx context.Context, tags []TrackTag) error {
+func (r *pgTagRepository) UpsertBatch(ctx context.Context, tags []TrackTag) error {
 	if len(tags) == 0 {
 		return nil
 	}
@@ -50,7 +50,7 @@ func (r *TagRepository) UpsertBatch(ctx context.Context, tags []TrackTag) error
 }
 
 // GetForTrack retrieves all tags for a track.
-func (r *TagRepository) GetForTrack(ctx context.Context, trackID string) ([]TrackTag, error) {
+func (r *pgTagRepository) GetForTrack(ctx context.Context, trackID string) ([]TrackTag, error) {
 	query := `
 		SELECT track_id, tag_name, tag_count, source, fetched_at
 		FROM track_tags
@@ -81,7 +81,7 @@ func (r *TagRepository) GetForTrack(ctx context.Context, trackID string) ([]Trac
 }
 
 // GetForTracks retrieves tags for multiple tracks, returning a map of track ID to tags.
-func (r *TagRepository) GetForTracks(ctx context.Context, trackIDs []string) (map[string][]TrackTag, error) {
+func (r *pgTagRepository) GetForTracks(ctx context.Context, trackIDs []string) (map[string][]TrackTag, error) {
 	if len(trackIDs) == 0 {
 		return make(map[string][]TrackTag), nil
 	}
@@ -116,7 +116,7 @@ func (r *TagRepository) GetForTracks(ctx context.Context, trackIDs []string) (ma
 }
 
 // GetStale returns track IDs with tags older than the given time.
-func (r *TagRepository) GetStale(ctx context.Context, olderThan time.Time, limit int) ([]string, error) {
+func (r *pgTagRepository) GetStale(ctx context.Context, olderThan time.Time, limit int) ([]string, error) {
 	query := `
 		SELECT DISTINCT track_id
 		FROM track_tags
@@ -141,7 +141,7 @@ func (r *TagRepository) GetStale(ctx context.Context, olderThan time.Time, limit
 }
 
 // GetTracksWithoutTags returns track IDs that have no tags.
-func (r *TagRepository) GetTracksWithoutTags(ctx context.Context, trackIDs []string) ([]string, error) {
+func (r *pgTagRepository) GetTracksWithoutTags(ctx context.Context, trackIDs []string) ([]string, error) {
 	if len(trackIDs) == 0 {
 		return nil, nil
 	}
@@ -168,8 +168,39 @@ func (r *TagRepository) GetTracksWithoutTags(ctx context.Context, trackIDs []str
 	return result, rows.Err()
 }
 
+// GetTracksWithStaleTags returns the track IDs among trackIDs whose newest
+// tag was fetched before olderThan.
+func (r *pgTagRepository) GetTracksWithStaleTags(ctx context.Context, trackIDs []string, olderThan time.Time) ([]string, error) {
+	if len(trackIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT track_id
+		FROM track_tags
+		WHERE track_id = ANY($1)
+		GROUP BY track_id
+		HAVING MAX(fetched_at) < $2
+	`
+	rows, err := r.pool.Query(ctx, query, trackIDs, olderThan)
+	if err != nil {
+		return nil, fmt.Errorf("querying tracks with stale tags: %w", err)
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning track ID: %w", err)
+		}
+		result = append(result, id)
+	}
+	return result, rows.Err()
+}
+
 // DeleteForTrack removes all tags for a track.
-func (r *TagRepository) DeleteForTrack(ctx context.Context, trackID string) error {
+func (r *pgTagRepository) DeleteForTrack(ctx context.Context, trackID string) error {
 	query := `DELETE FROM track_tags WHERE track_id = $1`
 	_, err := r.pool.Exec(ctx, query, trackID)
 	if err != nil {
@@ -177,3 +208,111 @@ func (r *TagRepository) DeleteForTrack(ctx context.Context, trackID string) erro
 	}
 	return nil
 }
+
+// AddUserTag assigns a manual tag to a track in the user's library.
+// Returns ErrNotFound if the track is not in the user's library.
+func (r *pgTagRepository) AddUserTag(ctx context.Context, userID, trackID, tagName string) error {
+	query := `
+		INSERT INTO user_track_tags (user_id, track_id, tag_name, created_at)
+		SELECT $1, $2, $3, NOW()
+		WHERE EXISTS (SELECT 1 FROM user_tracks WHERE user_id = $1 AND track_id = $2)
+		ON CONFLICT (user_id, track_id, tag_name) DO NOTHING
+	`
+	result, err := r.pool.Exec(ctx, query, userID, trackID, tagName)
+	if err != nil {
+		return fmt.Errorf("inserting user tag: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		// Either the tag already exists or the track isn't in the library
+		var exists bool
+		err := r.pool.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM user_tracks WHERE user_id = $1 AND track_id = $2)`,
+			userID, trackID,
+		).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("checking user track: %w", err)
+		}
+		if !exists {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+// RemoveUserTag removes a manual tag from a track.
+func (r *pgTagRepository) RemoveUserTag(ctx context.Context, userID, trackID, tagName string) error {
+	query := `DELETE FROM user_track_tags WHERE user_id = $1 AND track_id = $2 AND tag_name = $3`
+	_, err := r.pool.Exec(ctx, query, userID, trackID, tagName)
+	if err != nil {
+		return fmt.Errorf("deleting user tag: %w", err)
+	}
+	return nil
+}
+
+// GetUserTagsForTracks retrieves a user's manual tags for multiple tracks,
+// returning a map of track ID to tags.
+func (r *pgTagRepository) GetUserTagsForTracks(ctx context.Context, userID string, trackIDs []string) (map[string][]UserTrackTag, error) {
+	if len(trackIDs) == 0 {
+		return make(map[string][]UserTrackTag), nil
+	}
+
+	query := `
+		SELECT user_id, track_id, tag_name, created_at
+		FROM user_track_tags
+		WHERE user_id = $1 AND track_id = ANY($2)
+		ORDER BY track_id, created_at
+	`
+	rows, err := r.pool.Query(ctx, query, userID, trackIDs)
+	if err != nil {
+		return nil, fmt.Errorf("querying user tags: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]UserTrackTag)
+	for rows.Next() {
+		var tag UserTrackTag
+		if err := rows.Scan(
+			&tag.UserID,
+			&tag.TrackID,
+			&tag.TagName,
+			&tag.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scanning user tag: %w", err)
+		}
+		result[tag.TrackID] = append(result[tag.TrackID], tag)
+	}
+	return result, rows.Err()
+}
+
+// InsertUserTagsBatch restores manual tags with their original creation
+// times. Tags on tracks outside the user's library and tags that already
+// exist are skipped.
+func (r *pgTagRepository) InsertUserTagsBatch(ctx context.Context, userID string, tags []UserTrackTag) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO user_track_tags (user_id, track_id, tag_name, created_at)
+		SELECT $1, t.track_id, t.tag_name, t.created_at
+		FROM unnest($2::text[], $3::text[], $4::timestamptz[]) AS t(track_id, tag_name, created_at)
+		JOIN user_tracks ut ON ut.user_id = $1 AND ut.track_id = t.track_id
+		ON CONFLICT (user_id, track_id, tag_name) DO NOTHING
+	`
+
+	trackIDs := make([]string, len(tags))
+	tagNames := make([]string, len(tags))
+	createdAts := make([]time.Time, len(tags))
+
+	for i, t := range tags {
+		trackIDs[i] = t.TrackID
+		tagNames[i] = t.TagName
+		createdAts[i] = t.CreatedAt
+	}
+
+	_, err := r.pool.Exec(ctx, query, userID, trackIDs, tagNames, createdAts)
+	if err != nil {
+		return fmt.Errorf("batch inserting user tags: %w", err)
+	}
+	return nil
+}
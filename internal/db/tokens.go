@@ -0,0 +1,174 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// sealTokens encrypts an access and refresh token pair.
+func sealTokens(keys *Keyring, accessToken, refreshToken string) (string, string, error) {
+	access, err := keys.Seal(accessToken)
+	if err != nil {
+		return "", "", fmt.Errorf("encrypting access token: %w", err)
+	}
+	refresh, err := keys.Seal(refreshToken)
+	if err != nil {
+		return "", "", fmt.Errorf("encrypting refresh token: %w", err)
+	}
+	return access, refresh, nil
+}
+
+// openTokens decrypts an access and refresh token pair.
+func openTokens(keys *Keyring, accessToken, refreshToken string) (string, string, error) {
+	access, err := keys.Open(accessToken)
+	if err != nil {
+		return "", "", fmt.Errorf("decrypting access token: %w", err)
+	}
+	refresh, err := keys.Open(refreshToken)
+	if err != nil {
+		return "", "", fmt.Errorf("decrypting refresh token: %w", err)
+	}
+	return access, refresh, nil
+}
+
+// TokenRotation counts the rows RotateTokens re-encrypted.
+type TokenRotation struct {
+	Sessions int `json:"sessions"`
+	Users    int `json:"users"`
+}
+
+// tokenRow is a row's ID with its stored, possibly encrypted, tokens.
+type tokenRow struct {
+	id                        string
+	accessToken, refreshToken string
+}
+
+// RotateTokens encrypts every stored session and user token that is still
+// plaintext or sealed with an older key, using the keyring's primary key.
+// It encrypts existing rows when encryption is first enabled and finishes
+// a key rotation, after which the old key can be dropped from the ring.
+// Rows changed concurrently are skipped, since their new tokens were
+// written with the primary key.
+func (db *DB) RotateTokens(ctx context.Context) (*TokenRotation, error) {
+	if db.keys == nil {
+		return nil, ErrNoKeyring
+	}
+
+	// Comparing prefixes with substr() rather than LIKE, since key IDs may
+	// contain LIKE wildcards; unlike left() it exists in SQLite too. Empty
+	// tokens are never encrypted
+	prefix := encryptedPrefix + db.keys.PrimaryKeyID() + ":"
+	sessions, err := db.rotateTable(ctx, prefix, `
+		SELECT id, access_token, refresh_token
+		FROM sessions
+		WHERE (access_token <> '' AND substr(access_token, 1, length($1)) <> $1)
+			OR (refresh_token <> '' AND substr(refresh_token, 1, length($1)) <> $1)
+	`, `
+		UPDATE sessions
+		SET access_token = $2, refresh_token = $3
+		WHERE id = $1 AND access_token = $4 AND refresh_token = $5
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("rotating session tokens: %w", err)
+	}
+
+	users, err := db.rotateTable(ctx, prefix, `
+		SELECT id, COALESCE(access_token, ''), COALESCE(refresh_token, '')
+		FROM users
+		WHERE (COALESCE(access_token, '') <> '' AND substr(access_token, 1, length($1)) <> $1)
+			OR (COALESCE(refresh_token, '') <> '' AND substr(refresh_token, 1, length($1)) <> $1)
+	`, `
+		UPDATE users
+		SET access_token = NULLIF($2, ''), refresh_token = NULLIF($3, '')
+		WHERE id = $1 AND COALESCE(access_token, '') = $4 AND COALESCE(refresh_token, '') = $5
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("rotating user tokens: %w", err)
+	}
+
+	return &TokenRotation{Sessions: sessions, Users: users}, nil
+}
+
+// rotateTable re-encrypts the tokens of the rows selectQuery returns. The
+// update only applies if the row still holds the tokens that were read.
+func (db *DB) rotateTable(ctx context.Context, prefix, selectQuery, updateQuery string) (int, error) {
+	stored, err := db.readTokens(ctx, selectQuery, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for _, t := range stored {
+		access, refresh, err := openTokens(db.keys, t.accessToken, t.refreshToken)
+		if err != nil {
+			return rotated, fmt.Errorf("row %s: %w", t.id, err)
+		}
+		access, refresh, err = sealTokens(db.keys, access, refresh)
+		if err != nil {
+			return rotated, fmt.Errorf("row %s: %w", t.id, err)
+		}
+		n, err := db.updateTokens(ctx, updateQuery, t.id, access, refresh, t.accessToken, t.refreshToken)
+		if err != nil {
+			return rotated, fmt.Errorf("updating row %s: %w", t.id, err)
+		}
+		rotated += int(n)
+	}
+	return rotated, nil
+}
+
+// readTokens returns the rows selectQuery returns, all read before any is
+// updated.
+func (db *DB) readTokens(ctx context.Context, selectQuery, prefix string) ([]tokenRow, error) {
+	if db.sqlite != nil {
+		rows, err := sqliteQuery(ctx, db.sqlite, selectQuery, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("querying tokens: %w", err)
+		}
+		defer rows.Close()
+
+		var stored []tokenRow
+		for rows.Next() {
+			var t tokenRow
+			if err := rows.Scan(&t.id, &t.accessToken, &t.refreshToken); err != nil {
+				return nil, fmt.Errorf("scanning tokens: %w", err)
+			}
+			stored = append(stored, t)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("scanning tokens: %w", err)
+		}
+		return stored, nil
+	}
+
+	rows, err := db.pool.Query(ctx, selectQuery, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("querying tokens: %w", err)
+	}
+	stored, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (tokenRow, error) {
+		var t tokenRow
+		err := row.Scan(&t.id, &t.accessToken, &t.refreshToken)
+		return t, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scanning tokens: %w", err)
+	}
+	return stored, nil
+}
+
+// updateTokens runs updateQuery and returns the number of rows it changed.
+func (db *DB) updateTokens(ctx context.Context, updateQuery string, args ...any) (int64, error) {
+	if db.sqlite != nil {
+		result, err := sqliteExec(ctx, db.sqlite, updateQuery, args...)
+		if err != nil {
+			return 0, err
+		}
+		return rowsAffected(result), nil
+	}
+	result, err := db.pool.Exec(ctx, updateQuery, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -10,13 +10,13 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// TrackRepository handles track database operations.
-type TrackRepository struct {
+// pgTrackRepository implements TrackRepository with PostgreSQL.
+type pgTrackRepository struct {
 	pool *pgxpool.Pool
 }
 
 // Upsert creates or updates a track.
-func (r *TrackRepository) Upsert(ctx context.Context, track *Track) error {
+func (r *pgTrackRepository) Upsert(ctx context.Context, track *Track) error {
 	query := `
 		INSERT INTO tracks (id, name, artist, album, album_id, duration_ms, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6, NOW())
@@ -25,7 +25,8 @@ func (r *TrackRepository) Upsert(ctx context.Context, track *Track) error {
 			artist = EXCLUDED.artist,
 			album = EXCLUDED.album,
 			album_id = EXCLUDED.album_id,
-			duration_ms = EXCLUDED.duration_ms
+			duration_ms = EXCLUDED.duration_ms,
+			updated_at = NOW()
 		RETURNING created_at
 	`
 	err := r.pool.QueryRow(ctx, query,
@@ -43,7 +44,7 @@ func (r *TrackRepository) Upsert(ctx context.Context, track *Track) error {
 }
 
 // UpsertBatch inserts or updates multiple tracks efficiently.
-func (r *TrackRepository) UpsertBatch(ctx context.Context, tracks []Track) error {
+func (r *pgTrackRepository) UpsertBatch(ctx context.Context, tracks []Track) error {
 	if len(tracks) == 0 {
 		return nil
 	}
@@ -56,7 +57,8 @@ func (r *TrackRepository) UpsertBatch(ctx context.Context, tracks []Track) error
 			artist = EXCLUDED.artist,
 			album = EXCLUDED.album,
 			album_id = EXCLUDED.album_id,
-			duration_ms = EXCLUDED.duration_ms
+			duration_ms = EXCLUDED.duration_ms,
+			updated_at = NOW()
 	`
 
 	ids := make([]string, len(tracks))
@@ -85,8 +87,48 @@ func (r *TrackRepository) UpsertBatch(ctx context.Context, tracks []Track) error
 	return nil
 }
 
+// InsertBatchIfAbsent inserts tracks that don't exist yet, leaving the
+// metadata of existing rows untouched. Used for sources with less metadata
+// than the Spotify API.
+func (r *pgTrackRepository) InsertBatchIfAbsent(ctx context.Context, tracks []Track) error {
+	if len(tracks) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO tracks (id, name, artist, album, album_id, duration_ms, created_at)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::int[], $7::timestamptz[])
+		ON CONFLICT (id) DO UPDATE SET updated_at = NOW()
+	`
+
+	ids := make([]string, len(tracks))
+	names := make([]string, len(tracks))
+	artists := make([]string, len(tracks))
+	albums := make([]*string, len(tracks))
+	albumIDs := make([]*string, len(tracks))
+	durations := make([]*int, len(tracks))
+	createdAts := make([]time.Time, len(tracks))
+
+	now := time.Now()
+	for i, t := range tracks {
+		ids[i] = t.ID
+		names[i] = t.Name
+		artists[i] = t.Artist
+		albums[i] = t.Album
+		albumIDs[i] = t.AlbumID
+		durations[i] = t.DurationMs
+		createdAts[i] = now
+	}
+
+	_, err := r.pool.Exec(ctx, query, ids, names, artists, albums, albumIDs, durations, createdAts)
+	if err != nil {
+		return fmt.Errorf("batch inserting tracks: %w", err)
+	}
+	return nil
+}
+
 // Get retrieves a track by ID.
-func (r *TrackRepository) Get(ctx context.Context, id string) (*Track, error) {
+func (r *pgTrackRepository) Get(ctx context.Context, id string) (*Track, error) {
 	query := `
 		SELECT id, name, artist, album, album_id, duration_ms, created_at
 		FROM tracks
@@ -112,7 +154,7 @@ func (r *TrackRepository) Get(ctx context.Context, id string) (*Track, error) {
 }
 
 // GetUserTracks retrieves all liked tracks for a user, ordered by added_at desc.
-func (r *TrackRepository) GetUserTracks(ctx context.Context, userID string) ([]Track, error) {
+func (r *pgTrackRepository) GetUserTracks(ctx context.Context, userID string) ([]Track, error) {
 	query := `
 		SELECT t.id, t.name, t.artist, t.album, t.album_id, t.duration_ms, t.created_at
 		FROM tracks t
@@ -146,7 +188,7 @@ func (r *TrackRepository) GetUserTracks(ctx context.Context, userID string) ([]T
 }
 
 // GetUserTracksWithAddedAt retrieves all liked tracks for a user with their added_at timestamps.
-func (r *TrackRepository) GetUserTracksWithAddedAt(ctx context.Context, userID string) ([]UserTrack, []Track, error) {
+func (r *pgTrackRepository) GetUserTracksWithAddedAt(ctx context.Context, userID string) ([]UserTrack, []Track, error) {
 	query := `
 		SELECT t.id, t.name, t.artist, t.album, t.album_id, t.duration_ms, t.created_at, ut.added_at
 		FROM tracks t
@@ -188,7 +230,7 @@ func (r *TrackRepository) GetUserTracksWithAddedAt(ctx context.Context, userID s
 }
 
 // LinkToUser links a track to a user's library.
-func (r *TrackRepository) LinkToUser(ctx context.Context, userID, trackID string, addedAt time.Time) error {
+func (r *pgTrackRepository) LinkToUser(ctx context.Context, userID, trackID string, addedAt time.Time) error {
 	query := `
 		INSERT INTO user_tracks (user_id, track_id, added_at)
 		VALUES ($1, $2, $3)
@@ -202,7 +244,7 @@ func (r *TrackRepository) LinkToUser(ctx context.Context, userID, trackID string
 }
 
 // LinkBatchToUser links multiple tracks to a user's library efficiently.
-func (r *TrackRepository) LinkBatchToUser(ctx context.Context, userID string, tracks []UserTrack) error {
+func (r *pgTrackRepository) LinkBatchToUser(ctx context.Context, userID string, tracks []UserTrack) error {
 	if len(tracks) == 0 {
 		return nil
 	}
@@ -228,8 +270,36 @@ func (r *TrackRepository) LinkBatchToUser(ctx context.Context, userID string, tr
 	return nil
 }
 
+// LinkBatchToUserIfAbsent links tracks to a user's library without changing
+// the added_at of tracks that are already linked.
+func (r *pgTrackRepository) LinkBatchToUserIfAbsent(ctx context.Context, userID string, tracks []UserTrack) error {
+	if len(tracks) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO user_tracks (user_id, track_id, added_at)
+		SELECT $1, * FROM unnest($2::text[], $3::timestamptz[])
+		ON CONFLICT (user_id, track_id) DO NOTHING
+	`
+
+	trackIDs := make([]string, len(tracks))
+	addedAts := make([]time.Time, len(tracks))
+
+	for i, t := range tracks {
+		trackIDs[i] = t.TrackID
+		addedAts[i] = t.AddedAt
+	}
+
+	_, err := r.pool.Exec(ctx, query, userID, trackIDs, addedAts)
+	if err != nil {
+		return fmt.Errorf("batch linking tracks to user: %w", err)
+	}
+	return nil
+}
+
 // UnlinkAllFromUser removes all tracks from a user's library.
-func (r *TrackRepository) UnlinkAllFromUser(ctx context.Context, userID string) error {
+func (r *pgTrackRepository) UnlinkAllFromUser(ctx context.Context, userID string) error {
 	query := `DELETE FROM user_tracks WHERE user_id = $1`
 	_, err := r.pool.Exec(ctx, query, userID)
 	if err != nil {
@@ -237,3 +307,40 @@ func (r *TrackRepository) UnlinkAllFromUser(ctx context.Context, userID string)
 	}
 	return nil
 }
+
+// orphanTracks selects tracks last upserted before $1 that nothing refers
+// to: no user has liked, tagged or played them and no era contains them.
+// The age limit keeps tracks a running sync has just upserted but not yet
+// linked, including old orphans it is liking again. SQLite's updated_at is
+// nullable, since a column added there can't default to the current time,
+// so rows without it fall back to created_at.
+const orphanTracks = `
+	SELECT t.id FROM tracks t
+	WHERE COALESCE(t.updated_at, t.created_at) < $1
+		AND NOT EXISTS (SELECT 1 FROM user_tracks ut WHERE ut.track_id = t.id)
+		AND NOT EXISTS (SELECT 1 FROM user_track_tags utt WHERE utt.track_id = t.id)
+		AND NOT EXISTS (SELECT 1 FROM era_tracks et WHERE et.track_id = t.id)
+		AND NOT EXISTS (SELECT 1 FROM plays p WHERE p.track_id = t.id)
+`
+
+// DeleteOrphanTags removes the Last.fm tags of orphaned tracks last
+// upserted before cutoff. Returns the number of tags removed.
+func (r *pgTrackRepository) DeleteOrphanTags(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `DELETE FROM track_tags WHERE track_id IN (` + orphanTracks + `)`
+	result, err := r.pool.Exec(ctx, query, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("deleting orphan track tags: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// DeleteOrphans removes orphaned tracks last upserted before cutoff, with their
+// artist credits. Returns the number of tracks removed.
+func (r *pgTrackRepository) DeleteOrphans(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `DELETE FROM tracks WHERE id IN (` + orphanTracks + `)`
+	result, err := r.pool.Exec(ctx, query, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("deleting orphan tracks: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
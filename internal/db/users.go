@@ -10,13 +10,14 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// UserRepository handles user database operations.
-type UserRepository struct {
+// pgUserRepository implements UserRepository with PostgreSQL.
+type pgUserRepository struct {
 	pool *pgxpool.Pool
+	keys *Keyring
 }
 
 // Create inserts a new user.
-func (r *UserRepository) Create(ctx context.Context, user *User) error {
+func (r *pgUserRepository) Create(ctx context.Context, user *User) error {
 	query := `
 		INSERT INTO users (id, display_name, email, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5)
@@ -38,7 +39,7 @@ func (r *UserRepository) Create(ctx context.Context, user *User) error {
 }
 
 // Get retrieves a user by ID.
-func (r *UserRepository) Get(ctx context.Context, id string) (*User, error) {
+func (r *pgUserRepository) Get(ctx context.Context, id string) (*User, error) {
 	query := `
 		SELECT id, display_name, email, created_at, updated_at, last_sync_at
 		FROM users
@@ -63,7 +64,7 @@ func (r *UserRepository) Get(ctx context.Context, id string) (*User, error) {
 }
 
 // Upsert creates or updates a user.
-func (r *UserRepository) Upsert(ctx context.Context, user *User) error {
+func (r *pgUserRepository) Upsert(ctx context.Context, user *User) error {
 	query := `
 		INSERT INTO users (id, display_name, email, created_at, updated_at)
 		VALUES ($1, $2, $3, NOW(), NOW())
@@ -85,7 +86,7 @@ func (r *UserRepository) Upsert(ctx context.Context, user *User) error {
 }
 
 // UpdateLastSync updates the last sync timestamp for a user.
-func (r *UserRepository) UpdateLastSync(ctx context.Context, id string, syncTime time.Time) error {
+func (r *pgUserRepository) UpdateLastSync(ctx context.Context, id string, syncTime time.Time) error {
 	query := `
 		UPDATE users
 		SET last_sync_at = $2, updated_at = NOW()
@@ -100,3 +101,138 @@ func (r *UserRepository) UpdateLastSync(ctx context.Context, id string, syncTime
 	}
 	return nil
 }
+
+// EnsureExists creates a bare user record if one doesn't exist yet, using the
+// ID as the display name until the user logs in. Existing users are left untouched.
+func (r *pgUserRepository) EnsureExists(ctx context.Context, id string) error {
+	query := `
+		INSERT INTO users (id, display_name, email, created_at, updated_at)
+		VALUES ($1, $1, '', NOW(), NOW())
+		ON CONFLICT (id) DO NOTHING
+	`
+	_, err := r.pool.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("ensuring user: %w", err)
+	}
+	return nil
+}
+
+// SaveToken stores a Spotify OAuth token for the user, encrypted if a
+// keyring is set.
+func (r *pgUserRepository) SaveToken(ctx context.Context, id, accessToken, refreshToken string, expiry time.Time) error {
+	accessToken, refreshToken, err := sealTokens(r.keys, accessToken, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE users
+		SET access_token = $2, refresh_token = $3, token_expiry = $4, updated_at = NOW()
+		WHERE id = $1
+	`
+	result, err := r.pool.Exec(ctx, query, id, accessToken, refreshToken, expiry)
+	if err != nil {
+		return fmt.Errorf("saving user token: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetToken retrieves the user's stored Spotify OAuth token.
+// Returns ErrNotFound if the user has no stored token.
+func (r *pgUserRepository) GetToken(ctx context.Context, id string) (*UserToken, error) {
+	query := `
+		SELECT access_token, refresh_token, token_expiry
+		FROM users
+		WHERE id = $1 AND refresh_token IS NOT NULL
+	`
+	var token UserToken
+	var accessToken *string
+	var expiry *time.Time
+	err := r.pool.QueryRow(ctx, query, id).Scan(&accessToken, &token.RefreshToken, &expiry)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying user token: %w", err)
+	}
+	if accessToken != nil {
+		token.AccessToken = *accessToken
+	}
+	if expiry != nil {
+		token.TokenExpiry = *expiry
+	}
+	token.AccessToken, token.RefreshToken, err = openTokens(r.keys, token.AccessToken, token.RefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("user %s token: %w", id, err)
+	}
+	return &token, nil
+}
+
+// ClearToken removes the user's stored Spotify OAuth token. The user isn't
+// scheduled again until a login saves a new one.
+func (r *pgUserRepository) ClearToken(ctx context.Context, id string) error {
+	query := `
+		UPDATE users
+		SET access_token = NULL, refresh_token = NULL, token_expiry = NULL, updated_at = NOW()
+		WHERE id = $1
+	`
+	result, err := r.pool.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("clearing user token: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordSyncFailure records when a scheduled sync of the user failed.
+func (r *pgUserRepository) RecordSyncFailure(ctx context.Context, id string, at time.Time) error {
+	query := `
+		UPDATE users
+		SET sync_failed_at = $2, updated_at = NOW()
+		WHERE id = $1
+	`
+	result, err := r.pool.Exec(ctx, query, id, at)
+	if err != nil {
+		return fmt.Errorf("recording sync failure: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListScheduled returns every user with a stored Spotify refresh token,
+// with their last sync and failure times and sync schedule, least recently
+// synced first.
+func (r *pgUserRepository) ListScheduled(ctx context.Context) ([]ScheduledUser, error) {
+	query := `
+		SELECT u.id, u.last_sync_at, u.sync_failed_at, s.sync_interval_hours
+		FROM users u
+		LEFT JOIN user_settings s ON s.user_id = u.id
+		WHERE u.refresh_token IS NOT NULL
+		ORDER BY u.last_sync_at ASC NULLS FIRST, u.id
+	`
+	rows, err := r.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("querying scheduled users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []ScheduledUser
+	for rows.Next() {
+		var user ScheduledUser
+		if err := rows.Scan(&user.ID, &user.LastSyncAt, &user.SyncFailedAt, &user.SyncIntervalHours); err != nil {
+			return nil, fmt.Errorf("scanning scheduled user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating scheduled users: %w", err)
+	}
+	return users, nil
+}
@@ -0,0 +1,89 @@
+package eras
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/exporter"
+)
+
+// EraStore looks up and updates eras by ID on behalf of their owner. Every
+// method takes the requesting user's ID and treats another user's era as
+// missing: lookups return db.ErrNotFound or nothing, and updates return
+// db.ErrNotFound. It is implemented by db.EraRepository.
+type EraStore interface {
+	GetForOwner(ctx context.Context, userID string, id uuid.UUID) (*db.Era, error)
+	GetTracksForOwner(ctx context.Context, userID string, eraID uuid.UUID) ([]db.Track, error)
+	GetTrackReasonsForOwner(ctx context.Context, userID string, eraID uuid.UUID) (map[string][]string, error)
+	UpdatePlaylistIDForOwner(ctx context.Context, userID string, eraID uuid.UUID, playlistID string) error
+	UpdateNameOverrideForOwner(ctx context.Context, userID string, eraID uuid.UUID, name *string) error
+}
+
+// GetEra retrieves a single era owned by the user. Every request that names
+// an era by ID goes through here first. Returns db.ErrNotFound if the ID is
+// malformed, the era doesn't exist or it belongs to another user, so
+// callers can't tell whether someone else's era exists.
+func (s *Service) GetEra(ctx context.Context, userID, eraID string) (*db.Era, error) {
+	id, err := uuid.Parse(eraID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid era ID: %w", db.ErrNotFound)
+	}
+	era, err := s.owned.GetForOwner(ctx, userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("getting era: %w", err)
+	}
+	return era, nil
+}
+
+// GetEraTracks retrieves all tracks for one of the user's eras.
+// Returns db.ErrNotFound under the same conditions as GetEra.
+func (s *Service) GetEraTracks(ctx context.Context, userID, eraID string) ([]db.Track, error) {
+	era, err := s.GetEra(ctx, userID, eraID)
+	if err != nil {
+		return nil, err
+	}
+	tracks, err := s.owned.GetTracksForOwner(ctx, userID, era.ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting era tracks: %w", err)
+	}
+	return tracks, nil
+}
+
+// GetEraTrackReasons returns the tags tying each track to one of the user's
+// eras, keyed by track ID. Returns db.ErrNotFound under the same conditions
+// as GetEra.
+func (s *Service) GetEraTrackReasons(ctx context.Context, userID, eraID string) (map[string][]string, error) {
+	era, err := s.GetEra(ctx, userID, eraID)
+	if err != nil {
+		return nil, err
+	}
+	reasons, err := s.owned.GetTrackReasonsForOwner(ctx, userID, era.ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting track reasons: %w", err)
+	}
+	return reasons, nil
+}
+
+// Exporter returns an exporter of the user's eras whose lookups by ID go
+// through the same ownership checks as GetEra.
+func (s *Service) Exporter() *exporter.Exporter {
+	return exporter.New(&exportSource{Source: exporter.NewDBSource(s.db), owned: s.owned})
+}
+
+// exportSource is an exporter.Source that looks up eras by ID in the
+// service's EraStore.
+type exportSource struct {
+	exporter.Source
+	owned EraStore
+}
+
+func (e *exportSource) GetEra(ctx context.Context, userID string, eraID uuid.UUID) (*db.Era, error) {
+	return e.owned.GetForOwner(ctx, userID, eraID)
+}
+
+func (e *exportSource) GetEraTracks(ctx context.Context, userID string, eraID uuid.UUID) ([]db.Track, error) {
+	return e.owned.GetTracksForOwner(ctx, userID, eraID)
+}
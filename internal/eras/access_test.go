@@ -0,0 +1,121 @@
+package eras
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/db/dbtest"
+)
+
+// newAccessStore returns a store with the users alice and mallory and the
+// given eras, each holding track t1 with "ambient" as its reason.
+func newAccessStore(t *testing.T, eras ...db.Era) *dbtest.Store {
+	t.Helper()
+	ctx := context.Background()
+	store := dbtest.NewStore()
+	for _, id := range []string{"alice", "mallory"} {
+		if err := store.Users().EnsureExists(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Tracks().Upsert(ctx, &db.Track{ID: "t1", Name: "Secret"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, era := range eras {
+		tracks := []db.EraTrack{{TrackID: "t1", ReasonTags: []string{"ambient"}}}
+		if err := store.Eras().CreateWithTracks(ctx, &era, tracks); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestAccess_CrossUser(t *testing.T) {
+	ctx := context.Background()
+	aliceEra := db.Era{ID: uuid.New(), UserID: "alice", Name: "Alice's era"}
+	playlist := "playlist1"
+	alicePublished := db.Era{ID: uuid.New(), UserID: "alice", Name: "Published", PlaylistID: &playlist}
+	store := newAccessStore(t, aliceEra, alicePublished)
+	s := New(store)
+
+	// Publishing another user's era must fail before Spotify is called, so
+	// a nil client is never used
+	calls := map[string]func(eraID string) error{
+		"GetEra": func(id string) error {
+			_, err := s.GetEra(ctx, "mallory", id)
+			return err
+		},
+		"GetEraTracks": func(id string) error {
+			_, err := s.GetEraTracks(ctx, "mallory", id)
+			return err
+		},
+		"GetEraTrackReasons": func(id string) error {
+			_, err := s.GetEraTrackReasons(ctx, "mallory", id)
+			return err
+		},
+		"RenameEra": func(id string) error {
+			_, err := s.RenameEra(ctx, "mallory", id, "Mine now")
+			return err
+		},
+		"PublishPlaylist": func(id string) error {
+			_, err := s.PublishPlaylist(ctx, nil, "mallory", id, false)
+			return err
+		},
+		"Export": func(id string) error {
+			_, err := s.Exporter().Load(ctx, "mallory", id)
+			return err
+		},
+	}
+	ids := map[string]string{
+		"other user's era":           aliceEra.ID.String(),
+		"other user's published era": alicePublished.ID.String(),
+		"missing era":                uuid.NewString(),
+		"malformed ID":               "not-a-uuid",
+	}
+	for name, call := range calls {
+		for idName, id := range ids {
+			t.Run(name+"/"+idName, func(t *testing.T) {
+				if err := call(id); !errors.Is(err, db.ErrNotFound) {
+					t.Errorf("error = %v, want db.ErrNotFound", err)
+				}
+			})
+		}
+	}
+
+	got, err := store.Eras().GetForOwner(ctx, "alice", aliceEra.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.NameOverride != nil {
+		t.Errorf("another user renamed the era to %q", *got.NameOverride)
+	}
+}
+
+func TestAccess_Owner(t *testing.T) {
+	ctx := context.Background()
+	era := db.Era{ID: uuid.New(), UserID: "alice", Name: "Generated"}
+	s := New(newAccessStore(t, era))
+	id := era.ID.String()
+
+	tracks, err := s.GetEraTracks(ctx, "alice", id)
+	if err != nil || len(tracks) != 1 {
+		t.Errorf("GetEraTracks() = %v, %v, want one track", tracks, err)
+	}
+	reasons, err := s.GetEraTrackReasons(ctx, "alice", id)
+	if err != nil || len(reasons["t1"]) != 1 {
+		t.Errorf("GetEraTrackReasons() = %v, %v, want t1's reasons", reasons, err)
+	}
+
+	renamed, err := s.RenameEra(ctx, "alice", id, "  Road trip  ")
+	if err != nil || renamed.DisplayName() != "Road trip" {
+		t.Fatalf("RenameEra() = %+v, %v, want Road trip", renamed, err)
+	}
+	restored, err := s.RenameEra(ctx, "alice", id, "")
+	if err != nil || restored.DisplayName() != "Generated" {
+		t.Errorf("RenameEra(\"\") = %+v, %v, want the generated name back", restored, err)
+	}
+}
@@ -0,0 +1,295 @@
+package eras
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/clustering"
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// MatchThreshold is the Jaccard similarity of track sets at which a new era
+// takes over a previous era's identity: its ID, name override and playlist.
+const MatchThreshold = 0.5
+
+// minShare is the fraction of a previous era's tracks a new era must take
+// to count as one of its successors when telling splits and merges apart.
+const minShare = 0.3
+
+// ChangeStatus describes how an era relates to the eras of the previous run.
+type ChangeStatus string
+
+const (
+	StatusUnchanged ChangeStatus = "unchanged" // Matched with the same tracks
+	StatusChanged   ChangeStatus = "changed"   // Matched with different tracks
+	StatusNew       ChangeStatus = "new"       // No previous era to match
+	StatusSplit     ChangeStatus = "split"     // Part of a previous era that split up
+	StatusMerged    ChangeStatus = "merged"    // Made from several previous eras
+	StatusGone      ChangeStatus = "gone"      // Previous era with no successor
+)
+
+// EraChange reports what happened to one era in a re-analysis.
+type EraChange struct {
+	Status ChangeStatus
+	// EraID is the era's ID after the run, or the deleted era's ID if gone.
+	// A matched, split or merged era keeps the ID of the previous era it
+	// matched, if any.
+	EraID uuid.UUID
+	Name  string
+	// Previous lists the previous eras the era continues: the matched era,
+	// or every era it took a large share of when split or merged.
+	Previous   []PreviousEra
+	Similarity float64 // Jaccard similarity with the matched era, 0 if none
+	Depth      int     // 0 for top-level eras, 1 for their sub-eras, ...
+}
+
+// PreviousEra identifies an era from before a re-analysis.
+type PreviousEra struct {
+	ID   uuid.UUID
+	Name string
+}
+
+// eraTracks is an era with its set of track IDs, for matching.
+type eraTracks struct {
+	id     uuid.UUID
+	name   string
+	tracks map[string]bool
+}
+
+// newEraTracks builds a track set from a list of track IDs.
+func newEraTracks(id uuid.UUID, name string, trackIDs []string) eraTracks {
+	set := make(map[string]bool, len(trackIDs))
+	for _, t := range trackIDs {
+		set[t] = true
+	}
+	return eraTracks{id: id, name: name, tracks: set}
+}
+
+// intersection returns the number of tracks in both sets.
+func intersection(a, b map[string]bool) int {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	n := 0
+	for t := range a {
+		if b[t] {
+			n++
+		}
+	}
+	return n
+}
+
+// jaccard returns |a ∩ b| / |a ∪ b|, or 0 if both are empty.
+func jaccard(a, b map[string]bool) float64 {
+	inter := intersection(a, b)
+	union := len(a) + len(b) - inter
+	if union == 0 {
+		return 0
+	}
+	return float64(inter) / float64(union)
+}
+
+// matchResult is the outcome of matching one level of eras.
+type matchResult struct {
+	// matched holds, per new era, the index of the previous era it takes
+	// the identity of, or -1.
+	matched    []int
+	similarity []float64
+	changes    []EraChange // One per new era in order, then one per gone era
+}
+
+// matchEras pairs new eras with previous ones. Pairs are taken greedily from
+// the most similar down, each era used at most once, until similarity drops
+// below MatchThreshold. Previous eras that handed at least minShare of
+// their tracks to two or more new eras split; new eras that took at least
+// minShare of two or more previous eras merged. IDs of the returned changes
+// are left nil for new eras, to be filled in once they are saved.
+func matchEras(previous, next []eraTracks, depth int) matchResult {
+	type pair struct {
+		prev, next int
+		sim        float64
+	}
+	var pairs []pair
+	successors := make([][]int, len(previous))
+	sources := make([][]int, len(next))
+	for i, p := range previous {
+		for j, n := range next {
+			inter := intersection(p.tracks, n.tracks)
+			if inter == 0 {
+				continue
+			}
+			if sim := jaccard(p.tracks, n.tracks); sim >= MatchThreshold {
+				pairs = append(pairs, pair{prev: i, next: j, sim: sim})
+			}
+			if float64(inter) >= minShare*float64(len(p.tracks)) {
+				successors[i] = append(successors[i], j)
+				sources[j] = append(sources[j], i)
+			}
+		}
+	}
+	// Stable, so ties go to the earliest (newest) eras
+	sort.SliceStable(pairs, func(a, b int) bool { return pairs[a].sim > pairs[b].sim })
+
+	result := matchResult{
+		matched:    make([]int, len(next)),
+		similarity: make([]float64, len(next)),
+	}
+	for j := range result.matched {
+		result.matched[j] = -1
+	}
+	taken := make([]bool, len(previous))
+	for _, p := range pairs {
+		if taken[p.prev] || result.matched[p.next] >= 0 {
+			continue
+		}
+		taken[p.prev] = true
+		result.matched[p.next] = p.prev
+		result.similarity[p.next] = p.sim
+	}
+
+	previousEras := func(indexes []int) []PreviousEra {
+		eras := make([]PreviousEra, len(indexes))
+		for k, i := range indexes {
+			eras[k] = PreviousEra{ID: previous[i].id, Name: previous[i].name}
+		}
+		return eras
+	}
+
+	for j, n := range next {
+		change := EraChange{Status: StatusNew, Name: n.name, Depth: depth, Similarity: result.similarity[j]}
+		matched := result.matched[j]
+		if matched >= 0 {
+			change.EraID = previous[matched].id
+		}
+
+		split := false
+		for _, i := range sources[j] {
+			if len(successors[i]) >= 2 {
+				split = true
+			}
+		}
+		switch {
+		case len(sources[j]) >= 2:
+			change.Status = StatusMerged
+			change.Previous = previousEras(sources[j])
+		case split:
+			change.Status = StatusSplit
+			change.Previous = previousEras(sources[j])
+		case matched >= 0:
+			change.Status = StatusChanged
+			if result.similarity[j] == 1 {
+				change.Status = StatusUnchanged
+			}
+			change.Previous = previousEras([]int{matched})
+		}
+		result.changes = append(result.changes, change)
+	}
+
+	for i, p := range previous {
+		if !taken[i] && len(successors[i]) == 0 {
+			result.changes = append(result.changes, EraChange{
+				Status: StatusGone,
+				EraID:  p.id,
+				Name:   p.name,
+				Depth:  depth,
+			})
+		}
+	}
+	return result
+}
+
+// previousLevels groups a user's stored eras by depth, with their tracks,
+// so each level is matched separately.
+func previousLevels(stored []db.Era, trackIDs map[uuid.UUID][]string) ([][]eraTracks, map[uuid.UUID]db.Era) {
+	byID := make(map[uuid.UUID]db.Era, len(stored))
+	var levels [][]eraTracks
+	var walk func(nodes []EraNode, depth int)
+	walk = func(nodes []EraNode, depth int) {
+		for _, node := range nodes {
+			if len(levels) <= depth {
+				levels = append(levels, nil)
+			}
+			levels[depth] = append(levels[depth], newEraTracks(node.Era.ID, node.Era.DisplayName(), trackIDs[node.Era.ID]))
+			byID[node.Era.ID] = node.Era
+			walk(node.SubEras, depth+1)
+		}
+	}
+	walk(BuildTree(stored), 0)
+	return levels, byID
+}
+
+// identities is the outcome of matching newly detected eras, at every
+// depth, with the user's stored eras.
+type identities struct {
+	previous map[*clustering.MoodEra]db.Era // Previous era each new era takes over
+	changes  []EraChange
+	eras     []*clustering.MoodEra // New era of each change; nil for gone eras
+}
+
+// matchIdentities matches new eras with the user's stored eras level by
+// level: top-level eras with top-level eras, sub-eras with sub-eras of the
+// same depth, whatever their parents.
+func matchIdentities(stored []db.Era, trackIDs map[uuid.UUID][]string, moodEras []clustering.MoodEra) identities {
+	levels, byID := previousLevels(stored, trackIDs)
+	result := identities{previous: make(map[*clustering.MoodEra]db.Era)}
+
+	level := make([]*clustering.MoodEra, len(moodEras))
+	for i := range moodEras {
+		level[i] = &moodEras[i]
+	}
+	for depth := 0; len(level) > 0 || depth < len(levels); depth++ {
+		var prev []eraTracks
+		if depth < len(levels) {
+			prev = levels[depth]
+		}
+		next := make([]eraTracks, len(level))
+		for j, era := range level {
+			ids := make([]string, len(era.Tracks))
+			for k, t := range era.Tracks {
+				ids[k] = t.ID
+			}
+			next[j] = newEraTracks(uuid.Nil, era.Name, ids)
+		}
+
+		m := matchEras(prev, next, depth)
+		for j, i := range m.matched {
+			if i >= 0 {
+				result.previous[level[j]] = byID[prev[i].id]
+			}
+		}
+		result.changes = append(result.changes, m.changes...)
+		for k := range m.changes {
+			var era *clustering.MoodEra
+			if k < len(level) {
+				era = level[k]
+			}
+			result.eras = append(result.eras, era)
+		}
+
+		var children []*clustering.MoodEra
+		for _, era := range level {
+			for i := range era.SubEras {
+				children = append(children, &era.SubEras[i])
+			}
+		}
+		level = children
+	}
+	return result
+}
+
+// report fills in each new era's saved ID and name from created and returns
+// the changes. Returns nil if the user had no previous eras, since there is
+// nothing to compare with; matchIdentities still reports every era as new.
+func (ids identities) report(created map[*clustering.MoodEra]db.Era, hadPrevious bool) []EraChange {
+	for i, era := range ids.eras {
+		if era != nil {
+			ids.changes[i].EraID = created[era].ID
+			ids.changes[i].Name = created[era].DisplayName()
+		}
+	}
+	if !hadPrevious {
+		return nil
+	}
+	return ids.changes
+}
@@ -0,0 +1,193 @@
+package eras
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/clustering"
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// trackRange returns track IDs "t<from>" to "t<to>" inclusive.
+func trackRange(from, to int) []string {
+	var ids []string
+	for i := from; i <= to; i++ {
+		ids = append(ids, fmt.Sprintf("t%d", i))
+	}
+	return ids
+}
+
+func TestMatchEras(t *testing.T) {
+	prev := func(name string, ids ...[]string) eraTracks {
+		var all []string
+		for _, list := range ids {
+			all = append(all, list...)
+		}
+		return newEraTracks(uuid.New(), name, all)
+	}
+	a := prev("A", trackRange(1, 10))
+	b := prev("B", trackRange(11, 20))
+	c := prev("C", trackRange(21, 30))
+	d := prev("D", trackRange(31, 40))
+	e := prev("E", trackRange(41, 44))
+	f := prev("F", trackRange(51, 60))
+
+	next := []eraTracks{
+		prev("same as A", trackRange(1, 10)),
+		prev("most of B", trackRange(13, 20), trackRange(91, 92)),
+		prev("bigger part of C", trackRange(21, 26)),
+		prev("rest of C", trackRange(27, 30)),
+		prev("D and F", trackRange(31, 40), trackRange(51, 60)),
+		prev("brand new", trackRange(81, 85)),
+	}
+
+	result := matchEras([]eraTracks{a, b, c, d, e, f}, next, 0)
+
+	want := []struct {
+		status   ChangeStatus
+		id       uuid.UUID
+		previous []uuid.UUID
+	}{
+		{StatusUnchanged, a.id, []uuid.UUID{a.id}},
+		{StatusChanged, b.id, []uuid.UUID{b.id}},
+		{StatusSplit, c.id, []uuid.UUID{c.id}},
+		{StatusSplit, uuid.Nil, []uuid.UUID{c.id}},
+		{StatusMerged, d.id, []uuid.UUID{d.id, f.id}},
+		{StatusNew, uuid.Nil, nil},
+		{StatusGone, e.id, nil},
+	}
+	if len(result.changes) != len(want) {
+		t.Fatalf("got %d changes, want %d: %+v", len(result.changes), len(want), result.changes)
+	}
+	for i, w := range want {
+		got := result.changes[i]
+		if got.Status != w.status || got.EraID != w.id {
+			t.Errorf("changes[%d] (%s) = %s %s, want %s %s", i, got.Name, got.Status, got.EraID, w.status, w.id)
+		}
+		if len(got.Previous) != len(w.previous) {
+			t.Errorf("changes[%d] (%s) previous = %+v, want %d eras", i, got.Name, got.Previous, len(w.previous))
+			continue
+		}
+		for k, id := range w.previous {
+			if got.Previous[k].ID != id {
+				t.Errorf("changes[%d] (%s) previous[%d] = %s, want %s", i, got.Name, k, got.Previous[k].ID, id)
+			}
+		}
+	}
+
+	// 8 shared tracks of 12 in either
+	if sim := result.changes[1].Similarity; sim < 0.66 || sim > 0.67 {
+		t.Errorf("B similarity = %v, want 2/3", sim)
+	}
+	if result.matched[5] != -1 {
+		t.Errorf("new era matched previous era %d", result.matched[5])
+	}
+}
+
+func TestMatchEras_BelowThreshold(t *testing.T) {
+	previous := []eraTracks{newEraTracks(uuid.New(), "old", trackRange(1, 10))}
+	next := []eraTracks{newEraTracks(uuid.Nil, "new", trackRange(6, 15))}
+
+	// 5 shared of 15: too different to keep the identity, but it's still
+	// the old era's only successor
+	result := matchEras(previous, next, 0)
+	if len(result.changes) != 1 || result.changes[0].Status != StatusNew || result.matched[0] != -1 {
+		t.Errorf("changes = %+v, want one new era", result.changes)
+	}
+}
+
+func TestMatchIdentities(t *testing.T) {
+	override := "My summer"
+	parent := db.Era{ID: uuid.New(), Name: "Summer", NameOverride: &override}
+	childA := db.Era{ID: uuid.New(), Name: "Summer A", ParentID: &parent.ID}
+	childB := db.Era{ID: uuid.New(), Name: "Summer B", ParentID: &parent.ID}
+	trackIDs := map[uuid.UUID][]string{
+		parent.ID: trackRange(1, 6),
+		childA.ID: trackRange(1, 3),
+		childB.ID: trackRange(4, 6),
+	}
+
+	tracks := func(ids []string) []clustering.Track {
+		result := make([]clustering.Track, len(ids))
+		for i, id := range ids {
+			result[i] = clustering.Track{ID: id}
+		}
+		return result
+	}
+	moodEras := []clustering.MoodEra{{
+		Name:   "Summer 2024",
+		Tracks: tracks(trackRange(1, 7)),
+		SubEras: []clustering.MoodEra{
+			{Name: "new B", Tracks: tracks(trackRange(4, 7))},
+			{Name: "new A", Tracks: tracks(trackRange(1, 3))},
+		},
+	}}
+
+	ids := matchIdentities([]db.Era{parent, childA, childB}, trackIDs, moodEras)
+
+	if got := ids.previous[&moodEras[0]]; got.ID != parent.ID || got.DisplayName() != override {
+		t.Errorf("top-level era took over %+v, want the parent with its name override", got)
+	}
+	if got := ids.previous[&moodEras[0].SubEras[0]]; got.ID != childB.ID {
+		t.Errorf("new B took over %s, want %s", got.Name, childB.Name)
+	}
+	if got := ids.previous[&moodEras[0].SubEras[1]]; got.ID != childA.ID {
+		t.Errorf("new A took over %s, want %s", got.Name, childA.Name)
+	}
+
+	wantStatus := []ChangeStatus{StatusChanged, StatusChanged, StatusUnchanged}
+	wantDepth := []int{0, 1, 1}
+	if len(ids.changes) != len(wantStatus) {
+		t.Fatalf("got %d changes, want %d: %+v", len(ids.changes), len(wantStatus), ids.changes)
+	}
+	for i, c := range ids.changes {
+		if c.Status != wantStatus[i] || c.Depth != wantDepth[i] {
+			t.Errorf("changes[%d] = %s at depth %d, want %s at depth %d", i, c.Status, c.Depth, wantStatus[i], wantDepth[i])
+		}
+		if ids.eras[i] == nil {
+			t.Errorf("changes[%d] has no new era", i)
+		}
+	}
+}
+
+func TestMatchIdentities_NoPreviousEras(t *testing.T) {
+	moodEras := []clustering.MoodEra{{Name: "first", Tracks: []clustering.Track{{ID: "t1"}}}}
+
+	ids := matchIdentities(nil, nil, moodEras)
+
+	if len(ids.previous) != 0 {
+		t.Errorf("previous = %+v, want none", ids.previous)
+	}
+	if len(ids.changes) != 1 || ids.changes[0].Status != StatusNew {
+		t.Errorf("changes = %+v, want one new era", ids.changes)
+	}
+}
+
+func TestIdentities_Report(t *testing.T) {
+	moodEras := []clustering.MoodEra{
+		{Name: "first", Tracks: []clustering.Track{{ID: "t1"}}},
+		{Name: "second", Tracks: []clustering.Track{{ID: "t2"}}},
+	}
+	created := map[*clustering.MoodEra]db.Era{
+		&moodEras[0]: {ID: uuid.New(), Name: "First"},
+		&moodEras[1]: {ID: uuid.New(), Name: "Second"},
+	}
+
+	// A first analysis reports no changes, though every era is new
+	if got := matchIdentities(nil, nil, moodEras).report(created, false); got != nil {
+		t.Errorf("report() on a first analysis = %+v, want nil", got)
+	}
+
+	got := matchIdentities(nil, nil, moodEras).report(created, true)
+	if len(got) != 2 {
+		t.Fatalf("report() = %+v, want two new eras", got)
+	}
+	for i, c := range got {
+		want := created[&moodEras[i]]
+		if c.EraID != want.ID || c.Name != want.Name {
+			t.Errorf("changes[%d] = %s %q, want %s %q", i, c.EraID, c.Name, want.ID, want.Name)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package eras
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/clustering"
+)
+
+// PreviewTTL is how long a preview can be applied after it was created.
+const PreviewTTL = 30 * time.Minute
+
+// ErrPreviewNotFound is returned when applying a preview that doesn't exist,
+// has expired, or was replaced by a newer preview.
+var ErrPreviewNotFound = errors.New("preview not found or expired")
+
+// Preview is the result of era detection that hasn't been saved. Clustering
+// is randomized, so applying a preview saves exactly these eras rather than
+// detecting again.
+type Preview struct {
+	ID          string
+	Config      clustering.TagClusterConfig
+	Eras        []clustering.MoodEra
+	Outliers    []clustering.Track
+	TotalTracks int
+	CreatedAt   time.Time
+}
+
+// Preview runs era detection on the user's stored tracks without changing
+// their eras. The preview can be saved with ApplyPreview until it expires or
+// the user previews again.
+func (s *Service) Preview(ctx context.Context, userID string, cfg clustering.TagClusterConfig) (*Preview, error) {
+	detection, err := s.detect(ctx, userID, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &Preview{
+		ID:          uuid.NewString(),
+		Config:      cfg,
+		Eras:        detection.eras,
+		Outliers:    detection.outliers,
+		TotalTracks: detection.totalTracks,
+	}
+	s.previews.put(userID, preview)
+	return preview, nil
+}
+
+// ApplyPreview replaces the user's eras with a preview's eras.
+// Returns ErrPreviewNotFound if the preview can no longer be applied.
+func (s *Service) ApplyPreview(ctx context.Context, userID, previewID string) (*DetectResult, error) {
+	preview, ok := s.previews.take(userID, previewID)
+	if !ok {
+		return nil, ErrPreviewNotFound
+	}
+
+	result, err := s.persist(ctx, userID, preview.Eras)
+	if err != nil {
+		return nil, err
+	}
+	result.OutlierCount = len(preview.Outliers)
+	result.TotalTracks = preview.TotalTracks
+	return result, nil
+}
+
+// previewCache holds each user's latest preview in memory.
+type previewCache struct {
+	mu     sync.Mutex
+	byUser map[string]*Preview
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// newPreviewCache creates a cache whose previews expire after ttl.
+func newPreviewCache(ttl time.Duration) *previewCache {
+	return &previewCache{
+		byUser: make(map[string]*Preview),
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// put stores a user's preview, replacing any previous one, and drops
+// expired previews of other users.
+func (c *previewCache) put(userID string, preview *Preview) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	preview.CreatedAt = now
+	for id, p := range c.byUser {
+		if now.Sub(p.CreatedAt) > c.ttl {
+			delete(c.byUser, id)
+		}
+	}
+	c.byUser[userID] = preview
+}
+
+// take removes and returns the user's preview if it has the given ID and
+// hasn't expired.
+func (c *previewCache) take(userID, previewID string) (*Preview, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	preview, ok := c.byUser[userID]
+	if !ok || preview.ID != previewID {
+		return nil, false
+	}
+	delete(c.byUser, userID)
+	if c.now().Sub(preview.CreatedAt) > c.ttl {
+		return nil, false
+	}
+	return preview, true
+}
@@ -0,0 +1,51 @@
+package eras
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPreviewCache_Take(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	cache := newPreviewCache(time.Hour)
+	cache.now = func() time.Time { return now }
+
+	cache.put("user1", &Preview{ID: "p1"})
+
+	if _, ok := cache.take("user2", "p1"); ok {
+		t.Error("take() returned another user's preview")
+	}
+	if _, ok := cache.take("user1", "other"); ok {
+		t.Error("take() returned a preview for the wrong ID")
+	}
+	if p, ok := cache.take("user1", "p1"); !ok || p.ID != "p1" {
+		t.Fatalf("take() = %v, %v, want p1", p, ok)
+	}
+	if _, ok := cache.take("user1", "p1"); ok {
+		t.Error("take() returned a preview twice")
+	}
+}
+
+func TestPreviewCache_ReplacedAndExpired(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	cache := newPreviewCache(time.Hour)
+	cache.now = func() time.Time { return now }
+
+	cache.put("user1", &Preview{ID: "old"})
+	cache.put("user1", &Preview{ID: "new"})
+	if _, ok := cache.take("user1", "old"); ok {
+		t.Error("take() returned a replaced preview")
+	}
+
+	cache.put("user2", &Preview{ID: "p2"})
+	now = now.Add(2 * time.Hour)
+	if _, ok := cache.take("user2", "p2"); ok {
+		t.Error("take() returned an expired preview")
+	}
+
+	// Putting a preview drops other users' expired previews
+	cache.put("user3", &Preview{ID: "p3"})
+	if _, ok := cache.byUser["user1"]; ok {
+		t.Error("expired preview for user1 was not dropped")
+	}
+}
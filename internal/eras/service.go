@@ -3,35 +3,124 @@ package eras
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 
 	"github.com/justestif/go-spotify-era-organizer/internal/clustering"
 	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/spotify"
 )
 
+// ErrAlreadyPublished is returned when publishing an era that already has a playlist.
+var ErrAlreadyPublished = errors.New("era already published as a playlist")
+
+// ErrNameTooLong is returned when renaming an era to more than
+// MaxEraNameLength characters.
+var ErrNameTooLong = fmt.Errorf("era name must be at most %d characters", MaxEraNameLength)
+
+// Store provides the repositories the service reads and writes. It is
+// implemented by *db.DB and, in tests, by dbtest.Store.
+type Store interface {
+	Tracks() db.TrackRepository
+	Tags() db.TagRepository
+	Plays() db.PlayRepository
+	Eras() db.EraRepository
+	Settings() db.SettingsRepository
+}
+
 // Service handles era detection and persistence.
 type Service struct {
-	db *db.DB
+	db       Store
+	owned    EraStore // Eras looked up by ID, scoped to their owner
+	previews *previewCache
+	defaults clustering.TagClusterConfig
+}
+
+// Option configures a Service.
+type Option func(*Service)
+
+// WithDefaults sets the clustering configuration that users' settings
+// override, in place of clustering.DefaultTagClusterConfig.
+func WithDefaults(cfg clustering.TagClusterConfig) Option {
+	return func(s *Service) {
+		s.defaults = cfg
+	}
 }
 
 // New creates a new era service.
-func New(database *db.DB) *Service {
-	return &Service{db: database}
+func New(database Store, opts ...Option) *Service {
+	return NewWithEraStore(database, database.Eras(), opts...)
+}
+
+// NewWithEraStore creates an era service that looks up eras by ID in store
+// rather than the database.
+func NewWithEraStore(database Store, store EraStore, opts ...Option) *Service {
+	s := &Service{
+		db:       database,
+		owned:    store,
+		previews: newPreviewCache(PreviewTTL),
+		defaults: clustering.DefaultTagClusterConfig(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Defaults returns the clustering configuration before users' settings.
+func (s *Service) Defaults() clustering.TagClusterConfig {
+	return s.defaults
 }
 
 // DetectResult contains the outcome of era detection.
 type DetectResult struct {
-	Eras         []db.Era // Detected and persisted eras
+	Eras         []db.Era // Detected and persisted top-level eras
+	SubEras      []db.Era // Their sub-eras at every depth, each after its parent
 	OutlierCount int      // Number of tracks that didn't fit any era
 	TotalTracks  int      // Total tracks analyzed
+
+	// Changes reports how the eras relate to the previous ones: one entry
+	// per saved era, level by level, plus one per previous era that's gone.
+	// Nil if the user had no eras before.
+	Changes []EraChange
 }
 
 // DetectAndPersist runs era detection on a user's tracks and saves results.
-// This deletes any existing eras for the user before saving new ones.
-// Returns an error if the user has no tracks.
+// This replaces any existing eras for the user; see persist for how eras
+// keep their identity.
+// Returns an empty result if the user has no tracks.
 func (s *Service) DetectAndPersist(ctx context.Context, userID string, cfg clustering.TagClusterConfig) (*DetectResult, error) {
+	detection, err := s.detect(ctx, userID, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if detection.totalTracks == 0 {
+		return &DetectResult{}, nil
+	}
+
+	result, err := s.persist(ctx, userID, detection.eras)
+	if err != nil {
+		return nil, err
+	}
+	result.OutlierCount = len(detection.outliers)
+	result.TotalTracks = detection.totalTracks
+	return result, nil
+}
+
+// detection is the unsaved output of era detection.
+type detection struct {
+	eras        []clustering.MoodEra
+	outliers    []clustering.Track
+	totalTracks int
+}
+
+// detect runs era detection on a user's stored tracks without saving.
+func (s *Service) detect(ctx context.Context, userID string, cfg clustering.TagClusterConfig) (*detection, error) {
 	// Load user's tracks with added_at timestamps
 	userTracks, tracks, err := s.db.Tracks().GetUserTracksWithAddedAt(ctx, userID)
 	if err != nil {
@@ -39,11 +128,7 @@ func (s *Service) DetectAndPersist(ctx context.Context, userID string, cfg clust
 	}
 
 	if len(tracks) == 0 {
-		return &DetectResult{
-			Eras:         nil,
-			OutlierCount: 0,
-			TotalTracks:  0,
-		}, nil
+		return &detection{}, nil
 	}
 
 	// Build track ID list and addedAt map
@@ -60,40 +145,138 @@ func (s *Service) DetectAndPersist(ctx context.Context, userID string, cfg clust
 		return nil, fmt.Errorf("loading track tags: %w", err)
 	}
 
+	// Load the user's manual tags, which are weighted above Last.fm tags
+	userTagsMap, err := s.db.Tags().GetUserTagsForTracks(ctx, userID, trackIDs)
+	if err != nil {
+		return nil, fmt.Errorf("loading user tags: %w", err)
+	}
+
+	// Load listening history when eras are built from plays
+	var playsMap map[string][]time.Time
+	if cfg.Basis == clustering.BasisPlays {
+		playsMap, err = s.db.Plays().GetPlayTimesForUser(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("loading plays: %w", err)
+		}
+	}
+
 	// Convert to clustering.Track format
 	clusteringTracks := make([]clustering.Track, len(tracks))
 	for i, t := range tracks {
 		ut := addedAtMap[t.ID]
-		tags := tagsMap[t.ID]
-		clusteringTracks[i] = toClusteringTrack(t, ut, tags)
+		clusteringTracks[i] = toClusteringTrack(t, ut, tagsMap[t.ID], userTagsMap[t.ID])
+		clusteringTracks[i].Plays = playsMap[t.ID]
 	}
 
 	// Run era detection algorithm
 	moodEras, outliers := clustering.DetectMoodEras(clusteringTracks, cfg)
 
-	// Delete existing eras for user (fresh detection each time)
-	if err := s.db.Eras().DeleteForUser(ctx, userID); err != nil {
-		return nil, fmt.Errorf("deleting existing eras: %w", err)
+	return &detection{
+		eras:        moodEras,
+		outliers:    outliers,
+		totalTracks: len(tracks),
+	}, nil
+}
+
+// persist replaces the user's eras with the detected ones. New eras that
+// match a previous era take over its ID, name override and playlist, so
+// links and edits survive re-analysis. The old eras are deleted and the new
+// ones created in one transaction, so a failure keeps the previous eras.
+// The result holds the top-level eras, their sub-eras at every depth and
+// what changed since the previous eras.
+func (s *Service) persist(ctx context.Context, userID string, moodEras []clustering.MoodEra) (*DetectResult, error) {
+	previous, err := s.db.Eras().GetForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("loading previous eras: %w", err)
+	}
+	trackIDs, err := s.db.Eras().GetTrackIDsForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("loading previous era tracks: %w", err)
+	}
+	ids := matchIdentities(previous, trackIDs, moodEras)
+
+	plan := &eraPlan{index: make(map[*clustering.MoodEra]int)}
+	plan.add(userID, moodEras, nil, ids.previous)
+	if err := s.db.Eras().ReplaceForUser(ctx, userID, plan.eras, plan.tracks); err != nil {
+		return nil, fmt.Errorf("saving eras: %w", err)
+	}
+
+	result := &DetectResult{}
+	result.Eras = plan.collect(moodEras, &result.SubEras)
+	created := make(map[*clustering.MoodEra]db.Era, len(plan.index))
+	for moodEra, i := range plan.index {
+		created[moodEra] = plan.eras[i]
+	}
+	result.Changes = ids.report(created, len(previous) > 0)
+	return result, nil
+}
+
+// eraPlan is the eras to save for detected mood eras, parents before their
+// sub-eras, with the tracks of each.
+type eraPlan struct {
+	eras   []db.Era
+	tracks [][]db.EraTrack
+	index  map[*clustering.MoodEra]int // Position of each mood era's era
+}
+
+// add plans eras under parentID (nil for top-level eras), each followed by
+// its sub-eras. Eras in previous take over the previous era's identity;
+// the others get a new ID so their sub-eras can refer to them.
+func (p *eraPlan) add(userID string, moodEras []clustering.MoodEra, parentID *uuid.UUID, previous map[*clustering.MoodEra]db.Era) {
+	for i := range moodEras {
+		moodEra := &moodEras[i]
+		dbEra, eraTracks := toDBEra(*moodEra, userID)
+		dbEra.ID = uuid.New()
+		dbEra.ParentID = parentID
+		if prev, ok := previous[moodEra]; ok {
+			dbEra.ID = prev.ID
+			dbEra.NameOverride = prev.NameOverride
+			dbEra.PlaylistID = prev.PlaylistID
+		}
+		p.index[moodEra] = len(p.eras)
+		p.eras = append(p.eras, dbEra)
+		p.tracks = append(p.tracks, eraTracks)
+		p.add(userID, moodEra.SubEras, &dbEra.ID, previous)
 	}
+}
 
-	// Persist new eras
-	persistedEras := make([]db.Era, 0, len(moodEras))
-	for _, moodEra := range moodEras {
-		dbEra, eraTrackIDs := toDBEra(moodEra, userID)
-		if err := s.db.Eras().Create(ctx, &dbEra, eraTrackIDs); err != nil {
-			return nil, fmt.Errorf("creating era %q: %w", dbEra.Name, err)
+// collect returns the saved eras of moodEras and appends their sub-eras at
+// every depth to subEras.
+func (p *eraPlan) collect(moodEras []clustering.MoodEra, subEras *[]db.Era) []db.Era {
+	result := make([]db.Era, 0, len(moodEras))
+	for i := range moodEras {
+		result = append(result, p.eras[p.index[&moodEras[i]]])
+		if len(moodEras[i].SubEras) > 0 {
+			*subEras = append(*subEras, p.collect(moodEras[i].SubEras, subEras)...)
 		}
-		persistedEras = append(persistedEras, dbEra)
 	}
+	return result
+}
 
-	return &DetectResult{
-		Eras:         persistedEras,
-		OutlierCount: len(outliers),
-		TotalTracks:  len(tracks),
-	}, nil
+// DefaultConfig returns the clustering configuration for a user: the
+// service's defaults overridden by the user's stored settings. Unless the user
+// chose a basis, eras are built from listening history when the user has
+// plays matched to their library, and from like dates otherwise.
+func (s *Service) DefaultConfig(ctx context.Context, userID string) (clustering.TagClusterConfig, error) {
+	cfg := s.defaults
+	plays, err := s.db.Plays().CountMatchedForUser(ctx, userID)
+	if err != nil {
+		return cfg, fmt.Errorf("counting plays: %w", err)
+	}
+	if plays > 0 {
+		cfg.Basis = clustering.BasisPlays
+	}
+
+	settings, err := s.GetSettings(ctx, userID)
+	if err != nil {
+		return cfg, err
+	}
+	settings.Apply(&cfg)
+	return cfg, nil
 }
 
-// GetUserEras retrieves all persisted eras for a user.
+// GetUserEras retrieves all persisted eras for a user, including sub-eras.
+// Parents come before their sub-eras.
 func (s *Service) GetUserEras(ctx context.Context, userID string) ([]db.Era, error) {
 	eras, err := s.db.Eras().GetForUser(ctx, userID)
 	if err != nil {
@@ -102,27 +285,94 @@ func (s *Service) GetUserEras(ctx context.Context, userID string) ([]db.Era, err
 	return eras, nil
 }
 
-// GetEraTracks retrieves all tracks for a specific era.
-func (s *Service) GetEraTracks(ctx context.Context, eraID string) ([]db.Track, error) {
-	id, err := uuid.Parse(eraID)
+// PublishPlaylist creates a Spotify playlist with an era's tracks and records
+// its ID on the era. Returns ErrAlreadyPublished if the era has a playlist.
+func (s *Service) PublishPlaylist(ctx context.Context, client spotify.LibraryClient, userID, eraID string, public bool) (*db.Era, error) {
+	era, err := s.GetEra(ctx, userID, eraID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid era ID: %w", err)
+		return nil, err
+	}
+	if era.PlaylistID != nil {
+		return era, ErrAlreadyPublished
 	}
-	tracks, err := s.db.Eras().GetTracks(ctx, id)
+
+	tracks, err := s.owned.GetTracksForOwner(ctx, userID, era.ID)
 	if err != nil {
 		return nil, fmt.Errorf("getting era tracks: %w", err)
 	}
-	return tracks, nil
+	trackIDs := make([]string, len(tracks))
+	for i, t := range tracks {
+		trackIDs[i] = t.ID
+	}
+
+	playlistID, err := client.CreatePlaylist(ctx, era.DisplayName(), playlistDescription(era), public)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.AddTracksToPlaylist(ctx, playlistID, trackIDs); err != nil {
+		return nil, err
+	}
+
+	if err := s.owned.UpdatePlaylistIDForOwner(ctx, userID, era.ID, playlistID); err != nil {
+		return nil, fmt.Errorf("saving playlist ID: %w", err)
+	}
+	era.PlaylistID = &playlistID
+	return era, nil
+}
+
+// MaxEraNameLength is the longest name a user can give an era.
+const MaxEraNameLength = 100
+
+// RenameEra sets the name the user gave an era, which replaces the generated
+// name and survives re-analysis while the era is matched. An empty name
+// restores the generated name. Returns db.ErrNotFound if the era doesn't
+// exist or belongs to another user.
+func (s *Service) RenameEra(ctx context.Context, userID, eraID, name string) (*db.Era, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > MaxEraNameLength {
+		return nil, ErrNameTooLong
+	}
+	era, err := s.GetEra(ctx, userID, eraID)
+	if err != nil {
+		return nil, err
+	}
+
+	var override *string
+	if name != "" {
+		override = &name
+	}
+	if err := s.owned.UpdateNameOverrideForOwner(ctx, userID, era.ID, override); err != nil {
+		return nil, fmt.Errorf("renaming era: %w", err)
+	}
+	era.NameOverride = override
+	return era, nil
+}
+
+// playlistDescription describes an era for its Spotify playlist.
+func playlistDescription(era *db.Era) string {
+	const dateFormat = "Jan 2, 2006"
+	desc := fmt.Sprintf("Listening era from %s to %s", era.StartDate.Format(dateFormat), era.EndDate.Format(dateFormat))
+	if len(era.TopTags) > 0 {
+		desc += ": " + strings.Join(era.TopTags, ", ")
+	}
+	return desc + ". Created by Spotify Era Organizer."
 }
 
 // toClusteringTrack converts database types to a clustering.Track.
-func toClusteringTrack(track db.Track, userTrack db.UserTrack, tags []db.TrackTag) clustering.Track {
-	clusterTags := make([]clustering.Tag, len(tags))
-	for i, t := range tags {
-		clusterTags[i] = clustering.Tag{
+// Manual user tags are appended after the Last.fm tags.
+func toClusteringTrack(track db.Track, userTrack db.UserTrack, tags []db.TrackTag, userTags []db.UserTrackTag) clustering.Track {
+	clusterTags := make([]clustering.Tag, 0, len(tags)+len(userTags))
+	for _, t := range tags {
+		clusterTags = append(clusterTags, clustering.Tag{
 			Name:  t.TagName,
 			Count: t.TagCount,
-		}
+		})
+	}
+	for _, t := range userTags {
+		clusterTags = append(clusterTags, clustering.Tag{
+			Name:   t.TagName,
+			Manual: true,
+		})
 	}
 	return clustering.Track{
 		ID:      track.ID,
@@ -133,17 +383,27 @@ func toClusteringTrack(track db.Track, userTrack db.UserTrack, tags []db.TrackTa
 	}
 }
 
-// toDBEra converts a clustering.MoodEra to a db.Era and track IDs.
-func toDBEra(era clustering.MoodEra, userID string) (db.Era, []string) {
-	trackIDs := make([]string, len(era.Tracks))
+// toDBEra converts a clustering.MoodEra to a db.Era and its tracks.
+func toDBEra(era clustering.MoodEra, userID string) (db.Era, []db.EraTrack) {
+	tracks := make([]db.EraTrack, len(era.Tracks))
 	for i, t := range era.Tracks {
-		trackIDs[i] = t.ID
+		tracks[i] = db.EraTrack{TrackID: t.ID, ReasonTags: era.Reasons[t.ID]}
 	}
+
+	weights := make([]db.EraTagWeight, len(era.Metrics.TagWeights))
+	for i, w := range era.Metrics.TagWeights {
+		weights[i] = db.EraTagWeight{Name: w.Name, Weight: w.Weight}
+	}
+	cohesion, silhouette := era.Metrics.Cohesion, era.Metrics.Silhouette
+
 	return db.Era{
-		UserID:    userID,
-		Name:      era.Name,
-		TopTags:   era.TopTags,
-		StartDate: era.StartDate,
-		EndDate:   era.EndDate,
-	}, trackIDs
+		UserID:     userID,
+		Name:       era.Name,
+		TopTags:    era.TopTags,
+		StartDate:  era.StartDate,
+		EndDate:    era.EndDate,
+		Cohesion:   &cohesion,
+		Silhouette: &silhouette,
+		TagWeights: weights,
+	}, tracks
 }
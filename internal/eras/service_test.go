@@ -0,0 +1,386 @@
+package eras
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/clustering"
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/db/dbtest"
+)
+
+// seedStore is a Store that can also create users: a dbtest.Store or a
+// *db.DB.
+type seedStore interface {
+	Store
+	Users() db.UserRepository
+}
+
+// seedLibrary creates the user and likes three tracks tagged with each tag,
+// one a day from start. Track IDs are the tag followed by 1, 2 and 3.
+func seedLibrary(t *testing.T, store seedStore, userID string, start time.Time, tags ...string) {
+	t.Helper()
+	ctx := context.Background()
+	if err := store.Users().EnsureExists(ctx, userID); err != nil {
+		t.Fatal(err)
+	}
+
+	var tracks []db.Track
+	var liked []db.UserTrack
+	var trackTags []db.TrackTag
+	for _, tag := range tags {
+		for i := 1; i <= 3; i++ {
+			id := fmt.Sprintf("%s%d", tag, i)
+			tracks = append(tracks, db.Track{ID: id, Name: "Song " + id, Artist: "Artist " + tag})
+			liked = append(liked, db.UserTrack{UserID: userID, TrackID: id, AddedAt: start.AddDate(0, 0, len(liked))})
+			trackTags = append(trackTags, db.TrackTag{TrackID: id, TagName: tag, TagCount: 100 - i, Source: "track"})
+		}
+		start = start.AddDate(0, 6, 0)
+	}
+	if err := store.Tracks().UpsertBatch(ctx, tracks); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Tracks().LinkBatchToUser(ctx, userID, liked); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Tags().UpsertBatch(ctx, trackTags); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// twoEraConfig clusters the tracks from seedLibrary into one era per tag.
+func twoEraConfig() clustering.TagClusterConfig {
+	cfg := clustering.DefaultTagClusterConfig()
+	cfg.NumClusters = 2
+	return cfg
+}
+
+var seedStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestService_DetectAndPersist(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.NewStore()
+	seedLibrary(t, store, "alice", seedStart, "jazz", "rock")
+	s := New(store)
+
+	result, err := s.DetectAndPersist(ctx, "alice", twoEraConfig())
+	if err != nil {
+		t.Fatalf("DetectAndPersist() error = %v", err)
+	}
+	if len(result.Eras) != 2 || result.TotalTracks != 6 || result.OutlierCount != 0 {
+		t.Fatalf("DetectAndPersist() = %d eras, %d tracks, %d outliers, want 2, 6, 0",
+			len(result.Eras), result.TotalTracks, result.OutlierCount)
+	}
+	if result.Changes != nil {
+		t.Errorf("Changes = %v, want nil on the first run", result.Changes)
+	}
+
+	saved, err := s.GetUserEras(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUserEras() error = %v", err)
+	}
+	if len(saved) != 2 {
+		t.Fatalf("GetUserEras() returned %d eras, want 2", len(saved))
+	}
+	// Newest first: the rock tracks were liked six months after the jazz ones
+	for i, tag := range []string{"rock", "jazz"} {
+		era := saved[i]
+		if era.ID != result.Eras[i].ID {
+			t.Errorf("era %d ID = %s, want %s", i, era.ID, result.Eras[i].ID)
+		}
+		if len(era.TopTags) == 0 || era.TopTags[0] != tag {
+			t.Errorf("era %d top tags = %v, want %s first", i, era.TopTags, tag)
+		}
+
+		tracks, err := s.GetEraTracks(ctx, "alice", era.ID.String())
+		if err != nil {
+			t.Fatalf("GetEraTracks() error = %v", err)
+		}
+		if len(tracks) != 3 {
+			t.Fatalf("era %d has %d tracks, want 3", i, len(tracks))
+		}
+		for _, track := range tracks {
+			if track.Artist != "Artist "+tag {
+				t.Errorf("era %d contains %s by %s, want only %s tracks", i, track.ID, track.Artist, tag)
+			}
+		}
+
+		reasons, err := s.GetEraTrackReasons(ctx, "alice", era.ID.String())
+		if err != nil {
+			t.Fatalf("GetEraTrackReasons() error = %v", err)
+		}
+		if got := reasons[tag+"1"]; len(got) == 0 || got[0] != tag {
+			t.Errorf("reasons for %s1 = %v, want %s", tag, got, tag)
+		}
+	}
+}
+
+// TestService_DetectAndPersist_FirstAnalysis runs a user's first analysis
+// against SQLite. With no stored eras every era is new, and reporting them
+// once indexed a nil Changes slice and panicked.
+func TestService_DetectAndPersist_FirstAnalysis(t *testing.T) {
+	ctx := context.Background()
+	database := dbtest.NewSQLite(t)
+	seedLibrary(t, database, "alice", seedStart, "jazz", "rock")
+	s := New(database)
+
+	result, err := s.DetectAndPersist(ctx, "alice", twoEraConfig())
+	if err != nil {
+		t.Fatalf("DetectAndPersist() error = %v", err)
+	}
+	if len(result.Eras) != 2 || result.Changes != nil {
+		t.Fatalf("DetectAndPersist() = %d eras, changes %v, want 2 eras and no changes", len(result.Eras), result.Changes)
+	}
+	saved, err := s.GetUserEras(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 {
+		t.Errorf("GetUserEras() returned %d eras, want 2", len(saved))
+	}
+}
+
+func TestService_DetectAndPersist_KeepsIdentity(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.NewStore()
+	seedLibrary(t, store, "alice", seedStart, "jazz", "rock")
+	s := New(store)
+
+	first, err := s.DetectAndPersist(ctx, "alice", twoEraConfig())
+	if err != nil {
+		t.Fatalf("DetectAndPersist() error = %v", err)
+	}
+	renamed := first.Eras[0]
+	if _, err := s.RenameEra(ctx, "alice", renamed.ID.String(), "  My rock phase  "); err != nil {
+		t.Fatalf("RenameEra() error = %v", err)
+	}
+	if err := store.Eras().UpdatePlaylistIDForOwner(ctx, "alice", renamed.ID, "playlist1"); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.DetectAndPersist(ctx, "alice", twoEraConfig())
+	if err != nil {
+		t.Fatalf("second DetectAndPersist() error = %v", err)
+	}
+	if len(second.Changes) != 2 {
+		t.Fatalf("Changes = %v, want one per era", second.Changes)
+	}
+	for _, change := range second.Changes {
+		if change.Status != StatusUnchanged {
+			t.Errorf("era %s status = %s, want %s", change.EraID, change.Status, StatusUnchanged)
+		}
+	}
+
+	era, err := s.GetEra(ctx, "alice", renamed.ID.String())
+	if err != nil {
+		t.Fatalf("GetEra() after re-analysis error = %v", err)
+	}
+	if era.DisplayName() != "My rock phase" {
+		t.Errorf("DisplayName() = %q, want the name the user gave", era.DisplayName())
+	}
+	if era.PlaylistID == nil || *era.PlaylistID != "playlist1" {
+		t.Errorf("PlaylistID = %v, want playlist1", era.PlaylistID)
+	}
+}
+
+func TestService_DetectAndPersist_OtherUsers(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.NewStore()
+	seedLibrary(t, store, "alice", seedStart, "jazz", "rock")
+	seedLibrary(t, store, "bob", seedStart, "jazz", "rock")
+	s := New(store)
+
+	bobs, err := s.DetectAndPersist(ctx, "bob", twoEraConfig())
+	if err != nil {
+		t.Fatalf("DetectAndPersist(bob) error = %v", err)
+	}
+	if _, err := s.DetectAndPersist(ctx, "alice", twoEraConfig()); err != nil {
+		t.Fatalf("DetectAndPersist(alice) error = %v", err)
+	}
+
+	for _, era := range bobs.Eras {
+		if _, err := s.GetEra(ctx, "bob", era.ID.String()); err != nil {
+			t.Errorf("bob's era %s after alice's analysis: %v", era.ID, err)
+		}
+		if _, err := s.GetEra(ctx, "alice", era.ID.String()); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("GetEra(alice, bob's era) error = %v, want ErrNotFound", err)
+		}
+	}
+}
+
+func TestService_DetectAndPersist_NoTracks(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.NewStore()
+	if err := store.Users().EnsureExists(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	s := New(store)
+
+	result, err := s.DetectAndPersist(ctx, "alice", twoEraConfig())
+	if err != nil {
+		t.Fatalf("DetectAndPersist() error = %v", err)
+	}
+	if len(result.Eras) != 0 || result.TotalTracks != 0 {
+		t.Errorf("DetectAndPersist() = %d eras of %d tracks, want none", len(result.Eras), result.TotalTracks)
+	}
+}
+
+func TestService_PreviewAndApply(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.NewStore()
+	seedLibrary(t, store, "alice", seedStart, "jazz", "rock")
+	s := New(store)
+
+	preview, err := s.Preview(ctx, "alice", twoEraConfig())
+	if err != nil {
+		t.Fatalf("Preview() error = %v", err)
+	}
+	if len(preview.Eras) != 2 || preview.TotalTracks != 6 {
+		t.Fatalf("Preview() = %d eras of %d tracks, want 2 of 6", len(preview.Eras), preview.TotalTracks)
+	}
+	if saved, _ := s.GetUserEras(ctx, "alice"); len(saved) != 0 {
+		t.Fatalf("Preview() saved %d eras, want none", len(saved))
+	}
+
+	result, err := s.ApplyPreview(ctx, "alice", preview.ID)
+	if err != nil {
+		t.Fatalf("ApplyPreview() error = %v", err)
+	}
+	saved, err := s.GetUserEras(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUserEras() error = %v", err)
+	}
+	if len(result.Eras) != 2 || len(saved) != 2 {
+		t.Errorf("ApplyPreview() saved %d eras, result has %d, want 2", len(saved), len(result.Eras))
+	}
+
+	if _, err := s.ApplyPreview(ctx, "alice", preview.ID); !errors.Is(err, ErrPreviewNotFound) {
+		t.Errorf("second ApplyPreview() error = %v, want ErrPreviewNotFound", err)
+	}
+}
+
+func TestService_DefaultConfig(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.NewStore()
+	seedLibrary(t, store, "alice", seedStart, "jazz")
+	defaults := clustering.DefaultTagClusterConfig()
+	defaults.MaxTags = 20
+	s := New(store, WithDefaults(defaults))
+
+	cfg, err := s.DefaultConfig(ctx, "alice")
+	if err != nil {
+		t.Fatalf("DefaultConfig() error = %v", err)
+	}
+	if cfg.Basis != clustering.BasisLikes || cfg.MaxTags != 20 {
+		t.Errorf("DefaultConfig() without plays = basis %s, %d tags, want likes, 20", cfg.Basis, cfg.MaxTags)
+	}
+
+	// Plays matched to the library switch eras to listening history
+	plays := []db.Play{{ArtistName: "artist JAZZ", TrackName: "song jazz1", PlayedAt: seedStart.AddDate(0, 1, 0), Source: db.PlaySourceLastFM}}
+	if _, err := store.Plays().InsertBatch(ctx, "alice", plays); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.Plays().MatchTracks(ctx, "alice"); err != nil || n != 1 {
+		t.Fatalf("MatchTracks() = %d, %v, want 1", n, err)
+	}
+	cfg, err = s.DefaultConfig(ctx, "alice")
+	if err != nil {
+		t.Fatalf("DefaultConfig() error = %v", err)
+	}
+	if cfg.Basis != clustering.BasisPlays {
+		t.Errorf("DefaultConfig() with plays basis = %s, want plays", cfg.Basis)
+	}
+
+	// Settings override both the defaults and the automatic basis
+	basis := clustering.BasisLikes
+	numClusters := 7
+	if err := s.SaveSettings(ctx, "alice", Settings{Basis: &basis, NumClusters: &numClusters}); err != nil {
+		t.Fatalf("SaveSettings() error = %v", err)
+	}
+	cfg, err = s.DefaultConfig(ctx, "alice")
+	if err != nil {
+		t.Fatalf("DefaultConfig() error = %v", err)
+	}
+	if cfg.Basis != clustering.BasisLikes || cfg.NumClusters != 7 || cfg.MaxTags != 20 {
+		t.Errorf("DefaultConfig() with settings = basis %s, %d clusters, %d tags, want likes, 7, 20",
+			cfg.Basis, cfg.NumClusters, cfg.MaxTags)
+	}
+}
+
+func TestService_Settings(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.NewStore()
+	if err := store.Users().EnsureExists(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	s := New(store)
+
+	settings, err := s.GetSettings(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetSettings() error = %v", err)
+	}
+	if settings != (Settings{}) {
+		t.Errorf("GetSettings() before saving = %+v, want empty", settings)
+	}
+
+	tooMany := MaxNumClusters + 1
+	var invalid ValidationError
+	if err := s.SaveSettings(ctx, "alice", Settings{NumClusters: &tooMany}); !errors.As(err, &invalid) {
+		t.Fatalf("SaveSettings() error = %v, want ValidationError", err)
+	}
+
+	depth, interval := 2, 0
+	basis := clustering.BasisPlays
+	if err := s.SaveSettings(ctx, "alice", Settings{SubEraDepth: &depth, SyncIntervalHours: &interval, Basis: &basis}); err != nil {
+		t.Fatalf("SaveSettings() error = %v", err)
+	}
+	settings, err = s.GetSettings(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetSettings() error = %v", err)
+	}
+	if settings.SubEraDepth == nil || *settings.SubEraDepth != 2 ||
+		settings.SyncIntervalHours == nil || *settings.SyncIntervalHours != 0 ||
+		settings.Basis == nil || *settings.Basis != clustering.BasisPlays || settings.NumClusters != nil {
+		t.Errorf("GetSettings() = %+v, want the saved depth, interval and basis only", settings)
+	}
+}
+
+func TestService_RenameEra(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.NewStore()
+	seedLibrary(t, store, "alice", seedStart, "jazz")
+	era := db.Era{UserID: "alice", Name: "Generated", StartDate: seedStart, EndDate: seedStart}
+	if err := store.Eras().Create(ctx, &era, []string{"jazz1"}); err != nil {
+		t.Fatal(err)
+	}
+	s := New(store)
+
+	long := make([]rune, MaxEraNameLength+1)
+	for i := range long {
+		long[i] = 'é'
+	}
+	if _, err := s.RenameEra(ctx, "alice", era.ID.String(), string(long)); !errors.Is(err, ErrNameTooLong) {
+		t.Errorf("RenameEra() with a long name error = %v, want ErrNameTooLong", err)
+	}
+	if _, err := s.RenameEra(ctx, "alice", uuid.NewString(), "Mine"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("RenameEra() of a missing era error = %v, want ErrNotFound", err)
+	}
+
+	renamed, err := s.RenameEra(ctx, "alice", era.ID.String(), "Mine")
+	if err != nil || renamed.DisplayName() != "Mine" {
+		t.Fatalf("RenameEra() = %v, %v, want Mine", renamed, err)
+	}
+	restored, err := s.RenameEra(ctx, "alice", era.ID.String(), " ")
+	if err != nil || restored.DisplayName() != "Generated" {
+		t.Fatalf("RenameEra() with a blank name = %v, %v, want the generated name", restored, err)
+	}
+	stored, err := s.GetEra(ctx, "alice", era.ID.String())
+	if err != nil || stored.NameOverride != nil {
+		t.Errorf("stored era = %+v, %v, want no name override", stored, err)
+	}
+}
@@ -0,0 +1,194 @@
+package eras
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/clustering"
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// Settings are a user's overrides of the default clustering configuration
+// and of the background sync schedule. Nil fields keep the default. The same
+// shape is used for stored settings and for per-request overrides in the
+// analyze API.
+type Settings struct {
+	NumClusters       *int              `json:"num_clusters,omitempty"`
+	MinClusterSize    *int              `json:"min_cluster_size,omitempty"`
+	MaxTags           *int              `json:"max_tags,omitempty"`
+	ManualTagWeight   *float64          `json:"manual_tag_weight,omitempty"`
+	Basis             *clustering.Basis `json:"basis,omitempty"`
+	TimeWeight        *float64          `json:"time_weight,omitempty"`
+	NameTemplate      *string           `json:"name_template,omitempty"`
+	SubEraDepth       *int              `json:"sub_era_depth,omitempty"`
+	SyncIntervalHours *int              `json:"sync_interval_hours,omitempty"` // Hours between scheduled syncs; 0 for none
+}
+
+// Settings limits. They keep clustering fast and its output meaningful.
+const (
+	MinNumClusters    = 1
+	MaxNumClusters    = 20
+	MinMinClusterSize = 1
+	MaxMinClusterSize = 100
+	MinMaxTags        = 5
+	MaxMaxTags        = 500
+	MaxWeight         = 10.0
+	MaxSubEraDepth    = 3
+	MaxSyncInterval   = 168 // Hours: a week
+)
+
+// Settings field names, as used in JSON and forms.
+const (
+	FieldNumClusters     = "num_clusters"
+	FieldMinClusterSize  = "min_cluster_size"
+	FieldMaxTags         = "max_tags"
+	FieldManualTagWeight = "manual_tag_weight"
+	FieldBasis           = "basis"
+	FieldTimeWeight      = "time_weight"
+	FieldNameTemplate    = "name_template"
+	FieldSubEraDepth     = "sub_era_depth"
+	FieldSyncInterval    = "sync_interval_hours"
+)
+
+// ValidationError maps settings field names to problems with their values.
+type ValidationError map[string]string
+
+func (e ValidationError) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, len(fields))
+	for i, field := range fields {
+		msgs[i] = field + ": " + e[field]
+	}
+	return "invalid settings: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks that each set field is within its limits.
+// Returns a ValidationError, or nil if the settings are valid.
+func (s Settings) Validate() error {
+	errs := make(ValidationError)
+	checkInt := func(field string, v *int, lo, hi int) {
+		if v != nil && (*v < lo || *v > hi) {
+			errs[field] = fmt.Sprintf("must be between %d and %d", lo, hi)
+		}
+	}
+	checkWeight := func(field string, v *float64) {
+		if v != nil && (*v < 0 || *v > MaxWeight) {
+			errs[field] = fmt.Sprintf("must be between 0 and %g", MaxWeight)
+		}
+	}
+
+	checkInt(FieldNumClusters, s.NumClusters, MinNumClusters, MaxNumClusters)
+	checkInt(FieldMinClusterSize, s.MinClusterSize, MinMinClusterSize, MaxMinClusterSize)
+	checkInt(FieldMaxTags, s.MaxTags, MinMaxTags, MaxMaxTags)
+	checkInt(FieldSubEraDepth, s.SubEraDepth, 0, MaxSubEraDepth)
+	checkInt(FieldSyncInterval, s.SyncIntervalHours, 0, MaxSyncInterval)
+	checkWeight(FieldManualTagWeight, s.ManualTagWeight)
+	checkWeight(FieldTimeWeight, s.TimeWeight)
+	if s.Basis != nil {
+		if _, ok := clustering.ParseBasis(string(*s.Basis)); !ok {
+			errs[FieldBasis] = `must be "likes" or "plays"`
+		}
+	}
+	if s.NameTemplate != nil {
+		if err := clustering.ValidateNameTemplate(*s.NameTemplate); err != nil {
+			errs[FieldNameTemplate] = err.Error()
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Apply overrides cfg with the clustering fields that are set.
+func (s Settings) Apply(cfg *clustering.TagClusterConfig) {
+	if s.NumClusters != nil {
+		cfg.NumClusters = *s.NumClusters
+	}
+	if s.MinClusterSize != nil {
+		cfg.MinClusterSize = *s.MinClusterSize
+	}
+	if s.MaxTags != nil {
+		cfg.MaxTags = *s.MaxTags
+	}
+	if s.ManualTagWeight != nil {
+		cfg.ManualTagWeight = *s.ManualTagWeight
+	}
+	if s.Basis != nil {
+		cfg.Basis = *s.Basis
+	}
+	if s.TimeWeight != nil {
+		cfg.TimeWeight = *s.TimeWeight
+	}
+	if s.NameTemplate != nil {
+		cfg.NameTemplate = *s.NameTemplate
+	}
+	if s.SubEraDepth != nil {
+		cfg.SubEraDepth = *s.SubEraDepth
+	}
+}
+
+// GetSettings returns the user's stored settings, or empty settings if they
+// have never saved any.
+func (s *Service) GetSettings(ctx context.Context, userID string) (Settings, error) {
+	stored, err := s.db.Settings().Get(ctx, userID)
+	if errors.Is(err, db.ErrNotFound) {
+		return Settings{}, nil
+	}
+	if err != nil {
+		return Settings{}, fmt.Errorf("getting settings: %w", err)
+	}
+
+	settings := Settings{
+		NumClusters:       stored.NumClusters,
+		MinClusterSize:    stored.MinClusterSize,
+		MaxTags:           stored.MaxTags,
+		ManualTagWeight:   stored.ManualTagWeight,
+		TimeWeight:        stored.TimeWeight,
+		NameTemplate:      stored.NameTemplate,
+		SubEraDepth:       stored.SubEraDepth,
+		SyncIntervalHours: stored.SyncIntervalHours,
+	}
+	if stored.Basis != nil {
+		basis := clustering.Basis(*stored.Basis)
+		settings.Basis = &basis
+	}
+	return settings, nil
+}
+
+// SaveSettings validates and stores the user's settings, replacing any
+// previous ones. Returns a ValidationError if a field is out of range.
+func (s *Service) SaveSettings(ctx context.Context, userID string, settings Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	stored := &db.UserSettings{
+		UserID:            userID,
+		NumClusters:       settings.NumClusters,
+		MinClusterSize:    settings.MinClusterSize,
+		MaxTags:           settings.MaxTags,
+		ManualTagWeight:   settings.ManualTagWeight,
+		TimeWeight:        settings.TimeWeight,
+		NameTemplate:      settings.NameTemplate,
+		SubEraDepth:       settings.SubEraDepth,
+		SyncIntervalHours: settings.SyncIntervalHours,
+	}
+	if settings.Basis != nil {
+		basis := string(*settings.Basis)
+		stored.Basis = &basis
+	}
+	if err := s.db.Settings().Upsert(ctx, stored); err != nil {
+		return fmt.Errorf("saving settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package eras
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/clustering"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestSettings_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		settings   Settings
+		wantFields []string
+	}{
+		{name: "empty", settings: Settings{}},
+		{
+			name: "all valid",
+			settings: Settings{
+				NumClusters:       ptr(MaxNumClusters),
+				MinClusterSize:    ptr(MinMinClusterSize),
+				MaxTags:           ptr(100),
+				ManualTagWeight:   ptr(0.0),
+				Basis:             ptr(clustering.BasisPlays),
+				TimeWeight:        ptr(MaxWeight),
+				NameTemplate:      ptr("{season} {year} – {tag1}"),
+				SubEraDepth:       ptr(MaxSubEraDepth),
+				SyncIntervalHours: ptr(0),
+			},
+		},
+		{
+			name:       "too many clusters",
+			settings:   Settings{NumClusters: ptr(MaxNumClusters + 1)},
+			wantFields: []string{FieldNumClusters},
+		},
+		{
+			name: "several invalid",
+			settings: Settings{
+				MinClusterSize:    ptr(0),
+				MaxTags:           ptr(MinMaxTags - 1),
+				TimeWeight:        ptr(-1.0),
+				Basis:             ptr(clustering.Basis("skips")),
+				NameTemplate:      ptr("{mood}"),
+				SubEraDepth:       ptr(MaxSubEraDepth + 1),
+				SyncIntervalHours: ptr(MaxSyncInterval + 1),
+			},
+			wantFields: []string{FieldMinClusterSize, FieldMaxTags, FieldTimeWeight, FieldBasis, FieldNameTemplate, FieldSubEraDepth, FieldSyncInterval},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.Validate()
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var verr ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate() error = %v, want ValidationError", err)
+			}
+			if len(verr) != len(tt.wantFields) {
+				t.Errorf("got errors for %v, want %v", verr, tt.wantFields)
+			}
+			for _, field := range tt.wantFields {
+				if verr[field] == "" {
+					t.Errorf("missing error for %s", field)
+				}
+				if !strings.Contains(err.Error(), field) {
+					t.Errorf("Error() = %q, want mention of %s", err.Error(), field)
+				}
+			}
+		})
+	}
+}
+
+func TestSettings_Apply(t *testing.T) {
+	cfg := clustering.DefaultTagClusterConfig()
+	Settings{
+		NumClusters: ptr(7),
+		Basis:       ptr(clustering.BasisPlays),
+	}.Apply(&cfg)
+
+	want := clustering.DefaultTagClusterConfig()
+	want.NumClusters = 7
+	want.Basis = clustering.BasisPlays
+	if cfg != want {
+		t.Errorf("Apply() = %+v, want %+v", cfg, want)
+	}
+
+	// Request overrides layer on top of stored settings
+	Settings{NumClusters: ptr(4)}.Apply(&cfg)
+	if cfg.NumClusters != 4 || cfg.Basis != clustering.BasisPlays {
+		t.Errorf("second Apply() = %+v, want NumClusters 4 and plays basis kept", cfg)
+	}
+}
@@ -0,0 +1,42 @@
+package eras
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// EraNode is an era with its sub-eras.
+type EraNode struct {
+	Era     db.Era
+	SubEras []EraNode
+}
+
+// BuildTree arranges eras into a tree by parent ID, keeping their order
+// among siblings. Eras whose parent isn't in the list are top-level.
+func BuildTree(list []db.Era) []EraNode {
+	known := make(map[uuid.UUID]bool, len(list))
+	for _, era := range list {
+		known[era.ID] = true
+	}
+
+	var roots []db.Era
+	children := make(map[uuid.UUID][]db.Era)
+	for _, era := range list {
+		if era.ParentID != nil && known[*era.ParentID] {
+			children[*era.ParentID] = append(children[*era.ParentID], era)
+		} else {
+			roots = append(roots, era)
+		}
+	}
+
+	var build func([]db.Era) []EraNode
+	build = func(level []db.Era) []EraNode {
+		nodes := make([]EraNode, len(level))
+		for i, era := range level {
+			nodes[i] = EraNode{Era: era, SubEras: build(children[era.ID])}
+		}
+		return nodes
+	}
+	return build(roots)
+}
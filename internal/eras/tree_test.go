@@ -0,0 +1,35 @@
+package eras
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+func TestBuildTree(t *testing.T) {
+	parent := db.Era{ID: uuid.New(), Name: "parent"}
+	other := db.Era{ID: uuid.New(), Name: "other"}
+	childA := db.Era{ID: uuid.New(), Name: "a", ParentID: &parent.ID}
+	childB := db.Era{ID: uuid.New(), Name: "b", ParentID: &parent.ID}
+	grandchild := db.Era{ID: uuid.New(), Name: "grandchild", ParentID: &childB.ID}
+	missing := uuid.New()
+	orphan := db.Era{ID: uuid.New(), Name: "orphan", ParentID: &missing}
+
+	tree := BuildTree([]db.Era{parent, other, childA, orphan, childB, grandchild})
+
+	if len(tree) != 3 || tree[0].Era.Name != "parent" || tree[1].Era.Name != "other" || tree[2].Era.Name != "orphan" {
+		t.Fatalf("roots = %+v, want parent, other and orphan in order", tree)
+	}
+	subs := tree[0].SubEras
+	if len(subs) != 2 || subs[0].Era.Name != "a" || subs[1].Era.Name != "b" {
+		t.Fatalf("parent sub-eras = %+v, want a and b", subs)
+	}
+	if len(subs[1].SubEras) != 1 || subs[1].SubEras[0].Era.Name != "grandchild" {
+		t.Errorf("b sub-eras = %+v, want grandchild", subs[1].SubEras)
+	}
+	if len(tree[1].SubEras) != 0 {
+		t.Errorf("other has sub-eras %+v, want none", tree[1].SubEras)
+	}
+}
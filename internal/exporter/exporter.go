@@ -0,0 +1,275 @@
+// Package exporter renders eras as playlist and data files (M3U8, XSPF, CSV
+// and JSON) so they can be used outside the app.
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// Era is an era prepared for export.
+type Era struct {
+	ID         string
+	Name       string
+	TopTags    []string
+	StartDate  time.Time
+	EndDate    time.Time
+	PlaylistID string // Empty if the era hasn't been published
+	Tracks     []Track
+}
+
+// Track is an era track prepared for export.
+type Track struct {
+	ID         string
+	Name       string
+	Artist     string
+	Album      string
+	DurationMs int
+	AddedAt    *time.Time // When the user liked the track, if known
+	Tags       []string   // User tags first, then Last.fm tags by weight
+}
+
+// URI returns the track's Spotify URI.
+func (t Track) URI() string {
+	return "spotify:track:" + t.ID
+}
+
+// URL returns the track's Spotify web URL.
+func (t Track) URL() string {
+	return "https://open.spotify.com/track/" + t.ID
+}
+
+// Source loads the data needed to export a user's eras.
+type Source interface {
+	GetEras(ctx context.Context, userID string) ([]db.Era, error)
+	// GetEra returns one of the user's eras, or db.ErrNotFound if the era
+	// doesn't exist or belongs to another user.
+	GetEra(ctx context.Context, userID string, eraID uuid.UUID) (*db.Era, error)
+	GetEraTracks(ctx context.Context, userID string, eraID uuid.UUID) ([]db.Track, error)
+	// GetAddedAt returns when each of the user's tracks was liked.
+	GetAddedAt(ctx context.Context, userID string) (map[string]time.Time, error)
+	GetTags(ctx context.Context, userID string, trackIDs []string) (map[string][]string, error)
+}
+
+// Repositories provides the repositories a database Source reads. It is
+// implemented by *db.DB.
+type Repositories interface {
+	Tracks() db.TrackRepository
+	Tags() db.TagRepository
+	Eras() db.EraRepository
+}
+
+// dbSource implements Source using the database.
+type dbSource struct {
+	db Repositories
+}
+
+// NewDBSource creates a Source backed by the database.
+func NewDBSource(database Repositories) Source {
+	return &dbSource{db: database}
+}
+
+func (s *dbSource) GetEras(ctx context.Context, userID string) ([]db.Era, error) {
+	return s.db.Eras().GetForUser(ctx, userID)
+}
+
+func (s *dbSource) GetEra(ctx context.Context, userID string, eraID uuid.UUID) (*db.Era, error) {
+	return s.db.Eras().GetForOwner(ctx, userID, eraID)
+}
+
+func (s *dbSource) GetEraTracks(ctx context.Context, userID string, eraID uuid.UUID) ([]db.Track, error) {
+	return s.db.Eras().GetTracksForOwner(ctx, userID, eraID)
+}
+
+func (s *dbSource) GetAddedAt(ctx context.Context, userID string) (map[string]time.Time, error) {
+	userTracks, _, err := s.db.Tracks().GetUserTracksWithAddedAt(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	addedAt := make(map[string]time.Time, len(userTracks))
+	for _, ut := range userTracks {
+		addedAt[ut.TrackID] = ut.AddedAt
+	}
+	return addedAt, nil
+}
+
+// GetTags merges the user's own tags with Last.fm tags, user tags first.
+func (s *dbSource) GetTags(ctx context.Context, userID string, trackIDs []string) (map[string][]string, error) {
+	userTags, err := s.db.Tags().GetUserTagsForTracks(ctx, userID, trackIDs)
+	if err != nil {
+		return nil, err
+	}
+	lastfmTags, err := s.db.Tags().GetForTracks(ctx, trackIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]string, len(trackIDs))
+	for _, id := range trackIDs {
+		seen := make(map[string]bool)
+		var names []string
+		for _, t := range userTags[id] {
+			if !seen[t.TagName] {
+				seen[t.TagName] = true
+				names = append(names, t.TagName)
+			}
+		}
+		for _, t := range lastfmTags[id] {
+			if !seen[t.TagName] {
+				seen[t.TagName] = true
+				names = append(names, t.TagName)
+			}
+		}
+		if len(names) > 0 {
+			result[id] = names
+		}
+	}
+	return result, nil
+}
+
+// Exporter loads eras for export.
+type Exporter struct {
+	source Source
+}
+
+// New creates an Exporter.
+func New(source Source) *Exporter {
+	return &Exporter{source: source}
+}
+
+// Load returns the user's eras ready for export, or only the given era IDs
+// in the order given. Returns db.ErrNotFound if an ID doesn't name one of
+// the user's eras.
+//
+// Eras are ordered by start date (newest first) and tracks by when they were
+// liked, so repeated exports of the same data are identical.
+func (e *Exporter) Load(ctx context.Context, userID string, eraIDs ...string) ([]Era, error) {
+	var eras []db.Era
+	if len(eraIDs) == 0 {
+		all, err := e.source.GetEras(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("getting eras: %w", err)
+		}
+		eras = all
+	} else {
+		for _, id := range eraIDs {
+			eraUUID, err := uuid.Parse(id)
+			if err != nil {
+				return nil, db.ErrNotFound
+			}
+			era, err := e.source.GetEra(ctx, userID, eraUUID)
+			if err != nil {
+				return nil, err
+			}
+			eras = append(eras, *era)
+		}
+	}
+
+	addedAt, err := e.source.GetAddedAt(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("getting liked dates: %w", err)
+	}
+
+	result := make([]Era, 0, len(eras))
+	for _, era := range eras {
+		tracks, err := e.source.GetEraTracks(ctx, userID, era.ID)
+		if err != nil {
+			return nil, fmt.Errorf("getting era tracks: %w", err)
+		}
+
+		trackIDs := make([]string, len(tracks))
+		for i, t := range tracks {
+			trackIDs[i] = t.ID
+		}
+		tags, err := e.source.GetTags(ctx, userID, trackIDs)
+		if err != nil {
+			return nil, fmt.Errorf("getting track tags: %w", err)
+		}
+
+		result = append(result, toEra(era, tracks, addedAt, tags))
+	}
+	return result, nil
+}
+
+// toEra converts database records to an export era.
+func toEra(era db.Era, tracks []db.Track, addedAt map[string]time.Time, tags map[string][]string) Era {
+	out := Era{
+		ID:        era.ID.String(),
+		Name:      era.DisplayName(),
+		TopTags:   era.TopTags,
+		StartDate: era.StartDate,
+		EndDate:   era.EndDate,
+		Tracks:    make([]Track, 0, len(tracks)),
+	}
+	if era.PlaylistID != nil {
+		out.PlaylistID = *era.PlaylistID
+	}
+
+	for _, t := range tracks {
+		track := Track{
+			ID:     t.ID,
+			Name:   t.Name,
+			Artist: t.Artist,
+			Tags:   tags[t.ID],
+		}
+		if t.Album != nil {
+			track.Album = *t.Album
+		}
+		if t.DurationMs != nil {
+			track.DurationMs = *t.DurationMs
+		}
+		if added, ok := addedAt[t.ID]; ok {
+			track.AddedAt = &added
+		}
+		out.Tracks = append(out.Tracks, track)
+	}
+
+	sort.SliceStable(out.Tracks, func(i, j int) bool {
+		a, b := out.Tracks[i], out.Tracks[j]
+		switch {
+		case a.AddedAt != nil && b.AddedAt != nil && !a.AddedAt.Equal(*b.AddedAt):
+			return a.AddedAt.Before(*b.AddedAt)
+		case (a.AddedAt == nil) != (b.AddedAt == nil):
+			return a.AddedAt != nil // Undated tracks last
+		default:
+			return a.ID < b.ID
+		}
+	})
+	return out
+}
+
+// Filename returns a download filename for the eras in the given format.
+func Filename(eras []Era, format Format) string {
+	base := "eras"
+	if len(eras) == 1 {
+		base = slugify(eras[0].Name)
+	}
+	return base + "." + format.Extension()
+}
+
+// slugify converts a name to a lowercase filename-safe slug.
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		return "era"
+	}
+	return slug
+}
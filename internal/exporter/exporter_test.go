@@ -0,0 +1,151 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// mockSource implements Source for testing.
+type mockSource struct {
+	eras    []db.Era
+	tracks  map[uuid.UUID][]db.Track
+	addedAt map[string]time.Time
+	tags    map[string][]string
+}
+
+func (m *mockSource) GetEras(_ context.Context, userID string) ([]db.Era, error) {
+	var result []db.Era
+	for _, era := range m.eras {
+		if era.UserID == userID {
+			result = append(result, era)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockSource) GetEra(_ context.Context, userID string, eraID uuid.UUID) (*db.Era, error) {
+	for _, era := range m.eras {
+		if era.ID == eraID && era.UserID == userID {
+			return &era, nil
+		}
+	}
+	return nil, db.ErrNotFound
+}
+
+func (m *mockSource) GetEraTracks(_ context.Context, userID string, eraID uuid.UUID) ([]db.Track, error) {
+	if _, err := m.GetEra(context.Background(), userID, eraID); err != nil {
+		return nil, nil
+	}
+	return m.tracks[eraID], nil
+}
+
+func (m *mockSource) GetAddedAt(_ context.Context, _ string) (map[string]time.Time, error) {
+	return m.addedAt, nil
+}
+
+func (m *mockSource) GetTags(_ context.Context, _ string, _ []string) (map[string][]string, error) {
+	return m.tags, nil
+}
+
+var (
+	eraA = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	eraB = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+)
+
+func newMockSource() *mockSource {
+	album := "Album"
+	return &mockSource{
+		eras: []db.Era{
+			{ID: eraA, UserID: "user1", Name: "Indie: Jan - Mar 2024", TopTags: []string{"indie"}},
+			{ID: eraB, UserID: "user2", Name: "Jazz: Apr - Jun 2024"},
+		},
+		tracks: map[uuid.UUID][]db.Track{
+			eraA: {
+				{ID: "t3", Name: "Undated", Artist: "C"},
+				{ID: "t2", Name: "Second", Artist: "B"},
+				{ID: "t1", Name: "First", Artist: "A", Album: &album},
+			},
+		},
+		addedAt: map[string]time.Time{
+			"t1": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			"t2": time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+		tags: map[string][]string{"t1": {"favorite", "indie"}},
+	}
+}
+
+func TestLoad_AllEras(t *testing.T) {
+	eras, err := New(newMockSource()).Load(context.Background(), "user1")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(eras) != 1 {
+		t.Fatalf("got %d eras, want only the user's 1", len(eras))
+	}
+
+	era := eras[0]
+	var order []string
+	for _, track := range era.Tracks {
+		order = append(order, track.ID)
+	}
+	if len(order) != 3 || order[0] != "t1" || order[1] != "t2" || order[2] != "t3" {
+		t.Errorf("track order = %v, want [t1 t2 t3] (by added_at, undated last)", order)
+	}
+
+	first := era.Tracks[0]
+	if first.Album != "Album" || first.AddedAt == nil || len(first.Tags) != 2 {
+		t.Errorf("first track = %+v, want album, added_at and tags", first)
+	}
+	if era.Tracks[2].AddedAt != nil {
+		t.Errorf("undated track AddedAt = %v, want nil", era.Tracks[2].AddedAt)
+	}
+}
+
+func TestLoad_SelectedEra(t *testing.T) {
+	eras, err := New(newMockSource()).Load(context.Background(), "user1", eraA.String())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(eras) != 1 || eras[0].ID != eraA.String() {
+		t.Errorf("Load() = %+v, want era %s", eras, eraA)
+	}
+}
+
+func TestLoad_NotFound(t *testing.T) {
+	tests := []struct {
+		name  string
+		eraID string
+	}{
+		{"other user's era", eraB.String()},
+		{"unknown era", uuid.NewString()},
+		{"invalid ID", "not-a-uuid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(newMockSource()).Load(context.Background(), "user1", tt.eraID)
+			if !errors.Is(err, db.ErrNotFound) {
+				t.Errorf("Load() error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestFilename(t *testing.T) {
+	one := []Era{{Name: "Indie Rock: Jan 1 - Mar 1, 2024"}}
+	if got := Filename(one, FormatCSV); got != "indie-rock-jan-1-mar-1-2024.csv" {
+		t.Errorf("Filename(one) = %q", got)
+	}
+	if got := Filename(append(one, Era{}), FormatM3U8); got != "eras.m3u8" {
+		t.Errorf("Filename(two) = %q", got)
+	}
+	if got := Filename([]Era{{Name: "☆"}}, FormatJSON); got != "era.json" {
+		t.Errorf("Filename(symbols) = %q", got)
+	}
+}
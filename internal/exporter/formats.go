@@ -0,0 +1,267 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Format is an export file format.
+type Format string
+
+// Supported export formats.
+const (
+	FormatM3U8 Format = "m3u8"
+	FormatXSPF Format = "xspf"
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+// Formats lists the supported formats.
+var Formats = []Format{FormatM3U8, FormatXSPF, FormatCSV, FormatJSON}
+
+// ParseFormat parses a format name. "m3u" is accepted for M3U8.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatM3U8, FormatXSPF, FormatCSV, FormatJSON:
+		return f, nil
+	case "m3u":
+		return FormatM3U8, nil
+	}
+
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("unknown export format %q (want %s)", s, strings.Join(names, ", "))
+}
+
+// Extension returns the file extension for the format, without a dot.
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// ContentType returns the MIME type for the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatM3U8:
+		return "audio/x-mpegurl; charset=utf-8"
+	case FormatXSPF:
+		return "application/xspf+xml; charset=utf-8"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// Write renders eras in the given format.
+func Write(w io.Writer, format Format, eras []Era) error {
+	switch format {
+	case FormatM3U8:
+		return WriteM3U8(w, eras)
+	case FormatXSPF:
+		return WriteXSPF(w, eras)
+	case FormatCSV:
+		return WriteCSV(w, eras)
+	case FormatJSON:
+		return WriteJSON(w, eras)
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// WriteM3U8 renders eras as an extended M3U playlist of Spotify URIs.
+// Each era starts a new #EXTGRP group.
+func WriteM3U8(w io.Writer, eras []Era) error {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	if len(eras) == 1 {
+		fmt.Fprintf(&b, "#PLAYLIST:%s\n", eras[0].Name)
+	}
+	for _, era := range eras {
+		fmt.Fprintf(&b, "#EXTGRP:%s\n", era.Name)
+		for _, t := range era.Tracks {
+			seconds := -1
+			if t.DurationMs > 0 {
+				seconds = t.DurationMs / 1000
+			}
+			fmt.Fprintf(&b, "#EXTINF:%d,%s - %s\n", seconds, t.Artist, t.Name)
+			b.WriteString(t.URI() + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// xspfPlaylist is the root of an XSPF document.
+type xspfPlaylist struct {
+	XMLName    xml.Name    `xml:"playlist"`
+	Version    string      `xml:"version,attr"`
+	Namespace  string      `xml:"xmlns,attr"`
+	Title      string      `xml:"title"`
+	Annotation string      `xml:"annotation,omitempty"`
+	Tracks     []xspfTrack `xml:"trackList>track"`
+}
+
+// xspfTrack is a track in an XSPF document.
+type xspfTrack struct {
+	Location   string `xml:"location"`
+	Identifier string `xml:"identifier"`
+	Title      string `xml:"title"`
+	Creator    string `xml:"creator"`
+	Album      string `xml:"album,omitempty"`
+	Annotation string `xml:"annotation,omitempty"` // Era name when exporting several eras
+	Duration   int    `xml:"duration,omitempty"`   // Milliseconds
+}
+
+// WriteXSPF renders eras as an XSPF playlist. Tracks link to Spotify's web
+// player; when several eras are exported, each track is annotated with its era.
+func WriteXSPF(w io.Writer, eras []Era) error {
+	doc := xspfPlaylist{
+		Version:   "1",
+		Namespace: "http://xspf.org/ns/0/",
+		Title:     "Spotify Eras",
+	}
+	if len(eras) == 1 {
+		doc.Title = eras[0].Name
+		doc.Annotation = strings.Join(eras[0].TopTags, ", ")
+	}
+
+	for _, era := range eras {
+		for _, t := range era.Tracks {
+			track := xspfTrack{
+				Location:   t.URL(),
+				Identifier: t.URI(),
+				Title:      t.Name,
+				Creator:    t.Artist,
+				Album:      t.Album,
+				Duration:   t.DurationMs,
+			}
+			if len(eras) > 1 {
+				track.Annotation = era.Name
+			}
+			doc.Tracks = append(doc.Tracks, track)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("encoding XSPF: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// csvHeader is the header row of CSV exports.
+var csvHeader = []string{"era", "track", "artist", "album", "added_at", "tags", "spotify_uri"}
+
+// WriteCSV renders one row per era track. Tags are separated by semicolons
+// and added_at is RFC 3339, or empty if unknown.
+func WriteCSV(w io.Writer, eras []Era) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, era := range eras {
+		for _, t := range era.Tracks {
+			addedAt := ""
+			if t.AddedAt != nil {
+				addedAt = t.AddedAt.UTC().Format(time.RFC3339)
+			}
+			row := []string{era.Name, t.Name, t.Artist, t.Album, addedAt, strings.Join(t.Tags, ";"), t.URI()}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// DocumentVersion is the version of the JSON export schema. It changes only
+// when fields are removed or change meaning.
+const DocumentVersion = 1
+
+// Document is the JSON export document.
+type Document struct {
+	Version int       `json:"version"`
+	Eras    []EraJSON `json:"eras"`
+}
+
+// EraJSON is an era in the JSON export document.
+type EraJSON struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	TopTags    []string    `json:"top_tags"`
+	StartDate  string      `json:"start_date"` // YYYY-MM-DD
+	EndDate    string      `json:"end_date"`   // YYYY-MM-DD
+	PlaylistID string      `json:"playlist_id,omitempty"`
+	Tracks     []TrackJSON `json:"tracks"`
+}
+
+// TrackJSON is a track in the JSON export document.
+type TrackJSON struct {
+	ID         string   `json:"id"`
+	URI        string   `json:"uri"`
+	Name       string   `json:"name"`
+	Artist     string   `json:"artist"`
+	Album      string   `json:"album,omitempty"`
+	DurationMs int      `json:"duration_ms,omitempty"`
+	AddedAt    string   `json:"added_at,omitempty"` // RFC 3339, UTC
+	Tags       []string `json:"tags"`
+}
+
+// NewDocument converts eras to the JSON export document.
+func NewDocument(eras []Era) Document {
+	doc := Document{Version: DocumentVersion, Eras: make([]EraJSON, 0, len(eras))}
+	for _, era := range eras {
+		e := EraJSON{
+			ID:         era.ID,
+			Name:       era.Name,
+			TopTags:    era.TopTags,
+			StartDate:  era.StartDate.Format(time.DateOnly),
+			EndDate:    era.EndDate.Format(time.DateOnly),
+			PlaylistID: era.PlaylistID,
+			Tracks:     make([]TrackJSON, 0, len(era.Tracks)),
+		}
+		if e.TopTags == nil {
+			e.TopTags = []string{}
+		}
+		for _, t := range era.Tracks {
+			track := TrackJSON{
+				ID:         t.ID,
+				URI:        t.URI(),
+				Name:       t.Name,
+				Artist:     t.Artist,
+				Album:      t.Album,
+				DurationMs: t.DurationMs,
+				Tags:       t.Tags,
+			}
+			if t.AddedAt != nil {
+				track.AddedAt = t.AddedAt.UTC().Format(time.RFC3339)
+			}
+			if track.Tags == nil {
+				track.Tags = []string{}
+			}
+			e.Tracks = append(e.Tracks, track)
+		}
+		doc.Eras = append(doc.Eras, e)
+	}
+	return doc
+}
+
+// WriteJSON renders eras as an indented JSON Document.
+func WriteJSON(w io.Writer, eras []Era) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(NewDocument(eras))
+}
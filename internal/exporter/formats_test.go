@@ -0,0 +1,140 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEras() []Era {
+	added := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	return []Era{{
+		ID:        "11111111-1111-1111-1111-111111111111",
+		Name:      "Indie: Jan - Mar 2024",
+		TopTags:   []string{"indie", "rock"},
+		StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+		Tracks: []Track{
+			{ID: "t1", Name: "Song, One", Artist: "Artist A", Album: "Album", DurationMs: 215000, AddedAt: &added, Tags: []string{"indie", "chill"}},
+			{ID: "t2", Name: "Song Two", Artist: "Artist B"},
+		},
+	}}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := map[string]Format{"m3u8": FormatM3U8, "M3U": FormatM3U8, "xspf": FormatXSPF, "csv": FormatCSV, "json": FormatJSON}
+	for name, want := range tests {
+		got, err := ParseFormat(name)
+		if err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("pls"); err == nil {
+		t.Error("ParseFormat(pls) succeeded, want error")
+	}
+}
+
+func TestWriteM3U8(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteM3U8(&buf, testEras()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `#EXTM3U
+#PLAYLIST:Indie: Jan - Mar 2024
+#EXTGRP:Indie: Jan - Mar 2024
+#EXTINF:215,Artist A - Song, One
+spotify:track:t1
+#EXTINF:-1,Artist B - Song Two
+spotify:track:t2
+`
+	if buf.String() != want {
+		t.Errorf("WriteM3U8() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteXSPF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteXSPF(&buf, testEras()); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc xspfPlaylist
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid XML: %v\n%s", err, buf.String())
+	}
+	if doc.Title != "Indie: Jan - Mar 2024" || len(doc.Tracks) != 2 {
+		t.Fatalf("doc = %+v, want era title and 2 tracks", doc)
+	}
+	first := doc.Tracks[0]
+	if first.Location != "https://open.spotify.com/track/t1" || first.Identifier != "spotify:track:t1" || first.Duration != 215000 {
+		t.Errorf("first track = %+v", first)
+	}
+	if !strings.Contains(buf.String(), `xmlns="http://xspf.org/ns/0/"`) {
+		t.Error("missing XSPF namespace")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, testEras()); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want header + 2", len(rows))
+	}
+	want := []string{"Indie: Jan - Mar 2024", "Song, One", "Artist A", "Album", "2024-01-15T10:30:00Z", "indie;chill", "spotify:track:t1"}
+	for i := range want {
+		if rows[1][i] != want[i] {
+			t.Errorf("row 1 column %s = %q, want %q", rows[0][i], rows[1][i], want[i])
+		}
+	}
+	if rows[2][4] != "" || rows[2][5] != "" {
+		t.Errorf("row 2 added_at/tags = %q/%q, want empty", rows[2][4], rows[2][5])
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, testEras()); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != DocumentVersion || len(doc.Eras) != 1 {
+		t.Fatalf("doc = %+v", doc)
+	}
+	era := doc.Eras[0]
+	if era.StartDate != "2024-01-01" || era.EndDate != "2024-03-31" {
+		t.Errorf("dates = %s - %s", era.StartDate, era.EndDate)
+	}
+	if era.Tracks[0].URI != "spotify:track:t1" || era.Tracks[0].AddedAt != "2024-01-15T10:30:00Z" {
+		t.Errorf("track = %+v", era.Tracks[0])
+	}
+
+	// Empty tag lists are arrays, not null, so consumers can rely on the shape
+	if !strings.Contains(buf.String(), `"tags": []`) {
+		t.Error("untagged track should have an empty tags array")
+	}
+
+	// Output is deterministic
+	var again bytes.Buffer
+	if err := WriteJSON(&again, testEras()); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != again.String() {
+		t.Error("WriteJSON() output differs between runs")
+	}
+}
@@ -0,0 +1,119 @@
+// Package housekeeping runs periodic cleanup tasks: purging expired sessions
+// and OAuth states, and garbage-collecting tracks no user refers to any
+// more. Each task run is recorded in the database.
+package housekeeping
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// DefaultInterval is the time between housekeeping runs.
+const DefaultInterval = time.Hour
+
+// OrphanGracePeriod is how long since its last upsert an unreferenced track
+// is kept, so tracks a sync has upserted but not linked yet survive.
+const OrphanGracePeriod = 24 * time.Hour
+
+// Task is one cleanup job. Run returns how many rows or entries it removed.
+type Task struct {
+	Name string
+	Run  func(ctx context.Context) (int64, error)
+}
+
+// Result is the outcome of one task run.
+type Result struct {
+	Task       string    `json:"task"`
+	Removed    int64     `json:"removed"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// DBTasks returns the tasks that clean up the database: expired sessions,
+// then the tags of orphaned tracks, then the tracks themselves.
+func DBTasks(database *db.DB) []Task {
+	return []Task{
+		{Name: "expired_sessions", Run: database.Sessions().DeleteExpired},
+		{Name: "orphan_track_tags", Run: func(ctx context.Context) (int64, error) {
+			return database.Tracks().DeleteOrphanTags(ctx, time.Now().Add(-OrphanGracePeriod))
+		}},
+		{Name: "orphan_tracks", Run: func(ctx context.Context) (int64, error) {
+			return database.Tracks().DeleteOrphans(ctx, time.Now().Add(-OrphanGracePeriod))
+		}},
+	}
+}
+
+// Housekeeper runs cleanup tasks and records their results.
+type Housekeeper struct {
+	db    *db.DB
+	tasks []Task
+	now   func() time.Time
+}
+
+// New creates a housekeeper for tasks. Results are recorded in database;
+// with a nil database they are only logged.
+func New(database *db.DB, tasks ...Task) *Housekeeper {
+	return &Housekeeper{db: database, tasks: tasks, now: time.Now}
+}
+
+// RunOnce runs every task in order. A failing task doesn't stop the ones
+// after it; its error is in its result.
+func (h *Housekeeper) RunOnce(ctx context.Context) []Result {
+	results := make([]Result, 0, len(h.tasks))
+	for _, task := range h.tasks {
+		if ctx.Err() != nil {
+			break
+		}
+		result := Result{Task: task.Name, StartedAt: h.now()}
+		removed, err := task.Run(ctx)
+		result.FinishedAt = h.now()
+		result.Removed = removed
+		if err != nil {
+			result.Error = err.Error()
+			log.Printf("Housekeeping task %s failed: %v", task.Name, err)
+		} else if removed > 0 {
+			log.Printf("Housekeeping task %s removed %d", task.Name, removed)
+		}
+
+		if h.db != nil {
+			if err := h.db.Housekeeping().Record(ctx, toRun(result)); err != nil {
+				log.Printf("Warning: recording housekeeping run: %v", err)
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Run runs every task now and then every interval until ctx is done.
+func (h *Housekeeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// toRun converts a result to its database record.
+func toRun(r Result) *db.HousekeepingRun {
+	run := &db.HousekeepingRun{
+		Task:       r.Task,
+		Removed:    r.Removed,
+		StartedAt:  r.StartedAt,
+		FinishedAt: r.FinishedAt,
+	}
+	if r.Error != "" {
+		run.Error = &r.Error
+	}
+	return run
+}
@@ -0,0 +1,65 @@
+package housekeeping
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestHousekeeper_RunOnce(t *testing.T) {
+	var ran []string
+	task := func(name string, removed int64, err error) Task {
+		return Task{Name: name, Run: func(context.Context) (int64, error) {
+			ran = append(ran, name)
+			return removed, err
+		}}
+	}
+
+	h := New(nil,
+		task("sessions", 3, nil),
+		task("broken", 0, errors.New("database down")),
+		task("tracks", 7, nil),
+	)
+	results := h.RunOnce(context.Background())
+
+	// A failing task doesn't stop the ones after it
+	if len(ran) != 3 || len(results) != 3 {
+		t.Fatalf("ran %v with results %+v, want all three tasks", ran, results)
+	}
+	want := []Result{
+		{Task: "sessions", Removed: 3},
+		{Task: "broken", Error: "database down"},
+		{Task: "tracks", Removed: 7},
+	}
+	for i, w := range want {
+		got := results[i]
+		if got.Task != w.Task || got.Removed != w.Removed || got.Error != w.Error {
+			t.Errorf("results[%d] = %+v, want %+v", i, got, w)
+		}
+		if got.StartedAt.IsZero() || got.FinishedAt.Before(got.StartedAt) {
+			t.Errorf("results[%d] times = %s to %s", i, got.StartedAt, got.FinishedAt)
+		}
+	}
+}
+
+func TestHousekeeper_RunOnceCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	h := New(nil, Task{Name: "sessions", Run: func(context.Context) (int64, error) {
+		t.Error("task ran after the context was cancelled")
+		return 0, nil
+	}})
+	if results := h.RunOnce(ctx); len(results) != 0 {
+		t.Errorf("results = %+v, want none", results)
+	}
+}
+
+func TestToRun(t *testing.T) {
+	if run := toRun(Result{Task: "ok", Removed: 2}); run.Error != nil || run.Removed != 2 {
+		t.Errorf("toRun(success) = %+v, want no error", run)
+	}
+	if run := toRun(Result{Task: "broken", Error: "boom"}); run.Error == nil || *run.Error != "boom" {
+		t.Errorf("toRun(failure) = %+v, want the error", run)
+	}
+}
@@ -0,0 +1,244 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// Store persists imported data. It never overwrites data synced from the
+// Spotify API, which is more complete than an export.
+type Store interface {
+	EnsureUser(ctx context.Context, userID string) error
+	InsertTracks(ctx context.Context, tracks []db.Track) error
+	LinkTracks(ctx context.Context, userID string, tracks []db.UserTrack) error
+	// InsertPlays stores plays, skipping ones already recorded, and returns
+	// the number of new plays.
+	InsertPlays(ctx context.Context, userID string, plays []db.Play) (int, error)
+	// LatestPlayedAt returns the user's most recent play from source, or nil.
+	LatestPlayedAt(ctx context.Context, userID, source string) (*time.Time, error)
+}
+
+// dbStore implements Store using the database.
+type dbStore struct {
+	db *db.DB
+}
+
+// NewDBStore creates a Store backed by the database.
+func NewDBStore(database *db.DB) Store {
+	return &dbStore{db: database}
+}
+
+func (s *dbStore) EnsureUser(ctx context.Context, userID string) error {
+	return s.db.Users().EnsureExists(ctx, userID)
+}
+
+func (s *dbStore) InsertTracks(ctx context.Context, tracks []db.Track) error {
+	return s.db.Tracks().InsertBatchIfAbsent(ctx, tracks)
+}
+
+func (s *dbStore) LinkTracks(ctx context.Context, userID string, tracks []db.UserTrack) error {
+	return s.db.Tracks().LinkBatchToUserIfAbsent(ctx, userID, tracks)
+}
+
+// InsertPlays stores plays and matches them to tracks in the user's library.
+func (s *dbStore) InsertPlays(ctx context.Context, userID string, plays []db.Play) (int, error) {
+	inserted, err := s.db.Plays().InsertBatch(ctx, userID, plays)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.db.Plays().MatchTracks(ctx, userID); err != nil {
+		return 0, err
+	}
+	return int(inserted), nil
+}
+
+func (s *dbStore) LatestPlayedAt(ctx context.Context, userID, source string) (*time.Time, error) {
+	return s.db.Plays().LatestPlayedAt(ctx, userID, source)
+}
+
+// playBatchSize bounds how many plays are sent to the store at once.
+// Extended histories can hold hundreds of thousands of plays.
+const playBatchSize = 5000
+
+// Importer ingests parsed exports into a Store.
+type Importer struct {
+	store Store
+	now   func() time.Time
+}
+
+// Option configures an Importer.
+type Option func(*Importer)
+
+// WithClock sets the clock used to date tracks that were never played.
+func WithClock(now func() time.Time) Option {
+	return func(i *Importer) {
+		i.now = now
+	}
+}
+
+// New creates a new Importer.
+func New(store Store, opts ...Option) *Importer {
+	i := &Importer{
+		store: store,
+		now:   time.Now,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Result contains the outcome of an import.
+type Result struct {
+	TracksImported int `json:"tracks_imported"` // Library tracks linked to the user
+	TracksSkipped  int `json:"tracks_skipped"`  // Library entries without a Spotify track URI
+	TracksPlayed   int `json:"tracks_played"`   // Library tracks dated from streaming history
+	PlaysRead      int `json:"plays_read"`      // History plays long enough to count
+	PlaysImported  int `json:"plays_imported"`  // Plays not already recorded
+}
+
+// Import links the export's liked songs to the user's library and records
+// its streaming history as plays.
+// YourLibrary.json has no like dates, so each track is dated by its first
+// play in the streaming history; tracks never played are dated at import time.
+// Tracks already in the user's library keep their existing added_at.
+// Tracks played in the extended history are added to the track catalog, but
+// only liked songs are added to the library.
+func (i *Importer) Import(ctx context.Context, userID string, export *Export) (*Result, error) {
+	result := &Result{
+		TracksSkipped: export.Skipped,
+		PlaysRead:     len(export.History),
+	}
+	if len(export.Library) == 0 && len(export.History) == 0 {
+		return result, nil
+	}
+
+	if err := i.store.EnsureUser(ctx, userID); err != nil {
+		return nil, fmt.Errorf("ensuring user: %w", err)
+	}
+
+	firstPlays := firstPlayTimes(export.History)
+	importedAt := i.now()
+
+	dbTracks := make([]db.Track, 0, len(export.Library))
+	userTracks := make([]db.UserTrack, 0, len(export.Library))
+	seen := make(map[string]bool, len(export.Library))
+
+	for _, t := range export.Library {
+		if seen[t.TrackID] {
+			continue
+		}
+		seen[t.TrackID] = true
+		dbTracks = append(dbTracks, newTrack(t.TrackID, t.Name, t.Artist, t.Album))
+
+		addedAt, played := firstPlays[idKey(t.TrackID)]
+		if !played {
+			addedAt, played = firstPlays[playKey(t.Artist, t.Name)]
+		}
+		if played {
+			result.TracksPlayed++
+		} else {
+			addedAt = importedAt
+		}
+		userTracks = append(userTracks, db.UserTrack{
+			UserID:  userID,
+			TrackID: t.TrackID,
+			AddedAt: addedAt,
+		})
+	}
+
+	// Catalog tracks known only from the extended history, so their plays
+	// keep their track ID
+	for _, p := range export.History {
+		if p.TrackID == "" || seen[p.TrackID] {
+			continue
+		}
+		seen[p.TrackID] = true
+		dbTracks = append(dbTracks, newTrack(p.TrackID, p.Track, p.Artist, p.Album))
+	}
+
+	if err := i.store.InsertTracks(ctx, dbTracks); err != nil {
+		return nil, fmt.Errorf("inserting tracks: %w", err)
+	}
+	if err := i.store.LinkTracks(ctx, userID, userTracks); err != nil {
+		return nil, fmt.Errorf("linking tracks to user: %w", err)
+	}
+	result.TracksImported = len(userTracks)
+
+	for start := 0; start < len(export.History); start += playBatchSize {
+		batch := export.History[start:min(start+playBatchSize, len(export.History))]
+		inserted, err := i.store.InsertPlays(ctx, userID, toDBPlays(batch))
+		if err != nil {
+			return nil, fmt.Errorf("inserting plays: %w", err)
+		}
+		result.PlaysImported += inserted
+	}
+
+	return result, nil
+}
+
+// newTrack builds a catalog track from export metadata.
+func newTrack(id, name, artist, album string) db.Track {
+	track := db.Track{
+		ID:     id,
+		Name:   name,
+		Artist: artist,
+	}
+	if album != "" {
+		track.Album = &album
+	}
+	return track
+}
+
+// toDBPlays converts parsed plays to database plays.
+func toDBPlays(plays []Play) []db.Play {
+	result := make([]db.Play, len(plays))
+	for i, p := range plays {
+		result[i] = db.Play{
+			ArtistName: p.Artist,
+			TrackName:  p.Track,
+			PlayedAt:   p.PlayedAt,
+			MsPlayed:   int(p.Played.Milliseconds()),
+			Source:     db.PlaySourceSpotify,
+		}
+		if p.TrackID != "" {
+			trackID := p.TrackID
+			result[i].TrackID = &trackID
+		}
+	}
+	return result
+}
+
+// firstPlayTimes returns the earliest play time for each artist/track pair,
+// and for each track ID when the history includes one.
+func firstPlayTimes(plays []Play) map[string]time.Time {
+	first := make(map[string]time.Time)
+	record := func(key string, at time.Time) {
+		if t, ok := first[key]; !ok || at.Before(t) {
+			first[key] = at
+		}
+	}
+	for _, p := range plays {
+		record(playKey(p.Artist, p.Track), p.PlayedAt)
+		if p.TrackID != "" {
+			record(idKey(p.TrackID), p.PlayedAt)
+		}
+	}
+	return first
+}
+
+// idKey keys first plays by track ID. Unlike playKey it has no NUL
+// separator, so the two never collide.
+func idKey(trackID string) string {
+	return "\x01" + trackID
+}
+
+// playKey matches history entries to library tracks, which the export only
+// links by name.
+func playKey(artist, track string) string {
+	return strings.ToLower(artist) + "\x00" + strings.ToLower(track)
+}
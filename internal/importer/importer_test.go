@@ -0,0 +1,216 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// mockStore implements Store for testing.
+type mockStore struct {
+	users      []string
+	tracks     []db.Track
+	userTracks []db.UserTrack
+	plays      []db.Play
+	insertErr  error
+}
+
+func (m *mockStore) EnsureUser(ctx context.Context, userID string) error {
+	m.users = append(m.users, userID)
+	return nil
+}
+
+func (m *mockStore) InsertTracks(ctx context.Context, tracks []db.Track) error {
+	if m.insertErr != nil {
+		return m.insertErr
+	}
+	m.tracks = append(m.tracks, tracks...)
+	return nil
+}
+
+func (m *mockStore) LinkTracks(ctx context.Context, userID string, tracks []db.UserTrack) error {
+	m.userTracks = append(m.userTracks, tracks...)
+	return nil
+}
+
+func (m *mockStore) InsertPlays(ctx context.Context, userID string, plays []db.Play) (int, error) {
+	m.plays = append(m.plays, plays...)
+	return len(plays), nil
+}
+
+func (m *mockStore) LatestPlayedAt(ctx context.Context, userID, source string) (*time.Time, error) {
+	var latest *time.Time
+	for _, p := range m.plays {
+		if p.Source == source && (latest == nil || p.PlayedAt.After(*latest)) {
+			playedAt := p.PlayedAt
+			latest = &playedAt
+		}
+	}
+	return latest, nil
+}
+
+func TestImport(t *testing.T) {
+	export, err := LoadDir(os.DirFS("testdata/export"))
+	if err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := &mockStore{}
+	imp := New(store, WithClock(func() time.Time { return now }))
+
+	result, err := imp.Import(context.Background(), "user1", export)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	if result.TracksImported != 3 {
+		t.Errorf("TracksImported = %d, want 3", result.TracksImported)
+	}
+	if result.TracksSkipped != 1 {
+		t.Errorf("TracksSkipped = %d, want 1", result.TracksSkipped)
+	}
+	if result.TracksPlayed != 2 {
+		t.Errorf("TracksPlayed = %d, want 2", result.TracksPlayed)
+	}
+	if result.PlaysImported != 3 {
+		t.Errorf("PlaysImported = %d, want 3", result.PlaysImported)
+	}
+	for _, p := range store.plays {
+		if p.Source != db.PlaySourceSpotify {
+			t.Errorf("play source = %q, want %q", p.Source, db.PlaySourceSpotify)
+		}
+		if p.TrackID != nil {
+			t.Errorf("play %s has track ID %q, want nil (standard history has no URIs)", p.TrackName, *p.TrackID)
+		}
+	}
+	if len(store.users) != 1 || store.users[0] != "user1" {
+		t.Errorf("EnsureUser calls = %v, want [user1]", store.users)
+	}
+
+	addedAt := make(map[string]time.Time)
+	for _, ut := range store.userTracks {
+		if ut.UserID != "user1" {
+			t.Errorf("UserTrack.UserID = %q, want user1", ut.UserID)
+		}
+		addedAt[ut.TrackID] = ut.AddedAt
+	}
+
+	tests := []struct {
+		trackID string
+		want    time.Time
+	}{
+		// Earliest play wins; history matching ignores case.
+		{"6LgJvl0Xdtc73RJ1mmpotq", time.Date(2023, 2, 1, 19, 40, 0, 0, time.UTC)},
+		// The earlier 12s play is below MinPlayDuration.
+		{"1Ld6rGT2bfQgI8nM8VaGpN", time.Date(2023, 4, 2, 10, 30, 0, 0, time.UTC)},
+		// Never played: dated at import time.
+		{"5lBsUyqE6gCXJdYpCnxSuS", now},
+	}
+	for _, tt := range tests {
+		if got := addedAt[tt.trackID]; !got.Equal(tt.want) {
+			t.Errorf("added_at for %s = %v, want %v", tt.trackID, got, tt.want)
+		}
+	}
+
+	for _, track := range store.tracks {
+		if track.Album == nil || *track.Album == "" {
+			t.Errorf("track %s has no album", track.ID)
+		}
+	}
+}
+
+func TestImport_DeduplicatesTracks(t *testing.T) {
+	export := &Export{
+		Library: []LibraryTrack{
+			{TrackID: "t1", Name: "Song", Artist: "Artist"},
+			{TrackID: "t1", Name: "Song", Artist: "Artist"},
+		},
+	}
+
+	store := &mockStore{}
+	result, err := New(store).Import(context.Background(), "user1", export)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if result.TracksImported != 1 {
+		t.Errorf("TracksImported = %d, want 1", result.TracksImported)
+	}
+	if len(store.tracks) != 1 {
+		t.Errorf("inserted %d tracks, want 1", len(store.tracks))
+	}
+	if store.tracks[0].Album != nil {
+		t.Errorf("Album = %q, want nil for empty album", *store.tracks[0].Album)
+	}
+}
+
+func TestImport_ExtendedHistory(t *testing.T) {
+	export := &Export{
+		Library: []LibraryTrack{
+			{TrackID: "liked", Name: "Liked Song", Artist: "Artist"},
+		},
+		History: []Play{
+			{TrackID: "liked", Artist: "Artist (feat. Guest)", Track: "Liked Song (Remastered)",
+				PlayedAt: time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC), Played: time.Minute},
+			{TrackID: "other", Artist: "Other", Track: "Not Liked", Album: "B-Sides",
+				PlayedAt: time.Date(2021, 5, 2, 0, 0, 0, 0, time.UTC), Played: time.Minute},
+		},
+	}
+
+	store := &mockStore{}
+	result, err := New(store).Import(context.Background(), "user1", export)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	// Matched by ID even though the names differ
+	if result.TracksPlayed != 1 {
+		t.Errorf("TracksPlayed = %d, want 1", result.TracksPlayed)
+	}
+	if !store.userTracks[0].AddedAt.Equal(export.History[0].PlayedAt) {
+		t.Errorf("added_at = %v, want %v", store.userTracks[0].AddedAt, export.History[0].PlayedAt)
+	}
+
+	// Played tracks are cataloged, but only liked tracks join the library
+	if len(store.tracks) != 2 {
+		t.Errorf("inserted %d tracks, want 2", len(store.tracks))
+	}
+	if len(store.userTracks) != 1 {
+		t.Errorf("linked %d tracks, want 1", len(store.userTracks))
+	}
+
+	if len(store.plays) != 2 {
+		t.Fatalf("inserted %d plays, want 2", len(store.plays))
+	}
+	if p := store.plays[1]; p.TrackID == nil || *p.TrackID != "other" || p.MsPlayed != 60000 {
+		t.Errorf("play = %+v, want track ID other and 60000ms", p)
+	}
+}
+
+func TestImport_EmptyLibrary(t *testing.T) {
+	store := &mockStore{}
+	result, err := New(store).Import(context.Background(), "user1", &Export{Skipped: 2})
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if result.TracksImported != 0 || result.TracksSkipped != 2 {
+		t.Errorf("result = %+v, want 0 imported, 2 skipped", result)
+	}
+	if len(store.users) != 0 {
+		t.Error("expected no store calls for empty library")
+	}
+}
+
+func TestImport_StoreError(t *testing.T) {
+	export := &Export{Library: []LibraryTrack{{TrackID: "t1", Name: "Song", Artist: "Artist"}}}
+	storeErr := errors.New("connection refused")
+
+	_, err := New(&mockStore{insertErr: storeErr}).Import(context.Background(), "user1", export)
+	if !errors.Is(err, storeErr) {
+		t.Errorf("Import() error = %v, want wrapped %v", err, storeErr)
+	}
+}
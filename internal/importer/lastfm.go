@@ -0,0 +1,97 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/lastfm"
+)
+
+// ScrobbleSource fetches pages of a Last.fm user's scrobble history.
+// Implemented by *lastfm.Client.
+type ScrobbleSource interface {
+	GetRecentTracks(ctx context.Context, user string, opts lastfm.RecentTracksOptions) (*lastfm.RecentTracksPage, error)
+}
+
+// ScrobbleResult contains the outcome of a scrobble import.
+type ScrobbleResult struct {
+	Since         *time.Time `json:"since,omitempty"` // Cursor the import resumed from (nil on first run)
+	Pages         int        `json:"pages"`           // Pages fetched
+	PlaysRead     int        `json:"plays_read"`      // Scrobbles fetched
+	PlaysImported int        `json:"plays_imported"`  // Scrobbles not already recorded
+}
+
+// ImportScrobbles records a Last.fm user's scrobbles as plays.
+//
+// The import resumes after the user's latest Last.fm play, so repeated runs
+// only fetch new scrobbles. Pages are fetched oldest first and stored as they
+// arrive, so an interrupted run leaves no gaps before the cursor. The upper
+// bound is fixed at the start of the run so scrobbles arriving mid-run don't
+// shift page boundaries; they are picked up by the next run.
+func (i *Importer) ImportScrobbles(ctx context.Context, userID, lastfmUser string, source ScrobbleSource) (*ScrobbleResult, error) {
+	if err := i.store.EnsureUser(ctx, userID); err != nil {
+		return nil, fmt.Errorf("ensuring user: %w", err)
+	}
+
+	since, err := i.store.LatestPlayedAt(ctx, userID, db.PlaySourceLastFM)
+	if err != nil {
+		return nil, fmt.Errorf("loading scrobble cursor: %w", err)
+	}
+
+	opts := lastfm.RecentTracksOptions{
+		To:    i.now(),
+		Limit: lastfm.MaxRecentTracksLimit,
+	}
+	if since != nil {
+		// Scrobble times have second precision
+		opts.From = since.Add(time.Second)
+	}
+
+	result := &ScrobbleResult{Since: since}
+
+	// The first request reports how many pages there are
+	opts.Page = 1
+	first, err := source.GetRecentTracks(ctx, lastfmUser, opts)
+	if err != nil {
+		return nil, fmt.Errorf("fetching scrobbles page 1: %w", err)
+	}
+	result.Pages++
+
+	for page := first.TotalPages; page >= 1; page-- {
+		current := first
+		if page > 1 {
+			opts.Page = page
+			current, err = source.GetRecentTracks(ctx, lastfmUser, opts)
+			if err != nil {
+				return result, fmt.Errorf("fetching scrobbles page %d: %w", page, err)
+			}
+			result.Pages++
+		}
+
+		inserted, err := i.store.InsertPlays(ctx, userID, scrobblesToPlays(current.Scrobbles))
+		if err != nil {
+			return result, fmt.Errorf("inserting scrobbles from page %d: %w", page, err)
+		}
+		result.PlaysRead += len(current.Scrobbles)
+		result.PlaysImported += inserted
+	}
+
+	return result, nil
+}
+
+// scrobblesToPlays converts scrobbles to database plays. Last.fm doesn't
+// report playback duration or Spotify IDs, so plays are matched by name.
+func scrobblesToPlays(scrobbles []lastfm.Scrobble) []db.Play {
+	plays := make([]db.Play, len(scrobbles))
+	for i, s := range scrobbles {
+		plays[i] = db.Play{
+			ArtistName: s.Artist,
+			TrackName:  s.Track,
+			PlayedAt:   s.PlayedAt,
+			Source:     db.PlaySourceLastFM,
+		}
+	}
+	return plays
+}
@@ -0,0 +1,215 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/lastfm"
+)
+
+// fakeLastFM serves user.getRecentTracks from an in-memory scrobble history,
+// honoring from, to, page and limit like the real API (newest first).
+type fakeLastFM struct {
+	mu        sync.Mutex
+	scrobbles []time.Time // Play times; artist and track are derived from the index
+	requests  []string    // Requested pages
+	failPage  int         // Page to fail with an API error (0 for none)
+}
+
+func (f *fakeLastFM) add(times ...time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scrobbles = append(f.scrobbles, times...)
+}
+
+func (f *fakeLastFM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	from, _ := strconv.ParseInt(q.Get("from"), 10, 64)
+	to, _ := strconv.ParseInt(q.Get("to"), 10, 64)
+	f.requests = append(f.requests, q.Get("page"))
+
+	w.Header().Set("Content-Type", "application/json")
+	if page == f.failPage {
+		fmt.Fprint(w, `{"error": 8, "message": "Operation failed"}`)
+		return
+	}
+
+	// Select scrobbles in range, newest first
+	var matched []int
+	for i, ts := range f.scrobbles {
+		if q.Has("from") && ts.Unix() < from {
+			continue
+		}
+		if q.Has("to") && ts.Unix() > to {
+			continue
+		}
+		matched = append(matched, i)
+	}
+	slices.SortFunc(matched, func(a, b int) int {
+		return f.scrobbles[b].Compare(f.scrobbles[a])
+	})
+
+	totalPages := (len(matched) + limit - 1) / limit
+	start := min((page-1)*limit, len(matched))
+	end := min(start+limit, len(matched))
+
+	fmt.Fprint(w, `{"recenttracks": {"track": [`)
+	for n, i := range matched[start:end] {
+		if n > 0 {
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprintf(w, `{"artist": {"#text": "Artist %d"}, "album": {"#text": ""}, "name": "Track %d", "date": {"uts": "%d"}}`,
+			i, i, f.scrobbles[i].Unix())
+	}
+	fmt.Fprintf(w, `], "@attr": {"page": "%d", "totalPages": "%d", "total": "%d"}}}`, page, totalPages, len(matched))
+}
+
+// hourly returns n times an hour apart starting at start.
+func hourly(start time.Time, n int) []time.Time {
+	times := make([]time.Time, n)
+	for i := range times {
+		times[i] = start.Add(time.Duration(i) * time.Hour)
+	}
+	return times
+}
+
+// newFakeLastFMClient starts a fake Last.fm server and returns a client for it.
+func newFakeLastFMClient(t *testing.T, fake *fakeLastFM) *lastfm.Client {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return lastfm.NewClient(&lastfm.Config{APIKey: "test-api-key", BaseURL: server.URL + "/"})
+}
+
+// playTimes returns the sorted play times recorded in the store.
+func playTimes(plays []db.Play) []time.Time {
+	times := make([]time.Time, len(plays))
+	for i, p := range plays {
+		times[i] = p.PlayedAt
+	}
+	slices.SortFunc(times, time.Time.Compare)
+	return times
+}
+
+func TestImportScrobbles(t *testing.T) {
+	start := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := &fakeLastFM{}
+	fake.add(hourly(start, 450)...) // 3 pages of 200
+	client := newFakeLastFMClient(t, fake)
+
+	store := &mockStore{}
+	now := start.AddDate(1, 0, 0)
+	imp := New(store, WithClock(func() time.Time { return now }))
+
+	result, err := imp.ImportScrobbles(context.Background(), "user1", "rj", client)
+	if err != nil {
+		t.Fatalf("ImportScrobbles() error = %v", err)
+	}
+
+	if result.Since != nil {
+		t.Errorf("Since = %v, want nil on first run", result.Since)
+	}
+	if result.Pages != 3 || result.PlaysRead != 450 || result.PlaysImported != 450 {
+		t.Errorf("result = %+v, want 3 pages, 450 read and imported", result)
+	}
+	// Page 1 reports the page count, then pages are fetched oldest first
+	if want := []string{"1", "3", "2"}; !slices.Equal(fake.requests, want) {
+		t.Errorf("requested pages %v, want %v", fake.requests, want)
+	}
+
+	if got := playTimes(store.plays); !slices.EqualFunc(got, hourly(start, 450), time.Time.Equal) {
+		t.Error("stored plays don't match scrobble history")
+	}
+	for _, p := range store.plays {
+		if p.Source != db.PlaySourceLastFM {
+			t.Fatalf("play source = %q, want %q", p.Source, db.PlaySourceLastFM)
+		}
+	}
+}
+
+func TestImportScrobbles_ResumesFromCursor(t *testing.T) {
+	start := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := &fakeLastFM{}
+	fake.add(hourly(start, 250)...)
+	client := newFakeLastFMClient(t, fake)
+
+	store := &mockStore{}
+	now := start.AddDate(1, 0, 0)
+	imp := New(store, WithClock(func() time.Time { return now }))
+
+	if _, err := imp.ImportScrobbles(context.Background(), "user1", "rj", client); err != nil {
+		t.Fatalf("first ImportScrobbles() error = %v", err)
+	}
+
+	// New scrobbles arrive between runs
+	fake.add(hourly(start.Add(250*time.Hour), 10)...)
+
+	result, err := imp.ImportScrobbles(context.Background(), "user1", "rj", client)
+	if err != nil {
+		t.Fatalf("second ImportScrobbles() error = %v", err)
+	}
+
+	wantSince := start.Add(249 * time.Hour)
+	if result.Since == nil || !result.Since.Equal(wantSince) {
+		t.Errorf("Since = %v, want %v", result.Since, wantSince)
+	}
+	if result.PlaysRead != 10 || result.Pages != 1 {
+		t.Errorf("result = %+v, want only the 10 new scrobbles on 1 page", result)
+	}
+	if len(store.plays) != 260 {
+		t.Errorf("stored %d plays, want 260", len(store.plays))
+	}
+}
+
+func TestImportScrobbles_InterruptedRunLeavesNoGaps(t *testing.T) {
+	start := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := &fakeLastFM{failPage: 2}
+	fake.add(hourly(start, 450)...)
+	client := newFakeLastFMClient(t, fake)
+
+	store := &mockStore{}
+	now := start.AddDate(1, 0, 0)
+	imp := New(store, WithClock(func() time.Time { return now }))
+
+	// Page 3 (oldest) is stored before page 2 fails
+	if _, err := imp.ImportScrobbles(context.Background(), "user1", "rj", client); err == nil {
+		t.Fatal("expected error from failing page")
+	}
+	if got := playTimes(store.plays); !slices.EqualFunc(got, hourly(start, 50), time.Time.Equal) {
+		t.Fatalf("after failure stored %d plays, want the oldest 50", len(got))
+	}
+
+	fake.failPage = 0
+	if _, err := imp.ImportScrobbles(context.Background(), "user1", "rj", client); err != nil {
+		t.Fatalf("resumed ImportScrobbles() error = %v", err)
+	}
+	if got := playTimes(store.plays); !slices.EqualFunc(got, hourly(start, 450), time.Time.Equal) {
+		t.Errorf("after resume stored %d plays, want all 450 without gaps", len(got))
+	}
+}
+
+func TestImportScrobbles_NoScrobbles(t *testing.T) {
+	client := newFakeLastFMClient(t, &fakeLastFM{})
+
+	store := &mockStore{}
+	result, err := New(store).ImportScrobbles(context.Background(), "user1", "rj", client)
+	if err != nil {
+		t.Fatalf("ImportScrobbles() error = %v", err)
+	}
+	if result.PlaysRead != 0 || len(store.plays) != 0 {
+		t.Errorf("result = %+v, want nothing imported", result)
+	}
+}
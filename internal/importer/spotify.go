@@ -0,0 +1,285 @@
+// Package importer ingests Spotify privacy data exports into the database
+// without calling the Spotify API. Both the account data export
+// (YourLibrary.json, StreamingHistory*.json) and the extended streaming
+// history export (Streaming_History_Audio_*.json, endsong_*.json) are supported.
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// File names used in the Spotify account data export.
+const (
+	LibraryFile          = "YourLibrary.json"
+	streamingHistoryGlob = "StreamingHistory*.json"
+)
+
+// extendedHistoryGlobs match extended streaming history files. Older exports
+// name them endsong_N.json.
+var extendedHistoryGlobs = []string{"Streaming_History_Audio_*.json", "endsong_*.json"}
+
+// historyTimeFormat is the layout of endTime in StreamingHistory*.json (UTC).
+const historyTimeFormat = "2006-01-02 15:04"
+
+// trackURIPrefix prefixes Spotify track URIs, e.g. "spotify:track:4uLU6hMCjMI75M1A2tKUQC".
+const trackURIPrefix = "spotify:track:"
+
+// MinPlayDuration is the minimum playback time for a history entry to count
+// as a play. It matches the threshold Spotify uses to count a stream.
+const MinPlayDuration = 30 * time.Second
+
+// ErrEmptyExport is returned when an export directory has neither
+// YourLibrary.json nor any streaming history files.
+var ErrEmptyExport = errors.New("export has no library or streaming history files")
+
+// LibraryTrack is a liked song from YourLibrary.json.
+type LibraryTrack struct {
+	TrackID string // Spotify track ID parsed from the URI
+	Name    string
+	Artist  string // Primary artist name
+	Album   string
+}
+
+// Play is a single playback from a streaming history file.
+type Play struct {
+	TrackID  string // Spotify track ID (extended history only)
+	Artist   string
+	Track    string
+	Album    string    // Extended history only
+	PlayedAt time.Time // When playback ended
+	Played   time.Duration
+}
+
+// Export holds the parsed contents of a Spotify account data export.
+type Export struct {
+	Library []LibraryTrack
+	History []Play
+	Skipped int // Library entries without a Spotify track URI (e.g. local files)
+}
+
+// libraryJSON mirrors the relevant parts of YourLibrary.json.
+type libraryJSON struct {
+	Tracks []struct {
+		Artist string `json:"artist"`
+		Album  string `json:"album"`
+		Track  string `json:"track"`
+		URI    string `json:"uri"`
+	} `json:"tracks"`
+}
+
+// historyJSON mirrors an entry in StreamingHistory*.json.
+type historyJSON struct {
+	EndTime    string `json:"endTime"`
+	ArtistName string `json:"artistName"`
+	TrackName  string `json:"trackName"`
+	MsPlayed   int64  `json:"msPlayed"`
+}
+
+// extendedHistoryJSON mirrors an entry in the extended streaming history.
+// Metadata fields are null for podcast episodes and audiobooks.
+type extendedHistoryJSON struct {
+	TS         string  `json:"ts"`
+	MsPlayed   int64   `json:"ms_played"`
+	TrackName  *string `json:"master_metadata_track_name"`
+	ArtistName *string `json:"master_metadata_album_artist_name"`
+	AlbumName  *string `json:"master_metadata_album_album_name"`
+	TrackURI   *string `json:"spotify_track_uri"`
+}
+
+// ParseLibrary parses liked songs from a YourLibrary.json file.
+// Entries without a Spotify track URI are skipped and counted.
+func ParseLibrary(r io.Reader) ([]LibraryTrack, int, error) {
+	var lib libraryJSON
+	if err := json.NewDecoder(r).Decode(&lib); err != nil {
+		return nil, 0, fmt.Errorf("parsing %s: %w", LibraryFile, err)
+	}
+
+	tracks := make([]LibraryTrack, 0, len(lib.Tracks))
+	skipped := 0
+	for _, t := range lib.Tracks {
+		id, ok := strings.CutPrefix(t.URI, trackURIPrefix)
+		if !ok || id == "" {
+			skipped++
+			continue
+		}
+		tracks = append(tracks, LibraryTrack{
+			TrackID: id,
+			Name:    t.Track,
+			Artist:  t.Artist,
+			Album:   t.Album,
+		})
+	}
+	return tracks, skipped, nil
+}
+
+// ParseStreamingHistory parses plays from a StreamingHistory*.json file.
+// Entries shorter than MinPlayDuration are dropped.
+func ParseStreamingHistory(r io.Reader) ([]Play, error) {
+	var entries []historyJSON
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("parsing streaming history: %w", err)
+	}
+
+	plays := make([]Play, 0, len(entries))
+	for _, e := range entries {
+		played := time.Duration(e.MsPlayed) * time.Millisecond
+		if played < MinPlayDuration {
+			continue
+		}
+		playedAt, err := time.Parse(historyTimeFormat, e.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("parsing endTime %q: %w", e.EndTime, err)
+		}
+		plays = append(plays, Play{
+			Artist:   e.ArtistName,
+			Track:    e.TrackName,
+			PlayedAt: playedAt,
+			Played:   played,
+		})
+	}
+	return plays, nil
+}
+
+// ParseExtendedHistory parses music plays from an extended streaming history
+// file. Podcast and audiobook entries, and plays shorter than
+// MinPlayDuration, are dropped.
+func ParseExtendedHistory(r io.Reader) ([]Play, error) {
+	var entries []extendedHistoryJSON
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("parsing extended streaming history: %w", err)
+	}
+
+	plays := make([]Play, 0, len(entries))
+	for _, e := range entries {
+		if e.TrackName == nil || e.ArtistName == nil {
+			continue
+		}
+		played := time.Duration(e.MsPlayed) * time.Millisecond
+		if played < MinPlayDuration {
+			continue
+		}
+		playedAt, err := time.Parse(time.RFC3339, e.TS)
+		if err != nil {
+			return nil, fmt.Errorf("parsing ts %q: %w", e.TS, err)
+		}
+		play := Play{
+			Artist:   *e.ArtistName,
+			Track:    *e.TrackName,
+			PlayedAt: playedAt,
+			Played:   played,
+		}
+		if e.AlbumName != nil {
+			play.Album = *e.AlbumName
+		}
+		if e.TrackURI != nil {
+			play.TrackID, _ = strings.CutPrefix(*e.TrackURI, trackURIPrefix)
+		}
+		plays = append(plays, play)
+	}
+	return plays, nil
+}
+
+// fileKind identifies the type of an export file.
+type fileKind int
+
+const (
+	kindUnknown fileKind = iota
+	kindLibrary
+	kindHistory
+	kindExtendedHistory
+)
+
+// kindOf identifies an export file by its name.
+func kindOf(name string) fileKind {
+	base := path.Base(name)
+	if base == LibraryFile {
+		return kindLibrary
+	}
+	if matched, _ := path.Match(streamingHistoryGlob, base); matched {
+		return kindHistory
+	}
+	for _, glob := range extendedHistoryGlobs {
+		if matched, _ := path.Match(glob, base); matched {
+			return kindExtendedHistory
+		}
+	}
+	return kindUnknown
+}
+
+// IsExportFile reports whether a file name is one the importer understands.
+func IsExportFile(name string) bool {
+	return kindOf(name) != kindUnknown
+}
+
+// ParseFile parses a single export file into export, based on its name.
+// Unrecognized files are ignored.
+func ParseFile(export *Export, name string, r io.Reader) error {
+	var plays []Play
+	var err error
+
+	switch kindOf(name) {
+	case kindLibrary:
+		tracks, skipped, err := ParseLibrary(r)
+		if err != nil {
+			return err
+		}
+		export.Library = append(export.Library, tracks...)
+		export.Skipped += skipped
+		return nil
+	case kindHistory:
+		plays, err = ParseStreamingHistory(r)
+	case kindExtendedHistory:
+		plays, err = ParseExtendedHistory(r)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", path.Base(name), err)
+	}
+	export.History = append(export.History, plays...)
+	return nil
+}
+
+// LoadDir parses YourLibrary.json and every streaming history file in the
+// root of an unzipped export. Returns ErrEmptyExport if there are none.
+func LoadDir(fsys fs.FS) (*Export, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("reading export directory: %w", err)
+	}
+
+	export := &Export{}
+	found := false
+	for _, entry := range entries {
+		if entry.IsDir() || !IsExportFile(entry.Name()) {
+			continue
+		}
+		found = true
+		if err := parseFSFile(fsys, export, entry.Name()); err != nil {
+			return nil, err
+		}
+	}
+
+	if !found {
+		return nil, ErrEmptyExport
+	}
+	return export, nil
+}
+
+// parseFSFile opens and parses a single file from fsys.
+func parseFSFile(fsys fs.FS, export *Export, name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", name, err)
+	}
+	defer f.Close()
+	return ParseFile(export, name, f)
+}
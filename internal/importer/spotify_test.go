@@ -0,0 +1,179 @@
+package importer
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestParseLibrary(t *testing.T) {
+	input := `{"tracks": [
+		{"artist": "Radiohead", "album": "OK Computer", "track": "Airbag", "uri": "spotify:track:abc123"},
+		{"artist": "Me", "album": "Demos", "track": "Demo", "uri": "spotify:local:Me:Demos:Demo:180"},
+		{"artist": "Nobody", "album": "", "track": "Missing", "uri": ""}
+	]}`
+
+	tracks, skipped, err := ParseLibrary(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseLibrary() error = %v", err)
+	}
+	if skipped != 2 {
+		t.Errorf("skipped = %d, want 2", skipped)
+	}
+	if len(tracks) != 1 {
+		t.Fatalf("got %d tracks, want 1", len(tracks))
+	}
+
+	want := LibraryTrack{TrackID: "abc123", Name: "Airbag", Artist: "Radiohead", Album: "OK Computer"}
+	if tracks[0] != want {
+		t.Errorf("track = %+v, want %+v", tracks[0], want)
+	}
+}
+
+func TestParseLibrary_InvalidJSON(t *testing.T) {
+	_, _, err := ParseLibrary(strings.NewReader("not json"))
+	if err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestParseStreamingHistory(t *testing.T) {
+	input := `[
+		{"endTime": "2023-03-14 21:05", "artistName": "Radiohead", "trackName": "Airbag", "msPlayed": 284000},
+		{"endTime": "2023-03-14 21:06", "artistName": "Radiohead", "trackName": "Lucky", "msPlayed": 29999}
+	]`
+
+	plays, err := ParseStreamingHistory(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseStreamingHistory() error = %v", err)
+	}
+	if len(plays) != 1 {
+		t.Fatalf("got %d plays, want 1 (short plays dropped)", len(plays))
+	}
+
+	p := plays[0]
+	if p.Artist != "Radiohead" || p.Track != "Airbag" {
+		t.Errorf("play = %s - %s, want Radiohead - Airbag", p.Artist, p.Track)
+	}
+	wantTime := time.Date(2023, 3, 14, 21, 5, 0, 0, time.UTC)
+	if !p.PlayedAt.Equal(wantTime) {
+		t.Errorf("PlayedAt = %v, want %v", p.PlayedAt, wantTime)
+	}
+	if p.Played != 284*time.Second {
+		t.Errorf("Played = %v, want 284s", p.Played)
+	}
+}
+
+func TestParseStreamingHistory_InvalidTime(t *testing.T) {
+	input := `[{"endTime": "14/03/2023", "artistName": "A", "trackName": "B", "msPlayed": 60000}]`
+	if _, err := ParseStreamingHistory(strings.NewReader(input)); err == nil {
+		t.Error("expected error for invalid endTime")
+	}
+}
+
+func TestParseExtendedHistory(t *testing.T) {
+	input := `[
+		{"ts": "2021-06-01T18:30:00Z", "ms_played": 215000,
+		 "master_metadata_track_name": "Airbag", "master_metadata_album_artist_name": "Radiohead",
+		 "master_metadata_album_album_name": "OK Computer", "spotify_track_uri": "spotify:track:abc123"},
+		{"ts": "2021-06-01T18:31:00Z", "ms_played": 5000,
+		 "master_metadata_track_name": "Lucky", "master_metadata_album_artist_name": "Radiohead",
+		 "master_metadata_album_album_name": "OK Computer", "spotify_track_uri": "spotify:track:def456"},
+		{"ts": "2021-06-01T19:30:00Z", "ms_played": 1800000,
+		 "master_metadata_track_name": null, "master_metadata_album_artist_name": null,
+		 "master_metadata_album_album_name": null, "spotify_track_uri": null,
+		 "episode_name": "Some Podcast", "spotify_episode_uri": "spotify:episode:xyz"}
+	]`
+
+	plays, err := ParseExtendedHistory(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseExtendedHistory() error = %v", err)
+	}
+	if len(plays) != 1 {
+		t.Fatalf("got %d plays, want 1 (short plays and podcasts dropped)", len(plays))
+	}
+
+	want := Play{
+		TrackID:  "abc123",
+		Artist:   "Radiohead",
+		Track:    "Airbag",
+		Album:    "OK Computer",
+		PlayedAt: time.Date(2021, 6, 1, 18, 30, 0, 0, time.UTC),
+		Played:   215 * time.Second,
+	}
+	if plays[0] != want {
+		t.Errorf("play = %+v, want %+v", plays[0], want)
+	}
+}
+
+func TestIsExportFile(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"YourLibrary.json", true},
+		{"MyData/YourLibrary.json", true},
+		{"StreamingHistory0.json", true},
+		{"StreamingHistory_music_3.json", true},
+		{"StreamingHistory_podcast_0.json", true},
+		{"Streaming_History_Audio_2019-2021_0.json", true},
+		{"endsong_0.json", true},
+		{"Streaming_History_Video_2020.json", false},
+		{"Userdata.json", false},
+		{"Playlist1.json", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsExportFile(tt.name); got != tt.want {
+				t.Errorf("IsExportFile(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	export, err := LoadDir(os.DirFS("testdata/export"))
+	if err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+
+	if len(export.Library) != 3 {
+		t.Errorf("got %d library tracks, want 3", len(export.Library))
+	}
+	if export.Skipped != 1 {
+		t.Errorf("Skipped = %d, want 1 (local file)", export.Skipped)
+	}
+	// Four history entries across two files, one under MinPlayDuration.
+	if len(export.History) != 3 {
+		t.Errorf("got %d plays, want 3", len(export.History))
+	}
+}
+
+func TestLoadDir_HistoryOnly(t *testing.T) {
+	fsys := fstest.MapFS{
+		"StreamingHistory0.json": &fstest.MapFile{Data: []byte("[]")},
+	}
+
+	export, err := LoadDir(fsys)
+	if err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+	if len(export.Library) != 0 {
+		t.Errorf("got %d library tracks, want 0", len(export.Library))
+	}
+}
+
+func TestLoadDir_Empty(t *testing.T) {
+	fsys := fstest.MapFS{
+		"Userdata.json": &fstest.MapFile{Data: []byte("{}")},
+	}
+
+	_, err := LoadDir(fsys)
+	if !errors.Is(err, ErrEmptyExport) {
+		t.Errorf("LoadDir() error = %v, want ErrEmptyExport", err)
+	}
+}
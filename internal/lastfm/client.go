@@ -46,7 +46,7 @@ type Client struct {
 
 // NewClient creates a new Last.fm API client from the provided configuration.
 func NewClient(cfg *Config) *Client {
-	return &Client{
+	c := &Client{
 		apiKey: cfg.APIKey,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
@@ -54,6 +54,10 @@ func NewClient(cfg *Config) *Client {
 		baseURL: baseURL,
 		cache:   make(map[string][]Tag),
 	}
+	if cfg.BaseURL != "" {
+		c.baseURL = cfg.BaseURL
+	}
+	return c
 }
 
 // GetTags fetches tags for a track, falling back to artist tags if track has none.
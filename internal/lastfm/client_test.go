@@ -6,6 +6,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -329,3 +330,150 @@ func TestNewClient(t *testing.T) {
 		t.Errorf("NewClient() baseURL = %s, want %s", client.baseURL, baseURL)
 	}
 }
+
+func TestGetRecentTracks(t *testing.T) {
+	var gotQuery url.Values
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"recenttracks": {
+			"track": [
+				{"artist": {"#text": "Radiohead", "mbid": ""}, "album": {"#text": "OK Computer"},
+				 "name": "Airbag", "@attr": {"nowplaying": "true"}},
+				{"artist": {"#text": "Radiohead", "mbid": ""}, "album": {"#text": "OK Computer"},
+				 "name": "Lucky", "date": {"uts": "1700000000", "#text": "14 Nov 2023, 22:13"}},
+				{"artist": {"#text": "Portishead", "mbid": ""}, "album": {"#text": ""},
+				 "name": "Roads", "date": {"uts": "1699990000", "#text": "14 Nov 2023, 19:26"}}
+			],
+			"@attr": {"user": "rj", "page": "2", "perPage": "200", "totalPages": "7", "total": "1301"}
+		}}`))
+	}))
+	defer server.Close()
+
+	client := &Client{
+		apiKey:     "test-api-key",
+		httpClient: server.Client(),
+		baseURL:    server.URL + "/",
+		cache:      make(map[string][]Tag),
+	}
+
+	from := time.Unix(1600000000, 0)
+	to := time.Unix(1700000100, 0)
+	page, err := client.GetRecentTracks(context.Background(), "rj", RecentTracksOptions{From: from, To: to, Page: 2})
+	if err != nil {
+		t.Fatalf("GetRecentTracks() error = %v", err)
+	}
+
+	wantQuery := map[string]string{
+		"method": "user.getRecentTracks",
+		"user":   "rj",
+		"page":   "2",
+		"limit":  "200",
+		"from":   "1600000000",
+		"to":     "1700000100",
+	}
+	for key, want := range wantQuery {
+		if got := gotQuery.Get(key); got != want {
+			t.Errorf("query %s = %q, want %q", key, got, want)
+		}
+	}
+
+	if page.Page != 2 || page.TotalPages != 7 || page.Total != 1301 {
+		t.Errorf("paging = %d/%d (%d total), want 2/7 (1301 total)", page.Page, page.TotalPages, page.Total)
+	}
+
+	// Now playing track is skipped
+	if len(page.Scrobbles) != 2 {
+		t.Fatalf("got %d scrobbles, want 2", len(page.Scrobbles))
+	}
+	want := Scrobble{
+		Artist:   "Radiohead",
+		Track:    "Lucky",
+		Album:    "OK Computer",
+		PlayedAt: time.Unix(1700000000, 0).UTC(),
+	}
+	if page.Scrobbles[0] != want {
+		t.Errorf("scrobble = %+v, want %+v", page.Scrobbles[0], want)
+	}
+}
+
+func TestGetRecentTracks_SingleTrackObject(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Last.fm returns an object, not an array, for a single track
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"recenttracks": {
+			"track": {"artist": {"#text": "Björk"}, "album": {"#text": "Homogenic"},
+			          "name": "Jóga", "date": {"uts": "1500000000"}},
+			"@attr": {"page": "1", "totalPages": "1", "total": "1"}
+		}}`))
+	}))
+	defer server.Close()
+
+	client := &Client{
+		apiKey:     "test-api-key",
+		httpClient: server.Client(),
+		baseURL:    server.URL + "/",
+		cache:      make(map[string][]Tag),
+	}
+
+	page, err := client.GetRecentTracks(context.Background(), "user", RecentTracksOptions{})
+	if err != nil {
+		t.Fatalf("GetRecentTracks() error = %v", err)
+	}
+	if len(page.Scrobbles) != 1 || page.Scrobbles[0].Track != "Jóga" {
+		t.Errorf("scrobbles = %+v, want one scrobble of Jóga", page.Scrobbles)
+	}
+}
+
+func TestGetRecentTracks_Empty(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"recenttracks": {"track": [], "@attr": {"page": "1", "totalPages": "0", "total": "0"}}}`))
+	}))
+	defer server.Close()
+
+	client := &Client{
+		apiKey:     "test-api-key",
+		httpClient: server.Client(),
+		baseURL:    server.URL + "/",
+		cache:      make(map[string][]Tag),
+	}
+
+	page, err := client.GetRecentTracks(context.Background(), "user", RecentTracksOptions{})
+	if err != nil {
+		t.Fatalf("GetRecentTracks() error = %v", err)
+	}
+	if len(page.Scrobbles) != 0 || page.TotalPages != 0 {
+		t.Errorf("page = %+v, want empty", page)
+	}
+}
+
+func TestGetRecentTracks_RateLimitRetry(t *testing.T) {
+	var requestCount atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if requestCount.Add(1) == 1 {
+			json.NewEncoder(w).Encode(apiError{Error: 29, Message: "Rate limit exceeded"})
+			return
+		}
+		w.Write([]byte(`{"recenttracks": {"track": [], "@attr": {"page": "1", "totalPages": "0", "total": "0"}}}`))
+	}))
+	defer server.Close()
+
+	client := &Client{
+		apiKey:     "test-api-key",
+		httpClient: server.Client(),
+		baseURL:    server.URL + "/",
+		cache:      make(map[string][]Tag),
+	}
+
+	if _, err := client.GetRecentTracks(context.Background(), "user", RecentTracksOptions{}); err != nil {
+		t.Fatalf("GetRecentTracks() error = %v", err)
+	}
+	if count := requestCount.Load(); count != 2 {
+		t.Errorf("Expected 2 requests, got %d", count)
+	}
+}
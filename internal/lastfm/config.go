@@ -1,25 +1,15 @@
-// Package lastfm provides Last.fm API integration for fetching track tags.
+// Package lastfm provides Last.fm API integration for fetching track tags
+// and scrobble history.
 package lastfm
 
-import (
-	"errors"
-	"os"
-)
+import "errors"
 
-// ErrMissingAPIKey is returned when LASTFM_API_KEY is not set.
-var ErrMissingAPIKey = errors.New("missing LASTFM_API_KEY environment variable")
+// ErrMissingAPIKey is returned by commands that need Last.fm when no API key
+// is configured.
+var ErrMissingAPIKey = errors.New("missing Last.fm API key: set LASTFM_API_KEY or lastfm.api_key in the config file")
 
 // Config holds Last.fm API configuration.
 type Config struct {
-	APIKey string
-}
-
-// LoadConfig reads Last.fm configuration from environment variables.
-// Returns ErrMissingAPIKey if LASTFM_API_KEY is not set.
-func LoadConfig() (*Config, error) {
-	apiKey := os.Getenv("LASTFM_API_KEY")
-	if apiKey == "" {
-		return nil, ErrMissingAPIKey
-	}
-	return &Config{APIKey: apiKey}, nil
+	APIKey  string
+	BaseURL string // Optional - overrides the API endpoint (e.g. a local fake in tests)
 }
@@ -0,0 +1,131 @@
+package lastfm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// MaxRecentTracksLimit is the largest page size user.getRecentTracks allows.
+const MaxRecentTracksLimit = 200
+
+// Scrobble is a single play recorded by Last.fm.
+type Scrobble struct {
+	Artist   string
+	Track    string
+	Album    string
+	PlayedAt time.Time // When playback started
+}
+
+// RecentTracksOptions selects a page of a user's scrobble history.
+type RecentTracksOptions struct {
+	From  time.Time // Only scrobbles at or after this time (zero for no bound)
+	To    time.Time // Only scrobbles at or before this time (zero for no bound)
+	Page  int       // 1-based page number (default: 1)
+	Limit int       // Scrobbles per page (default and max: MaxRecentTracksLimit)
+}
+
+// RecentTracksPage is one page of scrobbles, newest first.
+type RecentTracksPage struct {
+	Scrobbles  []Scrobble
+	Page       int
+	TotalPages int
+	Total      int
+}
+
+// GetRecentTracks fetches a page of a user's scrobbles via user.getRecentTracks.
+// The track currently playing is omitted, since it hasn't been scrobbled yet.
+// Results are not cached.
+func (c *Client) GetRecentTracks(ctx context.Context, user string, opts RecentTracksOptions) (*RecentTracksPage, error) {
+	if opts.Page <= 0 {
+		opts.Page = 1
+	}
+	if opts.Limit <= 0 || opts.Limit > MaxRecentTracksLimit {
+		opts.Limit = MaxRecentTracksLimit
+	}
+
+	params := url.Values{
+		"method":  {"user.getRecentTracks"},
+		"user":    {user},
+		"page":    {strconv.Itoa(opts.Page)},
+		"limit":   {strconv.Itoa(opts.Limit)},
+		"format":  {"json"},
+		"api_key": {c.apiKey},
+	}
+	if !opts.From.IsZero() {
+		params.Set("from", strconv.FormatInt(opts.From.Unix(), 10))
+	}
+	if !opts.To.IsZero() {
+		params.Set("to", strconv.FormatInt(opts.To.Unix(), 10))
+	}
+
+	body, err := c.doRequest(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("fetching recent tracks: %w", err)
+	}
+
+	var resp recentTracksResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("parsing recent tracks response: %w", err)
+	}
+
+	tracks, err := decodeRecentTracks(resp.RecentTracks.Track)
+	if err != nil {
+		return nil, fmt.Errorf("parsing recent tracks response: %w", err)
+	}
+
+	page := &RecentTracksPage{
+		Scrobbles:  make([]Scrobble, 0, len(tracks)),
+		Page:       atoi(resp.RecentTracks.Attr.Page),
+		TotalPages: atoi(resp.RecentTracks.Attr.TotalPages),
+		Total:      atoi(resp.RecentTracks.Attr.Total),
+	}
+	for _, t := range tracks {
+		if t.Attr.NowPlaying == "true" || t.Date.UTS == "" {
+			continue
+		}
+		uts, err := strconv.ParseInt(t.Date.UTS, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing scrobble time %q: %w", t.Date.UTS, err)
+		}
+		page.Scrobbles = append(page.Scrobbles, Scrobble{
+			Artist:   t.Artist.Text,
+			Track:    t.Name,
+			Album:    t.Album.Text,
+			PlayedAt: time.Unix(uts, 0).UTC(),
+		})
+	}
+	return page, nil
+}
+
+// decodeRecentTracks decodes the track field, which Last.fm returns as a
+// single object rather than an array when a page holds exactly one track.
+func decodeRecentTracks(raw json.RawMessage) ([]recentTrack, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	if raw[0] == '{' {
+		var t recentTrack
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return nil, err
+		}
+		return []recentTrack{t}, nil
+	}
+	var list []recentTrack
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// atoi parses a numeric string attribute, returning 0 if it is malformed.
+// Last.fm reports paging attributes as strings.
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -1,5 +1,7 @@
 package lastfm
 
+import "encoding/json"
+
 // Tag represents a Last.fm tag with popularity count.
 type Tag struct {
 	Name  string `json:"name"`
@@ -33,3 +35,36 @@ type apiError struct {
 	Error   int    `json:"error"`
 	Message string `json:"message"`
 }
+
+// recentTracksResponse is the JSON response for user.getRecentTracks.
+type recentTracksResponse struct {
+	RecentTracks struct {
+		Track json.RawMessage `json:"track"` // Object when there is one track, array otherwise
+		Attr  struct {
+			User       string `json:"user"`
+			Page       string `json:"page"`
+			PerPage    string `json:"perPage"`
+			TotalPages string `json:"totalPages"`
+			Total      string `json:"total"`
+		} `json:"@attr"`
+	} `json:"recenttracks"`
+}
+
+// recentTrack is a track entry in a user.getRecentTracks response.
+type recentTrack struct {
+	Artist struct {
+		Text string `json:"#text"`
+		MBID string `json:"mbid"`
+	} `json:"artist"`
+	Album struct {
+		Text string `json:"#text"`
+	} `json:"album"`
+	Name string `json:"name"`
+	Date struct {
+		UTS  string `json:"uts"`
+		Text string `json:"#text"`
+	} `json:"date"`
+	Attr struct {
+		NowPlaying string `json:"nowplaying"`
+	} `json:"@attr"`
+}
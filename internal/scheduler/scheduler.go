@@ -0,0 +1,265 @@
+// Package scheduler runs background syncs for every user with a stored
+// Spotify token: it syncs their liked songs, tags new tracks and re-runs era
+// detection with their settings.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/clustering"
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/eras"
+	"github.com/justestif/go-spotify-era-organizer/internal/spotify"
+	syncpkg "github.com/justestif/go-spotify-era-organizer/internal/sync"
+	"github.com/justestif/go-spotify-era-organizer/internal/tags"
+)
+
+// Scheduler defaults.
+const (
+	DefaultInterval = 24 * time.Hour   // Time between a user's syncs
+	DefaultJitter   = 30 * time.Minute // Most a user's sync is delayed by
+	DefaultTick     = 5 * time.Minute  // How often due users are looked for
+)
+
+// Config configures a Scheduler. A zero Interval, Tick or Cooldown uses the
+// default.
+type Config struct {
+	// Interval is the time between syncs for users who haven't chosen one.
+	// Intervals shorter than Cooldown are raised to it.
+	Interval time.Duration
+	// Jitter spreads syncs out: each user's syncs are delayed by a fixed
+	// amount up to Jitter, derived from their ID, so users who signed up
+	// together don't all sync at once. Zero turns jitter off.
+	Jitter time.Duration
+	// Tick is how often the scheduler looks for users who are due.
+	Tick time.Duration
+	// Cooldown is the minimum time between a user's syncs.
+	Cooldown time.Duration
+	// Defaults is the clustering configuration that users' settings
+	// override. A zero value uses clustering.DefaultTagClusterConfig.
+	Defaults clustering.TagClusterConfig
+	// Spotify configures users' Spotify clients, e.g. with
+	// spotify.WithAPIURL in tests.
+	Spotify []spotify.Option
+}
+
+// withDefaults fills in zero fields.
+func (c Config) withDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.Tick <= 0 {
+		c.Tick = DefaultTick
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = syncpkg.DefaultSyncCooldown
+	}
+	if c.Defaults == (clustering.TagClusterConfig{}) {
+		c.Defaults = clustering.DefaultTagClusterConfig()
+	}
+	return c
+}
+
+// Scheduler periodically syncs and re-analyzes every user's library.
+type Scheduler struct {
+	db       *db.DB
+	syncs    *syncpkg.Service
+	eras     *eras.Service
+	enricher *tags.Enricher
+	refresh  spotify.RefreshFunc
+	cfg      Config
+	now      func() time.Time
+}
+
+// New creates a scheduler. refresh refreshes users' stored tokens with the
+// app's Spotify credentials. enricher may be nil to skip tagging.
+func New(database *db.DB, refresh spotify.RefreshFunc, enricher *tags.Enricher, cfg Config) *Scheduler {
+	cfg = cfg.withDefaults()
+	return &Scheduler{
+		db:       database,
+		syncs:    syncpkg.New(database, syncpkg.WithSyncCooldown(cfg.Cooldown)),
+		eras:     eras.New(database, eras.WithDefaults(cfg.Defaults)),
+		enricher: enricher,
+		refresh:  refresh,
+		cfg:      cfg,
+		now:      time.Now,
+	}
+}
+
+// Run syncs due users now and then every tick until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Printf("Scheduler started: syncing every %s (jitter up to %s), checking every %s",
+		s.cfg.Interval, s.cfg.Jitter, s.cfg.Tick)
+
+	ticker := time.NewTicker(s.cfg.Tick)
+	defer ticker.Stop()
+	for {
+		result, err := s.RunOnce(ctx)
+		if err != nil {
+			log.Printf("Scheduler error: %v", err)
+		} else if result.Synced > 0 || result.Failed > 0 {
+			log.Printf("Scheduler run: %d synced, %d failed, %d skipped", result.Synced, result.Failed, result.Skipped)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunResult counts the users one scheduler run handled.
+type RunResult struct {
+	Synced  int // Users synced
+	Failed  int // Users whose sync failed; they're retried after their interval
+	Skipped int // Users who synced elsewhere within the cooldown
+}
+
+// RunOnce syncs every user who is due. Users are synced one at a time, so
+// the server shares Spotify and Last.fm rate limits with at most one
+// background sync.
+func (s *Scheduler) RunOnce(ctx context.Context) (*RunResult, error) {
+	users, err := s.db.Users().ListScheduled(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RunResult{}
+	for _, user := range users {
+		if ctx.Err() != nil {
+			break
+		}
+		if !s.due(user) {
+			continue
+		}
+
+		err := s.syncUser(ctx, user.ID)
+		switch {
+		case errors.Is(err, syncpkg.ErrSyncTooRecent):
+			result.Skipped++
+		case err != nil:
+			result.Failed++
+			log.Printf("Scheduled sync failed for user %s: %v", user.ID, err)
+			s.recordFailure(ctx, user.ID, err)
+		default:
+			result.Synced++
+		}
+	}
+	return result, nil
+}
+
+// recordFailure backs off from a user whose sync failed. A revoked refresh
+// token is cleared, so the user isn't scheduled until they log in again;
+// other failures are retried once the user's interval has passed.
+func (s *Scheduler) recordFailure(ctx context.Context, userID string, syncErr error) {
+	if spotify.IsRevoked(syncErr) {
+		log.Printf("Spotify access revoked for user %s; scheduled syncs stop until they log in again", userID)
+		if err := s.db.Users().ClearToken(ctx, userID); err != nil {
+			log.Printf("Warning: clearing revoked token for user %s: %v", userID, err)
+		}
+		return
+	}
+	if err := s.db.Users().RecordSyncFailure(ctx, userID, s.now()); err != nil {
+		log.Printf("Warning: recording sync failure for user %s: %v", userID, err)
+	}
+}
+
+// due reports whether the user's interval and jitter have passed since
+// their last sync or, if it was later, their last failed sync. Users who
+// never synced are due at once; users who turned scheduled syncs off never
+// are.
+func (s *Scheduler) due(user db.ScheduledUser) bool {
+	interval := s.cfg.Interval
+	if user.SyncIntervalHours != nil {
+		if *user.SyncIntervalHours == 0 {
+			return false
+		}
+		interval = time.Duration(*user.SyncIntervalHours) * time.Hour
+	}
+	interval = max(interval, s.cfg.Cooldown)
+
+	last := user.LastSyncAt
+	if user.SyncFailedAt != nil && (last == nil || user.SyncFailedAt.After(*last)) {
+		last = user.SyncFailedAt
+	}
+	if last == nil {
+		return true
+	}
+	next := last.Add(interval + jitter(user.ID, s.cfg.Jitter))
+	return !s.now().Before(next)
+}
+
+// jitter returns the user's fixed delay, between 0 and limit.
+func jitter(userID string, limit time.Duration) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(userID))
+	return time.Duration(h.Sum64() % uint64(limit))
+}
+
+// syncUser syncs the user's liked songs, tags new tracks and re-runs era
+// detection with their settings. The cooldown applies, so a user who just
+// synced from the web UI is skipped with ErrSyncTooRecent.
+func (s *Scheduler) syncUser(ctx context.Context, userID string) error {
+	client, err := s.client(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	synced, err := s.syncs.SyncLikedSongs(ctx, client, userID, false)
+	if err != nil {
+		return err
+	}
+	log.Printf("Scheduled sync for user %s: %d tracks", userID, synced.TracksCount)
+
+	if s.enricher != nil {
+		tagged, err := s.enricher.FetchMissing(ctx, userID)
+		if err != nil {
+			// Eras can still be detected from the tags already stored
+			log.Printf("Warning: scheduled tag fetch failed for user %s: %v", userID, err)
+		} else if tagged.TracksMissing+tagged.TracksStale > 0 {
+			log.Printf("Scheduled tag fetch for user %s: tagged %d of %d tracks", userID, tagged.TracksTagged, tagged.TracksMissing+tagged.TracksStale)
+		}
+	}
+
+	cfg, err := s.eras.DefaultConfig(ctx, userID)
+	if err != nil {
+		return err
+	}
+	detected, err := s.eras.DetectAndPersist(ctx, userID, cfg)
+	if err != nil {
+		return fmt.Errorf("detecting eras: %w", err)
+	}
+	log.Printf("Scheduled analysis for user %s: %d eras", userID, len(detected.Eras))
+	return nil
+}
+
+// client returns a Spotify client for the user's stored token. Refreshed
+// tokens are saved back to the user record.
+func (s *Scheduler) client(ctx context.Context, userID string) (spotify.LibraryClient, error) {
+	stored, err := s.db.Users().GetToken(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("getting token: %w", err)
+	}
+	token := &oauth2.Token{
+		AccessToken:  stored.AccessToken,
+		RefreshToken: stored.RefreshToken,
+		Expiry:       stored.TokenExpiry,
+		TokenType:    "Bearer",
+	}
+	save := func(ctx context.Context, token *oauth2.Token) error {
+		return s.db.Users().SaveToken(ctx, userID, token.AccessToken, token.RefreshToken, token.Expiry)
+	}
+	return spotify.NewClientFromTokenSource(ctx, spotify.NewTokenSource(ctx, token, s.refresh, save), s.cfg.Spotify...), nil
+}
@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/db/dbtest"
+	"github.com/justestif/go-spotify-era-organizer/internal/spotify"
+	"github.com/justestif/go-spotify-era-organizer/internal/spotify/spotifytest"
+	syncpkg "github.com/justestif/go-spotify-era-organizer/internal/sync"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestScheduler_Due(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := &Scheduler{
+		cfg: Config{Interval: 24 * time.Hour}.withDefaults(),
+		now: func() time.Time { return now },
+	}
+	ago := func(d time.Duration) *time.Time {
+		at := now.Add(-d)
+		return &at
+	}
+
+	tests := []struct {
+		name string
+		user db.ScheduledUser
+		want bool
+	}{
+		{"never synced", db.ScheduledUser{ID: "a"}, true},
+		{"default interval passed", db.ScheduledUser{ID: "a", LastSyncAt: ago(25 * time.Hour)}, true},
+		{"default interval not passed", db.ScheduledUser{ID: "a", LastSyncAt: ago(23 * time.Hour)}, false},
+		{"own interval passed", db.ScheduledUser{ID: "a", LastSyncAt: ago(7 * time.Hour), SyncIntervalHours: ptr(6)}, true},
+		{"own interval not passed", db.ScheduledUser{ID: "a", LastSyncAt: ago(30 * time.Hour), SyncIntervalHours: ptr(48)}, false},
+		{"turned off", db.ScheduledUser{ID: "a", SyncIntervalHours: ptr(0)}, false},
+		{"failed since last sync", db.ScheduledUser{ID: "a", LastSyncAt: ago(48 * time.Hour), SyncFailedAt: ago(time.Hour)}, false},
+		{"failed before last sync", db.ScheduledUser{ID: "a", LastSyncAt: ago(25 * time.Hour), SyncFailedAt: ago(30 * time.Hour)}, true},
+		{"failed without ever syncing", db.ScheduledUser{ID: "a", SyncFailedAt: ago(time.Hour)}, false},
+		{"interval passed since failing", db.ScheduledUser{ID: "a", SyncFailedAt: ago(25 * time.Hour)}, true},
+		{
+			"interval below cooldown",
+			db.ScheduledUser{ID: "a", LastSyncAt: ago(syncpkg.DefaultSyncCooldown / 2), SyncIntervalHours: ptr(1)},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.due(tt.user); got != tt.want {
+				t.Errorf("due() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduler_DueWithJitter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := &Scheduler{
+		cfg: Config{Interval: 24 * time.Hour, Jitter: time.Hour}.withDefaults(),
+		now: func() time.Time { return now },
+	}
+
+	// Find a user whose jitter is large enough to matter
+	userID := ""
+	for _, id := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		if jitter(id, time.Hour) > time.Minute {
+			userID = id
+			break
+		}
+	}
+	if userID == "" {
+		t.Fatal("no user with jitter over a minute")
+	}
+	delay := jitter(userID, time.Hour)
+
+	justDue := now.Add(-24*time.Hour - delay)
+	if !s.due(db.ScheduledUser{ID: userID, LastSyncAt: &justDue}) {
+		t.Error("not due once interval and jitter passed")
+	}
+	early := now.Add(-24*time.Hour - delay + time.Minute)
+	if s.due(db.ScheduledUser{ID: userID, LastSyncAt: &early}) {
+		t.Error("due before the user's jitter passed")
+	}
+}
+
+func TestJitter(t *testing.T) {
+	if got := jitter("alice", 0); got != 0 {
+		t.Errorf("jitter with no limit = %s, want 0", got)
+	}
+
+	seen := make(map[time.Duration]bool)
+	for _, id := range []string{"alice", "bob", "carol", "dave"} {
+		got := jitter(id, 30*time.Minute)
+		if got < 0 || got >= 30*time.Minute {
+			t.Errorf("jitter(%q) = %s, want within [0, 30m)", id, got)
+		}
+		if again := jitter(id, 30*time.Minute); again != got {
+			t.Errorf("jitter(%q) changed from %s to %s", id, got, again)
+		}
+		seen[got] = true
+	}
+	if len(seen) < 2 {
+		t.Error("every user got the same jitter")
+	}
+}
+
+func TestScheduler_RunOnce_Failures(t *testing.T) {
+	ctx := context.Background()
+	database := dbtest.NewSQLite(t)
+	srv := spotifytest.NewServer(t, "alice")
+	srv.AddLikedSongs(spotifytest.Song{ID: "t1", Name: "Song", AddedAt: time.Now()})
+
+	// alice's access token is rejected by the API; bob's refresh token
+	// has been revoked
+	valid := srv.Token()
+	expired := srv.ExpiredToken()
+	tokens := map[string]struct {
+		access, refresh string
+		expiry          time.Time
+	}{
+		"alice": {"unknown", valid.RefreshToken, valid.Expiry},
+		"bob":   {expired.AccessToken, "revoked", expired.Expiry},
+	}
+	for id, token := range tokens {
+		if err := database.Users().EnsureExists(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if err := database.Users().SaveToken(ctx, id, token.access, token.refresh, token.expiry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	s := New(database, spotify.ConfigRefresher(srv.OAuthConfig()), nil, Config{
+		Interval: 24 * time.Hour,
+		Spotify:  []spotify.Option{spotify.WithAPIURL(srv.APIURL())},
+	})
+	s.now = func() time.Time { return now }
+
+	result, err := s.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if result.Failed != 2 {
+		t.Fatalf("RunOnce() = %+v, want both users failed", result)
+	}
+
+	// bob's revoked token is cleared; alice backs off until her interval
+	// has passed
+	if _, err := database.Users().GetToken(ctx, "bob"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("bob's token error = %v, want it cleared", err)
+	}
+	users, err := database.Users().ListScheduled(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].ID != "alice" || users[0].SyncFailedAt == nil {
+		t.Fatalf("scheduled users = %+v, want alice with her failure recorded", users)
+	}
+	result, err = s.RunOnce(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *result != (RunResult{}) {
+		t.Errorf("RunOnce() right after failing = %+v, want nobody synced", result)
+	}
+
+	// Once alice has a working token and her interval passes, she syncs
+	if err := database.Users().SaveToken(ctx, "alice", valid.AccessToken, valid.RefreshToken, valid.Expiry); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(25 * time.Hour)
+	result, err = s.RunOnce(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Synced != 1 || result.Failed != 0 {
+		t.Errorf("RunOnce() after the interval = %+v, want alice synced", result)
+	}
+}
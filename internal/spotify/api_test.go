@@ -0,0 +1,192 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/spotify/spotifytest"
+)
+
+var likedAt = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestClient returns a client for a fake API with a valid token.
+func newTestClient(t *testing.T, srv *spotifytest.Server) *Client {
+	t.Helper()
+	source := oauth2.StaticTokenSource(srv.Token())
+	return NewClientFromTokenSource(context.Background(), source, WithAPIURL(srv.APIURL()))
+}
+
+// likedSongs returns n songs liked an hour apart, the first by two artists.
+func likedSongs(n int) []spotifytest.Song {
+	songs := make([]spotifytest.Song, n)
+	for i := range songs {
+		songs[i] = spotifytest.Song{
+			ID:         fmt.Sprintf("t%d", i+1),
+			Name:       fmt.Sprintf("Song %d", i+1),
+			Artists:    []spotifytest.Artist{{ID: "ar1", Name: "Singer"}},
+			Album:      "Album",
+			AlbumID:    "al1",
+			DurationMs: 180000,
+			AddedAt:    likedAt.Add(time.Duration(i) * time.Hour),
+		}
+	}
+	songs[0].Artists = append(songs[0].Artists, spotifytest.Artist{ID: "ar2", Name: "Rapper"})
+	return songs
+}
+
+func TestClient_FetchAllLikedSongsWithMetadata(t *testing.T) {
+	srv := spotifytest.NewServer(t, "alice")
+	srv.AddLikedSongs(likedSongs(5)...)
+	srv.SetPageSize(2)
+	srv.RateLimit(2)
+
+	tracks, err := newTestClient(t, srv).FetchAllLikedSongsWithMetadata(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAllLikedSongsWithMetadata() error = %v", err)
+	}
+
+	if len(tracks) != 5 {
+		t.Fatalf("got %d tracks across pages, want 5", len(tracks))
+	}
+	seen := make(map[string]bool)
+	for _, track := range tracks {
+		seen[track.ID] = true
+	}
+	if len(seen) != 5 {
+		t.Errorf("tracks = %+v, want each song once", tracks)
+	}
+	if srv.Throttled() != 2 {
+		t.Errorf("throttled %d requests, want 2 retried", srv.Throttled())
+	}
+
+	var first FullTrack
+	for _, track := range tracks {
+		if track.ID == "t1" {
+			first = track
+		}
+	}
+	if first.Artist != "Singer, Rapper" || len(first.Artists) != 2 || first.Album != "Album" || !first.AddedAt.Equal(likedAt) {
+		t.Errorf("t1 = %+v, want its artists, album and liked time", first)
+	}
+}
+
+func TestClient_FetchAllLikedSongs(t *testing.T) {
+	srv := spotifytest.NewServer(t, "alice")
+	srv.AddLikedSongs(likedSongs(3)...)
+	srv.SetPageSize(1)
+
+	tracks, err := newTestClient(t, srv).FetchAllLikedSongs(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAllLikedSongs() error = %v", err)
+	}
+	if len(tracks) != 3 {
+		t.Errorf("got %d tracks, want 3", len(tracks))
+	}
+}
+
+func TestClient_RefreshesExpiredToken(t *testing.T) {
+	ctx := context.Background()
+	srv := spotifytest.NewServer(t, "alice")
+	var saved []*oauth2.Token
+	save := func(_ context.Context, token *oauth2.Token) error {
+		saved = append(saved, token)
+		return nil
+	}
+
+	source := NewTokenSource(ctx, srv.ExpiredToken(), ConfigRefresher(srv.OAuthConfig()), save)
+	client := NewClientFromTokenSource(ctx, source, WithAPIURL(srv.APIURL()))
+	userID, err := client.UserID(ctx)
+	if err != nil {
+		t.Fatalf("UserID() error = %v", err)
+	}
+
+	if userID != "alice" {
+		t.Errorf("UserID() = %q, want alice", userID)
+	}
+	if srv.Refreshes() != 1 {
+		t.Errorf("refreshed %d times, want 1", srv.Refreshes())
+	}
+	if len(saved) != 1 || !saved[0].Valid() || saved[0].RefreshToken == "" {
+		t.Errorf("saved = %+v, want the refreshed token with its refresh token", saved)
+	}
+}
+
+func TestClient_Profile(t *testing.T) {
+	srv := spotifytest.NewServer(t, "alice")
+
+	profile, err := newTestClient(t, srv).Profile(context.Background())
+	if err != nil {
+		t.Fatalf("Profile() error = %v", err)
+	}
+	if profile.ID != "alice" || profile.DisplayName == "" || profile.Email == "" {
+		t.Errorf("Profile() = %+v, want alice's ID, name and email", profile)
+	}
+}
+
+func TestClient_CreatePlaylist(t *testing.T) {
+	ctx := context.Background()
+	srv := spotifytest.NewServer(t, "alice")
+	client := newTestClient(t, srv)
+
+	playlistID, err := client.CreatePlaylist(ctx, "Summer", "Songs from summer", true)
+	if err != nil {
+		t.Fatalf("CreatePlaylist() error = %v", err)
+	}
+	trackIDs := make([]string, 250)
+	for i := range trackIDs {
+		trackIDs[i] = fmt.Sprintf("t%d", i)
+	}
+	if err := client.AddTracksToPlaylist(ctx, playlistID, trackIDs); err != nil {
+		t.Fatalf("AddTracksToPlaylist() error = %v", err)
+	}
+
+	playlists := srv.Playlists()
+	if len(playlists) != 1 {
+		t.Fatalf("playlists = %+v, want one", playlists)
+	}
+	got := playlists[0]
+	if got.ID != playlistID || got.Name != "Summer" || got.Description != "Songs from summer" || !got.Public {
+		t.Errorf("playlist = %+v, want %s named Summer", got, playlistID)
+	}
+	if len(got.TrackIDs) != 250 || got.TrackIDs[0] != "t0" || got.TrackIDs[249] != "t249" {
+		t.Errorf("playlist has %d tracks, want all 250 in order", len(got.TrackIDs))
+	}
+}
+
+func TestClient_AddTracksToPlaylist_UnknownPlaylist(t *testing.T) {
+	srv := spotifytest.NewServer(t, "alice")
+
+	err := newTestClient(t, srv).AddTracksToPlaylist(context.Background(), "missing", []string{"t1"})
+	if err == nil {
+		t.Error("AddTracksToPlaylist() to a missing playlist succeeded, want an error")
+	}
+}
+
+func TestClient_FetchArtists(t *testing.T) {
+	srv := spotifytest.NewServer(t, "alice")
+	songs := make([]spotifytest.Song, 60)
+	artistIDs := make([]string, 0, 61)
+	for i := range songs {
+		artist := spotifytest.Artist{ID: fmt.Sprintf("ar%d", i), Name: fmt.Sprintf("Artist %d", i)}
+		songs[i] = spotifytest.Song{ID: fmt.Sprintf("t%d", i), Artists: []spotifytest.Artist{artist}, AddedAt: likedAt}
+		artistIDs = append(artistIDs, artist.ID)
+	}
+	srv.AddLikedSongs(songs...)
+	artistIDs = append(artistIDs, "unknown")
+
+	artists, err := newTestClient(t, srv).FetchArtists(context.Background(), artistIDs)
+	if err != nil {
+		t.Fatalf("FetchArtists() error = %v", err)
+	}
+
+	if len(artists) != 60 {
+		t.Fatalf("got %d artists across batches, want 60 without the unknown one", len(artists))
+	}
+	if artists[59] != (Artist{ID: "ar59", Name: "Artist 59"}) {
+		t.Errorf("artists[59] = %+v, want ar59", artists[59])
+	}
+}
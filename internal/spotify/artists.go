@@ -0,0 +1,36 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+const maxArtistsPerRequest = 50
+
+// FetchArtists retrieves artists by ID, batching requests for large sets.
+// Spotify allows max 50 artists per request. IDs Spotify doesn't know are
+// skipped.
+func (c *Client) FetchArtists(ctx context.Context, artistIDs []string) ([]Artist, error) {
+	var artists []Artist
+	for i := 0; i < len(artistIDs); i += maxArtistsPerRequest {
+		end := min(i+maxArtistsPerRequest, len(artistIDs))
+		ids := make([]spotify.ID, end-i)
+		for j, id := range artistIDs[i:end] {
+			ids[j] = spotify.ID(id)
+		}
+
+		batch, err := c.api.GetArtists(ctx, ids...)
+		if err != nil {
+			return nil, fmt.Errorf("fetching artists (batch %d-%d): %w", i+1, end, err)
+		}
+		for _, a := range batch {
+			// Unknown IDs come back as null
+			if a != nil {
+				artists = append(artists, Artist{ID: a.ID.String(), Name: a.Name})
+			}
+		}
+	}
+	return artists, nil
+}
@@ -6,24 +6,88 @@ import (
 	"fmt"
 
 	"github.com/zmb3/spotify/v2"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/clustering"
 )
 
+// LibraryClient is the part of the Spotify Web API the app uses: the
+// current user's liked songs, playlists and the artists on their tracks.
+// It is implemented by *Client. Tests point a Client at a
+// spotifytest.Server with WithAPIURL.
+type LibraryClient interface {
+	// UserID returns the current user's Spotify ID.
+	UserID(ctx context.Context) (string, error)
+	// FetchAllLikedSongs retrieves every liked song for clustering.
+	FetchAllLikedSongs(ctx context.Context) ([]clustering.Track, error)
+	// FetchAllLikedSongsWithMetadata retrieves every liked song with the
+	// metadata stored on sync.
+	FetchAllLikedSongsWithMetadata(ctx context.Context) ([]FullTrack, error)
+	// FetchArtists retrieves artists by ID.
+	FetchArtists(ctx context.Context, artistIDs []string) ([]Artist, error)
+	// CreatePlaylist creates a playlist for the current user and returns
+	// its ID.
+	CreatePlaylist(ctx context.Context, name, description string, public bool) (string, error)
+	// AddTracksToPlaylist appends tracks to a playlist.
+	AddTracksToPlaylist(ctx context.Context, playlistID string, trackIDs []string) error
+}
+
 // Client wraps the Spotify API client with convenience methods.
 type Client struct {
 	api *spotify.Client
 }
 
+var _ LibraryClient = (*Client)(nil)
+
 // New creates a new Spotify client wrapper.
 // The underlying client should already be authenticated.
 func New(api *spotify.Client) *Client {
 	return &Client{api: api}
 }
 
+// Option configures a client built by NewClientFromTokenSource.
+type Option func(*options)
+
+type options struct {
+	apiURL string
+}
+
+// WithAPIURL sends requests to url, e.g. a spotifytest.Server's APIURL,
+// instead of the Spotify Web API. The URL must end in a slash.
+func WithAPIURL(url string) Option {
+	return func(o *options) {
+		o.apiURL = url
+	}
+}
+
+// apiOptions converts opts to options for the underlying client. Requests
+// rate-limited with 429 Too Many Requests are retried once the server's
+// Retry-After delay has passed.
+func apiOptions(opts []Option) []spotify.ClientOption {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	apiOpts := []spotify.ClientOption{spotify.WithRetry(true)}
+	if o.apiURL != "" {
+		apiOpts = append(apiOpts, spotify.WithBaseURL(o.apiURL))
+	}
+	return apiOpts
+}
+
 // UserID returns the current user's Spotify ID.
 func (c *Client) UserID(ctx context.Context) (string, error) {
+	profile, err := c.Profile(ctx)
+	if err != nil {
+		return "", err
+	}
+	return profile.ID, nil
+}
+
+// Profile returns the current user's profile.
+func (c *Client) Profile(ctx context.Context) (*Profile, error) {
 	user, err := c.api.CurrentUser(ctx)
 	if err != nil {
-		return "", fmt.Errorf("getting current user: %w", err)
+		return nil, fmt.Errorf("getting current user: %w", err)
 	}
-	return user.ID, nil
+	return &Profile{ID: user.ID, DisplayName: user.DisplayName, Email: user.Email}, nil
 }
@@ -114,6 +114,41 @@ func TestConvertTrack(t *testing.T) {
 	}
 }
 
+func TestConvertToFullTrack_Artists(t *testing.T) {
+	saved := spotify.SavedTrack{
+		AddedAt: "2024-01-15T10:30:00Z",
+		FullTrack: spotify.FullTrack{
+			SimpleTrack: spotify.SimpleTrack{
+				ID:   "track123",
+				Name: "Collab Track",
+				Artists: []spotify.SimpleArtist{
+					{ID: "artistA", Name: "Artist A"},
+					{ID: "artistB", Name: "Artist B"},
+				},
+			},
+		},
+	}
+
+	got := convertToFullTrack(saved)
+
+	if got.Artist != "Artist A, Artist B" {
+		t.Errorf("Artist = %q, want %q", got.Artist, "Artist A, Artist B")
+	}
+
+	want := []Artist{
+		{ID: "artistA", Name: "Artist A"},
+		{ID: "artistB", Name: "Artist B"},
+	}
+	if len(got.Artists) != len(want) {
+		t.Fatalf("got %d artists, want %d", len(got.Artists), len(want))
+	}
+	for i, a := range want {
+		if got.Artists[i] != a {
+			t.Errorf("Artists[%d] = %+v, want %+v", i, got.Artists[i], a)
+		}
+	}
+}
+
 func TestBatchChunking(t *testing.T) {
 	tests := []struct {
 		name          string
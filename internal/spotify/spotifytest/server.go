@@ -0,0 +1,499 @@
+// Package spotifytest provides a fake Spotify Web API for tests. A Server
+// serves one user's liked songs, artists and playlists over HTTP with the
+// same paging, errors and rate limiting as the real API, and issues and
+// refreshes OAuth tokens, so clients are exercised end to end without
+// network access.
+package spotifytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+	"golang.org/x/oauth2"
+)
+
+// OAuth client credentials the token endpoint accepts.
+const (
+	ClientID     = "spotifytest-client"
+	ClientSecret = "spotifytest-secret"
+)
+
+// Spotify API limits the server enforces.
+const (
+	maxPageSize                 = 50
+	maxArtistsPerRequest        = 50
+	maxTracksPerPlaylistRequest = 100
+)
+
+// Song is a liked song in the user's library.
+type Song struct {
+	ID         string
+	Name       string
+	Artists    []Artist // Credited artists in order, primary artist first
+	Album      string
+	AlbumID    string
+	DurationMs int
+	AddedAt    time.Time
+}
+
+// Artist is a Spotify artist.
+type Artist struct {
+	ID   string
+	Name string
+}
+
+// Playlist is a playlist created through the API.
+type Playlist struct {
+	ID          string
+	Name        string
+	Description string
+	Public      bool
+	TrackIDs    []string
+}
+
+// Server is a fake Spotify Web API for one user. Requests must carry a
+// valid access token from Token or the token endpoint. The zero value is
+// not usable; create one with NewServer. It is safe for concurrent use.
+type Server struct {
+	server *httptest.Server
+	userID string
+
+	mu           sync.Mutex
+	liked        []Song
+	artists      map[string]Artist
+	playlists    []Playlist
+	pageSize     int
+	rateLimit    int // Requests left to reject with 429
+	throttled    int // Requests rejected with 429
+	accessTokens map[string]bool
+	refreshToken string
+	issued       int // Access tokens issued
+	refreshes    int
+}
+
+// NewServer starts a fake API for the user with the given Spotify ID. It
+// is closed when the test finishes.
+func NewServer(t testing.TB, userID string) *Server {
+	t.Helper()
+	s := &Server{
+		userID:       userID,
+		artists:      make(map[string]Artist),
+		pageSize:     maxPageSize,
+		accessTokens: make(map[string]bool),
+		refreshToken: "refresh-" + userID,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/token", s.handleToken)
+	api := http.NewServeMux()
+	api.HandleFunc("GET /v1/me", s.handleMe)
+	api.HandleFunc("GET /v1/me/tracks", s.handleLikedSongs)
+	api.HandleFunc("GET /v1/artists", s.handleArtists)
+	api.HandleFunc("POST /v1/users/{user}/playlists", s.handleCreatePlaylist)
+	api.HandleFunc("POST /v1/playlists/{id}/tracks", s.handleAddTracks)
+	mux.Handle("/v1/", s.limit(s.authorize(api)))
+
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// APIURL returns the base URL of the Web API, for spotify.WithAPIURL.
+func (s *Server) APIURL() string {
+	return s.server.URL + "/v1/"
+}
+
+// TokenURL returns the URL of the OAuth token endpoint.
+func (s *Server) TokenURL() string {
+	return s.server.URL + "/api/token"
+}
+
+// OAuthConfig returns an OAuth configuration that refreshes tokens with
+// the server.
+func (s *Server) OAuthConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     ClientID,
+		ClientSecret: ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			TokenURL:  s.TokenURL(),
+			AuthStyle: oauth2.AuthStyleInHeader,
+		},
+	}
+}
+
+// Token issues a new access token, valid for an hour, with the user's
+// refresh token.
+func (s *Server) Token() *oauth2.Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &oauth2.Token{
+		AccessToken:  s.issue(),
+		TokenType:    "Bearer",
+		RefreshToken: s.refreshToken,
+		Expiry:       time.Now().Add(time.Hour),
+	}
+}
+
+// ExpiredToken issues an access token that has already expired, so a
+// client must refresh it before its first request.
+func (s *Server) ExpiredToken() *oauth2.Token {
+	token := s.Token()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.accessTokens, token.AccessToken)
+	token.Expiry = time.Now().Add(-time.Minute)
+	return token
+}
+
+// issue creates a valid access token. The caller must hold s.mu.
+func (s *Server) issue() string {
+	s.issued++
+	token := fmt.Sprintf("access-%s-%d", s.userID, s.issued)
+	s.accessTokens[token] = true
+	return token
+}
+
+// AddLikedSongs adds songs to the user's library and their artists to the
+// catalog.
+func (s *Server) AddLikedSongs(songs ...Song) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.liked = append(s.liked, songs...)
+	for _, song := range songs {
+		for _, a := range song.Artists {
+			s.artists[a.ID] = a
+		}
+	}
+}
+
+// SetPageSize caps the number of liked songs per page below Spotify's 50,
+// so small libraries span several pages.
+func (s *Server) SetPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pageSize = min(n, maxPageSize)
+}
+
+// RateLimit makes the next n API requests fail with 429 Too Many
+// Requests and a Retry-After of zero seconds.
+func (s *Server) RateLimit(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rateLimit = n
+}
+
+// Throttled returns the number of requests rejected with 429.
+func (s *Server) Throttled() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.throttled
+}
+
+// Refreshes returns the number of tokens refreshed.
+func (s *Server) Refreshes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.refreshes
+}
+
+// Playlists returns the playlists created so far, oldest first.
+func (s *Server) Playlists() []Playlist {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	playlists := make([]Playlist, len(s.playlists))
+	for i, p := range s.playlists {
+		p.TrackIDs = slices.Clone(p.TrackIDs)
+		playlists[i] = p
+	}
+	return playlists
+}
+
+// limit rejects requests while the server is rate limited.
+func (s *Server) limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		limited := s.rateLimit > 0
+		if limited {
+			s.rateLimit--
+			s.throttled++
+		}
+		s.mu.Unlock()
+
+		if limited {
+			w.Header().Set("Retry-After", "0")
+			writeError(w, http.StatusTooManyRequests, "API rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorize rejects requests without a valid access token.
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "No token provided")
+			return
+		}
+
+		s.mu.Lock()
+		valid := s.accessTokens[token]
+		s.mu.Unlock()
+
+		if !valid {
+			writeError(w, http.StatusUnauthorized, "The access token expired")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleToken refreshes an access token. Like Spotify, the response
+// leaves out the refresh token, which stays the same.
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != ClientID || secret != ClientSecret {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if r.FormValue("grant_type") != "refresh_token" {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	s.mu.Lock()
+	if r.FormValue("refresh_token") != s.refreshToken {
+		s.mu.Unlock()
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+	s.refreshes++
+	token := s.issue()
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, _ *http.Request) {
+	var user spotify.PrivateUser
+	user.ID = s.userID
+	user.DisplayName = "User " + s.userID
+	user.Email = s.userID + "@example.com"
+	writeJSON(w, http.StatusOK, user)
+}
+
+// handleLikedSongs serves a page of the user's liked songs, most recently
+// added first.
+func (s *Server) handleLikedSongs(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	liked := slices.Clone(s.liked)
+	limit = min(limit, s.pageSize)
+	s.mu.Unlock()
+
+	slices.SortStableFunc(liked, func(a, b Song) int { return b.AddedAt.Compare(a.AddedAt) })
+	end := min(offset+limit, len(liked))
+	start := min(offset, end)
+
+	var page spotify.SavedTrackPage
+	page.Endpoint = s.pageURL(offset, limit)
+	page.Limit = spotify.Numeric(limit)
+	page.Offset = spotify.Numeric(offset)
+	page.Total = spotify.Numeric(len(liked))
+	if end < len(liked) {
+		page.Next = s.pageURL(end, limit)
+	}
+	if offset > 0 {
+		page.Previous = s.pageURL(max(offset-limit, 0), limit)
+	}
+	page.Tracks = make([]spotify.SavedTrack, 0, end-start)
+	for _, song := range liked[start:end] {
+		page.Tracks = append(page.Tracks, savedTrack(song))
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// pageURL returns the URL of a page of liked songs.
+func (s *Server) pageURL(offset, limit int) string {
+	return fmt.Sprintf("%sme/tracks?offset=%d&limit=%d", s.APIURL(), offset, limit)
+}
+
+// pageParams parses the limit and offset of a paged request. Spotify's
+// default limit is 20 and its maximum 50.
+func pageParams(w http.ResponseWriter, r *http.Request) (limit, offset int, ok bool) {
+	limit, offset = 20, 0
+	query := r.URL.Query()
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			writeError(w, http.StatusBadRequest, "Invalid limit")
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "Invalid offset")
+			return 0, 0, false
+		}
+		offset = n
+	}
+	return limit, offset, true
+}
+
+// savedTrack converts a liked song to the API's representation.
+func savedTrack(song Song) spotify.SavedTrack {
+	artists := make([]spotify.SimpleArtist, len(song.Artists))
+	for i, a := range song.Artists {
+		artists[i] = spotify.SimpleArtist{ID: spotify.ID(a.ID), Name: a.Name}
+	}
+
+	var saved spotify.SavedTrack
+	saved.AddedAt = song.AddedAt.UTC().Format(spotify.TimestampLayout)
+	saved.ID = spotify.ID(song.ID)
+	saved.Name = song.Name
+	saved.Artists = artists
+	saved.Duration = spotify.Numeric(song.DurationMs)
+	saved.Album = spotify.SimpleAlbum{ID: spotify.ID(song.AlbumID), Name: song.Album, Artists: artists}
+	saved.URI = spotify.URI("spotify:track:" + song.ID)
+	return saved
+}
+
+// handleArtists serves several artists by ID. Unknown IDs are null.
+func (s *Server) handleArtists(w http.ResponseWriter, r *http.Request) {
+	ids := strings.Split(r.URL.Query().Get("ids"), ",")
+	if len(ids) > maxArtistsPerRequest {
+		writeError(w, http.StatusBadRequest, "Too many ids requested")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	artists := make([]*spotify.FullArtist, len(ids))
+	for i, id := range ids {
+		if a, ok := s.artists[id]; ok {
+			artists[i] = &spotify.FullArtist{SimpleArtist: spotify.SimpleArtist{ID: spotify.ID(a.ID), Name: a.Name}}
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"artists": artists})
+}
+
+// handleCreatePlaylist creates a playlist for the user. Creating one for
+// another user is forbidden.
+func (s *Server) handleCreatePlaylist(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("user") != s.userID {
+		writeError(w, http.StatusForbidden, "You cannot create a playlist for another user")
+		return
+	}
+	var body struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Public      bool   `json:"public"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" {
+		writeError(w, http.StatusBadRequest, "Missing playlist name")
+		return
+	}
+
+	s.mu.Lock()
+	playlist := Playlist{
+		ID:          fmt.Sprintf("playlist%d", len(s.playlists)+1),
+		Name:        body.Name,
+		Description: body.Description,
+		Public:      body.Public,
+	}
+	s.playlists = append(s.playlists, playlist)
+	s.mu.Unlock()
+
+	var created spotify.FullPlaylist
+	created.ID = spotify.ID(playlist.ID)
+	created.Name = playlist.Name
+	created.Description = playlist.Description
+	created.IsPublic = playlist.Public
+	created.Owner = spotify.User{ID: s.userID}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// handleAddTracks appends up to 100 tracks, given as URIs, to a playlist.
+func (s *Server) handleAddTracks(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		URIs []string `json:"uris"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(body.URIs) > maxTracksPerPlaylistRequest {
+		writeError(w, http.StatusBadRequest, "Too many tracks requested")
+		return
+	}
+	ids := make([]string, len(body.URIs))
+	for i, uri := range body.URIs {
+		id, ok := strings.CutPrefix(uri, "spotify:track:")
+		if !ok {
+			writeError(w, http.StatusBadRequest, "Invalid track uri: "+uri)
+			return
+		}
+		ids[i] = id
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.playlists, func(p Playlist) bool { return p.ID == r.PathValue("id") })
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+	s.playlists[i].TrackIDs = append(s.playlists[i].TrackIDs, ids...)
+	writeJSON(w, http.StatusCreated, map[string]string{
+		"snapshot_id": fmt.Sprintf("snapshot%d", len(s.playlists[i].TrackIDs)),
+	})
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a Web API error object.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{"status": status, "message": message},
+	})
+}
+
+// writeOAuthError writes an OAuth token endpoint error.
+func writeOAuthError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
@@ -0,0 +1,81 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/zmb3/spotify/v2"
+	"golang.org/x/oauth2"
+)
+
+// RefreshFunc returns token if it is still valid, or a refreshed token if
+// it has expired. spotifyauth.Authenticator.RefreshToken is a RefreshFunc.
+type RefreshFunc func(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error)
+
+// SaveFunc stores a refreshed token so later requests and background jobs
+// don't start from an expired one.
+type SaveFunc func(ctx context.Context, token *oauth2.Token) error
+
+// ConfigRefresher returns a RefreshFunc that refreshes tokens with cfg.
+func ConfigRefresher(cfg *oauth2.Config) RefreshFunc {
+	return func(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+		return cfg.TokenSource(ctx, token).Token()
+	}
+}
+
+// persistingTokenSource is an oauth2.TokenSource that saves every token it
+// refreshes.
+type persistingTokenSource struct {
+	ctx     context.Context
+	refresh RefreshFunc
+	save    SaveFunc
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+// NewTokenSource returns a token source that hands out token while it is
+// valid, refreshes it with refresh when it expires, and passes each new
+// token to save. save may be nil when there is nowhere to store tokens. A
+// failed save is logged rather than returned, since the refreshed token
+// still works for the current request.
+func NewTokenSource(ctx context.Context, token *oauth2.Token, refresh RefreshFunc, save SaveFunc) oauth2.TokenSource {
+	return &persistingTokenSource{ctx: ctx, refresh: refresh, save: save, token: token}
+}
+
+// Token returns a valid token, refreshing and saving it if needed.
+func (s *persistingTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, err := s.refresh(s.ctx, s.token)
+	if err != nil {
+		return nil, err
+	}
+	if token.AccessToken != s.token.AccessToken || token.RefreshToken != s.token.RefreshToken {
+		s.token = token
+		if s.save != nil {
+			if err := s.save(s.ctx, token); err != nil {
+				log.Printf("Warning: saving refreshed Spotify token: %v", err)
+			}
+		}
+	}
+	return token, nil
+}
+
+// IsRevoked reports whether err means Spotify rejected the refresh token,
+// typically because the user revoked the app's access. Retrying won't help
+// until the user logs in again.
+func IsRevoked(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	return errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant"
+}
+
+// NewClientFromTokenSource creates a client that authenticates with tokens
+// from source. Build clients with NewTokenSource so refreshed tokens are
+// saved.
+func NewClientFromTokenSource(ctx context.Context, source oauth2.TokenSource, opts ...Option) *Client {
+	return New(spotify.New(oauth2.NewClient(ctx, source), apiOptions(opts)...))
+}
@@ -0,0 +1,102 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// fakeRefresher refreshes a token once it has expired, counting refreshes.
+type fakeRefresher struct {
+	refreshes int
+}
+
+func (f *fakeRefresher) refresh(_ context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	if token.Valid() {
+		return token, nil
+	}
+	f.refreshes++
+	return &oauth2.Token{
+		AccessToken:  "access-refreshed",
+		RefreshToken: token.RefreshToken,
+		Expiry:       time.Now().Add(time.Hour),
+	}, nil
+}
+
+func TestTokenSource_SavesRefreshedToken(t *testing.T) {
+	ctx := context.Background()
+	refresher := &fakeRefresher{}
+	var saved []*oauth2.Token
+	save := func(_ context.Context, token *oauth2.Token) error {
+		saved = append(saved, token)
+		return nil
+	}
+	expired := &oauth2.Token{AccessToken: "access-old", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Minute)}
+
+	source := NewTokenSource(ctx, expired, refresher.refresh, save)
+	for range 3 {
+		token, err := source.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.AccessToken != "access-refreshed" {
+			t.Errorf("access token = %q, want the refreshed one", token.AccessToken)
+		}
+	}
+
+	if refresher.refreshes != 1 {
+		t.Errorf("refreshed %d times, want 1", refresher.refreshes)
+	}
+	if len(saved) != 1 || saved[0].AccessToken != "access-refreshed" || saved[0].RefreshToken != "refresh" {
+		t.Errorf("saved = %+v, want the refreshed token once", saved)
+	}
+}
+
+func TestTokenSource_ValidTokenNotSaved(t *testing.T) {
+	refresher := &fakeRefresher{}
+	valid := &oauth2.Token{AccessToken: "access", Expiry: time.Now().Add(time.Hour)}
+	save := func(context.Context, *oauth2.Token) error {
+		t.Error("saved a token that wasn't refreshed")
+		return nil
+	}
+
+	token, err := NewTokenSource(context.Background(), valid, refresher.refresh, save).Token()
+	if err != nil || token != valid {
+		t.Errorf("Token() = %+v, %v, want the valid token", token, err)
+	}
+}
+
+func TestTokenSource_SaveErrors(t *testing.T) {
+	ctx := context.Background()
+	expired := &oauth2.Token{AccessToken: "access-old", Expiry: time.Now().Add(-time.Minute)}
+	failing := func(context.Context, *oauth2.Token) error { return errors.New("database down") }
+
+	// A failed save shouldn't fail the request the token was refreshed for,
+	// and there may be nowhere to save at all
+	for name, save := range map[string]SaveFunc{"failing save": failing, "nil save": nil} {
+		t.Run(name, func(t *testing.T) {
+			refresher := &fakeRefresher{}
+			token, err := NewTokenSource(ctx, expired, refresher.refresh, save).Token()
+			if err != nil || token.AccessToken != "access-refreshed" {
+				t.Errorf("Token() = %+v, %v, want the refreshed token", token, err)
+			}
+		})
+	}
+}
+
+func TestTokenSource_RefreshError(t *testing.T) {
+	refresh := func(context.Context, *oauth2.Token) (*oauth2.Token, error) {
+		return nil, errors.New("invalid_grant")
+	}
+	save := func(context.Context, *oauth2.Token) error {
+		t.Error("saved after a failed refresh")
+		return nil
+	}
+
+	if _, err := NewTokenSource(context.Background(), &oauth2.Token{}, refresh, save).Token(); err == nil {
+		t.Error("Token() succeeded, want the refresh error")
+	}
+}
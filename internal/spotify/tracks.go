@@ -91,10 +91,12 @@ func convertTrack(saved spotify.SavedTrack) clustering.Track {
 
 // convertToFullTrack converts a Spotify SavedTrack to FullTrack with all metadata.
 func convertToFullTrack(saved spotify.SavedTrack) FullTrack {
-	// Join artist names
+	// Keep credited artists in order and join their names for display
 	artists := make([]string, len(saved.Artists))
+	credited := make([]Artist, len(saved.Artists))
 	for i, a := range saved.Artists {
 		artists[i] = a.Name
+		credited[i] = Artist{ID: a.ID.String(), Name: a.Name}
 	}
 
 	// Parse AddedAt timestamp, use zero value on failure
@@ -104,6 +106,7 @@ func convertToFullTrack(saved spotify.SavedTrack) FullTrack {
 		ID:         saved.ID.String(),
 		Name:       saved.Name,
 		Artist:     strings.Join(artists, ", "),
+		Artists:    credited,
 		Album:      saved.Album.Name,
 		AlbumID:    saved.Album.ID.String(),
 		DurationMs: int(saved.TimeDuration().Milliseconds()),
@@ -7,9 +7,23 @@ import "time"
 type FullTrack struct {
 	ID         string
 	Name       string
-	Artist     string // Comma-separated artist names
+	Artist     string   // Comma-separated artist names
+	Artists    []Artist // Credited artists in order (primary artist first)
 	Album      string
 	AlbumID    string
 	DurationMs int
 	AddedAt    time.Time // When user liked the track
 }
+
+// Artist identifies a Spotify artist credited on a track.
+type Artist struct {
+	ID   string
+	Name string
+}
+
+// Profile is the current user's Spotify profile.
+type Profile struct {
+	ID          string
+	DisplayName string
+	Email       string // Empty without the user-read-email scope
+}
@@ -1,4 +1,4 @@
-// Package sync provides services for syncing data between Spotify and PostgreSQL.
+// Package sync provides services for syncing data between Spotify and the database.
 package sync
 
 import (
@@ -20,9 +20,17 @@ var (
 // DefaultSyncCooldown is the default time between allowed syncs (1 hour).
 const DefaultSyncCooldown = 1 * time.Hour
 
+// Store provides the repositories the service writes to. It is implemented
+// by *db.DB and, in tests, by dbtest.Store.
+type Store interface {
+	Users() db.UserRepository
+	Tracks() db.TrackRepository
+	Artists() db.ArtistRepository
+}
+
 // Service handles syncing data from Spotify to the database.
 type Service struct {
-	db           *db.DB
+	db           Store
 	syncCooldown time.Duration
 }
 
@@ -37,7 +45,7 @@ func WithSyncCooldown(d time.Duration) Option {
 }
 
 // New creates a new sync service.
-func New(database *db.DB, opts ...Option) *Service {
+func New(database Store, opts ...Option) *Service {
 	s := &Service{
 		db:           database,
 		syncCooldown: DefaultSyncCooldown,
@@ -83,7 +91,7 @@ func (s *Service) CanSync(ctx context.Context, userID string) (bool, time.Time,
 // SyncLikedSongs fetches all liked songs from Spotify and persists them.
 // Returns ErrSyncTooRecent if called within the cooldown period.
 // Set force=true to bypass the cooldown check (for first-time sync after login).
-func (s *Service) SyncLikedSongs(ctx context.Context, client *spotify.Client, userID string, force bool) (*SyncResult, error) {
+func (s *Service) SyncLikedSongs(ctx context.Context, client spotify.LibraryClient, userID string, force bool) (*SyncResult, error) {
 	// Check cooldown unless forced
 	if !force {
 		canSync, nextTime, err := s.CanSync(ctx, userID)
@@ -113,6 +121,7 @@ func (s *Service) SyncLikedSongs(ctx context.Context, client *spotify.Client, us
 	// Convert to database types
 	dbTracks := make([]db.Track, len(spotifyTracks))
 	userTracks := make([]db.UserTrack, len(spotifyTracks))
+	artists, trackArtists := collectArtists(spotifyTracks)
 
 	for i, st := range spotifyTracks {
 		album := st.Album
@@ -140,6 +149,14 @@ func (s *Service) SyncLikedSongs(ctx context.Context, client *spotify.Client, us
 		return nil, fmt.Errorf("upserting tracks: %w", err)
 	}
 
+	// Upsert artists and their credits on each track
+	if err := s.db.Artists().UpsertBatch(ctx, artists); err != nil {
+		return nil, fmt.Errorf("upserting artists: %w", err)
+	}
+	if err := s.db.Artists().ReplaceTrackArtists(ctx, trackArtists); err != nil {
+		return nil, fmt.Errorf("linking artists to tracks: %w", err)
+	}
+
 	// Link tracks to user
 	if err := s.db.Tracks().LinkBatchToUser(ctx, userID, userTracks); err != nil {
 		return nil, fmt.Errorf("linking tracks to user: %w", err)
@@ -157,6 +174,35 @@ func (s *Service) SyncLikedSongs(ctx context.Context, client *spotify.Client, us
 	}, nil
 }
 
+// collectArtists extracts the unique artists and per-track artist credits
+// from Spotify tracks. Artists without a Spotify ID (local files) are skipped.
+func collectArtists(tracks []spotify.FullTrack) ([]db.Artist, []db.TrackArtist) {
+	var artists []db.Artist
+	var links []db.TrackArtist
+	seen := make(map[string]bool)
+
+	for _, t := range tracks {
+		position := 0
+		for _, a := range t.Artists {
+			if a.ID == "" {
+				continue
+			}
+			if !seen[a.ID] {
+				seen[a.ID] = true
+				artists = append(artists, db.Artist{ID: a.ID, Name: a.Name})
+			}
+			links = append(links, db.TrackArtist{
+				TrackID:  t.ID,
+				ArtistID: a.ID,
+				Position: position,
+			})
+			position++
+		}
+	}
+
+	return artists, links
+}
+
 // GetLastSyncTime returns the last sync time for a user.
 // Returns nil if the user has never synced.
 func (s *Service) GetLastSyncTime(ctx context.Context, userID string) (*time.Time, error) {
@@ -0,0 +1,194 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/db/dbtest"
+	"github.com/justestif/go-spotify-era-organizer/internal/spotify"
+)
+
+// fakeLibrary is a spotify.LibraryClient returning fixed liked songs. Its
+// other methods aren't used by syncing and panic.
+type fakeLibrary struct {
+	spotify.LibraryClient
+
+	tracks []spotify.FullTrack
+	err    error
+	calls  int
+}
+
+func (f *fakeLibrary) FetchAllLikedSongsWithMetadata(context.Context) ([]spotify.FullTrack, error) {
+	f.calls++
+	return f.tracks, f.err
+}
+
+var likedAt = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestStore(t *testing.T, userIDs ...string) *dbtest.Store {
+	t.Helper()
+	store := dbtest.NewStore()
+	for _, id := range userIDs {
+		if err := store.Users().EnsureExists(context.Background(), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestSyncLikedSongs(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, "alice")
+	library := &fakeLibrary{tracks: []spotify.FullTrack{
+		{
+			ID: "t1", Name: "Duet", Artist: "Singer, Rapper", Album: "Album", AlbumID: "a1", DurationMs: 180000, AddedAt: likedAt,
+			Artists: []spotify.Artist{{ID: "ar1", Name: "Singer"}, {ID: "ar2", Name: "Rapper"}},
+		},
+		{
+			ID: "t2", Name: "Solo", Artist: "Singer", AddedAt: likedAt.Add(time.Hour),
+			Artists: []spotify.Artist{{ID: "ar1", Name: "Singer"}},
+		},
+	}}
+	s := New(store)
+
+	result, err := s.SyncLikedSongs(ctx, library, "alice", false)
+	if err != nil {
+		t.Fatalf("SyncLikedSongs() error = %v", err)
+	}
+	if result.TracksCount != 2 {
+		t.Errorf("TracksCount = %d, want 2", result.TracksCount)
+	}
+
+	userTracks, tracks, err := store.Tracks().GetUserTracksWithAddedAt(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 2 || tracks[0].ID != "t2" || !userTracks[1].AddedAt.Equal(likedAt) {
+		t.Fatalf("library = %+v, want t2 then t1 liked at %v", userTracks, likedAt)
+	}
+	if got := tracks[1]; got.Album == nil || *got.Album != "Album" || got.DurationMs == nil || *got.DurationMs != 180000 {
+		t.Errorf("t1 = %+v, want its album and duration stored", got)
+	}
+
+	artists, err := store.Artists().GetForTracks(ctx, []string{"t1", "t2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artists["t1"]) != 2 || artists["t1"][0].Name != "Singer" || artists["t1"][1].Name != "Rapper" {
+		t.Errorf("t1 artists = %+v, want Singer then Rapper", artists["t1"])
+	}
+	if len(artists["t2"]) != 1 {
+		t.Errorf("t2 artists = %+v, want Singer", artists["t2"])
+	}
+
+	last, err := s.GetLastSyncTime(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last == nil || !last.Equal(result.SyncedAt) {
+		t.Errorf("GetLastSyncTime() = %v, want %v", last, result.SyncedAt)
+	}
+}
+
+func TestSyncLikedSongs_Cooldown(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, "alice")
+	library := &fakeLibrary{tracks: []spotify.FullTrack{{ID: "t1", Name: "Song", AddedAt: likedAt}}}
+	s := New(store, WithSyncCooldown(time.Hour))
+
+	if _, err := s.SyncLikedSongs(ctx, library, "alice", false); err != nil {
+		t.Fatalf("first SyncLikedSongs() error = %v", err)
+	}
+
+	ok, next, err := s.CanSync(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok || time.Until(next) <= 0 {
+		t.Errorf("CanSync() after syncing = %v, %v, want false with a future time", ok, next)
+	}
+	if _, err := s.SyncLikedSongs(ctx, library, "alice", false); !errors.Is(err, ErrSyncTooRecent) {
+		t.Errorf("SyncLikedSongs() within the cooldown error = %v, want ErrSyncTooRecent", err)
+	}
+	if library.calls != 1 {
+		t.Errorf("Spotify was called %d times, want once", library.calls)
+	}
+
+	// Forcing bypasses the cooldown
+	if _, err := s.SyncLikedSongs(ctx, library, "alice", true); err != nil {
+		t.Errorf("forced SyncLikedSongs() error = %v", err)
+	}
+}
+
+func TestSyncLikedSongs_Empty(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, "alice")
+	s := New(store)
+
+	result, err := s.SyncLikedSongs(ctx, &fakeLibrary{}, "alice", false)
+	if err != nil {
+		t.Fatalf("SyncLikedSongs() error = %v", err)
+	}
+	if result.TracksCount != 0 {
+		t.Errorf("TracksCount = %d, want 0", result.TracksCount)
+	}
+	if last, _ := s.GetLastSyncTime(ctx, "alice"); last == nil {
+		t.Error("last sync time not recorded for an empty library")
+	}
+}
+
+func TestSyncLikedSongs_SpotifyError(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, "alice")
+	spotifyErr := errors.New("spotify unavailable")
+	s := New(store)
+
+	if _, err := s.SyncLikedSongs(ctx, &fakeLibrary{err: spotifyErr}, "alice", false); !errors.Is(err, spotifyErr) {
+		t.Fatalf("SyncLikedSongs() error = %v, want %v", err, spotifyErr)
+	}
+	if last, _ := s.GetLastSyncTime(ctx, "alice"); last != nil {
+		t.Errorf("last sync time = %v after a failed sync, want nil", last)
+	}
+}
+
+func TestCanSync_NewUser(t *testing.T) {
+	s := New(newTestStore(t))
+
+	ok, _, err := s.CanSync(context.Background(), "nobody")
+	if err != nil || !ok {
+		t.Errorf("CanSync() for a new user = %v, %v, want true", ok, err)
+	}
+	last, err := s.GetLastSyncTime(context.Background(), "nobody")
+	if err != nil || last != nil {
+		t.Errorf("GetLastSyncTime() for a new user = %v, %v, want nil", last, err)
+	}
+}
+
+func TestCollectArtists(t *testing.T) {
+	tracks := []spotify.FullTrack{
+		{ID: "t1", Artists: []spotify.Artist{{ID: "a1", Name: "One"}, {ID: "", Name: "Local"}, {ID: "a2", Name: "Two"}}},
+		{ID: "t2", Artists: []spotify.Artist{{ID: "a2", Name: "Two"}}},
+	}
+
+	artists, links := collectArtists(tracks)
+
+	if len(artists) != 2 {
+		t.Errorf("artists = %+v, want a1 and a2 once each", artists)
+	}
+	want := []db.TrackArtist{
+		{TrackID: "t1", ArtistID: "a1", Position: 0},
+		{TrackID: "t1", ArtistID: "a2", Position: 1},
+		{TrackID: "t2", ArtistID: "a2", Position: 0},
+	}
+	if len(links) != len(want) {
+		t.Fatalf("links = %+v, want %+v", links, want)
+	}
+	for i := range want {
+		if links[i] != want[i] {
+			t.Errorf("links[%d] = %+v, want %+v", i, links[i], want[i])
+		}
+	}
+}
@@ -21,7 +21,7 @@ type CachedTagFetcher struct {
 }
 
 // NewCachedTagFetcher creates a new CachedTagFetcher that wraps the Last.fm client
-// with PostgreSQL persistence.
+// with database persistence.
 func NewCachedTagFetcher(database *db.DB, client *lastfm.Client) *CachedTagFetcher {
 	return &CachedTagFetcher{
 		db:     database,
@@ -114,7 +114,7 @@ func (c *CachedTagFetcher) fetchAndPersist(ctx context.Context, tracks []Track)
 		default:
 		}
 
-		tags, err := c.client.GetTags(ctx, t.Artist, t.Name)
+		tags, err := lookupTags(ctx, c.client, t)
 		if err != nil {
 			// Skip this track but continue with others
 			continue
@@ -0,0 +1,163 @@
+package tags
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+)
+
+// Enricher fetches and persists Last.fm tags for the tracks in a user's
+// library that don't have any yet, and refreshes tags older than the cache
+// TTL.
+type Enricher struct {
+	db       *db.DB
+	service  TagService
+	cacheTTL time.Duration
+}
+
+// EnricherOption configures an Enricher.
+type EnricherOption func(*Enricher)
+
+// WithCacheTTL sets the age after which fetched tags are refreshed.
+func WithCacheTTL(ttl time.Duration) EnricherOption {
+	return func(e *Enricher) {
+		if ttl > 0 {
+			e.cacheTTL = ttl
+		}
+	}
+}
+
+// NewEnricher creates a new Enricher.
+func NewEnricher(database *db.DB, service TagService, opts ...EnricherOption) *Enricher {
+	e := &Enricher{
+		db:       database,
+		service:  service,
+		cacheTTL: CacheTTL,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// EnrichResult contains the outcome of tag enrichment.
+type EnrichResult struct {
+	TracksMissing int // Tracks that had no tags
+	TracksStale   int // Tracks whose tags were older than the cache TTL
+	TracksTagged  int // Tracks that tags were found for
+	TracksFailed  int // Tracks whose lookup failed
+	TagsStored    int // Tags persisted
+}
+
+// FetchMissing fetches tags for the user's tracks that don't have any, and
+// refetches tags older than the cache TTL. Stale tags are kept if their
+// refetch fails or finds nothing. Individual lookup failures are counted rather than returned, so one
+// unknown track doesn't stop the rest from being tagged.
+func (e *Enricher) FetchMissing(ctx context.Context, userID string) (*EnrichResult, error) {
+	result := &EnrichResult{}
+
+	// Get all user's tracks
+	tracks, err := e.db.Tracks().GetUserTracks(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("getting user tracks: %w", err)
+	}
+
+	if len(tracks) == 0 {
+		return result, nil
+	}
+
+	// Get track IDs
+	trackIDs := make([]string, len(tracks))
+	for i, t := range tracks {
+		trackIDs[i] = t.ID
+	}
+
+	// Find tracks without tags
+	missingIDs, err := e.db.Tags().GetTracksWithoutTags(ctx, trackIDs)
+	if err != nil {
+		return nil, fmt.Errorf("finding tracks without tags: %w", err)
+	}
+
+	staleIDs, err := e.db.Tags().GetTracksWithStaleTags(ctx, trackIDs, time.Now().Add(-e.cacheTTL))
+	if err != nil {
+		return nil, fmt.Errorf("finding tracks with stale tags: %w", err)
+	}
+
+	result.TracksMissing = len(missingIDs)
+	result.TracksStale = len(staleIDs)
+	missingIDs = append(missingIDs, staleIDs...)
+	if len(missingIDs) == 0 {
+		return result, nil
+	}
+
+	// Build lookup map for track info
+	trackMap := make(map[string]db.Track)
+	for _, t := range tracks {
+		trackMap[t.ID] = t
+	}
+
+	// Load credited artists so lookups can fall back past the primary artist
+	artistsMap, err := e.db.Artists().GetForTracks(ctx, missingIDs)
+	if err != nil {
+		return nil, fmt.Errorf("getting track artists: %w", err)
+	}
+
+	// Convert to tag service format
+	tagTracks := make([]Track, 0, len(missingIDs))
+	for _, id := range missingIDs {
+		t, ok := trackMap[id]
+		if !ok {
+			continue
+		}
+		artists := make([]string, len(artistsMap[id]))
+		for i, a := range artistsMap[id] {
+			artists[i] = a.Name
+		}
+		tagTracks = append(tagTracks, Track{
+			ID:      t.ID,
+			Name:    t.Name,
+			Artist:  t.Artist,
+			Artists: artists,
+		})
+	}
+
+	// Fetch tags
+	results, err := e.service.FetchTagsForTracks(ctx, tagTracks)
+	if err != nil {
+		return nil, fmt.Errorf("fetching tags: %w", err)
+	}
+
+	// Convert and persist tags
+	now := time.Now()
+	var dbTags []db.TrackTag
+	for _, r := range results {
+		if r.Error != nil {
+			result.TracksFailed++
+			continue
+		}
+		if len(r.Tags) == 0 {
+			continue
+		}
+		result.TracksTagged++
+		for _, tag := range r.Tags {
+			dbTags = append(dbTags, db.TrackTag{
+				TrackID:   r.TrackID,
+				TagName:   tag.Name,
+				TagCount:  tag.Count,
+				Source:    string(r.Source),
+				FetchedAt: now,
+			})
+		}
+	}
+
+	if len(dbTags) > 0 {
+		if err := e.db.Tags().UpsertBatch(ctx, dbTags); err != nil {
+			return nil, fmt.Errorf("persisting tags: %w", err)
+		}
+	}
+	result.TagsStored = len(dbTags)
+
+	return result, nil
+}
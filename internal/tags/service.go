@@ -25,9 +25,18 @@ const DefaultConcurrency = 5
 
 // Track represents the minimal track info needed for tag lookup.
 type Track struct {
-	ID     string
-	Name   string
-	Artist string
+	ID      string
+	Name    string
+	Artist  string   // Display artist string, used when Artists is empty
+	Artists []string // Credited artists in order (primary artist first)
+}
+
+// lookupArtists returns the artists to try for a tag lookup, primary first.
+func (t Track) lookupArtists() []string {
+	if len(t.Artists) > 0 {
+		return t.Artists
+	}
+	return []string{t.Artist}
 }
 
 // TrackTags holds the tags fetched for a track.
@@ -120,7 +129,7 @@ func (s *Service) FetchTagsForTracks(ctx context.Context, tracks []Track) ([]Tra
 				default:
 				}
 
-				tags, err := s.fetcher.GetTags(ctx, work.track.Artist, work.track.Name)
+				tags, err := lookupTags(ctx, s.fetcher, work.track)
 				result := TrackTags{
 					TrackID: work.track.ID,
 					Tags:    tags,
@@ -154,3 +163,32 @@ func (s *Service) FetchTagsForTracks(ctx context.Context, tracks []Track) ([]Tra
 
 	return results, nil
 }
+
+// lookupTags fetches tags for a track using its primary artist, falling back
+// to the other credited artists in order until one returns tags.
+// An error is returned only if every lookup failed.
+func lookupTags(ctx context.Context, fetcher TagFetcher, track Track) ([]lastfm.Tag, error) {
+	var lastErr error
+	failures := 0
+	artists := track.lookupArtists()
+
+	for _, artist := range artists {
+		tags, err := fetcher.GetTags(ctx, artist, track.Name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			failures++
+			continue
+		}
+		if len(tags) > 0 {
+			return tags, nil
+		}
+	}
+
+	if failures == len(artists) {
+		return nil, lastErr
+	}
+	return []lastfm.Tag{}, nil
+}
@@ -213,6 +213,63 @@ func TestFetchTagsForTracks_IndividualErrors(t *testing.T) {
 	}
 }
 
+func TestFetchTagsForTracks_ArtistFallback(t *testing.T) {
+	fetcher := newMockFetcher()
+	fetcher.addTags("Daft Punk", "Get Lucky", []lastfm.Tag{{Name: "electronic", Count: 100}})
+	fetcher.addTags("Pharrell Williams", "Get Lucky", []lastfm.Tag{{Name: "pop", Count: 90}})
+	fetcher.addTags("Nile Rodgers", "Le Freak", []lastfm.Tag{{Name: "disco", Count: 80}})
+	fetcher.addError("Chic", "Le Freak", errors.New("API error"))
+
+	svc := NewService(fetcher)
+	tracks := []Track{
+		// Primary artist has tags, so it wins
+		{ID: "t1", Name: "Get Lucky", Artist: "Daft Punk, Pharrell Williams", Artists: []string{"Daft Punk", "Pharrell Williams"}},
+		// Primary artist has no tags, so the featured artist is used
+		{ID: "t2", Name: "Get Lucky", Artist: "Unknown, Pharrell Williams", Artists: []string{"Unknown", "Pharrell Williams"}},
+		// Primary artist fails, fallback still succeeds
+		{ID: "t3", Name: "Le Freak", Artist: "Chic, Nile Rodgers", Artists: []string{"Chic", "Nile Rodgers"}},
+		// No credited artists, so the display artist is used
+		{ID: "t4", Name: "Get Lucky", Artist: "Daft Punk"},
+	}
+
+	results, err := svc.FetchTagsForTracks(context.Background(), tracks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"electronic", "pop", "disco", "electronic"}
+	for i, want := range expected {
+		if results[i].Error != nil {
+			t.Errorf("result[%d]: unexpected error: %v", i, results[i].Error)
+		}
+		if len(results[i].Tags) == 0 || results[i].Tags[0].Name != want {
+			t.Errorf("result[%d]: expected tag %q, got %v", i, want, results[i].Tags)
+		}
+	}
+}
+
+func TestFetchTagsForTracks_AllArtistsFail(t *testing.T) {
+	fetcher := newMockFetcher()
+	fetcher.addError("A", "Song", errors.New("API error"))
+	fetcher.addError("B", "Song", errors.New("API error"))
+
+	svc := NewService(fetcher)
+	tracks := []Track{
+		{ID: "t1", Name: "Song", Artist: "A, B", Artists: []string{"A", "B"}},
+	}
+
+	results, err := svc.FetchTagsForTracks(context.Background(), tracks)
+	if err != nil {
+		t.Fatalf("unexpected batch error: %v", err)
+	}
+	if results[0].Error == nil {
+		t.Error("expected error when every artist lookup fails")
+	}
+	if results[0].Source != SourceNone {
+		t.Errorf("expected source 'none', got %q", results[0].Source)
+	}
+}
+
 func TestFetchTagsForTracks_ContextCancellation(t *testing.T) {
 	fetcher := newMockFetcher()
 	fetcher.delay = 100 * time.Millisecond
@@ -0,0 +1,33 @@
+package tags
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxUserTagLength is the maximum length of a user-assigned tag, in characters.
+const MaxUserTagLength = 50
+
+// ErrInvalidTag is returned when a user-assigned tag is empty or malformed.
+var ErrInvalidTag = errors.New("invalid tag")
+
+// NormalizeUserTag cleans up a tag typed by the user so it matches Last.fm's
+// conventions: lowercase, trimmed, with internal whitespace collapsed.
+// Returns ErrInvalidTag if the result is empty, too long, or contains control characters.
+func NormalizeUserTag(raw string) (string, error) {
+	tag := strings.ToLower(strings.Join(strings.Fields(raw), " "))
+	if tag == "" {
+		return "", ErrInvalidTag
+	}
+	if utf8.RuneCountInString(tag) > MaxUserTagLength {
+		return "", ErrInvalidTag
+	}
+	for _, r := range tag {
+		if unicode.IsControl(r) {
+			return "", ErrInvalidTag
+		}
+	}
+	return tag, nil
+}
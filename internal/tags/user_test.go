@@ -0,0 +1,37 @@
+package tags
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeUserTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{"lowercases", "Gym", "gym", nil},
+		{"trims and collapses whitespace", "  Rainy \t  Day ", "rainy day", nil},
+		{"keeps punctuation", "lo-fi & chill", "lo-fi & chill", nil},
+		{"empty", "", "", ErrInvalidTag},
+		{"whitespace only", "   ", "", ErrInvalidTag},
+		{"too long", strings.Repeat("a", MaxUserTagLength+1), "", ErrInvalidTag},
+		{"max length", strings.Repeat("a", MaxUserTagLength), strings.Repeat("a", MaxUserTagLength), nil},
+		{"control character", "gym\x00", "", ErrInvalidTag},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeUserTag(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NormalizeUserTag(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeUserTag(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/db/dbtest"
+	"github.com/justestif/go-spotify-era-organizer/internal/eras"
+	spotifyclient "github.com/justestif/go-spotify-era-organizer/internal/spotify"
+	"github.com/justestif/go-spotify-era-organizer/internal/spotify/spotifytest"
+	syncpkg "github.com/justestif/go-spotify-era-organizer/internal/sync"
+	webfs "github.com/justestif/go-spotify-era-organizer/web"
+)
+
+// flowTest is the app wired to a SQLite database and a fake Spotify API,
+// with alice logged in on an expired access token.
+type flowTest struct {
+	router   http.Handler
+	database *db.DB
+	spotify  *spotifytest.Server
+	sessions *SessionStore
+	session  *Session
+}
+
+func newFlowTest(t *testing.T) *flowTest {
+	t.Helper()
+	ctx := context.Background()
+
+	database := dbtest.NewSQLite(t)
+
+	templatesFS, err := fs.Sub(webfs.TemplatesFS, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, err := NewTemplates(templatesFS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := spotifytest.NewServer(t, "alice")
+	token := srv.ExpiredToken()
+	if err := database.Users().Upsert(ctx, &db.User{ID: "alice", DisplayName: "Alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Users().SaveToken(ctx, "alice", token.AccessToken, token.RefreshToken, token.Expiry); err != nil {
+		t.Fatal(err)
+	}
+	sessions := NewSessionStore()
+	session, err := sessions.Create(ctx, token, "alice", "Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		router: chi.NewRouter(),
+		handlers: NewHandlers(HandlerDeps{
+			Sessions:       sessions,
+			Templates:      templates,
+			DB:             database,
+			SyncService:    syncpkg.New(database),
+			EraService:     eras.New(database),
+			RefreshToken:   spotifyclient.ConfigRefresher(srv.OAuthConfig()),
+			SpotifyOptions: []spotifyclient.Option{spotifyclient.WithAPIURL(srv.APIURL())},
+		}),
+	}
+	s.setupRoutes(fstest.MapFS{})
+
+	return &flowTest{router: s.router, database: database, spotify: srv, sessions: sessions, session: session}
+}
+
+// do sends a request as alice and fails the test unless it returns 200 OK.
+func (f *flowTest) do(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: f.session.ID})
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s %s status = %d, want 200: %s", method, path, rec.Code, rec.Body)
+	}
+	return rec
+}
+
+// genreSongs returns three songs per genre, each genre liked six months
+// after the previous one.
+func genreSongs(genres ...string) []spotifytest.Song {
+	var songs []spotifytest.Song
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, genre := range genres {
+		for i := 1; i <= 3; i++ {
+			songs = append(songs, spotifytest.Song{
+				ID:      fmt.Sprintf("%s%d", genre, i),
+				Name:    fmt.Sprintf("Song %s%d", genre, i),
+				Artists: []spotifytest.Artist{{ID: "artist-" + genre, Name: "Artist " + genre}},
+				AddedAt: start.AddDate(0, 0, len(songs)),
+			})
+		}
+		start = start.AddDate(0, 6, 0)
+	}
+	return songs
+}
+
+// TestFlow_SyncAnalyzePublish drives the full flow through the handlers:
+// syncing a paged, rate-limited library on an expired token, detecting
+// eras and publishing one as a playlist.
+func TestFlow_SyncAnalyzePublish(t *testing.T) {
+	ctx := context.Background()
+	f := newFlowTest(t)
+	f.spotify.AddLikedSongs(genreSongs("jazz", "rock")...)
+	f.spotify.SetPageSize(4)
+	f.spotify.RateLimit(1)
+
+	// Sync
+	var synced SyncResponse
+	if err := json.NewDecoder(f.do(t, http.MethodPost, "/api/sync", "").Body).Decode(&synced); err != nil {
+		t.Fatal(err)
+	}
+	if synced.TracksSynced != 6 {
+		t.Errorf("tracks synced = %d, want 6 across pages", synced.TracksSynced)
+	}
+	if f.spotify.Throttled() != 1 {
+		t.Errorf("throttled %d requests, want 1 retried", f.spotify.Throttled())
+	}
+
+	// The refreshed token is saved to the session and the user record
+	if f.spotify.Refreshes() != 1 {
+		t.Errorf("refreshed %d times, want 1", f.spotify.Refreshes())
+	}
+	if token := f.sessions.Get(ctx, f.session.ID).Token; !token.Valid() {
+		t.Errorf("session token = %+v, want the refreshed one", token)
+	}
+	stored, err := f.database.Users().GetToken(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.TokenExpiry.After(time.Now()) {
+		t.Errorf("stored token expires %v, want the refreshed token", stored.TokenExpiry)
+	}
+
+	// Tag the library as Last.fm would, then analyze
+	var trackTags []db.TrackTag
+	for _, song := range genreSongs("jazz", "rock") {
+		genre := strings.TrimRight(song.ID, "123")
+		trackTags = append(trackTags, db.TrackTag{TrackID: song.ID, TagName: genre, TagCount: 100, Source: "track"})
+	}
+	if err := f.database.Tags().UpsertBatch(ctx, trackTags); err != nil {
+		t.Fatal(err)
+	}
+	var analyzed AnalyzeResponse
+	if err := json.NewDecoder(f.do(t, http.MethodPost, "/api/analyze", `{"num_clusters": 2}`).Body).Decode(&analyzed); err != nil {
+		t.Fatal(err)
+	}
+	if analyzed.EraCount != 2 || analyzed.TotalTracks != 6 {
+		t.Fatalf("analysis = %+v, want 2 eras from 6 tracks", analyzed)
+	}
+
+	var detected []EraJSON
+	if err := json.NewDecoder(f.do(t, http.MethodGet, "/api/eras", "").Body).Decode(&detected); err != nil {
+		t.Fatal(err)
+	}
+	if len(detected) != 2 {
+		t.Fatalf("eras = %+v, want 2", detected)
+	}
+	era := detected[0]
+
+	// Publish
+	f.do(t, http.MethodPost, "/eras/"+era.ID+"/playlist", "")
+
+	playlists := f.spotify.Playlists()
+	if len(playlists) != 1 {
+		t.Fatalf("playlists = %+v, want one", playlists)
+	}
+	tracks, err := f.database.Eras().GetTracksForOwner(ctx, "alice", uuid.MustParse(era.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, track := range tracks {
+		want = append(want, track.ID)
+	}
+	got := playlists[0].TrackIDs
+	slices.Sort(got)
+	slices.Sort(want)
+	if len(want) != 3 || !slices.Equal(got, want) {
+		t.Errorf("playlist tracks = %v, want the era's tracks %v", got, want)
+	}
+
+	published, err := f.database.Eras().GetForOwner(ctx, "alice", uuid.MustParse(era.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published.PlaylistID == nil || *published.PlaylistID != playlists[0].ID {
+		t.Errorf("era playlist ID = %v, want %s", published.PlaylistID, playlists[0].ID)
+	}
+}
+
+func TestFlow_RemoveTrackTag_Normalized(t *testing.T) {
+	ctx := context.Background()
+	f := newFlowTest(t)
+	if err := f.database.Tracks().Upsert(ctx, &db.Track{ID: "t1", Name: "One", Artist: "A"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.database.Tracks().LinkBatchToUser(ctx, "alice", []db.UserTrack{{UserID: "alice", TrackID: "t1", AddedAt: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The tag is saved normalized, and removing it as typed still matches
+	f.do(t, http.MethodPost, "/tracks/t1/tags?tag=Late+%20Night", "")
+	f.do(t, http.MethodDelete, "/tracks/t1/tags?tag=%20LATE+NIGHT", "")
+
+	userTags, err := f.database.Tags().GetUserTagsForTracks(ctx, "alice", []string{"t1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(userTags["t1"]) != 0 {
+		t.Errorf("tags on t1 = %v, want none after removal", userTags["t1"])
+	}
+}
@@ -1,25 +1,33 @@
 package web
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/zmb3/spotify/v2"
 	spotifyauth "github.com/zmb3/spotify/v2/auth"
 	"golang.org/x/oauth2"
 
+	"github.com/justestif/go-spotify-era-organizer/internal/backup"
 	"github.com/justestif/go-spotify-era-organizer/internal/clustering"
 	"github.com/justestif/go-spotify-era-organizer/internal/db"
 	"github.com/justestif/go-spotify-era-organizer/internal/eras"
+	"github.com/justestif/go-spotify-era-organizer/internal/exporter"
+	"github.com/justestif/go-spotify-era-organizer/internal/importer"
+	"github.com/justestif/go-spotify-era-organizer/internal/scheduler"
 	spotifyclient "github.com/justestif/go-spotify-era-organizer/internal/spotify"
 	syncpkg "github.com/justestif/go-spotify-era-organizer/internal/sync"
 	"github.com/justestif/go-spotify-era-organizer/internal/tags"
@@ -35,8 +43,14 @@ type Handlers struct {
 	syncService *syncpkg.Service
 	eraService  *eras.Service
 	tagService  *tags.Service
+	tagCacheTTL time.Duration
+	refresh     spotifyclient.RefreshFunc
+	spotifyOpts []spotifyclient.Option
 }
 
+// oauthStateTTL is how long a login has to complete.
+const oauthStateTTL = 5 * time.Minute
+
 // oauthStateStore stores OAuth state tokens server-side to avoid cookie issues
 // with localhost vs 127.0.0.1 during development.
 type oauthStateStore struct {
@@ -68,8 +82,24 @@ func (s *oauthStateStore) Validate(state string) bool {
 	// Remove the state (single use)
 	delete(s.states, state)
 
-	// Check if state is expired (5 minutes)
-	return time.Since(created) < 5*time.Minute
+	// Check if state is expired
+	return time.Since(created) < oauthStateTTL
+}
+
+// DeleteExpired removes states that are too old to validate: logins that
+// were started but never finished. Returns the number removed.
+func (s *oauthStateStore) DeleteExpired(context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int64
+	for state, created := range s.states {
+		if time.Since(created) >= oauthStateTTL {
+			delete(s.states, state)
+			removed++
+		}
+	}
+	return removed, nil
 }
 
 // HandlerDeps contains dependencies for handlers.
@@ -81,11 +111,17 @@ type HandlerDeps struct {
 	SyncService *syncpkg.Service
 	EraService  *eras.Service
 	TagService  *tags.Service
+	TagCacheTTL time.Duration // Optional - defaults to tags.CacheTTL
+
+	// Optional - default to refreshing tokens with Auth and calling the
+	// Spotify Web API. Tests point these at a spotifytest.Server.
+	RefreshToken   spotifyclient.RefreshFunc
+	SpotifyOptions []spotifyclient.Option
 }
 
 // NewHandlers creates a new Handlers instance.
 func NewHandlers(deps HandlerDeps) *Handlers {
-	return &Handlers{
+	h := &Handlers{
 		auth:        deps.Auth,
 		sessions:    deps.Sessions,
 		templates:   deps.Templates,
@@ -94,7 +130,14 @@ func NewHandlers(deps HandlerDeps) *Handlers {
 		syncService: deps.SyncService,
 		eraService:  deps.EraService,
 		tagService:  deps.TagService,
+		tagCacheTTL: deps.TagCacheTTL,
+		refresh:     deps.RefreshToken,
+		spotifyOpts: deps.SpotifyOptions,
+	}
+	if h.refresh == nil && deps.Auth != nil {
+		h.refresh = deps.Auth.RefreshToken
 	}
+	return h
 }
 
 // Home handles the home page (GET /).
@@ -164,16 +207,14 @@ func (h *Handlers) Callback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user info from Spotify
-	httpClient := h.auth.Client(ctx, token)
-	spotifyAPI := spotify.New(httpClient)
-	spotifyUser, err := spotifyAPI.CurrentUser(ctx)
+	// Get user info from Spotify; there's no session to save a refresh to yet
+	spotifyUser, err := spotifyclient.NewClientFromTokenSource(ctx, h.tokenSource(ctx, token, nil), h.spotifyOpts...).Profile(ctx)
 	if err != nil {
 		http.Error(w, "Failed to get user info", http.StatusInternalServerError)
 		return
 	}
 
-	userID := string(spotifyUser.ID)
+	userID := spotifyUser.ID
 	displayName := spotifyUser.DisplayName
 
 	// Upsert user in database (if DB is available)
@@ -186,6 +227,9 @@ func (h *Handlers) Callback(w http.ResponseWriter, r *http.Request) {
 		if err := h.db.Users().Upsert(ctx, user); err != nil {
 			log.Printf("Warning: failed to upsert user: %v", err)
 			// Continue anyway - session can still work
+		} else if err := h.db.Users().SaveToken(ctx, userID, token.AccessToken, token.RefreshToken, token.Expiry); err != nil {
+			// Without a stored token the scheduler can't sync this user
+			log.Printf("Warning: failed to save user token: %v", err)
 		}
 	}
 
@@ -201,7 +245,7 @@ func (h *Handlers) Callback(w http.ResponseWriter, r *http.Request) {
 
 	// Trigger initial sync if this is the user's first time (async)
 	if h.syncService != nil && h.db != nil {
-		go h.triggerInitialSync(token, userID)
+		go h.triggerInitialSync(session)
 	}
 
 	// Redirect to home
@@ -209,8 +253,11 @@ func (h *Handlers) Callback(w http.ResponseWriter, r *http.Request) {
 }
 
 // triggerInitialSync checks if user needs initial sync and runs it.
-func (h *Handlers) triggerInitialSync(token *oauth2.Token, userID string) {
+// It outlives the login request, so refreshed tokens are saved to the
+// session for later requests.
+func (h *Handlers) triggerInitialSync(session *Session) {
 	ctx := context.Background()
+	userID := session.UserID
 
 	// Check if user has ever synced
 	lastSync, err := h.syncService.GetLastSyncTime(ctx, userID)
@@ -226,10 +273,7 @@ func (h *Handlers) triggerInitialSync(token *oauth2.Token, userID string) {
 
 	log.Printf("Starting initial sync for user %s", userID)
 
-	// Create Spotify client with the token
-	httpClient := h.auth.Client(ctx, token)
-	spotifyAPI := spotify.New(httpClient)
-	client := spotifyclient.New(spotifyAPI)
+	client := h.spotifyClient(ctx, session)
 
 	// Run sync with force=true (bypass cooldown for initial sync)
 	result, err := h.syncService.SyncLikedSongs(ctx, client, userID, true)
@@ -241,6 +285,31 @@ func (h *Handlers) triggerInitialSync(token *oauth2.Token, userID string) {
 	log.Printf("Initial sync complete for user %s: %d tracks synced", userID, result.TracksCount)
 }
 
+// spotifyClient returns a Spotify client for the session's user. Tokens
+// refreshed while it's in use are saved to the session and the user record.
+func (h *Handlers) spotifyClient(ctx context.Context, session *Session) spotifyclient.LibraryClient {
+	return spotifyclient.NewClientFromTokenSource(ctx, h.tokenSource(ctx, session.Token, session), h.spotifyOpts...)
+}
+
+// tokenSource returns a token source that refreshes token with the app's
+// credentials and saves refreshed tokens to session, if there is one, and
+// to its user's record, where the scheduler reads it.
+func (h *Handlers) tokenSource(ctx context.Context, token *oauth2.Token, session *Session) oauth2.TokenSource {
+	var save spotifyclient.SaveFunc
+	if session != nil {
+		save = func(ctx context.Context, token *oauth2.Token) error {
+			if err := h.sessions.UpdateToken(ctx, session.ID, token); err != nil {
+				return err
+			}
+			if h.db == nil {
+				return nil
+			}
+			return h.db.Users().SaveToken(ctx, session.UserID, token.AccessToken, token.RefreshToken, token.Expiry)
+		}
+	}
+	return spotifyclient.NewTokenSource(ctx, token, h.refresh, save)
+}
+
 // Logout clears the session and redirects to home (POST /auth/logout).
 func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -275,35 +344,13 @@ func (h *Handlers) Eras(w http.ResponseWriter, r *http.Request) {
 	// Get user's eras from database
 	var erasData []EraData
 	if h.eraService != nil {
-		dbEras, err := h.eraService.GetUserEras(ctx, session.UserID)
+		var err error
+		erasData, err = h.loadEraData(ctx, session.UserID)
 		if err != nil {
 			log.Printf("Error getting user eras: %v", err)
 			http.Error(w, "Failed to load eras", http.StatusInternalServerError)
 			return
 		}
-
-		// Convert to template-friendly format and get track counts
-		for _, era := range dbEras {
-			trackCount := 0
-			if h.db != nil {
-				count, err := h.db.Eras().GetTrackCount(ctx, era.ID)
-				if err != nil {
-					log.Printf("Error getting track count for era %s: %v", era.ID, err)
-				} else {
-					trackCount = count
-				}
-			}
-
-			erasData = append(erasData, EraData{
-				ID:         era.ID.String(),
-				Name:       era.Name,
-				TopTags:    era.TopTags,
-				StartDate:  era.StartDate,
-				EndDate:    era.EndDate,
-				TrackCount: trackCount,
-				PlaylistID: era.PlaylistID,
-			})
-		}
 	}
 
 	// Get sync status for the header
@@ -347,6 +394,45 @@ func (h *Handlers) Eras(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// loadEraData returns the user's eras with track counts for templates,
+// with sub-eras nested under their parents.
+func (h *Handlers) loadEraData(ctx context.Context, userID string) ([]EraData, error) {
+	dbEras, err := h.eraService.GetUserEras(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return h.toEraData(ctx, eras.BuildTree(dbEras)), nil
+}
+
+// toEraData converts an era tree to template data, loading track counts.
+func (h *Handlers) toEraData(ctx context.Context, nodes []eras.EraNode) []EraData {
+	erasData := make([]EraData, 0, len(nodes))
+	for _, node := range nodes {
+		era := node.Era
+		trackCount := 0
+		if h.db != nil {
+			count, err := h.db.Eras().GetTrackCountForOwner(ctx, era.UserID, era.ID)
+			if err != nil {
+				log.Printf("Error getting track count for era %s: %v", era.ID, err)
+			} else {
+				trackCount = count
+			}
+		}
+
+		erasData = append(erasData, EraData{
+			ID:         era.ID.String(),
+			Name:       era.DisplayName(),
+			TopTags:    era.TopTags,
+			StartDate:  era.StartDate,
+			EndDate:    era.EndDate,
+			TrackCount: trackCount,
+			PlaylistID: era.PlaylistID,
+			SubEras:    h.toEraData(ctx, node.SubEras),
+		})
+	}
+	return erasData
+}
+
 // EraTracks handles fetching tracks for an era (GET /eras/{id}/tracks).
 // This is an HTMX partial endpoint.
 func (h *Handlers) EraTracks(w http.ResponseWriter, r *http.Request) {
@@ -369,38 +455,242 @@ func (h *Handlers) EraTracks(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	// Get tracks for the era
-	var tracks []TrackData
+	data := EraTracksData{}
 	if h.eraService != nil {
-		dbTracks, err := h.eraService.GetEraTracks(ctx, eraID)
+		era, err := h.eraService.GetEra(ctx, session.UserID, eraID)
+		if errors.Is(err, db.ErrNotFound) {
+			http.Error(w, "Era not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error getting era: %v", err)
+			http.Error(w, "Failed to load tracks", http.StatusInternalServerError)
+			return
+		}
+		data.Metrics = toEraMetricsData(era)
+
+		dbTracks, err := h.eraService.GetEraTracks(ctx, session.UserID, eraID)
 		if err != nil {
 			log.Printf("Error getting era tracks: %v", err)
 			http.Error(w, "Failed to load tracks", http.StatusInternalServerError)
 			return
 		}
 
+		// Load the user's manual tags so they can be edited inline
+		trackIDs := make([]string, len(dbTracks))
+		for i, t := range dbTracks {
+			trackIDs[i] = t.ID
+		}
+		userTags, err := h.db.Tags().GetUserTagsForTracks(ctx, session.UserID, trackIDs)
+		if err != nil {
+			log.Printf("Error getting user tags: %v", err)
+			http.Error(w, "Failed to load tracks", http.StatusInternalServerError)
+			return
+		}
+		reasons, err := h.eraService.GetEraTrackReasons(ctx, session.UserID, eraID)
+		if err != nil {
+			log.Printf("Error getting track reasons: %v", err)
+			http.Error(w, "Failed to load tracks", http.StatusInternalServerError)
+			return
+		}
+
 		for _, t := range dbTracks {
 			album := ""
 			if t.Album != nil {
 				album = *t.Album
 			}
-			tracks = append(tracks, TrackData{
-				ID:     t.ID,
-				Name:   t.Name,
-				Artist: t.Artist,
-				Album:  album,
+			data.Tracks = append(data.Tracks, TrackData{
+				ID:       t.ID,
+				Name:     t.Name,
+				Artist:   t.Artist,
+				Album:    album,
+				Reason:   reasons[t.ID],
+				UserTags: toTrackTagsData(t.ID, userTags[t.ID]),
 			})
 		}
 	}
 
 	// Render only the track list partial
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := h.templates.RenderPartial(w, "era-tracks", tracks); err != nil {
+	if err := h.templates.RenderPartial(w, "era-tracks", data); err != nil {
 		log.Printf("Error rendering era tracks: %v", err)
 		http.Error(w, "Failed to render tracks", http.StatusInternalServerError)
 		return
 	}
 }
 
+// maxTagWeightBars is the number of centroid tags shown in an era's
+// tag-weight breakdown.
+const maxTagWeightBars = 8
+
+// toEraMetricsData converts an era's stored metrics for templates.
+// Returns nil for eras detected before metrics were stored.
+func toEraMetricsData(era *db.Era) *EraMetricsData {
+	if era.Cohesion == nil || era.Silhouette == nil {
+		return nil
+	}
+	metrics := &EraMetricsData{Cohesion: *era.Cohesion, Silhouette: *era.Silhouette}
+	weights := era.TagWeights[:min(len(era.TagWeights), maxTagWeightBars)]
+	for _, w := range weights {
+		// Bars are relative to the heaviest tag, which comes first
+		metrics.TagWeights = append(metrics.TagWeights, TagWeightData{
+			Name:    w.Name,
+			Weight:  w.Weight,
+			Percent: int(100 * w.Weight / weights[0].Weight),
+		})
+	}
+	return metrics
+}
+
+// PublishEra publishes an era or sub-era as a private Spotify playlist
+// (POST /eras/{id}/playlist). This is an HTMX partial endpoint that renders
+// the era's playlist action: a link to the playlist, or a retry on failure.
+func (h *Handlers) PublishEra(w http.ResponseWriter, r *http.Request) {
+	session := h.sessions.GetFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.eraService == nil {
+		http.Error(w, "Era service not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx := r.Context()
+	eraID := chi.URLParam(r, "id")
+
+	// Check ownership before talking to Spotify
+	_, err := h.eraService.GetEra(ctx, session.UserID, eraID)
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "Era not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting era: %v", err)
+		http.Error(w, "Failed to publish era", http.StatusInternalServerError)
+		return
+	}
+	client := h.spotifyClient(ctx, session)
+
+	data := EraPlaylistData{EraID: eraID}
+	era, err := h.eraService.PublishPlaylist(ctx, client, session.UserID, eraID, false)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		http.Error(w, "Era not found", http.StatusNotFound)
+		return
+	case err == nil, errors.Is(err, eras.ErrAlreadyPublished):
+		data.PlaylistID = era.PlaylistID
+	default:
+		log.Printf("Error publishing era %s for user %s: %v", eraID, session.UserID, err)
+		data.Error = "Couldn't create the playlist. Try again."
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, "era-playlist", data); err != nil {
+		log.Printf("Error rendering era-playlist template: %v", err)
+	}
+}
+
+// AddTrackTag assigns a manual tag to a track (POST /tracks/{id}/tags).
+// This is an HTMX partial endpoint that re-renders the track's tag list.
+func (h *Handlers) AddTrackTag(w http.ResponseWriter, r *http.Request) {
+	session := h.sessions.GetFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.db == nil {
+		http.Error(w, "Database not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx := r.Context()
+	trackID := chi.URLParam(r, "id")
+
+	var validationErr string
+	tag, err := tags.NormalizeUserTag(r.FormValue("tag"))
+	if err != nil {
+		validationErr = fmt.Sprintf("Tags must be 1-%d characters", tags.MaxUserTagLength)
+	} else {
+		err = h.db.Tags().AddUserTag(ctx, session.UserID, trackID, tag)
+		if errors.Is(err, db.ErrNotFound) {
+			http.Error(w, "Track not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error adding user tag: %v", err)
+			http.Error(w, "Failed to add tag", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.renderTrackTags(w, r, session.UserID, trackID, validationErr)
+}
+
+// RemoveTrackTag removes a manual tag from a track (DELETE /tracks/{id}/tags?tag=...).
+// This is an HTMX partial endpoint that re-renders the track's tag list.
+func (h *Handlers) RemoveTrackTag(w http.ResponseWriter, r *http.Request) {
+	session := h.sessions.GetFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.db == nil {
+		http.Error(w, "Database not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx := r.Context()
+	trackID := chi.URLParam(r, "id")
+
+	// Normalize like AddTrackTag so the delete matches the stored tag
+	tag, err := tags.NormalizeUserTag(r.URL.Query().Get("tag"))
+	if err != nil {
+		http.Error(w, "Tag is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.Tags().RemoveUserTag(ctx, session.UserID, trackID, tag); err != nil {
+		log.Printf("Error removing user tag: %v", err)
+		http.Error(w, "Failed to remove tag", http.StatusInternalServerError)
+		return
+	}
+
+	h.renderTrackTags(w, r, session.UserID, trackID, "")
+}
+
+// renderTrackTags renders the track-tags partial with the track's current manual tags.
+func (h *Handlers) renderTrackTags(w http.ResponseWriter, r *http.Request, userID, trackID, validationErr string) {
+	userTags, err := h.db.Tags().GetUserTagsForTracks(r.Context(), userID, []string{trackID})
+	if err != nil {
+		log.Printf("Error getting user tags: %v", err)
+		http.Error(w, "Failed to load tags", http.StatusInternalServerError)
+		return
+	}
+
+	data := toTrackTagsData(trackID, userTags[trackID])
+	data.Error = validationErr
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, "track-tags", data); err != nil {
+		log.Printf("Error rendering track tags: %v", err)
+		http.Error(w, "Failed to render tags", http.StatusInternalServerError)
+		return
+	}
+}
+
+// toTrackTagsData converts a track's manual tags to template data.
+func toTrackTagsData(trackID string, userTags []db.UserTrackTag) *TrackTagsData {
+	names := make([]string, len(userTags))
+	for i, t := range userTags {
+		names[i] = t.TagName
+	}
+	return &TrackTagsData{
+		TrackID: trackID,
+		Tags:    names,
+	}
+}
+
 // splitPath splits a URL path into segments, removing empty strings.
 func splitPath(path string) []string {
 	var parts []string
@@ -431,14 +721,55 @@ func splitString(s string, sep rune) []string {
 // AnalyzeResponse is the JSON response for POST /api/analyze.
 type AnalyzeResponse struct {
 	EraCount     int    `json:"era_count"`
+	SubEraCount  int    `json:"sub_era_count,omitempty"`
 	OutlierCount int    `json:"outlier_count"`
 	TotalTracks  int    `json:"total_tracks"`
 	Message      string `json:"message"`
+
+	// How the eras relate to the previous ones, omitted on a first analysis
+	Changes []EraChangeJSON `json:"changes,omitempty"`
+}
+
+// EraChangeJSON is the JSON representation of what happened to an era in a
+// re-analysis.
+type EraChangeJSON struct {
+	Status     eras.ChangeStatus `json:"status"` // unchanged, changed, new, split, merged or gone
+	EraID      string            `json:"era_id"` // The deleted era's ID if gone
+	Name       string            `json:"name"`
+	Previous   []PreviousEraJSON `json:"previous,omitempty"`
+	Similarity float64           `json:"similarity,omitempty"` // Jaccard similarity with the matched era
+	Depth      int               `json:"depth,omitempty"`      // Set for sub-eras
+}
+
+// PreviousEraJSON identifies an era from before a re-analysis.
+type PreviousEraJSON struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// toChangeJSON converts a re-analysis report to JSON.
+func toChangeJSON(changes []eras.EraChange) []EraChangeJSON {
+	var result []EraChangeJSON
+	for _, c := range changes {
+		change := EraChangeJSON{
+			Status:     c.Status,
+			EraID:      c.EraID.String(),
+			Name:       c.Name,
+			Similarity: c.Similarity,
+			Depth:      c.Depth,
+		}
+		for _, p := range c.Previous {
+			change.Previous = append(change.Previous, PreviousEraJSON{ID: p.ID.String(), Name: p.Name})
+		}
+		result = append(result, change)
+	}
+	return result
 }
 
 // ErrorResponse is the JSON response for errors.
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"` // Per-field validation errors
 }
 
 // EraJSON is the JSON representation of an era.
@@ -449,6 +780,12 @@ type EraJSON struct {
 	StartDate  string   `json:"start_date"`
 	EndDate    string   `json:"end_date"`
 	TrackCount int      `json:"track_count,omitempty"`
+	ParentID   string   `json:"parent_id,omitempty"` // Set for sub-eras
+
+	// Quality metrics, omitted for eras detected before they were stored
+	Cohesion   *float64          `json:"cohesion,omitempty"`
+	Silhouette *float64          `json:"silhouette,omitempty"`
+	TagWeights []db.EraTagWeight `json:"tag_weights,omitempty"`
 }
 
 // TrackJSON is the JSON representation of a track.
@@ -459,6 +796,29 @@ type TrackJSON struct {
 	Album  string `json:"album,omitempty"`
 }
 
+// PreviewResponse is the JSON response for POST /api/analyze/preview.
+type PreviewResponse struct {
+	PreviewID    string           `json:"preview_id"`
+	ExpiresAt    string           `json:"expires_at"` // RFC 3339; apply before this time
+	Basis        clustering.Basis `json:"basis"`
+	EraCount     int              `json:"era_count"`
+	OutlierCount int              `json:"outlier_count"`
+	TotalTracks  int              `json:"total_tracks"`
+	Eras         []PreviewEraJSON `json:"eras"`
+	Outliers     []TrackJSON      `json:"outliers"`
+}
+
+// PreviewEraJSON is the JSON representation of an unsaved era.
+type PreviewEraJSON struct {
+	Name       string           `json:"name"`
+	TopTags    []string         `json:"top_tags"`
+	StartDate  string           `json:"start_date"`
+	EndDate    string           `json:"end_date"`
+	TrackCount int              `json:"track_count"`
+	Tracks     []TrackJSON      `json:"tracks"`
+	SubEras    []PreviewEraJSON `json:"sub_eras,omitempty"`
+}
+
 // Analyze handles the full analysis pipeline (POST /api/analyze).
 // This triggers: sync → tags → clustering → eras.
 func (h *Handlers) Analyze(w http.ResponseWriter, r *http.Request) {
@@ -485,13 +845,14 @@ func (h *Handlers) Analyze(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get session token for Spotify API calls
-	token := session.Token
+	// Optional per-request overrides of the user's settings
+	overrides, ok := h.decodeSettingsOverrides(w, r)
+	if !ok {
+		return
+	}
 
 	// Step 1: Sync liked songs (skip if recently synced)
-	httpClient := h.auth.Client(ctx, token)
-	spotifyAPI := spotify.New(httpClient)
-	client := spotifyclient.New(spotifyAPI)
+	client := h.spotifyClient(ctx, session)
 
 	log.Printf("Starting analysis for user %s", userID)
 
@@ -518,8 +879,14 @@ func (h *Handlers) Analyze(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Tag service not available, skipping tag fetch for user %s", userID)
 	}
 
-	// Step 3: Detect and persist eras
-	cfg := clustering.DefaultTagClusterConfig()
+	// Step 3: Detect and persist eras with the user's settings and any
+	// overrides from the request
+	cfg, err := h.eraService.DefaultConfig(ctx, userID)
+	if err != nil {
+		h.jsonError(w, fmt.Sprintf("Era detection failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	overrides.Apply(&cfg)
 	result, err := h.eraService.DetectAndPersist(ctx, userID, cfg)
 	if err != nil {
 		h.jsonError(w, fmt.Sprintf("Era detection failed: %v", err), http.StatusInternalServerError)
@@ -531,99 +898,184 @@ func (h *Handlers) Analyze(w http.ResponseWriter, r *http.Request) {
 	// Return success response
 	resp := AnalyzeResponse{
 		EraCount:     len(result.Eras),
+		SubEraCount:  len(result.SubEras),
 		OutlierCount: result.OutlierCount,
 		TotalTracks:  result.TotalTracks,
 		Message:      fmt.Sprintf("Detected %d eras from %d tracks", len(result.Eras), result.TotalTracks),
+		Changes:      toChangeJSON(result.Changes),
 	}
 
 	h.jsonResponse(w, resp, http.StatusOK)
 }
 
-// fetchMissingTags fetches Last.fm tags for tracks that don't have any.
-func (h *Handlers) fetchMissingTags(ctx context.Context, userID string) error {
-	// Get all user's tracks
-	tracks, err := h.db.Tracks().GetUserTracks(ctx, userID)
-	if err != nil {
-		return fmt.Errorf("getting user tracks: %w", err)
+// decodeSettingsOverrides reads clustering overrides from a JSON request
+// body. Requests without a JSON body have no overrides. On invalid input it
+// writes a 400 response and returns false.
+func (h *Handlers) decodeSettingsOverrides(w http.ResponseWriter, r *http.Request) (eras.Settings, bool) {
+	var overrides eras.Settings
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" || r.ContentLength == 0 {
+		return overrides, true
 	}
 
-	if len(tracks) == 0 {
-		return nil
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&overrides); err != nil && !errors.Is(err, io.EOF) {
+		h.jsonError(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return overrides, false
 	}
 
-	// Get track IDs
-	trackIDs := make([]string, len(tracks))
-	for i, t := range tracks {
-		trackIDs[i] = t.ID
+	var validationErr eras.ValidationError
+	if err := overrides.Validate(); errors.As(err, &validationErr) {
+		h.jsonResponse(w, ErrorResponse{Error: "Invalid settings", Fields: validationErr}, http.StatusBadRequest)
+		return overrides, false
 	}
+	return overrides, true
+}
 
-	// Find tracks without tags
-	missingIDs, err := h.db.Tags().GetTracksWithoutTags(ctx, trackIDs)
-	if err != nil {
-		return fmt.Errorf("finding tracks without tags: %w", err)
+// PreviewAnalyze runs era detection on the user's stored data without
+// saving it (POST /api/analyze/preview). It accepts the same JSON overrides
+// as Analyze but doesn't sync or fetch tags. The preview can be saved with
+// ApplyPreview.
+func (h *Handlers) PreviewAnalyze(w http.ResponseWriter, r *http.Request) {
+	session := h.sessions.GetFromRequest(r)
+	if session == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.eraService == nil {
+		h.jsonError(w, "Era service not configured", http.StatusServiceUnavailable)
+		return
 	}
 
-	if len(missingIDs) == 0 {
-		return nil
+	overrides, ok := h.decodeSettingsOverrides(w, r)
+	if !ok {
+		return
 	}
 
-	log.Printf("Fetching tags for %d tracks", len(missingIDs))
+	ctx := r.Context()
+	cfg, err := h.eraService.DefaultConfig(ctx, session.UserID)
+	if err != nil {
+		h.jsonError(w, fmt.Sprintf("Era detection failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	overrides.Apply(&cfg)
+	preview, err := h.eraService.Preview(ctx, session.UserID, cfg)
+	if err != nil {
+		h.jsonError(w, fmt.Sprintf("Era detection failed: %v", err), http.StatusInternalServerError)
+		return
+	}
 
-	// Build lookup map for track info
-	trackMap := make(map[string]db.Track)
-	for _, t := range tracks {
-		trackMap[t.ID] = t
+	resp := PreviewResponse{
+		PreviewID:    preview.ID,
+		ExpiresAt:    preview.CreatedAt.Add(eras.PreviewTTL).UTC().Format(time.RFC3339),
+		Basis:        preview.Config.Basis,
+		EraCount:     len(preview.Eras),
+		OutlierCount: len(preview.Outliers),
+		TotalTracks:  preview.TotalTracks,
+		Eras:         toPreviewEraJSON(preview.Eras),
+		Outliers:     toTrackJSON(preview.Outliers),
 	}
 
-	// Convert to tag service format
-	tagTracks := make([]tags.Track, 0, len(missingIDs))
-	for _, id := range missingIDs {
-		t, ok := trackMap[id]
-		if !ok {
-			continue
-		}
-		tagTracks = append(tagTracks, tags.Track{
-			ID:     t.ID,
-			Name:   t.Name,
-			Artist: t.Artist,
-		})
+	h.jsonResponse(w, resp, http.StatusOK)
+}
+
+// ApplyPreview replaces the user's eras with a preview's eras
+// (POST /api/analyze/preview/{id}/apply).
+func (h *Handlers) ApplyPreview(w http.ResponseWriter, r *http.Request) {
+	session := h.sessions.GetFromRequest(r)
+	if session == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.eraService == nil {
+		h.jsonError(w, "Era service not configured", http.StatusServiceUnavailable)
+		return
 	}
 
-	// Fetch tags
-	results, err := h.tagService.FetchTagsForTracks(ctx, tagTracks)
+	result, err := h.eraService.ApplyPreview(r.Context(), session.UserID, chi.URLParam(r, "id"))
+	if errors.Is(err, eras.ErrPreviewNotFound) {
+		h.jsonError(w, "Preview not found or expired; run the preview again", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		return fmt.Errorf("fetching tags: %w", err)
+		h.jsonError(w, fmt.Sprintf("Applying preview failed: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	// Convert and persist tags
-	now := time.Now()
-	var dbTags []db.TrackTag
-	for _, result := range results {
-		if result.Error != nil || len(result.Tags) == 0 {
-			continue
+	log.Printf("Applied preview with %d eras for user %s", len(result.Eras), session.UserID)
+
+	h.jsonResponse(w, AnalyzeResponse{
+		EraCount:     len(result.Eras),
+		SubEraCount:  len(result.SubEras),
+		OutlierCount: result.OutlierCount,
+		TotalTracks:  result.TotalTracks,
+		Message:      fmt.Sprintf("Saved %d eras from %d tracks", len(result.Eras), result.TotalTracks),
+		Changes:      toChangeJSON(result.Changes),
+	}, http.StatusOK)
+}
+
+// toPreviewEraJSON converts unsaved eras and their sub-eras to JSON.
+func toPreviewEraJSON(moodEras []clustering.MoodEra) []PreviewEraJSON {
+	result := make([]PreviewEraJSON, len(moodEras))
+	for i, era := range moodEras {
+		result[i] = PreviewEraJSON{
+			Name:       era.Name,
+			TopTags:    era.TopTags,
+			StartDate:  era.StartDate.Format("2006-01-02"),
+			EndDate:    era.EndDate.Format("2006-01-02"),
+			TrackCount: len(era.Tracks),
+			Tracks:     toTrackJSON(era.Tracks),
 		}
-		for _, tag := range result.Tags {
-			dbTags = append(dbTags, db.TrackTag{
-				TrackID:   result.TrackID,
-				TagName:   tag.Name,
-				TagCount:  tag.Count,
-				Source:    string(result.Source),
-				FetchedAt: now,
-			})
+		if len(era.SubEras) > 0 {
+			result[i].SubEras = toPreviewEraJSON(era.SubEras)
 		}
 	}
+	return result
+}
 
-	if len(dbTags) > 0 {
-		if err := h.db.Tags().UpsertBatch(ctx, dbTags); err != nil {
-			return fmt.Errorf("persisting tags: %w", err)
+// toPreviewEraData converts unsaved eras and their sub-eras to template data.
+func toPreviewEraData(moodEras []clustering.MoodEra) []EraData {
+	result := make([]EraData, len(moodEras))
+	for i, era := range moodEras {
+		result[i] = EraData{
+			Name:       era.Name,
+			TopTags:    era.TopTags,
+			StartDate:  era.StartDate,
+			EndDate:    era.EndDate,
+			TrackCount: len(era.Tracks),
+			SubEras:    toPreviewEraData(era.SubEras),
 		}
-		log.Printf("Persisted %d tags for user %s", len(dbTags), userID)
 	}
+	return result
+}
+
+// toTrackJSON converts clustering tracks to their JSON representation.
+func toTrackJSON(tracks []clustering.Track) []TrackJSON {
+	result := make([]TrackJSON, len(tracks))
+	for i, t := range tracks {
+		result[i] = TrackJSON{ID: t.ID, Name: t.Name, Artist: t.Artist}
+	}
+	return result
+}
 
+// fetchMissingTags fetches Last.fm tags for tracks that don't have any,
+// and refreshes stale tags.
+func (h *Handlers) fetchMissingTags(ctx context.Context, userID string) error {
+	enricher := tags.NewEnricher(h.db, h.tagService, tags.WithCacheTTL(h.tagCacheTTL))
+	result, err := enricher.FetchMissing(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if result.TagsStored > 0 {
+		log.Printf("Persisted %d tags for %d of %d untagged or stale tracks for user %s",
+			result.TagsStored, result.TracksTagged, result.TracksMissing+result.TracksStale, userID)
+	}
 	return nil
 }
 
-// GetEras returns all eras for the authenticated user (GET /api/eras).
+// GetEras returns all eras for the authenticated user, including sub-eras
+// with their parent IDs (GET /api/eras).
 func (h *Handlers) GetEras(w http.ResponseWriter, r *http.Request) {
 	session := h.sessions.GetFromRequest(r)
 	if session == nil {
@@ -649,20 +1101,27 @@ func (h *Handlers) GetEras(w http.ResponseWriter, r *http.Request) {
 	for _, era := range dbEras {
 		trackCount := 0
 		if h.db != nil {
-			count, err := h.db.Eras().GetTrackCount(ctx, era.ID)
+			count, err := h.db.Eras().GetTrackCountForOwner(ctx, era.UserID, era.ID)
 			if err == nil {
 				trackCount = count
 			}
 		}
 
-		result = append(result, EraJSON{
+		eraJSON := EraJSON{
 			ID:         era.ID.String(),
-			Name:       era.Name,
+			Name:       era.DisplayName(),
 			TopTags:    era.TopTags,
 			StartDate:  era.StartDate.Format("2006-01-02"),
 			EndDate:    era.EndDate.Format("2006-01-02"),
 			TrackCount: trackCount,
-		})
+			Cohesion:   era.Cohesion,
+			Silhouette: era.Silhouette,
+			TagWeights: era.TagWeights,
+		}
+		if era.ParentID != nil {
+			eraJSON.ParentID = era.ParentID.String()
+		}
+		result = append(result, eraJSON)
 	}
 
 	h.jsonResponse(w, result, http.StatusOK)
@@ -689,7 +1148,11 @@ func (h *Handlers) GetEraTracksAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dbTracks, err := h.eraService.GetEraTracks(ctx, eraID)
+	dbTracks, err := h.eraService.GetEraTracks(ctx, session.UserID, eraID)
+	if errors.Is(err, db.ErrNotFound) {
+		h.jsonError(w, "Era not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		h.jsonError(w, fmt.Sprintf("Failed to get tracks: %v", err), http.StatusInternalServerError)
 		return
@@ -713,6 +1176,151 @@ func (h *Handlers) GetEraTracksAPI(w http.ResponseWriter, r *http.Request) {
 	h.jsonResponse(w, result, http.StatusOK)
 }
 
+// maxRenameSize limits the request body for PUT /api/eras/{id}/name.
+const maxRenameSize = 4 << 10
+
+// RenameRequest is the JSON request for PUT /api/eras/{id}/name.
+type RenameRequest struct {
+	Name string `json:"name"` // Empty to restore the generated name
+}
+
+// RenameEra sets the user's name for an era (PUT /api/eras/{id}/name). The
+// name is kept when re-analysis matches the era with a new one.
+func (h *Handlers) RenameEra(w http.ResponseWriter, r *http.Request) {
+	session := h.sessions.GetFromRequest(r)
+	if session == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.eraService == nil {
+		h.jsonError(w, "Era service not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req RenameRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRenameSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		h.jsonError(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	era, err := h.eraService.RenameEra(r.Context(), session.UserID, chi.URLParam(r, "id"), req.Name)
+	switch {
+	case errors.Is(err, eras.ErrNameTooLong):
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, db.ErrNotFound):
+		h.jsonError(w, "Era not found", http.StatusNotFound)
+		return
+	case err != nil:
+		h.jsonError(w, fmt.Sprintf("Renaming era failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	resp := EraJSON{
+		ID:         era.ID.String(),
+		Name:       era.DisplayName(),
+		TopTags:    era.TopTags,
+		StartDate:  era.StartDate.Format("2006-01-02"),
+		EndDate:    era.EndDate.Format("2006-01-02"),
+		Cohesion:   era.Cohesion,
+		Silhouette: era.Silhouette,
+		TagWeights: era.TagWeights,
+	}
+	if era.ParentID != nil {
+		resp.ParentID = era.ParentID.String()
+	}
+	h.jsonResponse(w, resp, http.StatusOK)
+}
+
+// ExportEra downloads one era in the requested format
+// (GET /api/eras/{id}/export?format=m3u8|xspf|csv|json).
+func (h *Handlers) ExportEra(w http.ResponseWriter, r *http.Request) {
+	h.exportEras(w, r, chi.URLParam(r, "id"))
+}
+
+// ExportEras downloads all of the user's eras in the requested format
+// (GET /api/eras/export?format=m3u8|xspf|csv|json).
+func (h *Handlers) ExportEras(w http.ResponseWriter, r *http.Request) {
+	h.exportEras(w, r)
+}
+
+// exportEras writes the given eras, or all of the user's eras, as a file
+// download. The format defaults to JSON.
+func (h *Handlers) exportEras(w http.ResponseWriter, r *http.Request, eraIDs ...string) {
+	session := h.sessions.GetFromRequest(r)
+	if session == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if h.eraService == nil {
+		h.jsonError(w, "Era service not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	format := exporter.FormatJSON
+	if name := r.URL.Query().Get("format"); name != "" {
+		var err error
+		format, err = exporter.ParseFormat(name)
+		if err != nil {
+			h.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	list, err := h.eraService.Exporter().Load(r.Context(), session.UserID, eraIDs...)
+	if errors.Is(err, db.ErrNotFound) {
+		h.jsonError(w, "Era not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.jsonError(w, fmt.Sprintf("Failed to load eras: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Render before writing headers so a failure can still return an error
+	var buf bytes.Buffer
+	if err := exporter.Write(&buf, format, list); err != nil {
+		h.jsonError(w, fmt.Sprintf("Failed to export eras: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exporter.Filename(list, format)))
+	if _, err := buf.WriteTo(w); err != nil {
+		log.Printf("Error writing export: %v", err)
+	}
+}
+
+// Backup downloads a full-library backup archive (GET /api/backup).
+func (h *Handlers) Backup(w http.ResponseWriter, r *http.Request) {
+	session := h.sessions.GetFromRequest(r)
+	if session == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if h.db == nil {
+		h.jsonError(w, "Database not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	archive, err := backup.New(backup.NewDBStore(h.db)).Backup(r.Context(), session.UserID)
+	if err != nil {
+		h.jsonError(w, fmt.Sprintf("Failed to create backup: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("spotify-eras-backup-%s.json", archive.CreatedAt.Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if err := backup.Write(w, archive); err != nil {
+		log.Printf("Error writing backup: %v", err)
+	}
+}
+
 // jsonResponse writes a JSON response.
 func (h *Handlers) jsonResponse(w http.ResponseWriter, data any, status int) {
 	w.Header().Set("Content-Type", "application/json")
@@ -829,13 +1437,7 @@ func (h *Handlers) SyncLibrary(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get session token for Spotify API calls
-	token := session.Token
-
-	// Create Spotify client
-	httpClient := h.auth.Client(ctx, token)
-	spotifyAPI := spotify.New(httpClient)
-	client := spotifyclient.New(spotifyAPI)
+	client := h.spotifyClient(ctx, session)
 
 	log.Printf("Starting sync for user %s", userID)
 
@@ -867,7 +1469,11 @@ func (h *Handlers) SyncLibrary(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Re-detect and persist eras
-	cfg := clustering.DefaultTagClusterConfig()
+	cfg, err := h.eraService.DefaultConfig(ctx, userID)
+	if err != nil {
+		h.jsonError(w, fmt.Sprintf("Era detection failed: %v", err), http.StatusInternalServerError)
+		return
+	}
 	eraResult, err := h.eraService.DetectAndPersist(ctx, userID, cfg)
 	if err != nil {
 		h.jsonError(w, fmt.Sprintf("Era detection failed: %v", err), http.StatusInternalServerError)
@@ -887,3 +1493,407 @@ func (h *Handlers) SyncLibrary(w http.ResponseWriter, r *http.Request) {
 
 	h.jsonResponse(w, resp, http.StatusOK)
 }
+
+// maxImportSize limits the total upload size for POST /api/import/spotify.
+// Streaming history files are ~1.5 MB each; a decade of history fits comfortably.
+const maxImportSize = 64 << 20
+
+// ImportResponse is the JSON response for POST /api/import/spotify.
+type ImportResponse struct {
+	TracksImported int    `json:"tracks_imported"`
+	TracksSkipped  int    `json:"tracks_skipped"`
+	TracksPlayed   int    `json:"tracks_played"`
+	PlaysRead      int    `json:"plays_read"`
+	PlaysImported  int    `json:"plays_imported"`
+	ErasDetected   int    `json:"eras_detected"`
+	Message        string `json:"message"`
+}
+
+// ImportSpotify imports liked songs from an uploaded Spotify privacy data export
+// and re-detects eras without calling the Spotify API (POST /api/import/spotify).
+// Expects multipart form field "files" containing YourLibrary.json and/or
+// streaming history files (standard or extended); other files are ignored.
+func (h *Handlers) ImportSpotify(w http.ResponseWriter, r *http.Request) {
+	session := h.sessions.GetFromRequest(r)
+	if session == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+	userID := session.UserID
+
+	if h.db == nil {
+		h.jsonError(w, "Database not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		h.jsonError(w, "Invalid upload: expected multipart form under 64 MB", http.StatusBadRequest)
+		return
+	}
+
+	export := &importer.Export{}
+	found := false
+	for _, fh := range r.MultipartForm.File["files"] {
+		if !importer.IsExportFile(fh.Filename) {
+			continue
+		}
+		found = true
+		f, err := fh.Open()
+		if err != nil {
+			h.jsonError(w, fmt.Sprintf("Failed to read %s", fh.Filename), http.StatusBadRequest)
+			return
+		}
+		err = importer.ParseFile(export, fh.Filename, f)
+		f.Close()
+		if err != nil {
+			h.jsonError(w, fmt.Sprintf("Invalid export file: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if !found {
+		h.jsonError(w, "Upload must include YourLibrary.json or streaming history files", http.StatusBadRequest)
+		return
+	}
+
+	result, err := importer.New(importer.NewDBStore(h.db)).Import(ctx, userID, export)
+	if err != nil {
+		h.jsonError(w, fmt.Sprintf("Import failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Imported %d tracks for user %s from export (%d dated by history)", result.TracksImported, userID, result.TracksPlayed)
+
+	resp := ImportResponse{
+		TracksImported: result.TracksImported,
+		TracksSkipped:  result.TracksSkipped,
+		TracksPlayed:   result.TracksPlayed,
+		PlaysRead:      result.PlaysRead,
+		PlaysImported:  result.PlaysImported,
+	}
+
+	if h.tagService != nil {
+		if err := h.fetchMissingTags(ctx, userID); err != nil {
+			log.Printf("Warning: tag fetching failed for user %s: %v", userID, err)
+		}
+	}
+
+	if h.eraService != nil {
+		cfg, err := h.eraService.DefaultConfig(ctx, userID)
+		if err != nil {
+			h.jsonError(w, fmt.Sprintf("Era detection failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		eraResult, err := h.eraService.DetectAndPersist(ctx, userID, cfg)
+		if err != nil {
+			h.jsonError(w, fmt.Sprintf("Era detection failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		resp.ErasDetected = len(eraResult.Eras)
+	}
+
+	resp.Message = fmt.Sprintf("Imported %d tracks and %d plays, detected %d eras", result.TracksImported, result.PlaysImported, resp.ErasDetected)
+	h.jsonResponse(w, resp, http.StatusOK)
+}
+
+// Settings renders the analysis settings page (GET /settings).
+func (h *Handlers) Settings(w http.ResponseWriter, r *http.Request) {
+	session := h.sessions.GetFromRequest(r)
+	if session == nil {
+		http.Redirect(w, r, "/auth/login", http.StatusTemporaryRedirect)
+		return
+	}
+	if h.eraService == nil {
+		http.Error(w, "Database not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	settings, err := h.eraService.GetSettings(r.Context(), session.UserID)
+	if err != nil {
+		log.Printf("Error getting settings: %v", err)
+		http.Error(w, "Failed to load settings", http.StatusInternalServerError)
+		return
+	}
+
+	data := SettingsPageData{
+		PageData: PageData{
+			Title:       "Settings",
+			CurrentPath: r.URL.Path,
+			User: &UserData{
+				ID:   session.UserID,
+				Name: session.UserName,
+			},
+		},
+		Form: newSettingsForm(h.eraService.Defaults(), settingsValues(settings), nil),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.Render(w, "settings", data); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		return
+	}
+}
+
+// SaveSettings validates and stores the settings form (POST /settings).
+// This is an HTMX partial endpoint that re-renders the form.
+func (h *Handlers) SaveSettings(w http.ResponseWriter, r *http.Request) {
+	session := h.sessions.GetFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.eraService == nil {
+		http.Error(w, "Database not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	settings, values, errs := parseSettingsForm(r)
+	form := newSettingsForm(h.eraService.Defaults(), values, errs)
+	if len(errs) > 0 {
+		form.Invalid = true
+	} else {
+		err := h.eraService.SaveSettings(r.Context(), session.UserID, settings)
+		if err != nil {
+			log.Printf("Error saving settings: %v", err)
+			http.Error(w, "Failed to save settings", http.StatusInternalServerError)
+			return
+		}
+		form.Saved = true
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, "settings-form", form); err != nil {
+		log.Printf("Error rendering settings form: %v", err)
+		http.Error(w, "Failed to render settings", http.StatusInternalServerError)
+	}
+}
+
+// ValidateSettings checks one settings field as the user edits it
+// (POST /settings/validate?field=...). This is an HTMX partial endpoint
+// that renders the field's error message, which is empty when valid.
+func (h *Handlers) ValidateSettings(w http.ResponseWriter, r *http.Request) {
+	if h.sessions.GetFromRequest(r) == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	field := r.URL.Query().Get("field")
+	_, _, errs := parseSettingsForm(r)
+	data := SettingsFieldErrorData{Field: field, Message: errs[field]}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, "settings-field-error", data); err != nil {
+		log.Printf("Error rendering settings error: %v", err)
+		http.Error(w, "Failed to render settings", http.StatusInternalServerError)
+	}
+}
+
+// SettingsPreview runs era detection with the submitted settings form
+// without saving anything (POST /settings/preview). This is an HTMX partial
+// endpoint that renders the preview beside the current eras.
+func (h *Handlers) SettingsPreview(w http.ResponseWriter, r *http.Request) {
+	session := h.sessions.GetFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.eraService == nil {
+		http.Error(w, "Database not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx := r.Context()
+	data := EraPreviewData{}
+	settings, _, errs := parseSettingsForm(r)
+	if len(errs) > 0 {
+		data.Error = "Fix the highlighted fields to preview."
+	} else {
+		cfg, err := h.eraService.DefaultConfig(ctx, session.UserID)
+		if err == nil {
+			settings.Apply(&cfg)
+			var preview *eras.Preview
+			preview, err = h.eraService.Preview(ctx, session.UserID, cfg)
+			if err == nil {
+				data.PreviewID = preview.ID
+				data.Basis = string(preview.Config.Basis)
+				data.OutlierCount = len(preview.Outliers)
+				data.TotalTracks = preview.TotalTracks
+				data.Preview = toPreviewEraData(preview.Eras)
+			}
+		}
+		if err != nil {
+			log.Printf("Error previewing eras: %v", err)
+			data.Error = "Preview failed. Please try again."
+		}
+	}
+
+	current, err := h.loadEraData(ctx, session.UserID)
+	if err != nil {
+		log.Printf("Error getting user eras: %v", err)
+		http.Error(w, "Failed to load eras", http.StatusInternalServerError)
+		return
+	}
+	data.Current = current
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, "era-preview", data); err != nil {
+		log.Printf("Error rendering era preview: %v", err)
+		http.Error(w, "Failed to render preview", http.StatusInternalServerError)
+	}
+}
+
+// parseSettingsForm reads settings from a submitted form. Empty fields keep
+// the default. It returns the parsed settings, the raw values for
+// re-rendering, and any parse or range errors by field.
+func parseSettingsForm(r *http.Request) (eras.Settings, map[string]string, eras.ValidationError) {
+	var settings eras.Settings
+	values := make(map[string]string)
+	errs := make(eras.ValidationError)
+
+	parseInt := func(field string) *int {
+		raw := strings.TrimSpace(r.FormValue(field))
+		values[field] = raw
+		if raw == "" {
+			return nil
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			errs[field] = "must be a whole number"
+			return nil
+		}
+		return &v
+	}
+	parseFloat := func(field string) *float64 {
+		raw := strings.TrimSpace(r.FormValue(field))
+		values[field] = raw
+		if raw == "" {
+			return nil
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			errs[field] = "must be a number"
+			return nil
+		}
+		return &v
+	}
+
+	settings.NumClusters = parseInt(eras.FieldNumClusters)
+	settings.MinClusterSize = parseInt(eras.FieldMinClusterSize)
+	settings.MaxTags = parseInt(eras.FieldMaxTags)
+	settings.SubEraDepth = parseInt(eras.FieldSubEraDepth)
+	settings.SyncIntervalHours = parseInt(eras.FieldSyncInterval)
+	settings.ManualTagWeight = parseFloat(eras.FieldManualTagWeight)
+	settings.TimeWeight = parseFloat(eras.FieldTimeWeight)
+
+	values[eras.FieldBasis] = r.FormValue(eras.FieldBasis)
+	if raw := values[eras.FieldBasis]; raw != "" {
+		basis := clustering.Basis(raw)
+		settings.Basis = &basis
+	}
+
+	values[eras.FieldNameTemplate] = strings.TrimSpace(r.FormValue(eras.FieldNameTemplate))
+	if raw := values[eras.FieldNameTemplate]; raw != "" {
+		settings.NameTemplate = &raw
+	}
+
+	var rangeErrs eras.ValidationError
+	if errors.As(settings.Validate(), &rangeErrs) {
+		for field, msg := range rangeErrs {
+			if _, ok := errs[field]; !ok {
+				errs[field] = msg
+			}
+		}
+	}
+	return settings, values, errs
+}
+
+// settingsValues converts stored settings to form values.
+func settingsValues(s eras.Settings) map[string]string {
+	values := make(map[string]string)
+	if s.NumClusters != nil {
+		values[eras.FieldNumClusters] = strconv.Itoa(*s.NumClusters)
+	}
+	if s.MinClusterSize != nil {
+		values[eras.FieldMinClusterSize] = strconv.Itoa(*s.MinClusterSize)
+	}
+	if s.MaxTags != nil {
+		values[eras.FieldMaxTags] = strconv.Itoa(*s.MaxTags)
+	}
+	if s.ManualTagWeight != nil {
+		values[eras.FieldManualTagWeight] = strconv.FormatFloat(*s.ManualTagWeight, 'g', -1, 64)
+	}
+	if s.Basis != nil {
+		values[eras.FieldBasis] = string(*s.Basis)
+	}
+	if s.TimeWeight != nil {
+		values[eras.FieldTimeWeight] = strconv.FormatFloat(*s.TimeWeight, 'g', -1, 64)
+	}
+	if s.NameTemplate != nil {
+		values[eras.FieldNameTemplate] = *s.NameTemplate
+	}
+	if s.SubEraDepth != nil {
+		values[eras.FieldSubEraDepth] = strconv.Itoa(*s.SubEraDepth)
+	}
+	if s.SyncIntervalHours != nil {
+		values[eras.FieldSyncInterval] = strconv.Itoa(*s.SyncIntervalHours)
+	}
+	return values
+}
+
+// newSettingsForm builds the settings form with the given defaults, values
+// and errors.
+func newSettingsForm(defaults clustering.TagClusterConfig, values map[string]string, errs eras.ValidationError) *SettingsFormData {
+	field := func(name, label, help, def, lo, hi, step string) SettingsFieldData {
+		return SettingsFieldData{
+			Name:    name,
+			Label:   label,
+			Help:    help,
+			Value:   values[name],
+			Default: def,
+			Min:     lo,
+			Max:     hi,
+			Step:    step,
+			Error:   SettingsFieldErrorData{Field: name, Message: errs[name]},
+		}
+	}
+	weight := strconv.FormatFloat(eras.MaxWeight, 'g', -1, 64)
+
+	basis := field(eras.FieldBasis, "Date eras by", "Plays use your listening history when you've imported it.", "", "", "", "")
+	basis.Options = []SettingsOptionData{
+		{Value: "", Label: "Automatic"},
+		{Value: string(clustering.BasisLikes), Label: "When I liked songs"},
+		{Value: string(clustering.BasisPlays), Label: "When I played songs"},
+	}
+
+	placeholders := make([]string, len(clustering.NamePlaceholders))
+	for i, p := range clustering.NamePlaceholders {
+		placeholders[i] = "{" + p.Name + "}"
+	}
+	nameTemplate := field(eras.FieldNameTemplate, "Era names",
+		"Placeholders: "+strings.Join(placeholders, " ")+". Tags are the ones that set an era apart; artists stand in when tags are weak.",
+		clustering.DefaultNameTemplate, "", strconv.Itoa(clustering.MaxNameTemplateLength), "")
+	nameTemplate.Text = true
+
+	return &SettingsFormData{
+		Fields: []SettingsFieldData{
+			field(eras.FieldNumClusters, "Number of eras", "How many eras to split your library into.",
+				strconv.Itoa(defaults.NumClusters), strconv.Itoa(eras.MinNumClusters), strconv.Itoa(eras.MaxNumClusters), "1"),
+			field(eras.FieldMinClusterSize, "Minimum era size", "Smaller groups of tracks are left out as outliers.",
+				strconv.Itoa(defaults.MinClusterSize), strconv.Itoa(eras.MinMinClusterSize), strconv.Itoa(eras.MaxMinClusterSize), "1"),
+			field(eras.FieldMaxTags, "Tags considered", "The most common tags used to compare tracks.",
+				strconv.Itoa(defaults.MaxTags), strconv.Itoa(eras.MinMaxTags), strconv.Itoa(eras.MaxMaxTags), "1"),
+			field(eras.FieldManualTagWeight, "Your tag weight", "How much your own tags count compared to Last.fm tags.",
+				strconv.FormatFloat(defaults.ManualTagWeight, 'g', -1, 64), "0", weight, "0.1"),
+			basis,
+			field(eras.FieldTimeWeight, "Time weight", "How strongly play dates pull tracks together (plays only).",
+				strconv.FormatFloat(defaults.TimeWeight, 'g', -1, 64), "0", weight, "0.1"),
+			field(eras.FieldSubEraDepth, "Sub-era depth", "Split each era into sub-moods, and those into their own, this many levels deep.",
+				strconv.Itoa(defaults.SubEraDepth), "0", strconv.Itoa(eras.MaxSubEraDepth), "1"),
+			nameTemplate,
+			field(eras.FieldSyncInterval, "Background sync", "Hours between automatic syncs and re-analysis of your library. 0 turns them off.",
+				strconv.Itoa(int(scheduler.DefaultInterval/time.Hour)), "0", strconv.Itoa(eras.MaxSyncInterval), "1"),
+		},
+	}
+}
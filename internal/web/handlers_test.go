@@ -0,0 +1,157 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/justestif/go-spotify-era-organizer/internal/db"
+	"github.com/justestif/go-spotify-era-organizer/internal/db/dbtest"
+	"github.com/justestif/go-spotify-era-organizer/internal/eras"
+	spotifyclient "github.com/justestif/go-spotify-era-organizer/internal/spotify"
+	"github.com/justestif/go-spotify-era-organizer/internal/spotify/spotifytest"
+)
+
+// newTestServer returns a router with the app's routes, an era owned by
+// alice, and session cookies for alice and mallory.
+func newTestServer(t *testing.T) (http.Handler, db.Era, map[string]*http.Cookie) {
+	t.Helper()
+	ctx := context.Background()
+	store := dbtest.NewStore()
+	era := db.Era{UserID: "alice", Name: "Alice's era"}
+
+	sessions := NewSessionStore()
+	cookies := make(map[string]*http.Cookie)
+	for _, user := range []string{"alice", "mallory"} {
+		if err := store.Users().EnsureExists(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		session, err := sessions.Create(ctx, nil, user, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cookies[user] = &http.Cookie{Name: sessionCookieName, Value: session.ID}
+	}
+	if err := store.Tracks().Upsert(ctx, &db.Track{ID: "t1", Name: "Secret", Artist: "Someone"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Eras().Create(ctx, &era, []string{"t1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		router: chi.NewRouter(),
+		handlers: NewHandlers(HandlerDeps{
+			Sessions:   sessions,
+			EraService: eras.New(store),
+		}),
+	}
+	s.setupRoutes(fstest.MapFS{})
+	return s.router, era, cookies
+}
+
+func TestEraEndpoints_CrossUserNotFound(t *testing.T) {
+	router, era, cookies := newTestServer(t)
+	id := era.ID.String()
+
+	tests := []struct {
+		method, path, body string
+	}{
+		{http.MethodGet, "/eras/" + id + "/tracks", ""},
+		{http.MethodPost, "/eras/" + id + "/playlist", ""},
+		{http.MethodGet, "/api/eras/" + id + "/tracks", ""},
+		{http.MethodGet, "/api/eras/" + id + "/export?format=csv", ""},
+		{http.MethodPut, "/api/eras/" + id + "/name", `{"name": "Mine now"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.AddCookie(cookies["mallory"])
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want 404", rec.Code)
+			}
+			if strings.Contains(rec.Body.String(), "Secret") {
+				t.Error("response leaks the era's tracks")
+			}
+		})
+	}
+}
+
+func TestGetEraTracksAPI_Owner(t *testing.T) {
+	router, era, cookies := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/eras/"+era.ID.String()+"/tracks", nil)
+	req.AddCookie(cookies["alice"])
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var tracks []TrackJSON
+	if err := json.Unmarshal(rec.Body.Bytes(), &tracks); err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 1 || tracks[0].Name != "Secret" {
+		t.Errorf("tracks = %+v, want the era's track", tracks)
+	}
+}
+
+// brokenEraStore is a store whose era lookups fail.
+type brokenEraStore struct {
+	*dbtest.Store
+}
+
+func (s brokenEraStore) Eras() db.EraRepository {
+	return brokenEras{s.Store.Eras()}
+}
+
+type brokenEras struct {
+	db.EraRepository
+}
+
+func (brokenEras) GetForOwner(context.Context, string, uuid.UUID) (*db.Era, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func TestPublishEra_LookupError(t *testing.T) {
+	ctx := context.Background()
+	srv := spotifytest.NewServer(t, "alice")
+	sessions := NewSessionStore()
+	session, err := sessions.Create(ctx, srv.Token(), "alice", "Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		router: chi.NewRouter(),
+		handlers: NewHandlers(HandlerDeps{
+			Sessions:       sessions,
+			EraService:     eras.New(brokenEraStore{dbtest.NewStore()}),
+			SpotifyOptions: []spotifyclient.Option{spotifyclient.WithAPIURL(srv.APIURL())},
+		}),
+	}
+	s.setupRoutes(fstest.MapFS{})
+
+	req := httptest.NewRequest(http.MethodPost, "/eras/"+uuid.NewString()+"/playlist", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session.ID})
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if playlists := srv.Playlists(); len(playlists) != 0 {
+		t.Errorf("playlists = %+v, want Spotify left alone", playlists)
+	}
+}
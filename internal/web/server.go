@@ -15,30 +15,42 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	spotifyauth "github.com/zmb3/spotify/v2/auth"
 
+	"github.com/justestif/go-spotify-era-organizer/internal/clustering"
 	"github.com/justestif/go-spotify-era-organizer/internal/db"
 	"github.com/justestif/go-spotify-era-organizer/internal/eras"
+	"github.com/justestif/go-spotify-era-organizer/internal/housekeeping"
 	"github.com/justestif/go-spotify-era-organizer/internal/lastfm"
 	syncpkg "github.com/justestif/go-spotify-era-organizer/internal/sync"
 	"github.com/justestif/go-spotify-era-organizer/internal/tags"
 )
 
-const (
-	// DefaultAddr is the default server address.
-	DefaultAddr = "127.0.0.1:8080"
-
-	// RedirectURI must match the Spotify app configuration.
-	RedirectURI = "http://127.0.0.1:8080/callback"
-)
+// Scopes are the Spotify permissions requested at login.
+var Scopes = []string{
+	spotifyauth.ScopeUserLibraryRead,
+	spotifyauth.ScopePlaylistModifyPublic,
+	spotifyauth.ScopePlaylistModifyPrivate,
+}
 
 // ServerConfig holds server configuration.
 type ServerConfig struct {
 	Addr         string
+	RedirectURI  string // Must match the Spotify app configuration
 	ClientID     string
 	ClientSecret string
 	TemplatesFS  fs.FS
 	StaticFS     fs.FS
 	DB           *db.DB // Optional - if nil, uses in-memory sessions
 	LastFMAPIKey string // Optional - if empty, tag fetching is disabled
+
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration // Time allowed for requests to finish on shutdown
+
+	SyncCooldown   time.Duration               // Minimum time between a user's syncs
+	TagConcurrency int                         // Concurrent Last.fm lookups
+	TagCacheTTL    time.Duration               // Age after which fetched tags are refreshed
+	EraDefaults    clustering.TagClusterConfig // Clustering defaults users' settings override
 }
 
 // Server is the HTTP server for the web application.
@@ -52,6 +64,8 @@ type Server struct {
 	syncService *syncpkg.Service
 	eraService  *eras.Service
 	tagService  *tags.Service
+
+	shutdownTimeout time.Duration
 }
 
 // NewServer creates a new web server.
@@ -60,12 +74,8 @@ func NewServer(cfg ServerConfig) (*Server, error) {
 	auth := spotifyauth.New(
 		spotifyauth.WithClientID(cfg.ClientID),
 		spotifyauth.WithClientSecret(cfg.ClientSecret),
-		spotifyauth.WithRedirectURL(RedirectURI),
-		spotifyauth.WithScopes(
-			spotifyauth.ScopeUserLibraryRead,
-			spotifyauth.ScopePlaylistModifyPublic,
-			spotifyauth.ScopePlaylistModifyPrivate,
-		),
+		spotifyauth.WithRedirectURL(cfg.RedirectURI),
+		spotifyauth.WithScopes(Scopes...),
 	)
 
 	// Create template manager
@@ -87,13 +97,13 @@ func NewServer(cfg ServerConfig) (*Server, error) {
 	var eraService *eras.Service
 	var tagService *tags.Service
 	if cfg.DB != nil {
-		syncService = syncpkg.New(cfg.DB)
-		eraService = eras.New(cfg.DB)
+		syncService = syncpkg.New(cfg.DB, syncpkg.WithSyncCooldown(cfg.SyncCooldown))
+		eraService = eras.New(cfg.DB, eras.WithDefaults(cfg.EraDefaults))
 
 		// Create tag service if Last.fm API key is available
 		if cfg.LastFMAPIKey != "" {
 			lastfmClient := lastfm.NewClient(&lastfm.Config{APIKey: cfg.LastFMAPIKey})
-			tagService = tags.NewService(lastfmClient)
+			tagService = tags.NewService(lastfmClient, tags.WithConcurrency(cfg.TagConcurrency))
 		}
 	}
 
@@ -106,6 +116,7 @@ func NewServer(cfg ServerConfig) (*Server, error) {
 		SyncService: syncService,
 		EraService:  eraService,
 		TagService:  tagService,
+		TagCacheTTL: cfg.TagCacheTTL,
 	})
 
 	// Create router
@@ -120,6 +131,8 @@ func NewServer(cfg ServerConfig) (*Server, error) {
 		syncService: syncService,
 		eraService:  eraService,
 		tagService:  tagService,
+
+		shutdownTimeout: cfg.ShutdownTimeout,
 	}
 
 	// Configure middleware
@@ -132,9 +145,9 @@ func NewServer(cfg ServerConfig) (*Server, error) {
 	s.server = &http.Server{
 		Addr:         cfg.Addr,
 		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
 	}
 
 	return s, nil
@@ -159,6 +172,13 @@ func (s *Server) setupRoutes(staticFS fs.FS) {
 	s.router.Get("/", s.handlers.Home)
 	s.router.Get("/eras", s.handlers.Eras)
 	s.router.Get("/eras/{id}/tracks", s.handlers.EraTracks)
+	s.router.Post("/eras/{id}/playlist", s.handlers.PublishEra)
+	s.router.Get("/settings", s.handlers.Settings)
+	s.router.Post("/settings", s.handlers.SaveSettings)
+	s.router.Post("/settings/validate", s.handlers.ValidateSettings)
+	s.router.Post("/settings/preview", s.handlers.SettingsPreview)
+	s.router.Post("/tracks/{id}/tags", s.handlers.AddTrackTag)
+	s.router.Delete("/tracks/{id}/tags", s.handlers.RemoveTrackTag)
 
 	// Auth routes
 	s.router.Get("/auth/login", s.handlers.Login)
@@ -167,10 +187,30 @@ func (s *Server) setupRoutes(staticFS fs.FS) {
 
 	// API routes
 	s.router.Post("/api/analyze", s.handlers.Analyze)
+	s.router.Post("/api/analyze/preview", s.handlers.PreviewAnalyze)
+	s.router.Post("/api/analyze/preview/{id}/apply", s.handlers.ApplyPreview)
 	s.router.Get("/api/eras", s.handlers.GetEras)
+	s.router.Get("/api/eras/export", s.handlers.ExportEras)
 	s.router.Get("/api/eras/{id}/tracks", s.handlers.GetEraTracksAPI)
+	s.router.Put("/api/eras/{id}/name", s.handlers.RenameEra)
+	s.router.Get("/api/eras/{id}/export", s.handlers.ExportEra)
 	s.router.Post("/api/sync", s.handlers.SyncLibrary)
 	s.router.Get("/api/sync/status", s.handlers.GetSyncStatus)
+	s.router.Post("/api/import/spotify", s.handlers.ImportSpotify)
+	s.router.Get("/api/backup", s.handlers.Backup)
+}
+
+// HousekeepingTasks returns the tasks that clean up the server's in-memory
+// state: stale OAuth states and, without a database, expired sessions.
+// Database-backed sessions are cleaned up by housekeeping.DBTasks.
+func (s *Server) HousekeepingTasks() []housekeeping.Task {
+	tasks := []housekeeping.Task{
+		{Name: "oauth_states", Run: s.handlers.oauthStates.DeleteExpired},
+	}
+	if store, ok := s.sessions.(*SessionStore); ok {
+		tasks = append(tasks, housekeeping.Task{Name: "memory_sessions", Run: store.DeleteExpired})
+	}
+	return tasks
 }
 
 // Start starts the HTTP server.
@@ -207,7 +247,7 @@ func (s *Server) Run() error {
 	}
 
 	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	defer cancel()
 
 	if err := s.Shutdown(ctx); err != nil {
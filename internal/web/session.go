@@ -33,7 +33,7 @@ type SessionManager interface {
 	Create(ctx context.Context, token *oauth2.Token, userID, userName string) (*Session, error)
 	Get(ctx context.Context, id string) *Session
 	Delete(ctx context.Context, id string)
-	UpdateToken(ctx context.Context, id string, token *oauth2.Token)
+	UpdateToken(ctx context.Context, id string, token *oauth2.Token) error
 	GetFromRequest(r *http.Request) *Session
 	SetCookie(w http.ResponseWriter, session *Session)
 	ClearCookie(w http.ResponseWriter)
@@ -103,14 +103,34 @@ func (s *SessionStore) Delete(_ context.Context, id string) {
 	s.mu.Unlock()
 }
 
-// UpdateToken updates the OAuth token for a session.
-func (s *SessionStore) UpdateToken(_ context.Context, id string, token *oauth2.Token) {
+// UpdateToken updates the OAuth token for a session. The session is
+// replaced rather than modified, since handlers may still be reading it.
+func (s *SessionStore) UpdateToken(_ context.Context, id string, token *oauth2.Token) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if session, ok := s.sessions[id]; ok {
-		session.Token = token
+		updated := *session
+		updated.Token = token
+		s.sessions[id] = &updated
 	}
+	return nil
+}
+
+// DeleteExpired removes expired sessions, which Get already ignores.
+// Returns the number removed.
+func (s *SessionStore) DeleteExpired(context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int64
+	for id, session := range s.sessions {
+		if time.Since(session.CreatedAt) > sessionTTL {
+			delete(s.sessions, id)
+			removed++
+		}
+	}
+	return removed, nil
 }
 
 // GetFromRequest extracts the session from the request cookie.
@@ -136,7 +156,7 @@ func (s *SessionStore) ClearCookie(w http.ResponseWriter) {
 // Database-Backed Session Store
 // ============================================================================
 
-// DBSessionStore manages user sessions in PostgreSQL.
+// DBSessionStore manages user sessions in the database.
 type DBSessionStore struct {
 	database *db.DB
 }
@@ -210,8 +230,8 @@ func (s *DBSessionStore) Delete(ctx context.Context, id string) {
 }
 
 // UpdateToken updates the OAuth token for a session in the database.
-func (s *DBSessionStore) UpdateToken(ctx context.Context, id string, token *oauth2.Token) {
-	_ = s.database.Sessions().UpdateToken(ctx, id, token.AccessToken, token.RefreshToken, token.Expiry)
+func (s *DBSessionStore) UpdateToken(ctx context.Context, id string, token *oauth2.Token) error {
+	return s.database.Sessions().UpdateToken(ctx, id, token.AccessToken, token.RefreshToken, token.Expiry)
 }
 
 // GetFromRequest extracts the session from the request cookie.
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSessionStore_DeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	store := NewSessionStore()
+	fresh, err := store.Create(ctx, nil, "alice", "Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := store.Create(ctx, nil, "bob", "Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.sessions[stale.ID].CreatedAt = time.Now().Add(-sessionTTL - time.Minute)
+
+	removed, err := store.DeleteExpired(ctx)
+	if err != nil || removed != 1 {
+		t.Fatalf("DeleteExpired() = %d, %v, want 1", removed, err)
+	}
+	if _, ok := store.sessions[stale.ID]; ok {
+		t.Error("expired session was kept")
+	}
+	if store.Get(ctx, fresh.ID) == nil {
+		t.Error("live session was removed")
+	}
+}
+
+func TestOAuthStateStore_DeleteExpired(t *testing.T) {
+	store := newOAuthStateStore()
+	store.Set("fresh")
+	store.Set("stale")
+	store.states["stale"] = time.Now().Add(-oauthStateTTL)
+
+	removed, err := store.DeleteExpired(context.Background())
+	if err != nil || removed != 1 {
+		t.Fatalf("DeleteExpired() = %d, %v, want 1", removed, err)
+	}
+	if !store.Validate("fresh") {
+		t.Error("live state was removed")
+	}
+	if store.Validate("stale") {
+		t.Error("expired state still validates")
+	}
+}
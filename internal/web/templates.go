@@ -5,6 +5,7 @@ import (
 	"html/template"
 	"io"
 	"io/fs"
+	"net/url"
 	"path/filepath"
 	"time"
 )
@@ -93,12 +94,13 @@ func (t *Templates) load(templatesFS fs.FS) error {
 		t.templates[name] = tmpl
 	}
 
-	// Load partials as standalone templates for HTMX fragments
+	// Load partials as standalone templates for HTMX fragments.
+	// Every partial is parsed with the others so partials can include each other.
 	for _, partial := range partials {
 		name := filepath.Base(partial)
 		name = name[:len(name)-len(".html")] // Remove .html extension
 
-		tmpl, err := template.New(name).Funcs(t.funcs).ParseFS(templatesFS, partial)
+		tmpl, err := template.New(name).Funcs(t.funcs).ParseFS(templatesFS, partials...)
 		if err != nil {
 			return fmt.Errorf("parsing partial %s: %w", name, err)
 		}
@@ -145,6 +147,9 @@ func defaultFuncs() template.FuncMap {
 			return template.HTML(s) //nolint:gosec // Intentional for trusted content
 		},
 
+		// queryEscape escapes a string for use as a URL query value
+		"queryEscape": url.QueryEscape,
+
 		// add adds two integers (for 1-based indexing in loops)
 		"add": func(a, b int) int {
 			return a + b
@@ -301,12 +306,104 @@ type EraData struct {
 	EndDate    time.Time
 	TrackCount int
 	PlaylistID *string
+	SubEras    []EraData
+}
+
+// Playlist returns the data for the era's era-playlist partial.
+func (e EraData) Playlist() EraPlaylistData {
+	return EraPlaylistData{EraID: e.ID, PlaylistID: e.PlaylistID}
 }
 
 // TrackData contains data for a single track in templates.
 type TrackData struct {
-	ID     string
-	Name   string
-	Artist string
-	Album  string
+	ID       string
+	Name     string
+	Artist   string
+	Album    string
+	Reason   []string // Tags that most tie the track to its era
+	UserTags *TrackTagsData
+}
+
+// EraTracksData contains data for the era-tracks partial.
+type EraTracksData struct {
+	Tracks  []TrackData
+	Metrics *EraMetricsData // Nil for eras detected before metrics were stored
+}
+
+// EraPlaylistData contains data for the era-playlist partial.
+type EraPlaylistData struct {
+	EraID      string
+	PlaylistID *string // Set once the era is published
+	Error      string  // Why publishing failed
+}
+
+// EraMetricsData describes how well an era's tracks fit together.
+type EraMetricsData struct {
+	Cohesion   float64 // Mean distance to the centroid; lower is tighter
+	Silhouette float64 // -1 to 1; higher is better separated from other eras
+	TagWeights []TagWeightData
+}
+
+// TagWeightData is a bar in an era's tag-weight breakdown.
+type TagWeightData struct {
+	Name    string
+	Weight  float64
+	Percent int // Bar width relative to the heaviest tag
+}
+
+// TrackTagsData contains a track's manual tags for the track-tags partial.
+type TrackTagsData struct {
+	TrackID string
+	Tags    []string
+	Error   string // Validation error from the last edit, if any
+}
+
+// EraPreviewData contains data for the era-preview partial.
+type EraPreviewData struct {
+	PreviewID    string    // Empty if the preview failed
+	Current      []EraData // The user's saved eras
+	Preview      []EraData // Unsaved eras; ID and PlaylistID are unset
+	OutlierCount int
+	TotalTracks  int
+	Basis        string
+	Error        string
+}
+
+// SettingsPageData contains data for the settings page template.
+type SettingsPageData struct {
+	PageData
+	Form *SettingsFormData
+}
+
+// SettingsFormData contains data for the settings-form partial.
+type SettingsFormData struct {
+	Fields  []SettingsFieldData
+	Saved   bool // Settings were just saved
+	Invalid bool // The last submit failed validation
+}
+
+// SettingsFieldData describes one settings form field.
+type SettingsFieldData struct {
+	Name     string
+	Label    string
+	Help     string
+	Value    string // Submitted or stored value; empty uses the default
+	Default  string // Shown as a placeholder
+	Min, Max string
+	Step     string
+	Options  []SettingsOptionData // Set for select fields
+	Text     bool                 // Free-text field; Max is the maximum length
+	Error    SettingsFieldErrorData
+}
+
+// SettingsOptionData is an option in a settings select field.
+type SettingsOptionData struct {
+	Value string
+	Label string
+}
+
+// SettingsFieldErrorData contains data for the settings-field-error partial.
+type SettingsFieldErrorData struct {
+	Field   string
+	Message string
 }
@@ -0,0 +1,2 @@
+-- Drop user_track_tags table
+DROP TABLE IF EXISTS user_track_tags;
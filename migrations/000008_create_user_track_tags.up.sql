@@ -0,0 +1,11 @@
+-- Create user_track_tags table for tags assigned manually by users
+CREATE TABLE IF NOT EXISTS user_track_tags (
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    track_id        TEXT NOT NULL REFERENCES tracks(id) ON DELETE CASCADE,
+    tag_name        TEXT NOT NULL,                          -- Normalized (lowercase) tag name
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, track_id, tag_name)
+);
+
+-- The primary key's leading (user_id, track_id) columns serve fetching a
+-- user's tags for a set of tracks
@@ -0,0 +1,2 @@
+-- Drop artists table
+DROP TABLE IF EXISTS artists;
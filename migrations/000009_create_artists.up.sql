@@ -0,0 +1,6 @@
+-- Create artists table for Spotify artist metadata
+CREATE TABLE IF NOT EXISTS artists (
+    id              TEXT PRIMARY KEY,                       -- Spotify artist ID
+    name            TEXT NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
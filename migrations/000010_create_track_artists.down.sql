@@ -0,0 +1,2 @@
+-- Drop track_artists table
+DROP TABLE IF EXISTS track_artists;
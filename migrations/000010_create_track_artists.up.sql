@@ -0,0 +1,10 @@
+-- Create track_artists junction table for credited artists
+CREATE TABLE IF NOT EXISTS track_artists (
+    track_id        TEXT NOT NULL REFERENCES tracks(id) ON DELETE CASCADE,
+    artist_id       TEXT NOT NULL REFERENCES artists(id) ON DELETE CASCADE,
+    position        INTEGER NOT NULL,                       -- Credit order (0 = primary artist)
+    PRIMARY KEY (track_id, artist_id)
+);
+
+-- Index for per-artist lookups
+CREATE INDEX idx_track_artists_artist ON track_artists(artist_id);
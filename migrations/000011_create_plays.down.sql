@@ -0,0 +1,2 @@
+-- Drop plays table
+DROP TABLE IF EXISTS plays;
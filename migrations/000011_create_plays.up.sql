@@ -0,0 +1,19 @@
+-- Create plays table for listening history (streaming history and scrobbles)
+CREATE TABLE IF NOT EXISTS plays (
+    id              BIGSERIAL PRIMARY KEY,
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    track_id        TEXT REFERENCES tracks(id) ON DELETE SET NULL, -- NULL until matched to a known track
+    artist_name     TEXT NOT NULL,                          -- As reported by the source
+    track_name      TEXT NOT NULL,
+    played_at       TIMESTAMPTZ NOT NULL,                   -- When playback ended
+    ms_played       INTEGER NOT NULL DEFAULT 0,             -- 0 if the source doesn't report it
+    source          TEXT NOT NULL,                          -- 'spotify' or 'lastfm'
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, played_at, artist_name, track_name)    -- Re-imports are idempotent
+);
+
+-- Index for loading a user's plays by time
+CREATE INDEX idx_plays_user_played_at ON plays(user_id, played_at);
+
+-- Index for counting plays per track
+CREATE INDEX idx_plays_user_track ON plays(user_id, track_id) WHERE track_id IS NOT NULL;
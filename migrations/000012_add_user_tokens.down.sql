@@ -0,0 +1,5 @@
+-- Remove stored OAuth tokens from users
+ALTER TABLE users
+    DROP COLUMN IF EXISTS access_token,
+    DROP COLUMN IF EXISTS refresh_token,
+    DROP COLUMN IF EXISTS token_expiry;
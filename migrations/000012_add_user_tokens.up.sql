@@ -0,0 +1,5 @@
+-- Store Spotify OAuth tokens on users for headless CLI access
+ALTER TABLE users
+    ADD COLUMN access_token  TEXT,                          -- Spotify OAuth access token
+    ADD COLUMN refresh_token TEXT,                          -- Spotify OAuth refresh token
+    ADD COLUMN token_expiry  TIMESTAMPTZ;                   -- When access token expires
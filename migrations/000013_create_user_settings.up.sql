@@ -0,0 +1,18 @@
+-- Create user_settings table for per-user analysis configuration.
+-- NULL columns fall back to the built-in defaults.
+CREATE TABLE IF NOT EXISTS user_settings (
+    user_id           TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    num_clusters      INT,                                  -- Eras to detect
+    min_cluster_size  INT,                                  -- Minimum tracks per era
+    max_tags          INT,                                  -- Tags used in clustering vectors
+    manual_tag_weight DOUBLE PRECISION,                     -- Weight of user-assigned tags
+    basis             TEXT,                                 -- 'likes' or 'plays'; NULL picks automatically
+    time_weight       DOUBLE PRECISION,                     -- Weight of play time (plays basis)
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER update_user_settings_updated_at
+    BEFORE UPDATE ON user_settings
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
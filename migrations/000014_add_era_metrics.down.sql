@@ -0,0 +1,7 @@
+-- Remove era quality metrics
+ALTER TABLE era_tracks DROP COLUMN IF EXISTS reason_tags;
+
+ALTER TABLE eras
+    DROP COLUMN IF EXISTS tag_weights,
+    DROP COLUMN IF EXISTS silhouette,
+    DROP COLUMN IF EXISTS cohesion;
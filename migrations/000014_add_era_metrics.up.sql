@@ -0,0 +1,10 @@
+-- Store era quality metrics and the tags explaining each track's membership.
+-- Columns are nullable because eras detected before this migration, and
+-- eras restored from older backups, have no metrics.
+ALTER TABLE eras
+    ADD COLUMN cohesion    DOUBLE PRECISION,  -- Mean distance from tracks to the centroid
+    ADD COLUMN silhouette  DOUBLE PRECISION,  -- Mean simplified silhouette, -1 to 1
+    ADD COLUMN tag_weights JSONB;             -- Centroid tag weights: [{"name", "weight"}], heaviest first
+
+ALTER TABLE era_tracks
+    ADD COLUMN reason_tags TEXT[] NOT NULL DEFAULT '{}';  -- Tags that most tie the track to the era
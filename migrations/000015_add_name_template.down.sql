@@ -0,0 +1,2 @@
+-- Remove era name templates
+ALTER TABLE user_settings DROP COLUMN IF EXISTS name_template;
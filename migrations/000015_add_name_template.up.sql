@@ -0,0 +1,3 @@
+-- Store each user's era name template. NULL uses the built-in default.
+ALTER TABLE user_settings
+    ADD COLUMN name_template TEXT;  -- e.g. '{season} {year} – {tag1}'
@@ -0,0 +1,6 @@
+-- Remove sub-eras
+ALTER TABLE user_settings DROP COLUMN IF EXISTS sub_era_depth;
+
+DELETE FROM eras WHERE parent_id IS NOT NULL;
+DROP INDEX IF EXISTS idx_eras_parent_id;
+ALTER TABLE eras DROP COLUMN IF EXISTS parent_id;
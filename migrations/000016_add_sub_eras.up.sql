@@ -0,0 +1,9 @@
+-- Sub-eras: eras split out of a parent era by clustering its tracks again.
+-- Deleting an era deletes its sub-eras.
+ALTER TABLE eras
+    ADD COLUMN parent_id UUID REFERENCES eras(id) ON DELETE CASCADE;  -- NULL for top-level eras
+
+CREATE INDEX IF NOT EXISTS idx_eras_parent_id ON eras(parent_id);
+
+ALTER TABLE user_settings
+    ADD COLUMN sub_era_depth INT;  -- Levels of sub-eras to detect; NULL for none
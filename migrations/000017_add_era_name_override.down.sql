@@ -0,0 +1,2 @@
+-- Remove era name overrides
+ALTER TABLE eras DROP COLUMN IF EXISTS name_override;
@@ -0,0 +1,4 @@
+-- A name the user gave an era. It replaces the generated name and is kept
+-- when re-analysis matches the era with a new one.
+ALTER TABLE eras
+    ADD COLUMN name_override TEXT;  -- NULL to use the generated name
@@ -0,0 +1,2 @@
+-- Remove per-user sync schedules
+ALTER TABLE user_settings DROP COLUMN IF EXISTS sync_interval_hours;
@@ -0,0 +1,3 @@
+-- Per-user schedule for background syncs run by the scheduler
+ALTER TABLE user_settings
+    ADD COLUMN sync_interval_hours INT;  -- Hours between syncs; NULL for the server default, 0 for none
@@ -0,0 +1,2 @@
+-- Drop housekeeping run records
+DROP TABLE IF EXISTS housekeeping_runs;
@@ -0,0 +1,12 @@
+-- Record of each housekeeping task run: what it cleaned up and any error
+CREATE TABLE IF NOT EXISTS housekeeping_runs (
+    id              BIGSERIAL PRIMARY KEY,
+    task            TEXT NOT NULL,                          -- e.g. 'expired_sessions', 'orphan_tracks'
+    removed         BIGINT NOT NULL DEFAULT 0,              -- Rows or entries removed
+    error           TEXT,                                   -- NULL if the task succeeded
+    started_at      TIMESTAMPTZ NOT NULL,
+    finished_at     TIMESTAMPTZ NOT NULL
+);
+
+-- Index for listing recent runs
+CREATE INDEX idx_housekeeping_runs_started_at ON housekeeping_runs(started_at DESC);
@@ -0,0 +1,2 @@
+-- Remove failed background sync times
+ALTER TABLE users DROP COLUMN IF EXISTS sync_failed_at;
@@ -0,0 +1,3 @@
+-- Record failed background syncs so the scheduler backs off
+ALTER TABLE users
+    ADD COLUMN sync_failed_at TIMESTAMPTZ;                  -- Last failed scheduled sync
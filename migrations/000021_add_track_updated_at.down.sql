@@ -0,0 +1,2 @@
+-- Remove track upsert times
+ALTER TABLE tracks DROP COLUMN IF EXISTS updated_at;
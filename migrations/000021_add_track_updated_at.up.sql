@@ -0,0 +1,6 @@
+-- Track when a track was last upserted, so housekeeping keeps an orphan a
+-- running sync is about to link again
+ALTER TABLE tracks
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(); -- Last upserted
+
+UPDATE tracks SET updated_at = created_at;
@@ -0,0 +1,30 @@
+// Package migrations embeds the schema migrations, so the binary can apply
+// them without the migrate CLI. The PostgreSQL migrations are in this
+// directory, the SQLite ones in sqlite/.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// FS contains the numbered up and down PostgreSQL migrations.
+//
+//go:embed *.sql
+var FS embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFS embed.FS
+
+// SQLite contains the numbered up and down SQLite migrations. They number
+// independently of the PostgreSQL ones.
+var SQLite = mustSub(sqliteFS, "sqlite")
+
+// mustSub returns the subdirectory dir of fsys.
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
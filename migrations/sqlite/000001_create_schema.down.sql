@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS housekeeping_runs;
+DROP TABLE IF EXISTS user_settings;
+DROP TABLE IF EXISTS plays;
+DROP TABLE IF EXISTS track_artists;
+DROP TABLE IF EXISTS artists;
+DROP TABLE IF EXISTS user_track_tags;
+DROP TABLE IF EXISTS era_tracks;
+DROP TABLE IF EXISTS eras;
+DROP TABLE IF EXISTS track_tags;
+DROP TABLE IF EXISTS user_tracks;
+DROP TABLE IF EXISTS tracks;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,156 @@
+-- SQLite schema, equivalent to the PostgreSQL migrations 000001 to 000019.
+-- Times are stored as UTC text, which the driver parses back into times for
+-- columns declared TIMESTAMP. TEXT[] columns are JSON arrays, JSONB is JSON
+-- text and UUIDs are text.
+
+CREATE TABLE IF NOT EXISTS users (
+    id              TEXT PRIMARY KEY,                       -- Spotify user ID
+    display_name    TEXT,
+    email           TEXT,
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_sync_at    TIMESTAMP,                              -- Last Spotify library sync
+    access_token    TEXT,                                   -- Spotify OAuth access token
+    refresh_token   TEXT,                                   -- Spotify OAuth refresh token
+    token_expiry    TIMESTAMP                               -- When access token expires
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id              TEXT PRIMARY KEY,                       -- Random 64-char hex session ID
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    access_token    TEXT NOT NULL,
+    refresh_token   TEXT NOT NULL,
+    token_expiry    TIMESTAMP NOT NULL,
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at      TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_sessions_user ON sessions(user_id);
+CREATE INDEX idx_sessions_expires ON sessions(expires_at);
+
+CREATE TABLE IF NOT EXISTS tracks (
+    id              TEXT PRIMARY KEY,                       -- Spotify track ID
+    name            TEXT NOT NULL,
+    artist          TEXT NOT NULL,                          -- Comma-separated artist names
+    album           TEXT,
+    album_id        TEXT,
+    duration_ms     INTEGER,
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_tracks (
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    track_id        TEXT NOT NULL REFERENCES tracks(id) ON DELETE CASCADE,
+    added_at        TIMESTAMP NOT NULL,                     -- When user liked the track
+    PRIMARY KEY (user_id, track_id)
+);
+
+CREATE INDEX idx_user_tracks_added ON user_tracks(user_id, added_at DESC);
+CREATE INDEX idx_user_tracks_track ON user_tracks(track_id);
+
+CREATE TABLE IF NOT EXISTS track_tags (
+    track_id        TEXT NOT NULL REFERENCES tracks(id) ON DELETE CASCADE,
+    tag_name        TEXT NOT NULL,
+    tag_count       INTEGER NOT NULL,                       -- Last.fm popularity count
+    source          TEXT NOT NULL CHECK (source IN ('track', 'artist')),
+    fetched_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (track_id, tag_name)
+);
+
+CREATE INDEX idx_track_tags_fetched ON track_tags(fetched_at);
+
+CREATE TABLE IF NOT EXISTS eras (
+    id              TEXT PRIMARY KEY,                       -- UUID
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    top_tags        TEXT NOT NULL DEFAULT '[]',             -- JSON array of the top 3 tags
+    start_date      TIMESTAMP NOT NULL,
+    end_date        TIMESTAMP NOT NULL,
+    playlist_id     TEXT,                                   -- Spotify playlist ID (if created)
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    cohesion        REAL,                                   -- Mean distance from tracks to the centroid
+    silhouette      REAL,                                   -- Mean simplified silhouette, -1 to 1
+    tag_weights     TEXT,                                   -- JSON: [{"name", "weight"}], heaviest first
+    parent_id       TEXT REFERENCES eras(id) ON DELETE CASCADE, -- NULL for top-level eras
+    name_override   TEXT                                    -- NULL to use the generated name
+);
+
+CREATE INDEX idx_eras_user ON eras(user_id);
+CREATE INDEX idx_eras_playlist ON eras(playlist_id) WHERE playlist_id IS NOT NULL;
+CREATE INDEX idx_eras_parent_id ON eras(parent_id);
+
+CREATE TABLE IF NOT EXISTS era_tracks (
+    era_id          TEXT NOT NULL REFERENCES eras(id) ON DELETE CASCADE,
+    track_id        TEXT NOT NULL REFERENCES tracks(id) ON DELETE CASCADE,
+    reason_tags     TEXT NOT NULL DEFAULT '[]',             -- JSON array of the tags tying the track to the era
+    PRIMARY KEY (era_id, track_id)
+);
+
+CREATE INDEX idx_era_tracks_track ON era_tracks(track_id);
+
+CREATE TABLE IF NOT EXISTS user_track_tags (
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    track_id        TEXT NOT NULL REFERENCES tracks(id) ON DELETE CASCADE,
+    tag_name        TEXT NOT NULL,                          -- Normalized (lowercase) tag name
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, track_id, tag_name)
+);
+
+CREATE INDEX idx_user_track_tags_track ON user_track_tags(track_id);
+
+CREATE TABLE IF NOT EXISTS artists (
+    id              TEXT PRIMARY KEY,                       -- Spotify artist ID
+    name            TEXT NOT NULL,
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS track_artists (
+    track_id        TEXT NOT NULL REFERENCES tracks(id) ON DELETE CASCADE,
+    artist_id       TEXT NOT NULL REFERENCES artists(id) ON DELETE CASCADE,
+    position        INTEGER NOT NULL,                       -- Credit order (0 = primary artist)
+    PRIMARY KEY (track_id, artist_id)
+);
+
+CREATE INDEX idx_track_artists_artist ON track_artists(artist_id);
+
+CREATE TABLE IF NOT EXISTS plays (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    track_id        TEXT REFERENCES tracks(id) ON DELETE SET NULL, -- NULL until matched to a known track
+    artist_name     TEXT NOT NULL,
+    track_name      TEXT NOT NULL,
+    played_at       TIMESTAMP NOT NULL,                     -- When playback ended
+    ms_played       INTEGER NOT NULL DEFAULT 0,
+    source          TEXT NOT NULL,                          -- 'spotify' or 'lastfm'
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, played_at, artist_name, track_name)
+);
+
+CREATE INDEX idx_plays_user_track ON plays(user_id, track_id) WHERE track_id IS NOT NULL;
+CREATE INDEX idx_plays_track ON plays(track_id);
+
+CREATE TABLE IF NOT EXISTS user_settings (
+    user_id             TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    num_clusters        INTEGER,
+    min_cluster_size    INTEGER,
+    max_tags            INTEGER,
+    manual_tag_weight   REAL,
+    basis               TEXT,                               -- 'likes' or 'plays'; NULL picks automatically
+    time_weight         REAL,
+    name_template       TEXT,
+    sub_era_depth       INTEGER,
+    sync_interval_hours INTEGER,                            -- NULL for the server default, 0 for none
+    created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS housekeeping_runs (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    task            TEXT NOT NULL,
+    removed         INTEGER NOT NULL DEFAULT 0,
+    error           TEXT,                                   -- NULL if the task succeeded
+    started_at      TIMESTAMP NOT NULL,
+    finished_at     TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_housekeeping_runs_started_at ON housekeeping_runs(started_at DESC);
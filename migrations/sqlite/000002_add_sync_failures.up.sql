@@ -0,0 +1,3 @@
+-- Record failed background syncs so the scheduler backs off, as PostgreSQL
+-- migration 000020 does
+ALTER TABLE users ADD COLUMN sync_failed_at TIMESTAMP;      -- Last failed scheduled sync
@@ -0,0 +1,2 @@
+-- Remove track upsert times
+ALTER TABLE tracks DROP COLUMN updated_at;
@@ -0,0 +1,8 @@
+-- Track when a track was last upserted, as PostgreSQL migration 000021 does.
+-- SQLite can't add a column with a non-constant default, so inserts set it
+-- and the orphan query falls back to created_at where it is NULL. Rebuilding
+-- the table for NOT NULL would cascade-delete every row referring to a track,
+-- as foreign keys can't be turned off inside the migration's transaction.
+ALTER TABLE tracks ADD COLUMN updated_at TIMESTAMP;         -- Last upserted
+
+UPDATE tracks SET updated_at = created_at;